@@ -0,0 +1,229 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+	// Media query of MobileAlternate links unless MobileMedia is set
+	defaultMobileMedia = "only screen and (max-width: 640px)"
+)
+
+// Alternate is a localized version of a page, written as an xhtml:link
+// element with rel="alternate", or with Media set, a version for the
+// devices matching that media query, such as a separate mobile page.
+type Alternate struct {
+	XMLName  xml.Name `xml:"xhtml:link"`
+	Rel      string   `xml:"rel,attr"`
+	Hreflang string   `xml:"hreflang,attr,omitempty"`
+	Media    string   `xml:"media,attr,omitempty"`
+	Href     string   `xml:"href,attr"`
+}
+
+// hreflangPattern matches language, optional script and optional region
+// codes such as "en", "en-GB", "zh-Hant-TW" or "es-419", and "x-default".
+var hreflangPattern = regexp.MustCompile(`^(?i:x-default|[a-z]{2,3}(-[a-z]{4})?(-([a-z]{2}|[0-9]{3}))?)$`)
+
+// resolveAlternates returns a copy of alternates of a URL of the given group
+// with absolute, canonical hrefs and rel defaulted, leaving the caller's
+// slice untouched.
+func (s *SitemapOptions) resolveAlternates(group string, alternates []Alternate) ([]Alternate, error) {
+	if len(alternates) == 0 {
+		return nil, nil
+	}
+	resolved := make([]Alternate, len(alternates))
+	for i, alt := range alternates {
+		href, err := s.resolveGroupURL(group, alt.Href)
+		if err != nil {
+			return nil, err
+		}
+		if href, err = s.canonicalLoc(group, href); err != nil {
+			return nil, err
+		}
+		alt.Href = href
+		if alt.Rel == "" {
+			alt.Rel = "alternate"
+		}
+		resolved[i] = alt
+	}
+	return resolved, nil
+}
+
+// urlAlternates returns the resolved alternates of u, with the x-default
+// of XDefaultLocale, followed by its MobileAlternate, unless already among
+// them.
+func (s *SitemapOptions) urlAlternates(u SitemapURL) ([]Alternate, error) {
+	alternates, err := s.resolveAlternates(u.Group, u.Alternates)
+	if err != nil {
+		return nil, err
+	}
+	alternates = s.addXDefault(alternates)
+	if strings.TrimSpace(u.MobileAlternate) == "" {
+		return alternates, nil
+	}
+	href, err := s.resolveGroupURL(u.Group, strings.TrimSpace(u.MobileAlternate))
+	if err != nil {
+		return nil, err
+	}
+	if href, err = s.canonicalLoc(u.Group, href); err != nil {
+		return nil, err
+	}
+	for _, alt := range alternates {
+		if alt.Media != "" && alt.Href == href {
+			return alternates, nil
+		}
+	}
+	media := s.MobileMedia
+	if media == "" {
+		media = defaultMobileMedia
+	}
+	return append(alternates, Alternate{Rel: "alternate", Media: media, Href: href}), nil
+}
+
+// addXDefault appends an x-default alternate for XDefaultLocale to the
+// hreflang alternates lacking one. The alternate of the locale itself is
+// preferred over the first one sharing its language.
+func (s *SitemapOptions) addXDefault(alternates []Alternate) []Alternate {
+	if s.XDefaultLocale == "" {
+		return alternates
+	}
+	language, _, _ := strings.Cut(s.XDefaultLocale, "-")
+	var exact, sameLanguage *Alternate
+	for i, alt := range alternates {
+		if alt.Media != "" || alt.Hreflang == "" {
+			continue
+		}
+		if strings.EqualFold(alt.Hreflang, "x-default") {
+			return alternates
+		}
+		if exact == nil && strings.EqualFold(alt.Hreflang, s.XDefaultLocale) {
+			exact = &alternates[i]
+		}
+		if altLanguage, _, _ := strings.Cut(alt.Hreflang, "-"); sameLanguage == nil && strings.EqualFold(altLanguage, language) {
+			sameLanguage = &alternates[i]
+		}
+	}
+	target := exact
+	if target == nil {
+		target = sameLanguage
+	}
+	if target == nil {
+		return alternates
+	}
+	return append(alternates, Alternate{Rel: "alternate", Hreflang: "x-default", Href: target.Href})
+}
+
+// CheckHreflang validates the alternate clusters of urls: every URL with
+// alternates must reference itself and an x-default, use valid and unique
+// hreflang codes, and every alternate that is part of urls must declare the
+// same cluster back. Alternates with a Media query are not part of the
+// cluster.
+func CheckHreflang(urls []SitemapURL) []Issue {
+	clusters := make(map[string]map[string]string, len(urls))
+	for _, u := range urls {
+		if set := alternateSet(u.Alternates); len(set) > 0 {
+			clusters[u.Loc] = set
+		}
+	}
+
+	var issues []Issue
+	report := func(loc, format string, args ...any) {
+		issues = append(issues, Issue{Loc: loc, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	for _, u := range urls {
+		if _, ok := clusters[u.Loc]; !ok {
+			continue
+		}
+		seen := make(map[string]string)
+		self, xDefault := false, false
+		for _, alt := range u.Alternates {
+			if alt.Media != "" {
+				continue
+			}
+			lang := strings.ToLower(alt.Hreflang)
+			if !hreflangPattern.MatchString(lang) {
+				report(u.Loc, "invalid hreflang %q", alt.Hreflang)
+			}
+			if previous, ok := seen[lang]; ok && previous != alt.Href {
+				report(u.Loc, "hreflang %q points to both %s and %s", alt.Hreflang, previous, alt.Href)
+			}
+			seen[lang] = alt.Href
+			if alt.Href == u.Loc {
+				self = true
+			}
+			if lang == "x-default" {
+				xDefault = true
+			}
+		}
+		if !self {
+			report(u.Loc, "missing self-referencing alternate")
+		}
+		if !xDefault {
+			report(u.Loc, "missing x-default alternate")
+		}
+
+		// Alternates present in the sitemap must declare the same cluster
+		cluster := clusters[u.Loc]
+		targets := make([]string, 0, len(cluster))
+		for href := range cluster {
+			targets = append(targets, href)
+		}
+		sort.Strings(targets)
+		for _, href := range targets {
+			if href == u.Loc {
+				continue
+			}
+			other, ok := clusters[href]
+			if !ok {
+				if containsLoc(urls, href) {
+					report(u.Loc, "alternate %s does not link back", href)
+				}
+				continue
+			}
+			if _, ok := other[u.Loc]; !ok {
+				report(u.Loc, "alternate %s does not link back", href)
+			} else if !sameCluster(cluster, other) {
+				report(u.Loc, "alternate %s declares a different cluster", href)
+			}
+		}
+	}
+	return issues
+}
+
+// alternateSet maps each alternate href to its lowercased hreflang.
+func alternateSet(alternates []Alternate) map[string]string {
+	set := make(map[string]string, len(alternates))
+	for _, alt := range alternates {
+		if alt.Media == "" {
+			set[alt.Href] = strings.ToLower(alt.Hreflang)
+		}
+	}
+	return set
+}
+
+func sameCluster(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for href, lang := range a {
+		if b[href] != lang {
+			return false
+		}
+	}
+	return true
+}
+
+func containsLoc(urls []SitemapURL, loc string) bool {
+	for _, u := range urls {
+		if u.Loc == loc {
+			return true
+		}
+	}
+	return false
+}
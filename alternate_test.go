@@ -0,0 +1,170 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAlternatesAreWritten(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSitemapOptions(dir, "https://example.com")
+	s.AddURL(SitemapURL{Loc: "/en", Alternates: []Alternate{
+		{Hreflang: "en", Href: "/en"},
+		{Hreflang: "de", Href: "https://example.com/de"},
+	}})
+	if err := s.Write("https://example.com/sitemaps/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`xmlns:xhtml="http://www.w3.org/1999/xhtml"`,
+		`<xhtml:link rel="alternate" hreflang="en" href="https://example.com/en"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="de" href="https://example.com/de"></xhtml:link>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("sitemap missing %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestCheckHreflang(t *testing.T) {
+	cluster := []Alternate{
+		{Hreflang: "en", Href: "https://example.com/en"},
+		{Hreflang: "de", Href: "https://example.com/de"},
+		{Hreflang: "x-default", Href: "https://example.com/en"},
+	}
+	good := []SitemapURL{
+		{Loc: "https://example.com/en", Alternates: cluster},
+		{Loc: "https://example.com/de", Alternates: cluster},
+	}
+	if issues := CheckHreflang(good); len(issues) != 0 {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+
+	broken := []SitemapURL{
+		{Loc: "https://example.com/en", Alternates: cluster},
+		// No link back to /en, no x-default
+		{Loc: "https://example.com/de", Alternates: []Alternate{
+			{Hreflang: "de", Href: "https://example.com/de"},
+		}},
+		{Loc: "https://example.com/fr", Alternates: []Alternate{
+			{Hreflang: "french", Href: "https://example.com/fr"},
+			{Hreflang: "x-default", Href: "https://example.com/en"},
+		}},
+	}
+	var problems []string
+	for _, issue := range CheckHreflang(broken) {
+		problems = append(problems, issue.Loc+": "+issue.Problem)
+	}
+	got := strings.Join(problems, "\n")
+	for _, want := range []string{
+		"https://example.com/en: alternate https://example.com/de does not link back",
+		"https://example.com/de: missing x-default alternate",
+		`https://example.com/fr: invalid hreflang "french"`,
+		"https://example.com/fr: alternate https://example.com/en does not link back",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing issue %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestValidateHreflangReport(t *testing.T) {
+	s := NewSitemapOptions(t.TempDir(), "https://example.com")
+	s.ValidateHreflang = true
+	s.AddURL(SitemapURL{Loc: "/en", Alternates: []Alternate{{Hreflang: "en", Href: "/en"}}})
+	if err := s.Write("https://example.com/sitemaps/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	issues := s.Report().HreflangIssues
+	if len(issues) != 1 || issues[0].Problem != "missing x-default alternate" {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+}
+
+func TestMobileAlternate(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ValidateHreflang = true
+	sm.AddURL(SitemapURL{Loc: "/page", MobileAlternate: "https://m.example.com/page"})
+	sm.AddURL(SitemapURL{Loc: "/other", MobileAlternate: "https://m.example.com/other", Alternates: []Alternate{
+		{Hreflang: "en", Href: "/other"},
+		{Hreflang: "x-default", Href: "/other"},
+	}})
+	for range 2 {
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Error writing sitemap: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<xhtml:link rel="alternate" media="only screen and (max-width: 640px)" href="https://m.example.com/page"></xhtml:link>`
+	if !strings.Contains(string(data), want) {
+		t.Fatalf("Expected %s in:\n%s", want, data)
+	}
+	if n := strings.Count(string(data), `media="`); n != 2 {
+		t.Fatalf("Expected one mobile alternate per URL, got %d:\n%s", n, data)
+	}
+	if issues := sm.Report().HreflangIssues; len(issues) != 0 {
+		t.Fatalf("Expected mobile alternates to be left out of hreflang clusters, got %v", issues)
+	}
+}
+
+func TestXDefaultLocale(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSitemapOptions(dir, "https://example.com")
+	s.ValidateHreflang = true
+	s.XDefaultLocale = "en"
+	s.AddURL(SitemapURL{Loc: "/de", Alternates: []Alternate{
+		{Hreflang: "de", Href: "/de"},
+		{Hreflang: "en-US", Href: "/us"},
+		{Hreflang: "en", Href: "/en"},
+	}})
+	s.AddURL(SitemapURL{Loc: "/fr/about", Alternates: []Alternate{
+		{Hreflang: "fr", Href: "/fr/about"},
+		{Hreflang: "en-GB", Href: "/uk/about"},
+	}})
+	s.AddURL(SitemapURL{Loc: "/nl", Alternates: []Alternate{
+		{Hreflang: "nl", Href: "/nl"},
+		{Hreflang: "x-default", Href: "/nl"},
+	}})
+	s.AddURL(SitemapURL{Loc: "/ja", Alternates: []Alternate{{Hreflang: "ja", Href: "/ja"}}})
+	if err := s.Write("https://example.com/sitemaps/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<xhtml:link rel="alternate" hreflang="x-default" href="https://example.com/en"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="x-default" href="https://example.com/uk/about"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="x-default" href="https://example.com/nl"></xhtml:link>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("sitemap missing %s:\n%s", want, data)
+		}
+	}
+	if n := strings.Count(string(data), `hreflang="x-default"`); n != 3 {
+		t.Fatalf("Expected 3 x-default alternates, got %d:\n%s", n, data)
+	}
+
+	// Only the cluster without an English version still lacks one
+	var missing []string
+	for _, issue := range s.Report().HreflangIssues {
+		if issue.Problem == "missing x-default alternate" {
+			missing = append(missing, issue.Loc)
+		}
+	}
+	if len(missing) != 1 || missing[0] != "https://example.com/ja" {
+		t.Fatalf("Expected only /ja to miss an x-default, got %v", missing)
+	}
+}
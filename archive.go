@@ -0,0 +1,236 @@
+package sitemap
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// archiveLayout names the archived sets, so they sort by age.
+const archiveLayout = "20060102T150405Z"
+
+// archiveDirPath returns the path of ArchiveDir, resolving relative paths
+// against Dir, or "" if archiving is disabled.
+func (s *SitemapOptions) archiveDirPath() string {
+	if s.ArchiveDir == "" || filepath.IsAbs(s.ArchiveDir) {
+		return s.ArchiveDir
+	}
+	return filepath.Join(s.Dir, s.ArchiveDir)
+}
+
+// archiveSet copies the sitemap set in place, and the state file if any, to
+// a new directory of ArchiveDir named by the start of the running Write.
+// Files are hard linked where possible; they stay valid because Write
+// replaces files by renaming new ones over them. If the Write fails, the
+// archived copy is removed with the other files it created.
+func (s *SitemapOptions) archiveSet() error {
+	archiveDir := s.archiveDirPath()
+	if archiveDir == "" {
+		return nil
+	}
+	files, err := s.setFiles()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	name := s.generated.UTC().Format(archiveLayout)
+	target := filepath.Join(archiveDir, name)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return err
+		}
+		target = filepath.Join(archiveDir, fmt.Sprintf("%s-%d", name, n))
+	}
+
+	if statePath := s.stateFilePath(); statePath != "" {
+		if _, err := os.Stat(statePath); err == nil {
+			files = append(files, statePath)
+		}
+	}
+	for _, filePath := range files {
+		rel, err := filepath.Rel(s.Dir, filePath)
+		if err != nil || filePath == s.stateFilePath() {
+			rel = filepath.Base(filePath)
+		}
+		if err := s.archiveFile(filePath, filepath.Join(target, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback restores the most recently archived sitemap set of ArchiveDir
+// into Dir and ShardDir, along with its state file, for fast recovery from
+// a bad generation. Files of the current set missing from the archive are
+// removed and ManifestFile is rewritten. The archive is consumed, so a
+// repeated Rollback goes back one more set. Failures restore the current
+// files as for Write, and AfterWrite is called as after a Write.
+func (s *SitemapOptions) Rollback(baseSitemapURL string) error {
+	if _, err := parseBaseURL(baseSitemapURL); err != nil {
+		return fmt.Errorf("invalid base sitemap URL: %w", err)
+	}
+	unlock, err := lockDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if s.archiveDirPath() == "" {
+		return fmt.Errorf("rollback requires ArchiveDir")
+	}
+	names, err := s.archives()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no archived sitemap set in %s", s.archiveDirPath())
+	}
+	source := filepath.Join(s.archiveDirPath(), names[len(names)-1])
+
+	// Map each archived file to the path it is restored to
+	statePath := s.stateFilePath()
+	restore := make(map[string]string)
+	var stateSource string
+	err = filepath.WalkDir(source, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(source, filePath)
+		if err != nil {
+			return err
+		}
+		if statePath != "" && rel == filepath.Base(statePath) {
+			stateSource = filePath
+			return nil
+		}
+		restore[filepath.Join(s.Dir, rel)] = filePath
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	current, err := s.setFiles()
+	if err != nil {
+		return err
+	}
+
+	tx := &writeTx{}
+	err = s.runTx(tx, func() error {
+		for _, filePath := range current {
+			if _, ok := restore[filePath]; !ok {
+				if err := s.tx.removeFile(filePath); err != nil {
+					return err
+				}
+			}
+		}
+		targets := make([]string, 0, len(restore))
+		for target := range restore {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			data, err := os.ReadFile(restore[target])
+			if err != nil {
+				return err
+			}
+			if err := s.tx.mkdirAll(filepath.Dir(target)); err != nil {
+				return err
+			}
+			if err := s.tx.writeFile(target, data); err != nil {
+				return err
+			}
+			s.tx.completed++
+		}
+		if err := s.describeSet(); err != nil {
+			return err
+		}
+		if stateSource == "" {
+			return nil
+		}
+		data, err := os.ReadFile(stateSource)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(statePath, data, 0644)
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(source); err != nil {
+		return err
+	}
+
+	// The next Write compares against the restored state
+	if stateSource != "" {
+		s.PreviousState = nil
+		s.previousLoaded = false
+		s.state = nil
+	}
+	return s.afterWrite(baseSitemapURL, tx)
+}
+
+// archiveFile links or copies src to dst, recording dst as created by the
+// running Write.
+func (s *SitemapOptions) archiveFile(src, dst string) error {
+	if err := s.tx.mkdirAll(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		s.tx.file = dst
+		if err := s.tx.backup(dst, false); err != nil {
+			return err
+		}
+	}
+	if err := os.Link(src, dst); err != nil {
+		return copyFile(src, dst)
+	}
+	return nil
+}
+
+// archives lists the archived sets in ArchiveDir, oldest first.
+func (s *SitemapOptions) archives() ([]string, error) {
+	archiveDir := s.archiveDirPath()
+	if archiveDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(archiveDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) < len(archiveLayout) {
+			continue
+		}
+		if _, err := time.Parse(archiveLayout, entry.Name()[:len(archiveLayout)]); err != nil {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Slice(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+	return names, nil
+}
+
+// pruneArchives removes the oldest archived sets beyond ArchiveRetain.
+func (s *SitemapOptions) pruneArchives() error {
+	if s.ArchiveRetain <= 0 {
+		return nil
+	}
+	names, err := s.archives()
+	if err != nil {
+		return err
+	}
+	for len(names) > s.ArchiveRetain {
+		if err := os.RemoveAll(filepath.Join(s.archiveDirPath(), names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return now }
+	sm.StateFile = "state.txt"
+	sm.ArchiveDir = "archive"
+	sm.ArchiveRetain = 2
+
+	write := func(loc string) {
+		t.Helper()
+		sm.Reset()
+		sm.AddURL(SitemapURL{Loc: loc})
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Error writing sitemap: %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+	write("/first")
+	if _, err := os.Stat(filepath.Join(dir, "archive")); !os.IsNotExist(err) {
+		t.Fatalf("Expected nothing to be archived on the first run, got %v", err)
+	}
+	first, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("/second")
+	archived := filepath.Join(dir, "archive", "20240601T130000Z")
+	data, err := os.ReadFile(filepath.Join(archived, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Expected the previous set to be archived: %v", err)
+	}
+	if string(data) != string(first) {
+		t.Fatalf("Expected the archived sitemap to be the previous one, got %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(archived, "state.txt")); err != nil {
+		t.Fatalf("Expected the state file to be archived: %v", err)
+	}
+
+	write("/third")
+	write("/fourth")
+	names, err := sm.archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "20240601T140000Z" || names[1] != "20240601T150000Z" {
+		t.Fatalf("Expected the two most recent archives to be kept, got %v", names)
+	}
+
+	// A failed Write removes the archive it started
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/fifth"})
+	sm.MaxFileSize = 10
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected the Write to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive", "20240601T160000Z")); err == nil {
+		t.Fatal("Expected the archive of a failed Write to be removed")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return now }
+	sm.StateFile = "state.txt"
+	sm.ShardDir = "shards"
+	sm.ArchiveDir = "archive"
+	sm.MaxURLs = 1
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	sm.AddURL(SitemapURL{Loc: "/b"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	good, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A bad generation replaces the index by a single sitemap
+	now = now.Add(time.Hour)
+	sm.Reset()
+	sm.MaxURLs = 10
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	var purged []string
+	sm.AfterWrite = func(urls []string) error {
+		purged = urls
+		return nil
+	}
+	if err := sm.Rollback("https://www.example.com/"); err != nil {
+		t.Fatalf("Error rolling back: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil || string(index) != string(good) {
+		t.Fatalf("Expected the previous index to be restored, got %s (%v)", index, err)
+	}
+	for _, name := range []string{"shards/sitemap_1.xml", "shards/sitemap_2.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to be restored: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap.xml")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the sitemap of the bad generation to be removed, got %v", err)
+	}
+	state, err := LoadState(filepath.Join(dir, "state.txt"))
+	if err != nil || len(state.URLs) != 2 {
+		t.Fatalf("Expected the previous state to be restored, got %v (%v)", state, err)
+	}
+	if len(purged) == 0 {
+		t.Fatal("Expected AfterWrite to receive the restored files")
+	}
+
+	// The archive was consumed
+	if err := sm.Rollback("https://www.example.com/"); err == nil {
+		t.Fatal("Expected a second Rollback to find no archive")
+	}
+}
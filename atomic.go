@@ -0,0 +1,249 @@
+package sitemap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to filePath so that readers only ever observe
+// either the previous file or the complete new one. The data is written to a
+// temporary file in the same directory, fsynced, renamed over the target and
+// the directory itself is fsynced so the rename survives a crash.
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file on any failure before the rename
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
+	committed = true
+
+	return syncDir(dir)
+}
+
+// WriteError reports a Write that failed after it started replacing files.
+// The files of the previous run are restored before it is returned.
+type WriteError struct {
+	File      string // file being written or validated when Write failed
+	Completed int    // sitemap files written before the failure
+	Err       error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("writing %s failed after %d sitemap files: %v", e.File, e.Completed, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// writeTx records the files a Write replaces or removes so that a failed
+// run can be rolled back to the previous set. Replaced and removed files are
+// kept as hidden backups until the run commits. A nil writeTx writes and
+// removes files directly.
+type writeTx struct {
+	changes   []fileChange
+	dirs      []string // Directories created by the run
+	touched   map[string]bool
+	file      string
+	completed int
+	// put, if set, publishes the files written instead of storing them,
+	// see WritePut. Nothing is removed or rolled back then.
+	put func(filePath string, data []byte) error
+	// empty is set while the empty sitemap of EmptyWrite is written, which
+	// is not validated against the XSD.
+	empty bool
+	// phases is the time spent in each phase, reported as Timings.
+	phases phaseTimes
+}
+
+// remote reports whether the files are published by put rather than
+// written below Dir.
+func (tx *writeTx) remote() bool {
+	return tx != nil && tx.put != nil
+}
+
+// fileChange is a file touched by a Write and its backup, empty if the
+// file did not exist before.
+type fileChange struct {
+	path   string
+	backup string
+}
+
+// writeFile writes data to filePath atomically, backing up the file it
+// replaces.
+func (tx *writeTx) writeFile(filePath string, data []byte) error {
+	if tx.remote() {
+		tx.file = filePath
+		return tx.put(filePath, data)
+	}
+	if tx != nil {
+		tx.file = filePath
+		if err := tx.backup(filePath, false); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filePath, data, 0644)
+}
+
+// removeFile removes filePath, keeping it as a backup. A missing file is
+// not an error.
+func (tx *writeTx) removeFile(filePath string) error {
+	if tx.remote() {
+		return nil
+	}
+	if tx == nil {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	tx.file = filePath
+	return tx.backup(filePath, true)
+}
+
+// mkdirAll creates dir and any missing parents, which rollback removes
+// again if they are empty.
+func (tx *writeTx) mkdirAll(dir string) error {
+	if tx.remote() {
+		return nil
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := tx.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	if tx != nil {
+		tx.dirs = append(tx.dirs, dir)
+	}
+	return nil
+}
+
+// backup records filePath before its first change in this run. Removed
+// files are moved to the backup; replaced files are hard linked, or copied
+// where links are unsupported.
+func (tx *writeTx) backup(filePath string, remove bool) error {
+	if tx.touched[filePath] {
+		if remove {
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if tx.touched == nil {
+		tx.touched = make(map[string]bool)
+	}
+
+	change := fileChange{path: filePath}
+	if _, err := os.Lstat(filePath); err == nil {
+		backup, err := tx.backupName(filePath)
+		if err != nil {
+			return err
+		}
+		switch {
+		case remove:
+			err = os.Rename(filePath, backup)
+		default:
+			if err = os.Link(filePath, backup); err != nil {
+				err = copyFile(filePath, backup)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		change.backup = backup
+	} else if !os.IsNotExist(err) {
+		return err
+	} else if remove {
+		// Nothing to remove or restore
+		return nil
+	}
+	tx.touched[filePath] = true
+	tx.changes = append(tx.changes, change)
+	return nil
+}
+
+// backupName reserves an unused hidden name next to filePath.
+func (tx *writeTx) backupName(filePath string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".bak-*")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	f.Close()
+	return name, os.Remove(name)
+}
+
+// rollback restores the files of the previous run, most recent change
+// first.
+func (tx *writeTx) rollback() error {
+	var firstErr error
+	for i := len(tx.changes) - 1; i >= 0; i-- {
+		change := tx.changes[i]
+		var err error
+		if change.backup != "" {
+			err = os.Rename(change.backup, change.path)
+		} else if err = os.Remove(change.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for i := len(tx.dirs) - 1; i >= 0; i-- {
+		os.Remove(tx.dirs[i])
+	}
+	return firstErr
+}
+
+// commit deletes the backups of a successful run.
+func (tx *writeTx) commit() {
+	for _, change := range tx.changes {
+		if change.backup != "" {
+			os.Remove(change.backup)
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
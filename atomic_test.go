@@ -0,0 +1,91 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFailedWriteRestoresPreviousFiles(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}, {Loc: "/c"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	previousShard, previousIndex := read("sitemap_1.xml"), read("sitemap_index.xml")
+
+	// A directory in place of the second shard makes writing it fail
+	if err := os.Remove(filepath.Join(dir, "sitemap_2.xml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sitemap_2.xml"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sm.Reset()
+	sm.AddURLs([]SitemapURL{{Loc: "/d"}, {Loc: "/e"}, {Loc: "/f"}})
+	err := sm.Write("https://www.example.com/")
+
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("expected a *WriteError, got %v", err)
+	}
+	if filepath.Base(writeErr.File) != "sitemap_2.xml" || writeErr.Completed != 1 {
+		t.Fatalf("unexpected error details: file %s, completed %d", writeErr.File, writeErr.Completed)
+	}
+	if read("sitemap_1.xml") != previousShard || read("sitemap_index.xml") != previousIndex {
+		t.Fatal("previous files were not restored")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			t.Fatalf("leftover file %s", entry.Name())
+		}
+	}
+}
+
+func TestCanceledWriteRestoresPreviousFiles(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}, {Loc: "/c"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	previous, err := os.ReadFile(filepath.Join(dir, "sitemap_1.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancel once the first file of the next run is written
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sm.IndexLastMod = func(string, []SitemapURL) string {
+		cancel()
+		return ""
+	}
+	sm.Reset()
+	sm.AddURLs([]SitemapURL{{Loc: "/d"}, {Loc: "/e"}, {Loc: "/f"}})
+	err = sm.WriteContext(ctx, "https://www.example.com/")
+	var writeErr *WriteError
+	if !errors.Is(err, context.Canceled) || !errors.As(err, &writeErr) || filepath.Base(writeErr.File) != "sitemap_1.xml" {
+		t.Fatalf("Expected a canceled *WriteError after one file, got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "sitemap_1.xml")); err != nil || string(data) != string(previous) {
+		t.Fatalf("Expected the previous files restored, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package sitemap
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxAuditRedirects is the longest redirect chain Audit follows.
+const maxAuditRedirects = 10
+
+// AuditReport lists the sampled URLs that did not answer 200 directly or
+// that ask not to be indexed. It encodes to JSON for other tooling.
+type AuditReport struct {
+	Sampled  int           `json:"sampled"`
+	Problems []AuditResult `json:"problems"`
+}
+
+// AuditResult is the outcome of requesting one sampled URL.
+type AuditResult struct {
+	Loc     string `json:"loc"`
+	Sitemap string `json:"sitemap"` // sitemap file listing the URL
+	Status  int    `json:"status,omitempty"`
+	// Redirects are the locations followed, ending with the final URL.
+	Redirects []string `json:"redirects,omitempty"`
+	// NoIndex is set when the response carries a noindex X-Robots-Tag
+	// header or robots meta tag.
+	NoIndex bool   `json:"noindex,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Audit requests up to sampleSize random URLs of every sitemap file written
+// to Dir by the last Write and reports non-200 responses, redirects and
+// noindex directives. Requests go through HTTPClient and RateLimiter, on up
+// to Concurrency workers.
+func (s *SitemapOptions) Audit(ctx context.Context, sampleSize int) (*AuditReport, error) {
+	files, err := s.auditFiles(filepath.Join(s.Dir, "sitemap_index.xml"))
+	if os.IsNotExist(err) {
+		files, err = []string{filepath.Join(s.Dir, "sitemap.xml")}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client := *s.httpClient()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	var sampled []AuditResult
+	for _, filePath := range files {
+		urlSet, err := LoadURLSet(filePath)
+		if err != nil {
+			return nil, err
+		}
+		urls := urlSet.URLs
+		if len(urls) > sampleSize {
+			urls = append([]SitemapURL(nil), urls...)
+			rand.Shuffle(len(urls), func(i, j int) { urls[i], urls[j] = urls[j], urls[i] })
+			urls = urls[:sampleSize]
+		}
+		for _, u := range urls {
+			sampled = append(sampled, AuditResult{Loc: u.Loc, Sitemap: filepath.Base(filePath)})
+		}
+	}
+
+	err = s.parallel(ctx, len(sampled), func(i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := s.auditURL(ctx, &client, sampled[i].Loc)
+		result.Sitemap = sampled[i].Sitemap
+		sampled[i] = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{Sampled: len(sampled), Problems: []AuditResult{}}
+	for _, result := range sampled {
+		if result.Error != "" || result.Status != http.StatusOK || len(result.Redirects) > 0 || result.NoIndex {
+			report.Problems = append(report.Problems, result)
+		}
+	}
+	return report, nil
+}
+
+// auditFiles returns the local sitemap files referenced by the index at
+// indexPath, following nested indexes.
+func (s *SitemapOptions) auditFiles(indexPath string) ([]string, error) {
+	index, err := LoadSitemapIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, sitemap := range index.Sitemaps {
+		filePath, ok := s.localSitemapPath(sitemap.Loc)
+		if !ok {
+			// External sitemaps are audited where they are generated
+			continue
+		}
+		if isNestedIndex(filepath.Base(filePath)) {
+			nested, err := s.auditFiles(filePath)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, nested...)
+			continue
+		}
+		files = append(files, filePath)
+	}
+	return files, nil
+}
+
+// auditURL requests loc, following redirects by hand to record the chain.
+func (s *SitemapOptions) auditURL(ctx context.Context, client *http.Client, loc string) AuditResult {
+	result := AuditResult{Loc: loc}
+	target := loc
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		resp, err := s.doRequestWith(client, req)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Status = resp.StatusCode
+
+		location, err := resp.Location()
+		if resp.StatusCode >= 300 && resp.StatusCode < 400 && err == nil {
+			resp.Body.Close()
+			if len(result.Redirects) == maxAuditRedirects {
+				result.Error = "too many redirects"
+				return result
+			}
+			target = location.String()
+			result.Redirects = append(result.Redirects, target)
+			continue
+		}
+
+		result.NoIndex = noIndexHeader(resp.Header)
+		if !result.NoIndex && strings.Contains(resp.Header.Get("Content-Type"), "html") {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			result.NoIndex = noIndexMeta(body)
+		}
+		resp.Body.Close()
+		return result
+	}
+}
+
+// noIndexHeader reports whether an X-Robots-Tag header, possibly scoped to
+// a user agent as in "googlebot: noindex", contains noindex.
+func noIndexHeader(header http.Header) bool {
+	for _, value := range header.Values("X-Robots-Tag") {
+		if hasNoIndex(value) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	metaTagPattern  = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	metaAttrPattern = regexp.MustCompile(`(?is)\s(name|content)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// noIndexMeta reports whether the HTML body has a robots or googlebot meta
+// tag containing noindex.
+func noIndexMeta(body []byte) bool {
+	for _, tag := range metaTagPattern.FindAll(body, -1) {
+		var name, content string
+		for _, m := range metaAttrPattern.FindAllSubmatch(tag, -1) {
+			value := string(m[2]) + string(m[3]) + string(m[4])
+			if strings.EqualFold(string(m[1]), "name") {
+				name = strings.ToLower(strings.TrimSpace(value))
+			} else {
+				content = value
+			}
+		}
+		if (name == "robots" || name == "googlebot") && hasNoIndex(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNoIndex reports whether a comma-separated directive list contains
+// noindex or none. A user agent prefix such as "googlebot:" is ignored.
+func hasNoIndex(directives string) bool {
+	for _, directive := range strings.Split(directives, ",") {
+		if _, value, ok := strings.Cut(directive, ":"); ok {
+			directive = value
+		}
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex", "none":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta name="description" content="noindex"></head></html>`)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved-again", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved-again", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "googlebot: noindex, nofollow")
+	})
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><META content='NOINDEX' name=robots></head></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), server.URL)
+	sm.MaxURLs = 3
+	for _, loc := range []string{"/ok", "/gone", "/moved", "/header", "/meta"} {
+		sm.AddURL(SitemapURL{Loc: loc})
+	}
+	if err := sm.Write(server.URL + "/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	report, err := sm.Audit(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Error auditing: %v", err)
+	}
+	if report.Sampled != 5 {
+		t.Fatalf("Expected 5 sampled URLs, got %d", report.Sampled)
+	}
+	results := map[string]AuditResult{}
+	for _, result := range report.Problems {
+		results[result.Loc] = result
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 problems, got %+v", report.Problems)
+	}
+	if r := results[server.URL+"/gone"]; r.Status != http.StatusGone || r.Sitemap != "sitemap_1.xml" {
+		t.Fatalf("Unexpected result for /gone: %+v", r)
+	}
+	if r := results[server.URL+"/moved"]; r.Status != http.StatusOK || len(r.Redirects) != 2 || r.Redirects[1] != server.URL+"/ok" {
+		t.Fatalf("Unexpected redirect chain: %+v", r)
+	}
+	if !results[server.URL+"/header"].NoIndex || !results[server.URL+"/meta"].NoIndex {
+		t.Fatalf("Expected noindex results, got %+v", report.Problems)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Fatalf("Error encoding report: %v", err)
+	}
+
+	report, err = sm.Audit(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Error auditing: %v", err)
+	}
+	if report.Sampled != 2 {
+		t.Fatalf("Expected one sampled URL per sitemap, got %d", report.Sampled)
+	}
+}
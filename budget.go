@@ -0,0 +1,146 @@
+package sitemap
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OverflowPolicy controls what Write does with URLs beyond MaxTotalURLs or
+// MaxHostURLs.
+type OverflowPolicy int
+
+const (
+	// OverflowError fails the Write with ErrTooManyURLs.
+	OverflowError OverflowPolicy = iota
+	// OverflowLowestPriority drops the URLs with the lowest priority, a
+	// missing one counting as 0.5, and among those the oldest lastmod.
+	OverflowLowestPriority
+	// OverflowOldest drops the URLs with the oldest lastmod, undated ones
+	// first, and among those the lowest priority.
+	OverflowOldest
+)
+
+// overBudget is the reason recorded for URLs dropped by an OverflowPolicy.
+const overBudget = "over budget"
+
+// hostBudget returns the MaxHostURLs budget of host, or 0 if it has none.
+func (s *SitemapOptions) hostBudget(host string) int {
+	for h, n := range s.MaxHostURLs {
+		if strings.EqualFold(h, host) {
+			return n
+		}
+	}
+	return 0
+}
+
+// locHost returns the lowercased host of loc.
+func locHost(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// applyBudgets enforces MaxHostURLs and then MaxTotalURLs on urls, given
+// that flushed URLs were already written, counted by host in hosts, which
+// may be nil. Depending on Overflow, it returns an error matching
+// ErrTooManyURLs or the URLs within the budgets, in their order, with the
+// others excluded in report. hosts is updated with the URLs returned.
+func (s *SitemapOptions) applyBudgets(urls []SitemapURL, flushed int, hosts map[string]int, report *Report) ([]SitemapURL, error) {
+	drop := make(map[int]bool)
+	if len(s.MaxHostURLs) > 0 {
+		byHost := make(map[string][]int)
+		for i, u := range urls {
+			host := locHost(u.Loc)
+			byHost[host] = append(byHost[host], i)
+		}
+		for host, indexes := range byHost {
+			budget := s.hostBudget(host)
+			if budget <= 0 {
+				continue
+			}
+			n := hosts[host] + len(indexes)
+			if n <= budget {
+				continue
+			}
+			if s.Overflow == OverflowError {
+				return nil, errorf(ErrTooManyURLs, "%d URLs of %s exceed its MaxHostURLs limit of %d", n, host, budget)
+			}
+			s.dropOverflow(urls, indexes, n-budget, drop)
+		}
+	}
+	if n := flushed + len(urls) - len(drop); s.MaxTotalURLs > 0 && n > s.MaxTotalURLs {
+		if s.Overflow == OverflowError {
+			return nil, errorf(ErrTooManyURLs, "%d URLs exceed the MaxTotalURLs limit of %d", n, s.MaxTotalURLs)
+		}
+		var indexes []int
+		for i := range urls {
+			if !drop[i] {
+				indexes = append(indexes, i)
+			}
+		}
+		s.dropOverflow(urls, indexes, n-s.MaxTotalURLs, drop)
+	}
+
+	if len(drop) == 0 && hosts == nil {
+		return urls, nil
+	}
+	kept := urls[:0]
+	for i, u := range urls {
+		if drop[i] {
+			s.exclude(report, ExcludedURL{Loc: u.Loc, Reason: overBudget, Meta: u.Meta})
+			continue
+		}
+		if hosts != nil {
+			hosts[locHost(u.Loc)]++
+		}
+		kept = append(kept, u)
+	}
+	return kept, nil
+}
+
+// dropOverflow marks in drop the n URLs of indexes, positions in urls, that
+// Overflow drops first. Of equal URLs, the ones added last are dropped.
+func (s *SitemapOptions) dropOverflow(urls []SitemapURL, indexes []int, n int, drop map[int]bool) {
+	if n <= 0 {
+		return
+	}
+	if len(indexes) < n {
+		n = len(indexes)
+	}
+	priorities := make(map[int]float64, len(indexes))
+	lastMods := make(map[int]time.Time, len(indexes))
+	for _, i := range indexes {
+		priority, err := strconv.ParseFloat(urls[i].Priority, 64)
+		if err != nil {
+			priority = 0.5
+		}
+		priorities[i] = priority
+		if t, err := s.parseLastMod(urls[i].LastMod); err == nil {
+			lastMods[i] = t
+		}
+	}
+
+	ranked := append([]int(nil), indexes...)
+	sort.SliceStable(ranked, func(a, b int) bool {
+		i, j := ranked[a], ranked[b]
+		byPriority := priorities[i] != priorities[j]
+		byLastMod := !lastMods[i].Equal(lastMods[j])
+		switch {
+		case s.Overflow == OverflowOldest && byLastMod:
+			return lastMods[i].Before(lastMods[j])
+		case byPriority:
+			return priorities[i] < priorities[j]
+		case byLastMod:
+			return lastMods[i].Before(lastMods[j])
+		}
+		return i > j
+	})
+	for _, i := range ranked[:n] {
+		drop[i] = true
+	}
+}
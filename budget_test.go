@@ -0,0 +1,73 @@
+package sitemap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOverflowPolicies(t *testing.T) {
+	urls := []SitemapURL{
+		{Loc: "/a", Priority: "0.9", LastMod: "2024-01-01"},
+		{Loc: "/b", LastMod: "2024-03-01"},
+		{Loc: "/c", Priority: "0.1", LastMod: "2024-05-01"},
+		{Loc: "/d", Priority: "0.9", LastMod: "2023-01-01"},
+	}
+	for name, test := range map[string]struct {
+		policy  OverflowPolicy
+		dropped []string
+	}{
+		"lowest priority": {OverflowLowestPriority, []string{"/c", "/b"}},
+		"oldest":          {OverflowOldest, []string{"/d", "/a"}},
+	} {
+		sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+		sm.MaxTotalURLs = 2
+		sm.Overflow = test.policy
+		sm.AddURLs(urls)
+		if err := sm.TryAddURL(SitemapURL{Loc: "/e", Priority: "1.0", LastMod: "2024-06-01"}); err != nil {
+			t.Fatalf("%s: Expected TryAddURL to accept URLs over the budget, got %v", name, err)
+		}
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("%s: Error writing sitemap: %v", name, err)
+		}
+		report := sm.Report()
+		if report.URLs != 2 || len(report.Excluded) != 3 {
+			t.Fatalf("%s: Expected 2 URLs written and 3 excluded, got %d and %+v", name, report.URLs, report.Excluded)
+		}
+		dropped := map[string]bool{}
+		for _, excluded := range report.Excluded {
+			if excluded.Reason != overBudget {
+				t.Fatalf("%s: Unexpected reason %q", name, excluded.Reason)
+			}
+			dropped[excluded.Loc] = true
+		}
+		for _, loc := range test.dropped {
+			if !dropped["https://www.example.com"+loc] {
+				t.Fatalf("%s: Expected %s to be dropped, got %+v", name, loc, report.Excluded)
+			}
+		}
+	}
+}
+
+func TestMaxHostURLs(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.MaxHostURLs = map[string]int{"Shop.example.com": 1}
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/a"},
+		{Loc: "/b"},
+		{Loc: "https://shop.example.com/x", Absolute: true},
+		{Loc: "https://shop.example.com/y", Absolute: true},
+	})
+	err := sm.Write("https://www.example.com/")
+	if !errors.Is(err, ErrTooManyURLs) {
+		t.Fatalf("Expected ErrTooManyURLs, got %v", err)
+	}
+
+	sm.Overflow = OverflowLowestPriority
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	report := sm.Report()
+	if report.URLs != 3 || len(report.Excluded) != 1 || report.Excluded[0].Loc != "https://shop.example.com/y" {
+		t.Fatalf("Expected the last shop URL to be dropped, got %d URLs and %+v", report.URLs, report.Excluded)
+	}
+}
@@ -0,0 +1,171 @@
+package sitemap
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChangeFreq is a changefreq value allowed by the protocol.
+type ChangeFreq string
+
+// The changefreq values of the protocol.
+const (
+	Always  ChangeFreq = "always"
+	Hourly  ChangeFreq = "hourly"
+	Daily   ChangeFreq = "daily"
+	Weekly  ChangeFreq = "weekly"
+	Monthly ChangeFreq = "monthly"
+	Yearly  ChangeFreq = "yearly"
+	Never   ChangeFreq = "never"
+)
+
+// URLBuilder builds a SitemapURL, checking each value as it is set:
+//
+//	u, err := sitemap.NewURL("/about").
+//		LastMod(updated).
+//		ChangeFreq(sitemap.Weekly).
+//		Priority(0.8).
+//		Image(sitemap.Image{Loc: "https://www.example.com/team.jpg"}).
+//		Build()
+//
+// The first problem found is returned by Build as a *ValidationError; the
+// calls after it are ignored.
+type URLBuilder struct {
+	u   SitemapURL
+	err error
+}
+
+// NewURL starts building the URL at loc, which may be relative to BaseURL.
+func NewURL(loc string) *URLBuilder {
+	b := &URLBuilder{u: SitemapURL{Loc: strings.TrimSpace(loc)}}
+	switch {
+	case b.u.Loc == "":
+		b.fail("missing loc")
+	case !validChars(b.u.Loc, true):
+		b.fail("loc holds invalid characters")
+	default:
+		if _, err := url.Parse(b.u.Loc); err != nil {
+			b.fail("invalid loc: %v", err)
+		}
+	}
+	return b
+}
+
+// fail records the first problem.
+func (b *URLBuilder) fail(format string, args ...any) {
+	if b.err == nil {
+		b.err = &ValidationError{Loc: b.u.Loc, Problem: fmt.Sprintf(format, args...)}
+	}
+}
+
+// LastMod sets the time the page last changed.
+func (b *URLBuilder) LastMod(t time.Time) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	if t.IsZero() {
+		b.fail("zero lastmod")
+		return b
+	}
+	b.u.LastMod = t.Format(time.RFC3339)
+	return b
+}
+
+// ChangeFreq sets how often the page is likely to change.
+func (b *URLBuilder) ChangeFreq(freq ChangeFreq) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	if !changeFreqs[string(freq)] {
+		b.fail("invalid changefreq '%s'", freq)
+		return b
+	}
+	b.u.ChangeFreq = string(freq)
+	return b
+}
+
+// Priority sets the priority of the page relative to the others of the
+// site, from 0.0 to 1.0.
+func (b *URLBuilder) Priority(priority float64) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	if math.IsNaN(priority) || priority < 0 || priority > 1 {
+		b.fail("priority %v is outside 0.0 to 1.0", priority)
+		return b
+	}
+	b.u.Priority = formatPriority(priority)
+	return b
+}
+
+// Image adds an image of the page. Its loc and license must be absolute
+// http(s) URLs.
+func (b *URLBuilder) Image(img Image) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	switch {
+	case !isHTTPURL(img.Loc):
+		b.fail("image %s is not an absolute http(s) URL", img.Loc)
+	case img.License != "" && !isHTTPURL(img.License):
+		b.fail("image %s: license %s is not an absolute http(s) URL", img.Loc, img.License)
+	case len(b.u.Images) == maxImagesPerURL:
+		b.fail("more than %d images", maxImagesPerURL)
+	default:
+		b.u.Images = append(b.u.Images, img)
+	}
+	return b
+}
+
+// Alternate adds a localized version of the page at href, which may be
+// relative to BaseURL.
+func (b *URLBuilder) Alternate(hreflang, href string) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	switch {
+	case !hreflangPattern.MatchString(hreflang):
+		b.fail("invalid hreflang %q", hreflang)
+	case strings.TrimSpace(href) == "":
+		b.fail("alternate %q has no href", hreflang)
+	default:
+		b.u.Alternates = append(b.u.Alternates, Alternate{Rel: "alternate", Hreflang: hreflang, Href: strings.TrimSpace(href)})
+	}
+	return b
+}
+
+// Group places the URL in sitemap files of its own, see SitemapURL.Group.
+func (b *URLBuilder) Group(group string) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	if err := checkGroup(group); err != nil {
+		b.fail("%v", err)
+		return b
+	}
+	b.u.Group = group
+	return b
+}
+
+// Meta attaches a value that is not written, see SitemapURL.Meta.
+func (b *URLBuilder) Meta(key, value string) *URLBuilder {
+	if b.err != nil {
+		return b
+	}
+	if b.u.Meta == nil {
+		b.u.Meta = make(map[string]string)
+	}
+	b.u.Meta[key] = value
+	return b
+}
+
+// Build returns the URL, or the first problem found.
+func (b *URLBuilder) Build() (SitemapURL, error) {
+	if b.err != nil {
+		return SitemapURL{}, b.err
+	}
+	return b.u, nil
+}
@@ -0,0 +1,45 @@
+package sitemap
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestURLBuilder(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	u, err := NewURL("/about").
+		LastMod(updated).
+		ChangeFreq(Weekly).
+		Priority(0.8).
+		Image(Image{Loc: "https://www.example.com/team.jpg"}).
+		Alternate("de", "/de/about").
+		Group("pages").
+		Meta("id", "7").
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if u.Loc != "/about" || u.LastMod != "2024-03-01T12:00:00Z" || u.ChangeFreq != "weekly" || u.Priority != "0.8" ||
+		len(u.Images) != 1 || len(u.Alternates) != 1 || u.Group != "pages" || u.Meta["id"] != "7" {
+		t.Fatalf("Unexpected URL %+v", u)
+	}
+
+	for name, b := range map[string]*URLBuilder{
+		"empty loc":        NewURL(" "),
+		"control in loc":   NewURL("/a\x00b"),
+		"zero lastmod":     NewURL("/a").LastMod(time.Time{}),
+		"changefreq":       NewURL("/a").ChangeFreq("sometimes"),
+		"priority":         NewURL("/a").Priority(1.5),
+		"relative image":   NewURL("/a").Image(Image{Loc: "/team.jpg"}),
+		"hreflang":         NewURL("/a").Alternate("english", "/en/a"),
+		"reserved group":   NewURL("/a").Group("news"),
+		"first error kept": NewURL("/a").Priority(-1).ChangeFreq(Daily),
+	} {
+		_, err := b.Build()
+		var validation *ValidationError
+		if !errors.As(err, &validation) {
+			t.Errorf("%s: expected a ValidationError, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// Changes are the locs that differ between two runs, each list sorted.
+type Changes struct {
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+}
+
+// Changes compares st with the State of an earlier run. Unlike Diff, it
+// tells added locs from updated ones. A nil previous State means every loc
+// was added.
+func (st *State) Changes(previous *State) Changes {
+	changes := Changes{Added: []string{}, Updated: []string{}, Removed: []string{}}
+	changed, removed := st.Diff(previous)
+	for _, loc := range changed {
+		if previous != nil && previous.URLs[loc] != "" {
+			changes.Updated = append(changes.Updated, loc)
+		} else {
+			changes.Added = append(changes.Added, loc)
+		}
+	}
+	changes.Removed = append(changes.Removed, removed...)
+	return changes
+}
+
+// writeChangesFile writes changes to ChangesFile, if set.
+func (s *SitemapOptions) writeChangesFile(changes Changes) error {
+	if s.ChangesFile == "" {
+		return nil
+	}
+	filePath := s.ChangesFile
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(s.Dir, filePath)
+	}
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.tx.writeFile(filePath, append(data, '\n'))
+}
@@ -0,0 +1,60 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.StateFile = "state.txt"
+	sm.ChangesFile = "changes.json"
+	sm.AddURL(SitemapURL{Loc: "/kept", LastMod: "2024-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/updated", LastMod: "2024-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/removed", LastMod: "2024-01-01"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if sm.Report().Changes != nil {
+		t.Fatalf("Expected no changes without a previous state, got %+v", sm.Report().Changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "changes.json")); !os.IsNotExist(err) {
+		t.Fatalf("Expected no changes file on the first run")
+	}
+
+	next := NewSitemapOptions(dir, "https://www.example.com")
+	next.StateFile = "state.txt"
+	next.ChangesFile = "changes.json"
+	next.AddURL(SitemapURL{Loc: "/kept", LastMod: "2024-01-01"})
+	next.AddURL(SitemapURL{Loc: "/updated", LastMod: "2024-02-01"})
+	next.AddURL(SitemapURL{Loc: "/added", LastMod: "2024-02-01"})
+	if err := next.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	expected := Changes{
+		Added:   []string{"https://www.example.com/added"},
+		Updated: []string{"https://www.example.com/updated"},
+		Removed: []string{"https://www.example.com/removed"},
+	}
+	changes := next.Report().Changes
+	if changes == nil || !slices.Equal(changes.Added, expected.Added) || !slices.Equal(changes.Updated, expected.Updated) || !slices.Equal(changes.Removed, expected.Removed) {
+		t.Fatalf("Unexpected changes %+v", changes)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "changes.json"))
+	if err != nil {
+		t.Fatalf("Error reading changes file: %v", err)
+	}
+	var written Changes
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Error decoding changes file: %v", err)
+	}
+	if !slices.Equal(written.Added, expected.Added) || !slices.Equal(written.Removed, expected.Removed) {
+		t.Fatalf("Unexpected changes file %s", data)
+	}
+}
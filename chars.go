@@ -0,0 +1,221 @@
+package sitemap
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidCharPolicy selects how characters that may not appear in XML, and
+// control characters in URLs, are handled.
+type InvalidCharPolicy int
+
+const (
+	// InvalidCharsKeep leaves values as they are: the encoder replaces
+	// characters invalid in XML with U+FFFD, and a loc with control
+	// characters fails the Write.
+	InvalidCharsKeep InvalidCharPolicy = iota
+	// InvalidCharsStrip removes the characters.
+	InvalidCharsStrip
+	// InvalidCharsReject drops URLs holding any, recording them in the
+	// report as excluded.
+	InvalidCharsReject
+)
+
+// validChar reports whether the rune r, encoded in n bytes, may appear in
+// an XML 1.0 document and, if isURL, is not an ASCII control character.
+// Bytes that are not valid UTF-8 are invalid.
+func validChar(r rune, n int, isURL bool) bool {
+	switch {
+	case r == utf8.RuneError && n == 1:
+		return false
+	case isURL && (r < 0x20 || r == 0x7F):
+		return false
+	}
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// charCleaner applies an InvalidCharPolicy to the values of one URL,
+// remembering the first field holding invalid characters.
+type charCleaner struct {
+	policy  InvalidCharPolicy
+	invalid string
+}
+
+// clean returns value, with invalid characters removed under
+// InvalidCharsStrip.
+func (c *charCleaner) clean(field, value string, isURL bool) string {
+	if validChars(value, isURL) {
+		return value
+	}
+	if c.invalid == "" {
+		c.invalid = field
+	}
+	if c.policy != InvalidCharsStrip {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		r, n := utf8.DecodeRuneInString(value[i:])
+		if validChar(r, n, isURL) {
+			b.WriteString(value[i : i+n])
+		}
+		i += n
+	}
+	return b.String()
+}
+
+// validChars reports whether every character of value is valid.
+func validChars(value string, isURL bool) bool {
+	for i := 0; i < len(value); {
+		r, n := utf8.DecodeRuneInString(value[i:])
+		if !validChar(r, n, isURL) {
+			return false
+		}
+		i += n
+	}
+	return true
+}
+
+// cleanChars applies InvalidChars to the values of u that are written,
+// copying its slices rather than modifying the caller's. It returns the
+// first field holding invalid characters, or "" if there is none.
+func (s *SitemapOptions) cleanChars(u *SitemapURL) string {
+	c := &charCleaner{policy: s.InvalidChars}
+	u.Loc = c.clean("loc", u.Loc, true)
+	u.LastMod = c.clean("lastmod", u.LastMod, false)
+	u.ChangeFreq = c.clean("changefreq", u.ChangeFreq, false)
+	u.Priority = c.clean("priority", u.Priority, false)
+	u.MobileAlternate = c.clean("mobile alternate", u.MobileAlternate, true)
+
+	if len(u.Alternates) > 0 {
+		alternates := make([]Alternate, len(u.Alternates))
+		for i, alt := range u.Alternates {
+			alt.Rel = c.clean("xhtml:link rel", alt.Rel, false)
+			alt.Hreflang = c.clean("xhtml:link hreflang", alt.Hreflang, false)
+			alt.Media = c.clean("xhtml:link media", alt.Media, false)
+			alt.Href = c.clean("xhtml:link href", alt.Href, true)
+			alternates[i] = alt
+		}
+		u.Alternates = alternates
+	}
+
+	if u.News != nil {
+		news := *u.News
+		news.Publication.Name = c.clean("news:name", news.Publication.Name, false)
+		news.Publication.Language = c.clean("news:language", news.Publication.Language, false)
+		news.PublicationDate = c.clean("news:publication_date", news.PublicationDate, false)
+		news.Title = c.clean("news:title", news.Title, false)
+		u.News = &news
+	}
+
+	if len(u.Images) > 0 {
+		images := make([]Image, len(u.Images))
+		for i, img := range u.Images {
+			img.Loc = c.clean("image:loc", img.Loc, true)
+			img.Caption = c.clean("image:caption", img.Caption, false)
+			img.GeoLocation = c.clean("image:geo_location", img.GeoLocation, false)
+			img.Title = c.clean("image:title", img.Title, false)
+			img.License = c.clean("image:license", img.License, true)
+			images[i] = img
+		}
+		u.Images = images
+	}
+
+	if len(u.Videos) > 0 {
+		videos := make([]Video, len(u.Videos))
+		for i, v := range u.Videos {
+			c.cleanVideo(&v)
+			videos[i] = v
+		}
+		u.Videos = videos
+	}
+
+	if u.PageMap != nil {
+		pageMap := PageMap{DataObjects: make([]DataObject, len(u.PageMap.DataObjects))}
+		for i, obj := range u.PageMap.DataObjects {
+			obj.Type = c.clean("pagemap:DataObject type", obj.Type, false)
+			obj.ID = c.clean("pagemap:DataObject id", obj.ID, false)
+			attributes := make([]Attribute, len(obj.Attributes))
+			for j, attr := range obj.Attributes {
+				attr.Name = c.clean("pagemap:Attribute name", attr.Name, false)
+				attr.Value = c.clean("pagemap:Attribute", attr.Value, false)
+				attributes[j] = attr
+			}
+			obj.Attributes = attributes
+			pageMap.DataObjects[i] = obj
+		}
+		u.PageMap = &pageMap
+	}
+	return c.invalid
+}
+
+// cleanVideo cleans the values of v, copying its slices and pointers.
+func (c *charCleaner) cleanVideo(v *Video) {
+	v.ThumbnailLoc = c.clean("video:thumbnail_loc", v.ThumbnailLoc, true)
+	v.Title = c.clean("video:title", v.Title, false)
+	v.Description = c.clean("video:description", v.Description, false)
+	v.ContentLoc = c.clean("video:content_loc", v.ContentLoc, true)
+	v.PlayerLoc = c.clean("video:player_loc", v.PlayerLoc, true)
+	v.ExpirationDate = c.clean("video:expiration_date", v.ExpirationDate, false)
+	v.PublicationDate = c.clean("video:publication_date", v.PublicationDate, false)
+	v.FamilyFriendly = c.clean("video:family_friendly", v.FamilyFriendly, false)
+	v.RequiresSubscription = c.clean("video:requires_subscription", v.RequiresSubscription, false)
+	v.Live = c.clean("video:live", v.Live, false)
+	if len(v.Tags) > 0 {
+		tags := make([]string, len(v.Tags))
+		for i, tag := range v.Tags {
+			tags[i] = c.clean("video:tag", tag, false)
+		}
+		v.Tags = tags
+	}
+	if v.Restriction != nil {
+		restriction := *v.Restriction
+		restriction.Relationship = c.clean("video:restriction relationship", restriction.Relationship, false)
+		restriction.Countries = c.clean("video:restriction", restriction.Countries, false)
+		v.Restriction = &restriction
+	}
+	if len(v.Prices) > 0 {
+		prices := make([]VideoPrice, len(v.Prices))
+		for i, price := range v.Prices {
+			price.Currency = c.clean("video:price currency", price.Currency, false)
+			price.Type = c.clean("video:price type", price.Type, false)
+			price.Resolution = c.clean("video:price resolution", price.Resolution, false)
+			price.Value = c.clean("video:price", price.Value, false)
+			prices[i] = price
+		}
+		v.Prices = prices
+	}
+	if v.Uploader != nil {
+		uploader := *v.Uploader
+		uploader.Info = c.clean("video:uploader info", uploader.Info, true)
+		uploader.Name = c.clean("video:uploader", uploader.Name, false)
+		v.Uploader = &uploader
+	}
+	if v.Platform != nil {
+		platform := *v.Platform
+		platform.Relationship = c.clean("video:platform relationship", platform.Relationship, false)
+		platform.Platforms = c.clean("video:platform", platform.Platforms, false)
+		v.Platform = &platform
+	}
+}
+
+// cleanURLChars applies InvalidChars to urls and returns those to write,
+// dropping and reporting URLs with invalid characters under
+// InvalidCharsReject.
+func (s *SitemapOptions) cleanURLChars(urls []SitemapURL, report *Report) []SitemapURL {
+	if s.InvalidChars == InvalidCharsKeep {
+		return urls
+	}
+	kept := urls[:0:0]
+	for _, u := range urls {
+		if field := s.cleanChars(&u); field != "" && s.InvalidChars == InvalidCharsReject {
+			s.exclude(report, ExcludedURL{Loc: u.Loc, Reason: "invalid characters in " + field, Meta: u.Meta})
+			continue
+		}
+		kept = append(kept, u)
+	}
+	return kept
+}
@@ -0,0 +1,81 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInvalidChars(t *testing.T) {
+	add := func(sm *SitemapOptions) {
+		sm.AddURL(SitemapURL{Loc: "/ok", Images: []Image{{Loc: "https://www.example.com/a.jpg", Title: "fine"}}})
+		sm.AddURL(SitemapURL{Loc: "/bad\x01slug"})
+		sm.AddURL(SitemapURL{Loc: "/caption", Images: []Image{{Loc: "https://www.example.com/b.jpg", Caption: "bad\x0bcaption\xff"}}})
+	}
+
+	// By default a control character in a loc fails the Write
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	add(sm)
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected the invalid loc to fail the Write")
+	}
+
+	dir := t.TempDir()
+	sm = NewSitemapOptions(dir, "https://www.example.com")
+	sm.InvalidChars = InvalidCharsStrip
+	add(sm)
+	images := sm.URLs[2].Images
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"https://www.example.com/badslug", "<image:caption>badcaption</image:caption>"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("Expected %s in the sitemap, got %s", want, data)
+		}
+	}
+	if images[0].Caption != "bad\x0bcaption\xff" {
+		t.Fatal("Expected the caller's images to be untouched")
+	}
+
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.InvalidChars = InvalidCharsReject
+	add(sm)
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	report := sm.Report()
+	if report.URLs != 1 || len(report.Excluded) != 2 {
+		t.Fatalf("Expected two URLs to be rejected, got %+v", report)
+	}
+	if report.Excluded[0].Reason != "invalid characters in loc" || report.Excluded[1].Reason != "invalid characters in image:caption" {
+		t.Fatalf("Unexpected reasons: %+v", report.Excluded)
+	}
+}
+
+func TestValidChar(t *testing.T) {
+	tests := []struct {
+		value string
+		isURL bool
+		valid bool
+	}{
+		{"plain text", false, true},
+		{"tab\tand\nnewline", false, true},
+		{"tab\tin url", true, false},
+		{"del\x7f", false, true},
+		{"del\x7f", true, false},
+		{"nul\x00", false, false},
+		{"￾", false, false},
+		{"\xc3\x28", false, false},
+		{"émoji 🎉", true, true},
+	}
+	for _, tt := range tests {
+		if got := validChars(tt.value, tt.isURL); got != tt.valid {
+			t.Fatalf("validChars(%q, %v) = %v, expected %v", tt.value, tt.isURL, got, tt.valid)
+		}
+	}
+}
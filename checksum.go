@@ -0,0 +1,142 @@
+package sitemap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumExt is the extension of the checksum file next to each file.
+const checksumExt = ".sha256"
+
+// describeSet writes the Manifest and checksum files describing the
+// sitemap set, as configured.
+func (s *SitemapOptions) describeSet() error {
+	if err := s.writeChecksums(); err != nil {
+		return err
+	}
+	return s.writeManifest()
+}
+
+// writeChecksums writes, if ChecksumFiles is set, a <name>.sha256 file in
+// the format of sha256sum next to every sitemap file and stylesheet in Dir
+// and ShardDir, and removes those left by files that are gone.
+func (s *SitemapOptions) writeChecksums() error {
+	if !s.ChecksumFiles {
+		return nil
+	}
+	files, err := s.setFiles()
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]bool, len(files))
+	for _, filePath := range files {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), filepath.Base(filePath))
+		checksumPath := filePath + checksumExt
+		listed[checksumPath] = true
+		if old, err := os.ReadFile(checksumPath); err == nil && string(old) == line {
+			continue
+		}
+		if err := s.tx.writeFile(checksumPath, []byte(line)); err != nil {
+			return err
+		}
+	}
+
+	dirs := []string{s.Dir}
+	if s.ShardDir != "" {
+		dirs = append(dirs, s.shardDir())
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			checksumPath := filepath.Join(dir, entry.Name())
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), checksumExt) && !listed[checksumPath] {
+				if err := s.tx.removeFile(checksumPath); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyChecksums checks every file in dir and its subdirectories that has
+// a .sha256 checksum file next to it, as written with ChecksumFiles, such
+// as a copy of a sitemap set synced from a CDN or object store. It returns
+// an Issue, with Loc the path relative to dir, for each file that is
+// missing or does not match its checksum. Only failing to read dir or a
+// checksum file is returned as an error.
+func VerifyChecksums(dir string) ([]Issue, error) {
+	var issues []Issue
+	err := filepath.WalkDir(dir, func(checksumPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), checksumExt) {
+			return nil
+		}
+		line, err := os.ReadFile(checksumPath)
+		if err != nil {
+			return err
+		}
+		filePath := strings.TrimSuffix(checksumPath, checksumExt)
+		name, _ := filepath.Rel(dir, filePath)
+		name = filepath.ToSlash(name)
+		want, _, _ := strings.Cut(string(bytes.TrimSpace(line)), " ")
+		if _, err := hex.DecodeString(want); err != nil || len(want) != sha256.Size*2 {
+			issues = append(issues, Issue{Loc: name, Problem: "invalid checksum file"})
+			return nil
+		}
+		data, err := os.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			issues = append(issues, Issue{Loc: name, Problem: "missing"})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != strings.ToLower(want) {
+			issues = append(issues, Issue{Loc: name, Problem: "sha256 mismatch"})
+		}
+		return nil
+	})
+	return issues, err
+}
+
+// Verify checks the files of m in dir, a copy of the sitemap set the
+// Manifest describes, and returns an Issue, with Loc the file name, for
+// each file that is missing or whose size or sha256 differs.
+func (m *Manifest) Verify(dir string) ([]Issue, error) {
+	var issues []Issue
+	for _, file := range m.Files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Name)))
+		if os.IsNotExist(err) {
+			issues = append(issues, Issue{Loc: file.Name, Problem: "missing"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		switch {
+		case int64(len(data)) != file.Bytes:
+			issues = append(issues, Issue{Loc: file.Name, Problem: fmt.Sprintf("%d bytes, want %d", len(data), file.Bytes)})
+		case hex.EncodeToString(sum[:]) != file.SHA256:
+			issues = append(issues, Issue{Loc: file.Name, Problem: "sha256 mismatch"})
+		}
+	}
+	return issues, nil
+}
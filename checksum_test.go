@@ -0,0 +1,68 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksumFiles(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.ShardDir = "shards"
+	sm.ChecksumFiles = true
+	sm.ManifestFile = "manifest.json"
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	line, err := os.ReadFile(filepath.Join(dir, "shards", "sitemap_2.xml.sha256"))
+	if err != nil {
+		t.Fatalf("Expected a checksum file: %v", err)
+	}
+	if !strings.HasSuffix(string(line), "  sitemap_2.xml\n") || len(line) != 64+2+len("sitemap_2.xml")+1 {
+		t.Fatalf("Unexpected checksum line %q", line)
+	}
+	if issues, err := VerifyChecksums(dir); err != nil || len(issues) != 0 {
+		t.Fatalf("Expected the fresh set to verify, got %v, %v", issues, err)
+	}
+	manifest, err := LoadManifest(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Error loading manifest: %v", err)
+	}
+
+	// Corrupt one copy and lose another
+	if err := os.WriteFile(filepath.Join(dir, "shards", "sitemap_1.xml"), []byte("<urlset/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sitemap_index.xml")); err != nil {
+		t.Fatal(err)
+	}
+	issues, err := VerifyChecksums(dir)
+	if err != nil || len(issues) != 2 || issues[0].Loc != "shards/sitemap_1.xml" || issues[1].Loc != "sitemap_index.xml" || issues[1].Problem != "missing" {
+		t.Fatalf("Unexpected checksum issues: %v, %v", issues, err)
+	}
+	issues, err = manifest.Verify(dir)
+	if err != nil || len(issues) != 2 {
+		t.Fatalf("Unexpected manifest issues: %v, %v", issues, err)
+	}
+
+	// Checksums of files that are gone are removed
+	if err := os.Remove(filepath.Join(dir, "shards", "sitemap_2.xml")); err != nil {
+		t.Fatal(err)
+	}
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shards", "sitemap_2.xml.sha256")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the stale checksum file removed, got %v", err)
+	}
+	if issues, err := VerifyChecksums(dir); err != nil || len(issues) != 0 {
+		t.Fatalf("Expected the rewritten set to verify, got %v, %v", issues, err)
+	}
+}
@@ -0,0 +1,314 @@
+// Command sitemap works with sitemap files from the command line.
+//
+// Usage:
+//
+//	sitemap split [flags] <sitemap.xml | ->
+//	sitemap to-text <sitemap.xml | ->
+//	sitemap to-xml <urllist.txt | ->
+//	sitemap touch [flags] [loc ...]
+//	sitemap rollback [flags]
+//	sitemap lint [flags] <sitemap URL | file>
+//	sitemap generate -config <config.json> <urllist.txt | ->
+//
+// split re-emits an oversized sitemap as a sitemap index plus shards that
+// respect the URL count and file size limits, preserving extension data.
+// With -dir -, the files are written to standard output as a tar stream.
+// to-text and to-xml convert between XML sitemaps and plain text URL lists,
+// writing to standard output. touch refreshes the lastmod values of an
+// existing index and, for the given locs, of their URLs without rebuilding
+// the sitemaps. rollback restores the most recently archived set. lint
+// checks any sitemap or sitemap index against the protocol and prints the
+// problems found, exiting with status 1 if there are any. generate writes
+// the sitemap set of a plain text URL list, one loc per line, as set up by
+// a JSON config file, see sitemap.Config, to Dir or to the PUT endpoint of
+// its output backend, and prints the URLs dropped by reason. Requests to the
+// PUT endpoint carry the Authorization header set in
+// SITEMAP_PUT_AUTHORIZATION, if any.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coffyg/sitemap"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "split":
+		err = split(os.Args[2:])
+	case "to-text":
+		err = convert(os.Args[2:], sitemap.XMLToText)
+	case "to-xml":
+		err = convert(os.Args[2:], sitemap.TextToXML)
+	case "touch":
+		err = touch(os.Args[2:])
+	case "rollback":
+		err = rollback(os.Args[2:])
+	case "lint":
+		err = lint(os.Args[2:])
+	case "generate":
+		err = generate(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "sitemap: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sitemap: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: sitemap <command> [flags]
+
+commands:
+  split    split an oversized sitemap into an index plus shards
+  to-text  convert an XML sitemap to a plain text URL list
+  to-xml   convert a plain text URL list to an XML sitemap
+  touch    refresh lastmod values without rebuilding the sitemaps
+  rollback restore the most recently archived sitemap set
+  lint     check a sitemap or sitemap index against the protocol
+  generate write the sitemap set of a URL list as set up by a config file`)
+}
+
+func split(args []string) error {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	dir := fs.String("dir", ".", `output directory, or "-" for a tar stream on standard output`)
+	base := fs.String("base", "", "base URL the sitemap files are served from (required)")
+	maxURLs := fs.Int("max-urls", 0, "maximum URLs per shard (default 33333)")
+	maxSize := fs.Int("max-size", 0, "maximum bytes per shard (default 50MB)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sitemap split [flags] <sitemap.xml | ->")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *base == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	in, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	outDir := *dir
+	if outDir == "-" {
+		if outDir, err = os.MkdirTemp("", "sitemap-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(outDir)
+	}
+	opts := sitemap.NewSitemapOptions(outDir, *base)
+	if *maxURLs > 0 {
+		opts.MaxURLs = *maxURLs
+	}
+	if *maxSize > 0 {
+		opts.MaxFileSize = *maxSize
+	}
+	if err := opts.SplitSitemap(in, *base); err != nil {
+		return err
+	}
+	if *dir == "-" {
+		return sitemap.ArchiveDir(os.Stdout, outDir)
+	}
+	return nil
+}
+
+func touch(args []string) error {
+	fs := flag.NewFlagSet("touch", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory holding the sitemap files")
+	base := fs.String("base", "", "base URL the sitemap files are served from (required)")
+	shardDir := fs.String("shard-dir", "", "subdirectory of dir holding the shards, if any")
+	lastMod := fs.String("lastmod", "", "lastmod to set, as RFC 3339 or YYYY-MM-DD (default now)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sitemap touch [flags] [loc ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *base == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	t := time.Now()
+	if *lastMod != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *lastMod); err != nil {
+			if t, err = time.Parse(time.DateOnly, *lastMod); err != nil {
+				return fmt.Errorf("invalid -lastmod %q", *lastMod)
+			}
+		}
+	}
+	opts := sitemap.NewSitemapOptions(*dir, *base)
+	opts.ShardDir = *shardDir
+	return opts.TouchLastMod(*base, t, fs.Args()...)
+}
+
+func rollback(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory holding the sitemap files")
+	base := fs.String("base", "", "base URL the sitemap files are served from (required)")
+	shardDir := fs.String("shard-dir", "", "subdirectory of dir holding the shards, if any")
+	archiveDir := fs.String("archive-dir", "archive", "directory holding the archived sets, relative to dir")
+	stateFile := fs.String("state-file", "", "state file to restore, relative to dir, if any")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sitemap rollback [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *base == "" || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	opts := sitemap.NewSitemapOptions(*dir, *base)
+	opts.ShardDir = *shardDir
+	opts.ArchiveDir = *archiveDir
+	opts.StateFile = *stateFile
+	return opts.Rollback(*base)
+}
+
+func lint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	base := fs.String("base", "", "base URL the locs of a local file must be on, if any")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sitemap lint [flags] <sitemap URL | file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	opts := sitemap.NewSitemapOptions("", *base)
+	report, err := opts.Lint(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range report.Issues {
+			if issue.Loc != "" {
+				fmt.Printf("%s: %s: %s\n", issue.Sitemap, issue.Loc, issue.Problem)
+			} else {
+				fmt.Printf("%s: %s\n", issue.Sitemap, issue.Problem)
+			}
+		}
+		fmt.Printf("%d sitemaps, %d URLs, %d problems\n", len(report.Sitemaps), report.URLs, len(report.Issues))
+	}
+	if len(report.Issues) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func convert(args []string, fn func(io.Reader, io.Writer) error) error {
+	if len(args) != 1 {
+		usage()
+		os.Exit(2)
+	}
+	in, err := openInput(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return fn(in, os.Stdout)
+}
+
+// generate writes the sitemap set of a URL list as set up by a config file.
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	config := fs.String("config", "", "JSON config file (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sitemap generate -config <config.json> <urllist.txt | ->")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *config == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := sitemap.LoadConfig(*config)
+	if err != nil {
+		return err
+	}
+	opts, err := sitemap.NewFromConfig(c)
+	if err != nil {
+		return err
+	}
+	in, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if loc := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff")); loc != "" {
+			opts.AddURL(sitemap.SitemapURL{Loc: loc})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var authorize func(req *http.Request) error
+	if header := os.Getenv("SITEMAP_PUT_AUTHORIZATION"); header != "" {
+		authorize = func(req *http.Request) error {
+			req.Header.Set("Authorization", header)
+			return nil
+		}
+	}
+	if err := c.Publish(context.Background(), opts, authorize); err != nil {
+		return err
+	}
+	report := opts.Report()
+	fmt.Printf("%d URLs written, %d excluded\n", report.URLs, len(report.Excluded))
+	skipped := opts.Skipped()
+	reasons := make([]string, 0, len(skipped.ByReason))
+	for reason := range skipped.ByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("  %s: %d\n", reason, skipped.ByReason[reason])
+	}
+	return nil
+}
+
+// openInput opens the named file, or standard input for "-".
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
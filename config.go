@@ -0,0 +1,303 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Config is the generator configuration read by LoadConfig, so the sitemap
+// policy of a site can be changed without code. It is JSON; YAML is not
+// supported, as the package has no YAML parser:
+//
+//	{
+//	  "dir": "public",
+//	  "baseURL": "https://www.example.com",
+//	  "maxURLs": 10000,
+//	  "shardStrategy": "hash",
+//	  "groups": {"blog": {"maxURLs": 5000, "baseURL": "https://blog.example.com"}},
+//	  "sectionRules": [{"pattern": "/blog/*", "priority": 0.7, "changeFreq": "weekly"}],
+//	  "exclude": ["/admin/*"],
+//	  "output": {"shardDir": "sitemaps", "gzip": true, "manifestFile": "manifest.json"}
+//	}
+//
+// Zero values keep the defaults of NewSitemapOptions. Relative paths are
+// resolved as the fields of SitemapOptions they set. Output.Backend selects
+// where Publish writes the files.
+type Config struct {
+	Dir     string `json:"dir"`
+	BaseURL string `json:"baseURL"`
+	// BaseSitemapURL is where the sitemap files are served from, BaseURL
+	// if empty. It is not used by NewFromConfig but by Publish.
+	BaseSitemapURL string `json:"baseSitemapURL,omitempty"`
+
+	MaxURLs         int  `json:"maxURLs,omitempty"`
+	MaxFileSize     int  `json:"maxFileSize,omitempty"`
+	MaxIndexEntries int  `json:"maxIndexEntries,omitempty"`
+	MaxTotalURLs    int  `json:"maxTotalURLs,omitempty"`
+	NestedIndexes   bool `json:"nestedIndexes,omitempty"`
+	// MaxHostURLs sets URL budgets by host. Overflow is "error", the
+	// default, "lowestPriority" or "oldest".
+	MaxHostURLs map[string]int `json:"maxHostURLs,omitempty"`
+	Overflow    string         `json:"overflow,omitempty"`
+	// ShardStrategy is "sequential", the default, "hash", "recency" or
+	// "date".
+	ShardStrategy string `json:"shardStrategy,omitempty"`
+
+	Groups       map[string]GroupConfig `json:"groups,omitempty"`
+	MinGroupURLs int                    `json:"minGroupURLs,omitempty"`
+	SortGroups   bool                   `json:"sortGroups,omitempty"`
+
+	SectionRules      []SectionRuleConfig `json:"sectionRules,omitempty"`
+	DefaultChangeFreq string              `json:"defaultChangeFreq,omitempty"`
+	DefaultPriority   *float64            `json:"defaultPriority,omitempty"`
+	Include           []string            `json:"include,omitempty"`
+	Exclude           []string            `json:"exclude,omitempty"`
+	StripQueryParams  []string            `json:"stripQueryParams,omitempty"`
+	Strict            bool                `json:"strict,omitempty"`
+	// Empty is "error", the default, "write" or "skip".
+	Empty string `json:"empty,omitempty"`
+
+	Output OutputConfig `json:"output"`
+}
+
+// GroupConfig sets the limits and base URLs of one group, see GroupLimits
+// and GroupBaseURLs.
+type GroupConfig struct {
+	MaxURLs        int    `json:"maxURLs,omitempty"`
+	MaxFileSize    int    `json:"maxFileSize,omitempty"`
+	BaseURL        string `json:"baseURL,omitempty"`
+	SitemapBaseURL string `json:"sitemapBaseURL,omitempty"`
+}
+
+// SectionRuleConfig is a SectionRule with a numeric priority.
+type SectionRuleConfig struct {
+	Pattern    string   `json:"pattern"`
+	Priority   *float64 `json:"priority,omitempty"`
+	ChangeFreq string   `json:"changeFreq,omitempty"`
+}
+
+// OutputConfig sets where and how the files are written.
+type OutputConfig struct {
+	// Backend is "dir", the default, to write the files to Dir with
+	// Write, or "put" to send them to PutURL with WritePut, which does
+	// not support the options keeping files on disk, such as StateFile.
+	Backend string `json:"backend,omitempty"`
+	PutURL  string `json:"putURL,omitempty"`
+
+	ShardDir      string `json:"shardDir,omitempty"`
+	ShardBaseURL  string `json:"shardBaseURL,omitempty"`
+	Gzip          bool   `json:"gzip,omitempty"`
+	KeepPlain     bool   `json:"keepPlain,omitempty"`
+	IndexPlain    bool   `json:"indexPlain,omitempty"`
+	Stylesheet    string `json:"stylesheet,omitempty"`
+	ManifestFile  string `json:"manifestFile,omitempty"`
+	ChecksumFiles bool   `json:"checksumFiles,omitempty"`
+	StateFile     string `json:"stateFile,omitempty"`
+	ChangesFile   string `json:"changesFile,omitempty"`
+	MetricsFile   string `json:"metricsFile,omitempty"`
+	ReviewFile    string `json:"reviewFile,omitempty"`
+	ArchiveDir    string `json:"archiveDir,omitempty"`
+	ArchiveRetain int    `json:"archiveRetain,omitempty"`
+	// PublicNames maps sitemap file names to the names the index
+	// references them by, see SitemapOptions.PublicNames.
+	PublicNames map[string]string `json:"publicNames,omitempty"`
+}
+
+// shardStrategies are the names of the ShardStrategy values in a Config.
+var shardStrategies = map[string]ShardStrategy{
+	"":           ShardSequential,
+	"sequential": ShardSequential,
+	"hash":       ShardByHash,
+	"recency":    ShardByRecency,
+	"date":       ShardByDate,
+}
+
+// overflowPolicies are the names of the OverflowPolicy values in a Config.
+var overflowPolicies = map[string]OverflowPolicy{
+	"":               OverflowError,
+	"error":          OverflowError,
+	"lowestPriority": OverflowLowestPriority,
+	"oldest":         OverflowOldest,
+}
+
+// emptyPolicies are the names of the EmptyPolicy values in a Config.
+var emptyPolicies = map[string]EmptyPolicy{
+	"":      EmptyError,
+	"error": EmptyError,
+	"write": EmptyWrite,
+	"skip":  EmptySkip,
+}
+
+// Output backends of a Config.
+const (
+	backendDir = "dir"
+	backendPut = "put"
+)
+
+// LoadConfig reads the JSON Config at filePath. Unknown fields are errors,
+// so a misspelled option is not silently ignored.
+func LoadConfig(filePath string) (*Config, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid config '%s': %v", filePath, err)
+	}
+	return &c, nil
+}
+
+// NewFromConfig returns a SitemapOptions configured by c, or an error if
+// the base URL, a pattern, a group, a section rule or the shard strategy
+// is invalid. Options a Config cannot express, such as hooks, can be set on
+// the result.
+func NewFromConfig(c *Config) (*SitemapOptions, error) {
+	s, err := New(c.Dir, c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	strategy, ok := shardStrategies[c.ShardStrategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown shard strategy '%s'", c.ShardStrategy)
+	}
+	s.ShardStrategy = strategy
+	if c.MaxURLs > 0 {
+		s.MaxURLs = c.MaxURLs
+	}
+	if c.MaxFileSize > 0 {
+		s.MaxFileSize = c.MaxFileSize
+	}
+	s.MaxIndexEntries = c.MaxIndexEntries
+	s.MaxTotalURLs = c.MaxTotalURLs
+	s.NestedIndexes = c.NestedIndexes
+	overflow, ok := overflowPolicies[c.Overflow]
+	if !ok {
+		return nil, fmt.Errorf("unknown overflow policy '%s'", c.Overflow)
+	}
+	s.MaxHostURLs = c.MaxHostURLs
+	s.Overflow = overflow
+	empty, ok := emptyPolicies[c.Empty]
+	if !ok {
+		return nil, fmt.Errorf("unknown empty policy '%s'", c.Empty)
+	}
+	s.Empty = empty
+
+	for name, group := range c.Groups {
+		if err := checkGroup(name); err != nil {
+			return nil, err
+		}
+		if group.MaxURLs > 0 || group.MaxFileSize > 0 {
+			if s.GroupLimits == nil {
+				s.GroupLimits = make(map[string]GroupLimit)
+			}
+			s.GroupLimits[name] = GroupLimit{MaxURLs: group.MaxURLs, MaxFileSize: group.MaxFileSize}
+		}
+		if group.BaseURL != "" || group.SitemapBaseURL != "" {
+			if s.GroupBaseURLs == nil {
+				s.GroupBaseURLs = make(map[string]GroupBaseURL)
+			}
+			s.GroupBaseURLs[name] = GroupBaseURL{BaseURL: group.BaseURL, SitemapBaseURL: group.SitemapBaseURL}
+		}
+	}
+	if err := s.checkGroupBaseURLs(); err != nil {
+		return nil, err
+	}
+	s.MinGroupURLs = c.MinGroupURLs
+	s.SortGroups = c.SortGroups
+
+	for _, rule := range c.SectionRules {
+		if _, err := NewPattern(rule.Pattern); err != nil {
+			return nil, err
+		}
+		if rule.ChangeFreq != "" && !changeFreqs[rule.ChangeFreq] {
+			return nil, fmt.Errorf("section rule '%s': invalid changefreq '%s'", rule.Pattern, rule.ChangeFreq)
+		}
+		section := SectionRule{Pattern: rule.Pattern, ChangeFreq: rule.ChangeFreq}
+		if rule.Priority != nil {
+			if *rule.Priority < 0 || *rule.Priority > 1 {
+				return nil, fmt.Errorf("section rule '%s': priority %v is outside 0.0 to 1.0", rule.Pattern, *rule.Priority)
+			}
+			section.Priority = formatPriority(*rule.Priority)
+		}
+		s.SectionRules = append(s.SectionRules, section)
+	}
+	if c.DefaultChangeFreq != "" && !changeFreqs[c.DefaultChangeFreq] {
+		return nil, fmt.Errorf("invalid default changefreq '%s'", c.DefaultChangeFreq)
+	}
+	s.DefaultChangeFreq = c.DefaultChangeFreq
+	if c.DefaultPriority != nil {
+		if *c.DefaultPriority < 0 || *c.DefaultPriority > 1 {
+			return nil, fmt.Errorf("default priority %v is outside 0.0 to 1.0", *c.DefaultPriority)
+		}
+		s.DefaultPriority = formatPriority(*c.DefaultPriority)
+	}
+	if _, err := compilePatterns(append(append([]string(nil), c.Include...), c.Exclude...)); err != nil {
+		return nil, err
+	}
+	s.Include = c.Include
+	s.Exclude = c.Exclude
+	s.StripQueryParams = c.StripQueryParams
+	s.Strict = c.Strict
+
+	out := c.Output
+	s.ShardDir = out.ShardDir
+	s.ShardBaseURL = out.ShardBaseURL
+	s.Gzip = out.Gzip
+	s.KeepPlain = out.KeepPlain
+	s.IndexPlain = out.IndexPlain
+	if out.Stylesheet != "" {
+		s.Stylesheet = out.Stylesheet
+	}
+	s.ManifestFile = out.ManifestFile
+	s.ChecksumFiles = out.ChecksumFiles
+	s.StateFile = out.StateFile
+	s.ChangesFile = out.ChangesFile
+	s.MetricsFile = out.MetricsFile
+	s.PublicNames = out.PublicNames
+	if err := s.checkPublicNames(); err != nil {
+		return nil, err
+	}
+	s.ReviewFile = out.ReviewFile
+	s.ArchiveDir = out.ArchiveDir
+	s.ArchiveRetain = out.ArchiveRetain
+	if _, err := s.checkLimits(); err != nil {
+		return nil, err
+	}
+	switch out.Backend {
+	case "", backendDir:
+		if out.PutURL != "" {
+			return nil, fmt.Errorf("putURL is only used by the put backend")
+		}
+	case backendPut:
+		if !isHTTPURL(out.PutURL) {
+			return nil, fmt.Errorf("put backend: invalid putURL '%s'", out.PutURL)
+		}
+		if err := s.checkPutOptions(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown output backend '%s'", out.Backend)
+	}
+	return s, nil
+}
+
+// Publish writes the sitemap set of s, as returned by NewFromConfig(c), to
+// the output backend of c, below BaseSitemapURL or else BaseURL. authorize
+// is the Authorize hook of the PutTarget of the put backend and may be nil.
+func (c *Config) Publish(ctx context.Context, s *SitemapOptions, authorize func(req *http.Request) error) error {
+	baseSitemapURL := c.BaseSitemapURL
+	if baseSitemapURL == "" {
+		baseSitemapURL = c.BaseURL
+	}
+	if c.Output.Backend == backendPut {
+		return s.WritePut(ctx, PutTarget{URL: c.Output.PutURL, Authorize: authorize}, baseSitemapURL)
+	}
+	return s.Write(baseSitemapURL)
+}
@@ -0,0 +1,125 @@
+package sitemap
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "sitemap.json")
+	config := `{
+  "dir": "` + filepath.ToSlash(filepath.Join(dir, "public")) + `",
+  "baseURL": "https://www.example.com",
+  "maxURLs": 2,
+  "shardStrategy": "hash",
+  "groups": {"blog": {"maxURLs": 1, "baseURL": "https://blog.example.com"}},
+  "sectionRules": [{"pattern": "/docs/*", "priority": 0.7, "changeFreq": "weekly"}],
+  "exclude": ["/admin/*"],
+  "output": {"shardDir": "sitemaps", "manifestFile": "manifest.json"}
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	sm, err := NewFromConfig(c)
+	if err != nil {
+		t.Fatalf("NewFromConfig: %v", err)
+	}
+	if sm.MaxURLs != 2 || sm.ShardStrategy != ShardByHash || sm.GroupLimits["blog"].MaxURLs != 1 || sm.GroupBaseURLs["blog"].BaseURL != "https://blog.example.com" {
+		t.Fatalf("Unexpected options: %+v", sm)
+	}
+	if len(sm.SectionRules) != 1 || sm.SectionRules[0].Priority != "0.7" || sm.ShardDir != "sitemaps" || sm.Stylesheet != "sitemap.xsl" {
+		t.Fatalf("Unexpected options: %+v", sm)
+	}
+	sm.AddURLs([]SitemapURL{{Loc: "/docs/a"}, {Loc: "/admin/b"}, {Loc: "/c"}, {Loc: "/post", Group: "blog"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "public", "manifest.json")); err != nil {
+		t.Fatalf("Expected the manifest: %v", err)
+	}
+	if report := sm.Report(); report.URLs != 3 || len(report.Excluded) != 1 {
+		t.Fatalf("Expected 3 URLs and 1 exclusion, got %d and %v", report.URLs, report.Excluded)
+	}
+
+	for _, bad := range []string{
+		`{"baseURL": "https://www.example.com", "maxURL": 2}`,
+		`{"baseURL": "https://www.example.com", "shardStrategy": "random"}`,
+		`{"baseURL": "https://www.example.com", "groups": {"news": {}}}`,
+		`{"baseURL": "https://www.example.com", "sectionRules": [{"pattern": "/a", "priority": 2}]}`,
+		`{"baseURL": "https://www.example.com", "maxURLs": 60000}`,
+		`{"baseURL": "ftp://www.example.com"}`,
+		`{"baseURL": "https://www.example.com", "output": {"backend": "s3"}}`,
+		`{"baseURL": "https://www.example.com", "output": {"backend": "put"}}`,
+		`{"baseURL": "https://www.example.com", "output": {"putURL": "https://dav.example.com/"}}`,
+		`{"baseURL": "https://www.example.com", "output": {"backend": "put", "putURL": "https://dav.example.com/", "stateFile": "state.json"}}`,
+	} {
+		if err := os.WriteFile(configPath, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := LoadConfig(configPath)
+		if err == nil {
+			_, err = NewFromConfig(c)
+		}
+		if err == nil {
+			t.Fatalf("Expected an error for %s", bad)
+		}
+		if strings.Contains(bad, "maxURL\"") && !strings.Contains(err.Error(), "unknown field") {
+			t.Fatalf("Expected an unknown field error, got %v", err)
+		}
+	}
+}
+
+func TestConfigPublishPut(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		received[r.URL.Path] = true
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "public")
+	c := &Config{
+		Dir:            dir,
+		BaseURL:        "https://www.example.com",
+		BaseSitemapURL: "https://www.example.com/sitemaps/",
+		Output:         OutputConfig{Backend: "put", PutURL: server.URL + "/dav/"},
+	}
+	sm, err := NewFromConfig(c)
+	if err != nil {
+		t.Fatalf("NewFromConfig: %v", err)
+	}
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	authorize := func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer secret")
+		return nil
+	}
+	if err := c.Publish(context.Background(), sm, authorize); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if !received["/dav/sitemap.xml"] {
+		t.Fatalf("Expected sitemap.xml to be sent, got %v", received)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected nothing written to Dir, got %v", err)
+	}
+}
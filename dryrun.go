@@ -0,0 +1,36 @@
+package sitemap
+
+import "fmt"
+
+// DryRun prepares the buffered URLs as Write would, resolving, cleaning,
+// filtering and deduplicating them, and returns the State the Write would
+// save with its report, without writing or changing anything. Compare the
+// State with that of an earlier run, or of the published set read back
+// with NewState, to review a change before publishing it. OnWarning is
+// called as during a Write.
+func (s *SitemapOptions) DryRun() (*State, *Report, error) {
+	if s.flush != nil {
+		return nil, nil, fmt.Errorf("URLs were flushed to disk over MemoryLimit; DryRun needs them all in memory")
+	}
+	limits, err := s.checkLimits()
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &Report{Limits: limits}
+	report.Excluded = append(report.Excluded, s.rejected...)
+
+	urls, err := s.bufferedURLs(report)
+	if err != nil {
+		return nil, nil, err
+	}
+	urls = append([]SitemapURL(nil), urls...)
+	urls, err = s.prepareURLs(urls, report, make(map[string]bool, len(urls)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if urls, err = s.applyBudgets(urls, 0, nil, report); err != nil {
+		return nil, nil, err
+	}
+	report.URLs = len(urls)
+	return NewState(urls), report, nil
+}
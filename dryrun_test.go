@@ -0,0 +1,43 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Exclude = []string{"/private/*"}
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b", LastMod: "2024-01-01"}, {Loc: "/a"}, {Loc: "/private/x"}})
+
+	buffered := slices.Clone(sm.URLs)
+	state, report, err := sm.DryRun()
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if report.URLs != 2 || len(report.Excluded) != 2 {
+		t.Fatalf("Expected 2 URLs and 2 exclusions, got %d and %v", report.URLs, report.Excluded)
+	}
+	changes := state.Changes(NewState([]SitemapURL{{Loc: "https://www.example.com/b"}, {Loc: "https://www.example.com/c"}}))
+	if !slices.Equal(changes.Added, []string{"https://www.example.com/a"}) || !slices.Equal(changes.Updated, []string{"https://www.example.com/b"}) || !slices.Equal(changes.Removed, []string{"https://www.example.com/c"}) {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+
+	// Nothing is written and the buffered URLs are untouched
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("Expected no files, got %d", len(entries))
+	}
+	if !reflect.DeepEqual(sm.URLs, buffered) {
+		t.Fatalf("Expected the buffered URLs unchanged, got %v", sm.URLs)
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap.xml")); err != nil {
+		t.Fatalf("Expected the sitemap after the dry run: %v", err)
+	}
+}
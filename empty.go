@@ -0,0 +1,40 @@
+package sitemap
+
+import "errors"
+
+// EmptyPolicy controls what Write does when it has no URL to write.
+type EmptyPolicy int
+
+const (
+	// EmptyError fails the Write with ErrEmptySitemap, so that a data
+	// pipeline producing nothing does not replace the published set.
+	EmptyError EmptyPolicy = iota
+	// EmptyWrite writes a sitemap with an empty urlset. The sitemap XSD
+	// requires one url, so the file is not validated against it.
+	EmptyWrite
+	// EmptySkip writes nothing, leaving the files of the previous run
+	// published. The report of the Write is kept; the State is not saved.
+	EmptySkip
+)
+
+// errSkippedEmpty ends a Write that writes nothing with EmptySkip. It is
+// not returned to callers.
+var errSkippedEmpty = errors.New("empty sitemap set skipped")
+
+// skipEmpty applies Empty to a Write left with n URLs to write, counting
+// those flushed over MemoryLimit. The set is empty if n is 0 and no
+// ExternalSitemaps are listed. With EmptySkip it reports true, and the
+// Write stops without writing, archiving or pruning anything, and without
+// rewriting MetricsFile or ReviewFile.
+func (s *SitemapOptions) skipEmpty(n int, report *Report) (bool, error) {
+	if n > 0 || len(s.ExternalSitemaps) > 0 {
+		return false, nil
+	}
+	switch s.Empty {
+	case EmptySkip:
+		return true, nil
+	case EmptyError:
+		return false, errorf(ErrEmptySitemap, "no URLs to write, %d were excluded; set Empty to write an empty sitemap", len(report.Excluded))
+	}
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package sitemap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmptyPolicy(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sm.Now = func() time.Time {
+		now = now.Add(time.Hour)
+		return now
+	}
+	if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrEmptySitemap) {
+		t.Fatalf("Expected ErrEmptySitemap by default, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap.xml")); !os.IsNotExist(err) {
+		t.Fatalf("Expected no sitemap to be written, got %v", err)
+	}
+
+	sm.Empty = EmptyWrite
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	urlSet, err := LoadURLSet(filepath.Join(dir, "sitemap.xml"))
+	if err != nil || len(urlSet.URLs) != 0 {
+		t.Fatalf("Expected an empty urlset, got %+v (%v)", urlSet, err)
+	}
+
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.ArchiveDir = "archive"
+	sm.MetricsFile = "sitemap.prom"
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	published, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archives, err := os.ReadDir(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := os.ReadFile(filepath.Join(dir, "sitemap.prom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// URLs all filtered out leave the set empty too
+	sm.Reset()
+	sm.Exclude = []string{"/private/*"}
+	sm.AddURL(SitemapURL{Loc: "/private/a"})
+	sm.Empty = EmptyError
+	if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrEmptySitemap) {
+		t.Fatalf("Expected ErrEmptySitemap, got %v", err)
+	}
+	sm.Empty = EmptySkip
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if report := sm.Report(); report.URLs != 0 || len(report.Excluded) != 1 {
+		t.Fatalf("Expected the report of the skipped Write, got %+v", report)
+	}
+	after, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil || string(after) != string(published) {
+		t.Fatalf("Expected the previous sitemap to stay published, err %v", err)
+	}
+
+	// A skipped Write neither archives the unchanged set nor rewrites metrics
+	if after, err := os.ReadDir(filepath.Join(dir, "archive")); err != nil || len(after) != len(archives) {
+		t.Fatalf("Expected %d archives after the skipped Write, got %d (%v)", len(archives), len(after), err)
+	}
+	if after, err := os.ReadFile(filepath.Join(dir, "sitemap.prom")); err != nil || string(after) != string(metrics) {
+		t.Fatalf("Expected the metrics file untouched, err %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors matched with errors.Is by the errors of limit violations,
+// invalid configuration and overlapping Writes. The errors keep their
+// detailed messages.
+var (
+	// ErrTooManyURLs is matched when URLs or sitemaps exceed what the
+	// files or the index may hold.
+	ErrTooManyURLs = errors.New("too many URLs")
+	// ErrInvalidBaseURL is matched when a base URL or base sitemap URL is
+	// not an absolute http(s) URL.
+	ErrInvalidBaseURL = errors.New("invalid base URL")
+	// ErrShardTooLarge is matched when a sitemap file or a single url
+	// element exceeds MaxFileSize.
+	ErrShardTooLarge = errors.New("sitemap file too large")
+	// ErrInvalidLimit is matched when MaxURLs, MaxFileSize,
+	// MaxIndexEntries or GroupLimits are beyond the protocol's limits.
+	ErrInvalidLimit = errors.New("invalid limit")
+	// ErrLocked is matched when another Write, in this process or another,
+	// is writing to the same Dir.
+	ErrLocked = errors.New("sitemap directory locked")
+	// ErrEmptySitemap is matched when Write has no URL to write and Empty
+	// is EmptyError.
+	ErrEmptySitemap = errors.New("empty sitemap")
+)
+
+// errReleased is returned after a Write dropped the URLs flushed over
+// MemoryLimit.
+var errReleased = errors.New("the URLs flushed over MemoryLimit were released by an earlier Write; call Reset and add them again")
+
+// ValidationError reports a URL rejected in Strict mode, with Loc set, or a
+// file failing schema validation, with File set.
+type ValidationError struct {
+	Loc     string
+	File    string
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	if e.File != "" {
+		return "XML validation against schema failed: " + e.Problem
+	}
+	return e.Loc + ": " + e.Problem
+}
+
+// limitError is an error with its own message matching a sentinel.
+type limitError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *limitError) Error() string {
+	return e.msg
+}
+
+func (e *limitError) Unwrap() error {
+	return e.sentinel
+}
+
+// errorf formats an error matching sentinel with errors.Is.
+func errorf(sentinel error, format string, args ...any) error {
+	return &limitError{msg: fmt.Sprintf(format, args...), sentinel: sentinel}
+}
@@ -0,0 +1,47 @@
+package sitemap
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	if _, err := New(t.TempDir(), "ftp://www.example.com"); !errors.Is(err, ErrInvalidBaseURL) {
+		t.Fatalf("Expected ErrInvalidBaseURL from New, got %v", err)
+	}
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("example.com/?q"); !errors.Is(err, ErrInvalidBaseURL) {
+		t.Fatalf("Expected ErrInvalidBaseURL from Write, got %v", err)
+	}
+
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.MaxIndexEntries = 2
+	for i := 0; i < 3; i++ {
+		sm.AddURL(SitemapURL{Loc: "/page-" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrTooManyURLs) {
+		t.Fatalf("Expected ErrTooManyURLs, got %v", err)
+	}
+
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.MaxFileSize = 300
+	for i := 0; i < 10; i++ {
+		sm.AddURL(SitemapURL{Loc: "/page-" + strconv.Itoa(i)})
+	}
+	err := sm.Write("https://www.example.com/")
+	var writeErr *WriteError
+	if !errors.Is(err, ErrShardTooLarge) || !errors.As(err, &writeErr) {
+		t.Fatalf("Expected a WriteError wrapping ErrShardTooLarge, got %v", err)
+	}
+
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.Strict = true
+	sm.AddURL(SitemapURL{Loc: "/b", ChangeFreq: "sometimes"})
+	var validationErr *ValidationError
+	if err := sm.Write("https://www.example.com/"); !errors.As(err, &validationErr) || validationErr.Loc != "https://www.example.com/b" {
+		t.Fatalf("Expected a ValidationError for /b, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ExternalCheck selects whether Write verifies the external sitemaps it
+// references and what happens to broken ones.
+type ExternalCheck int
+
+const (
+	// ExternalUnchecked references external sitemaps without fetching them.
+	ExternalUnchecked ExternalCheck = iota
+	// ExternalWarn fetches each external sitemap and records problems in
+	// Report.ExternalIssues, still referencing broken sitemaps.
+	ExternalWarn
+	// ExternalDrop is ExternalWarn, leaving broken sitemaps out of the
+	// index.
+	ExternalDrop
+	// ExternalFail fails the Write if an external sitemap is broken.
+	ExternalFail
+)
+
+// AddExternalSitemap references a sitemap published elsewhere, such as a
+// vendor's product sitemap, from the generated index. A zero lastmod is
+// omitted. Adding an external sitemap always produces an index.
+func (s *SitemapOptions) AddExternalSitemap(loc string, lastmod time.Time) {
+	sitemap := Sitemap{Loc: loc}
+	if !lastmod.IsZero() {
+		sitemap.LastMod = s.formatLastMod(lastmod)
+	}
+	mu := s.lock()
+	defer mu.Unlock()
+	s.ExternalSitemaps = append(s.ExternalSitemaps, sitemap)
+}
+
+// externalSitemaps returns ExternalSitemaps checked to be absolute http(s)
+// URLs.
+func (s *SitemapOptions) externalSitemaps() ([]Sitemap, error) {
+	sitemaps := make([]Sitemap, 0, len(s.ExternalSitemaps))
+	for _, sitemap := range s.ExternalSitemaps {
+		sitemap.Loc = strings.TrimSpace(sitemap.Loc)
+		if s.brokenExternal[sitemap.Loc] {
+			continue
+		}
+		u, err := url.Parse(sitemap.Loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid external sitemap URL '%s': %v", sitemap.Loc, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid external sitemap URL '%s': must be an absolute http(s) URL", sitemap.Loc)
+		}
+		sitemaps = append(sitemaps, sitemap)
+	}
+	return sitemaps, nil
+}
+
+// checkExternalSitemaps fetches and parses the external sitemaps according
+// to CheckExternal before any file is written, recording the URLs or
+// sitemaps each lists and its problems in report. A sitemap is broken if it
+// cannot be fetched or parsed, lists nothing, or lists URLs on a host other
+// than its own, which crawlers ignore.
+func (s *SitemapOptions) checkExternalSitemaps(ctx context.Context, report *Report) error {
+	s.brokenExternal = nil
+	if s.CheckExternal == ExternalUnchecked || len(s.ExternalSitemaps) == 0 {
+		return nil
+	}
+	sitemaps, err := s.externalSitemaps()
+	if err != nil {
+		return err
+	}
+
+	counts := make([]int, len(sitemaps))
+	problems := make([]string, len(sitemaps))
+	err = s.parallel(ctx, len(sitemaps), func(i int) error {
+		counts[i], problems[i] = s.checkExternalSitemap(ctx, sitemaps[i].Loc)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	report.ExternalURLs = make(map[string]int, len(sitemaps))
+	for i, sitemap := range sitemaps {
+		report.ExternalURLs[sitemap.Loc] = counts[i]
+		if problems[i] == "" {
+			continue
+		}
+		if s.CheckExternal == ExternalFail {
+			return fmt.Errorf("external sitemap %s is broken: %s", sitemap.Loc, problems[i])
+		}
+		s.addIssue(&report.ExternalIssues, WarningExternal, Issue{Loc: sitemap.Loc, Problem: problems[i]})
+		if s.CheckExternal == ExternalDrop {
+			if s.brokenExternal == nil {
+				s.brokenExternal = make(map[string]bool)
+			}
+			s.brokenExternal[sitemap.Loc] = true
+		}
+	}
+	return nil
+}
+
+// checkExternalSitemap fetches the sitemap or sitemap index at loc and
+// returns the number of entries it lists and its problem, if any.
+func (s *SitemapOptions) checkExternalSitemap(ctx context.Context, loc string) (int, string) {
+	body, err := s.fetch(ctx, loc)
+	if err != nil {
+		return 0, err.Error()
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	var locs []string
+	if index, err := ParseSitemapIndex(bytes.NewReader(data)); err == nil {
+		for _, sitemap := range index.Sitemaps {
+			locs = append(locs, sitemap.Loc)
+		}
+	} else if urlSet, err := ParseURLSet(bytes.NewReader(data)); err == nil {
+		for _, u := range urlSet.URLs {
+			locs = append(locs, u.Loc)
+		}
+	} else {
+		return 0, "not a sitemap or sitemap index: " + err.Error()
+	}
+	if len(locs) == 0 {
+		return 0, "lists no URLs"
+	}
+
+	sitemapURL, _ := url.Parse(loc)
+	for _, entry := range locs {
+		u, err := url.Parse(strings.TrimSpace(entry))
+		if err != nil || !strings.EqualFold(u.Host, sitemapURL.Host) {
+			return len(locs), fmt.Sprintf("lists %s, not on host %s", entry, sitemapURL.Host)
+		}
+	}
+	return len(locs), ""
+}
@@ -0,0 +1,94 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddExternalSitemap(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/about"})
+	sm.AddExternalSitemap("https://vendor.example.net/products.xml", time.Date(2024, 2, 3, 10, 0, 0, 0, time.UTC))
+	sm.AddExternalSitemap("https://vendor.example.net/brands.xml", time.Time{})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := string(data)
+	for _, want := range []string{
+		"<loc>https://www.example.com/sitemap_1.xml</loc>",
+		"<loc>https://vendor.example.net/products.xml</loc>\n    <lastmod>2024-02-03</lastmod>",
+		"<loc>https://vendor.example.net/brands.xml</loc>\n  </sitemap>",
+	} {
+		if !strings.Contains(index, want) {
+			t.Fatalf("index missing %q:\n%s", want, index)
+		}
+	}
+	if strings.Index(index, "sitemap_1.xml") > strings.Index(index, "vendor.example.net") {
+		t.Fatalf("external sitemaps should follow local ones:\n%s", index)
+	}
+
+	sm.AddExternalSitemap("/relative.xml", time.Time{})
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("expected an error for a relative external sitemap")
+	}
+}
+
+func TestCheckExternal(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/ok.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/a</loc></url><url><loc>%s/b</loc></url></urlset>`, server.URL, server.URL)
+	})
+	mux.HandleFunc("/offsite.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://elsewhere.example.org/a</loc></url></urlset>`)
+	})
+
+	write := func(check ExternalCheck) (*SitemapOptions, string, error) {
+		dir := t.TempDir()
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.CheckExternal = check
+		sm.AddURL(SitemapURL{Loc: "/"})
+		sm.AddExternalSitemap(server.URL+"/ok.xml", time.Time{})
+		sm.AddExternalSitemap(server.URL+"/missing.xml", time.Time{})
+		sm.AddExternalSitemap(server.URL+"/offsite.xml", time.Time{})
+		err := sm.Write("https://www.example.com/")
+		data, _ := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+		return sm, string(data), err
+	}
+
+	sm, index, err := write(ExternalWarn)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	report := sm.Report()
+	if len(report.ExternalIssues) != 2 || report.ExternalURLs[server.URL+"/ok.xml"] != 2 {
+		t.Fatalf("Expected two broken external sitemaps and a count, got %+v %v", report.ExternalIssues, report.ExternalURLs)
+	}
+	if !strings.Contains(index, "missing.xml") {
+		t.Fatalf("Expected broken sitemaps to stay referenced with ExternalWarn:\n%s", index)
+	}
+
+	if _, index, err = write(ExternalDrop); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if strings.Contains(index, "missing.xml") || strings.Contains(index, "offsite.xml") || !strings.Contains(index, "ok.xml") {
+		t.Fatalf("Expected only the working external sitemap with ExternalDrop:\n%s", index)
+	}
+
+	if _, _, err = write(ExternalFail); err == nil {
+		t.Fatal("Expected ExternalFail to fail the Write")
+	}
+}
@@ -0,0 +1,96 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// Declaration controls the XML declaration starting each file. Files are
+// always UTF-8 without a byte order mark; only the declaration varies.
+type Declaration struct {
+	// Omit writes no declaration, which implies UTF-8.
+	Omit bool
+	// OmitEncoding leaves out the encoding attribute, which defaults to
+	// UTF-8.
+	OmitEncoding bool
+	// LowercaseEncoding labels the encoding "utf-8" instead of "UTF-8".
+	LowercaseEncoding bool
+	// Standalone adds a standalone attribute.
+	Standalone Standalone
+}
+
+// Standalone is the standalone attribute of the XML declaration.
+type Standalone int
+
+const (
+	StandaloneOmit Standalone = iota // No standalone attribute
+	StandaloneYes                    // standalone="yes"
+	StandaloneNo                     // standalone="no"
+)
+
+// declaration returns the XML declaration line of generated files, or an
+// empty string if it is omitted.
+func (s *SitemapOptions) declaration() string {
+	d := s.Declaration
+	if d.Omit {
+		return ""
+	}
+	decl := `<?xml version="1.0"`
+	switch {
+	case d.OmitEncoding:
+	case d.LowercaseEncoding:
+		decl += ` encoding="utf-8"`
+	default:
+		decl += ` encoding="UTF-8"`
+	}
+	switch d.Standalone {
+	case StandaloneYes:
+		decl += ` standalone="yes"`
+	case StandaloneNo:
+		decl += ` standalone="no"`
+	}
+	return decl + "?>" + s.newline()
+}
+
+// indent returns the indentation of nested elements.
+func (s *SitemapOptions) indent() string {
+	switch {
+	case s.Compact:
+		return ""
+	case s.Indent == "":
+		return "  "
+	default:
+		return s.Indent
+	}
+}
+
+// newline returns the line ending of generated files.
+func (s *SitemapOptions) newline() string {
+	if s.CRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// lineBreak returns the line ending placed between elements, which is
+// empty for Compact output.
+func (s *SitemapOptions) lineBreak() string {
+	if s.Compact {
+		return ""
+	}
+	return s.newline()
+}
+
+// marshalXML encodes v with the configured indentation and line endings.
+// Line breaks inside character data and attributes are escaped by the
+// encoder, so every newline in its output is formatting.
+func (s *SitemapOptions) marshalXML(v any) ([]byte, error) {
+	if s.Compact {
+		return xml.Marshal(v)
+	}
+	data, err := xml.MarshalIndent(v, "", s.indent())
+	if err != nil || !s.CRLF {
+		return data, err
+	}
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n")), nil
+}
@@ -0,0 +1,78 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(sm *SitemapOptions)
+		expected string
+	}{
+		{"default", func(sm *SitemapOptions) {}, "\n  <url>\n    <loc>https://www.example.com/</loc>"},
+		{"tabs", func(sm *SitemapOptions) { sm.Indent = "\t" }, "\n\t<url>\n\t\t<loc>https://www.example.com/</loc>"},
+		{"compact", func(sm *SitemapOptions) { sm.Compact = true }, `"><url><loc>https://www.example.com/</loc>`},
+		{"crlf", func(sm *SitemapOptions) { sm.CRLF = true }, "\r\n  <url>\r\n    <loc>https://www.example.com/</loc>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sm := NewSitemapOptions(dir, "https://www.example.com")
+			sm.GeneratorComment = true
+			tt.setup(sm)
+			sm.AddURL(SitemapURL{Loc: "/", LastMod: "2024-01-01"})
+			if err := sm.Write("https://www.example.com/"); err != nil {
+				t.Fatalf("Error writing sitemap: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+			if err != nil {
+				t.Fatalf("Error reading sitemap: %v", err)
+			}
+			output := string(data)
+			if !strings.Contains(output, tt.expected) {
+				t.Fatalf("Expected %q in output:\n%q", tt.expected, output)
+			}
+			if sm.CRLF && strings.Contains(strings.ReplaceAll(output, "\r\n", ""), "\n") {
+				t.Fatalf("Expected only CRLF line endings:\n%q", output)
+			}
+		})
+	}
+}
+
+func TestDeclaration(t *testing.T) {
+	tests := []struct {
+		name        string
+		declaration Declaration
+		expected    string
+	}{
+		{"default", Declaration{}, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<?xml-stylesheet"},
+		{"lowercase", Declaration{LowercaseEncoding: true, Standalone: StandaloneYes}, "<?xml version=\"1.0\" encoding=\"utf-8\" standalone=\"yes\"?>\n"},
+		{"no encoding", Declaration{OmitEncoding: true, Standalone: StandaloneNo}, "<?xml version=\"1.0\" standalone=\"no\"?>\n"},
+		{"omitted", Declaration{Omit: true}, "<?xml-stylesheet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sm := NewSitemapOptions(dir, "https://www.example.com")
+			sm.Declaration = tt.declaration
+			sm.MaxURLs = 1
+			sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+			if err := sm.Write("https://www.example.com/"); err != nil {
+				t.Fatalf("Error writing sitemap: %v", err)
+			}
+			for _, name := range []string{"sitemap_index.xml", "sitemap_1.xml"} {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("Error reading %s: %v", name, err)
+				}
+				if !strings.HasPrefix(string(data), tt.expected) {
+					t.Fatalf("Expected %s to start with %q, got:\n%s", name, tt.expected, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package sitemap
+
+// freeSpace reports that free space cannot be determined on this platform,
+// so the check is skipped.
+func freeSpace(dir string) (int64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,127 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// CheckFreshness fetches the live sitemap or sitemap index at liveURL and
+// compares it with the files written to Dir, following the sitemaps of an
+// index. It returns an Issue for every sitemap or URL that is missing on
+// either side or whose lastmod differs, so an empty result means the last
+// Write has propagated. Sitemaps not written to Dir, such as external ones,
+// are only compared by lastmod.
+func (s *SitemapOptions) CheckFreshness(ctx context.Context, liveURL string) ([]Issue, error) {
+	localPath, ok := s.localSitemapPath(liveURL)
+	if !ok {
+		return nil, fmt.Errorf("no local file for %s in %s", liveURL, s.Dir)
+	}
+	var issues []Issue
+	if err := s.compareFreshness(ctx, liveURL, localPath, &issues); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// compareFreshness compares the live file at liveURL with the local file at
+// localPath, which may be a sitemap or a sitemap index.
+func (s *SitemapOptions) compareFreshness(ctx context.Context, liveURL, localPath string, issues *[]Issue) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	localIndex, err := ParseSitemapIndex(bytes.NewReader(data))
+	if err != nil {
+		localSet, err := ParseURLSet(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		liveSet, err := s.FetchURLSet(ctx, liveURL)
+		if err != nil {
+			return err
+		}
+		compareURLSets(liveURL, liveSet, localSet, issues)
+		return nil
+	}
+	liveIndex, err := s.FetchSitemapIndex(ctx, liveURL)
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]string, len(liveIndex.Sitemaps))
+	for _, sitemap := range liveIndex.Sitemaps {
+		live[sitemap.Loc] = sitemap.LastMod
+	}
+	for _, sitemap := range localIndex.Sitemaps {
+		lastMod, ok := live[sitemap.Loc]
+		delete(live, sitemap.Loc)
+		if !ok {
+			*issues = append(*issues, Issue{Loc: sitemap.Loc, Problem: fmt.Sprintf("missing from live index %s", liveURL)})
+			continue
+		}
+		if lastMod != sitemap.LastMod {
+			*issues = append(*issues, Issue{Loc: sitemap.Loc, Problem: fmt.Sprintf("live lastmod '%s' differs from local '%s'", lastMod, sitemap.LastMod)})
+		}
+		if filePath, ok := s.localSitemapPath(sitemap.Loc); ok {
+			if err := s.compareFreshness(ctx, sitemap.Loc, filePath, issues); err != nil {
+				return err
+			}
+		}
+	}
+	for _, sitemap := range liveIndex.Sitemaps {
+		if _, ok := live[sitemap.Loc]; ok {
+			*issues = append(*issues, Issue{Loc: sitemap.Loc, Problem: fmt.Sprintf("not in local index %s", filepath.Base(localPath))})
+		}
+	}
+	return nil
+}
+
+// compareURLSets reports the URLs missing from either set or whose lastmod
+// differs.
+func compareURLSets(liveURL string, liveSet, localSet *URLSet, issues *[]Issue) {
+	live := make(map[string]string, len(liveSet.URLs))
+	for _, u := range liveSet.URLs {
+		live[u.Loc] = u.LastMod
+	}
+	for _, u := range localSet.URLs {
+		lastMod, ok := live[u.Loc]
+		delete(live, u.Loc)
+		switch {
+		case !ok:
+			*issues = append(*issues, Issue{Loc: u.Loc, Problem: fmt.Sprintf("missing from live sitemap %s", liveURL)})
+		case lastMod != u.LastMod:
+			*issues = append(*issues, Issue{Loc: u.Loc, Problem: fmt.Sprintf("live lastmod '%s' differs from local '%s'", lastMod, u.LastMod)})
+		}
+	}
+	for _, u := range liveSet.URLs {
+		if _, ok := live[u.Loc]; ok {
+			*issues = append(*issues, Issue{Loc: u.Loc, Problem: fmt.Sprintf("not in local sitemap %s", path.Base(liveURL))})
+		}
+	}
+}
+
+// localSitemapPath returns the file in Dir or ShardDir that the sitemap at
+// loc was written to.
+func (s *SitemapOptions) localSitemapPath(loc string) (string, bool) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return "", false
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "", false
+	}
+	name = localName(s.PublicNames, name)
+	for _, dir := range []string{s.Dir, s.shardDir()} {
+		filePath := filepath.Join(dir, name)
+		if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+			return filePath, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,61 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	liveDir := t.TempDir()
+	server := httptest.NewServer(http.FileServer(http.Dir(liveDir)))
+	defer server.Close()
+
+	write := func(dir string, pages int, lastMod string) *SitemapOptions {
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.MaxURLs = 2
+		for i := 0; i < pages; i++ {
+			sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i), LastMod: lastMod})
+		}
+		if err := sm.Write(server.URL + "/"); err != nil {
+			t.Fatalf("Error writing sitemap: %v", err)
+		}
+		return sm
+	}
+
+	deployed := write(liveDir, 3, "2024-01-01")
+	issues, err := deployed.CheckFreshness(context.Background(), server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("Error checking freshness: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("Expected no drift for the deployed files, got %v", issues)
+	}
+
+	// A newer local run that has not been deployed yet
+	local := write(t.TempDir(), 5, "2024-02-01")
+	issues, err = local.CheckFreshness(context.Background(), server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("Error checking freshness: %v", err)
+	}
+	problems := map[string]string{}
+	for _, issue := range issues {
+		problems[issue.Loc] = issue.Problem
+	}
+	if !strings.Contains(problems[server.URL+"/sitemap_3.xml"], "missing from live index") {
+		t.Fatalf("Expected the new sitemap to be missing live, got %v", issues)
+	}
+	if !strings.Contains(problems["https://www.example.com/page-0"], "live lastmod '2024-01-01' differs") {
+		t.Fatalf("Expected a stale lastmod for page-0, got %v", issues)
+	}
+	if !strings.Contains(problems["https://www.example.com/page-3"], "missing from live sitemap") {
+		t.Fatalf("Expected page-3 to be missing live, got %v", issues)
+	}
+
+	if _, err := local.CheckFreshness(context.Background(), server.URL+"/missing.xml"); err == nil {
+		t.Fatalf("Expected an error for a sitemap without a local file")
+	}
+}
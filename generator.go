@@ -0,0 +1,45 @@
+package sitemap
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const generatorName = "github.com/coffyg/sitemap"
+
+// generatorVersion returns the version of this module as recorded in the
+// build info of the running binary, or "(devel)" when it is unknown.
+func generatorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == generatorName && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == generatorName {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// generatorComment returns the metadata comment written at the top of each
+// file when GeneratorComment is enabled. count is the number of entries in
+// the file (URLs for a sitemap, sitemaps for an index).
+func (s *SitemapOptions) generatorComment(count int) string {
+	if !s.GeneratorComment {
+		return ""
+	}
+	comment := fmt.Sprintf(" generator=%s %s generated=%s entries=%d ",
+		generatorName, generatorVersion(), s.now().Format(time.RFC3339), count)
+	// "--" is not allowed inside XML comments
+	comment = strings.ReplaceAll(comment, "--", "- -")
+	return "<!--" + comment + "-->" + s.newline()
+}
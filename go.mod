@@ -2,7 +2,6 @@ module github.com/coffyg/sitemap
 
 go 1.23.0
 
-require (
-	github.com/lestrrat-go/libxml2 v0.0.0-20240905100032-c934e3fcb9d3 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
-)
+require github.com/lestrrat-go/libxml2 v0.0.0-20240905100032-c934e3fcb9d3
+
+require github.com/pkg/errors v0.9.1 // indirect
@@ -0,0 +1,81 @@
+package sitemap
+
+import "fmt"
+
+// GroupBaseURL overrides where the URLs and sitemap files of one group
+// live, such as a blog group on blog.example.com.
+type GroupBaseURL struct {
+	// BaseURL, if set, replaces BaseURL for the group: relative locs,
+	// alternates and canonical URLs resolve against it and locs are checked
+	// against its host.
+	BaseURL string
+	// SitemapBaseURL, if set, is the URL the group's sitemap files are
+	// served from, replacing the shard base URL in index entries and as
+	// the scope their URLs are checked against. The files are still written
+	// to ShardDir; publishing them there is up to the caller, and crawlers
+	// only accept a sitemap on another host than the index if robots.txt
+	// of that host references it.
+	SitemapBaseURL string
+}
+
+// groupBase returns the overrides for the URLs of the given group.
+func (s *SitemapOptions) groupBase(group string) GroupBaseURL {
+	if name := groupFileName(group); name != "" {
+		for g, base := range s.GroupBaseURLs {
+			if groupFileName(g) == name {
+				return base
+			}
+		}
+	}
+	return GroupBaseURL{}
+}
+
+// baseURL returns the base URL of the URLs of the given group.
+func (s *SitemapOptions) baseURL(group string) string {
+	if base := s.groupBase(group).BaseURL; base != "" {
+		return base
+	}
+	return s.BaseURL
+}
+
+// checkGroupBaseURLs returns an error if any of GroupBaseURLs is invalid.
+func (s *SitemapOptions) checkGroupBaseURLs() error {
+	for group, base := range s.GroupBaseURLs {
+		for _, raw := range []string{base.BaseURL, base.SitemapBaseURL} {
+			if raw == "" {
+				continue
+			}
+			if _, err := parseBaseURL(raw); err != nil {
+				return fmt.Errorf("group '%s': %w", group, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkGroupScopes runs checkScope for the URLs of groups whose files are
+// served from their own SitemapBaseURL against it, and for the others
+// against scope.
+func (s *SitemapOptions) checkGroupScopes(urls []SitemapURL, scope string, report *Report) error {
+	if len(s.GroupBaseURLs) == 0 {
+		return s.checkScope(urls, scope, report)
+	}
+	var scopes []string
+	byScope := make(map[string][]SitemapURL)
+	for _, u := range urls {
+		urlScope := scope
+		if base := s.groupBase(u.Group).SitemapBaseURL; base != "" {
+			urlScope = base
+		}
+		if _, ok := byScope[urlScope]; !ok {
+			scopes = append(scopes, urlScope)
+		}
+		byScope[urlScope] = append(byScope[urlScope], u)
+	}
+	for _, urlScope := range scopes {
+		if err := s.checkScope(byScope[urlScope], urlScope, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
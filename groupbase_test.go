@@ -0,0 +1,54 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGroupBaseURLs(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Strict = true
+	sm.GroupBaseURLs = map[string]GroupBaseURL{
+		"blog": {BaseURL: "https://blog.example.com", SitemapBaseURL: "https://blog.example.com/sitemaps/"},
+	}
+	sm.AddURL(SitemapURL{Loc: "/about"})
+	sm.AddURL(SitemapURL{Loc: "/sitemaps/hello", Group: "blog", Alternates: []Alternate{{Hreflang: "fr", Href: "/sitemaps/fr/hello"}}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<loc>https://www.example.com/sitemap_1.xml</loc>", "<loc>https://blog.example.com/sitemaps/sitemap_blog_1.xml</loc>"} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("Expected %s in the index:\n%s", want, index)
+		}
+	}
+	blog, err := os.ReadFile(filepath.Join(dir, "sitemap_blog_1.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<loc>https://blog.example.com/sitemaps/hello</loc>", `href="https://blog.example.com/sitemaps/fr/hello"`} {
+		if !strings.Contains(string(blog), want) {
+			t.Errorf("Expected %s in the blog sitemap:\n%s", want, blog)
+		}
+	}
+
+	// Blog URLs outside the directory of the blog sitemaps are out of scope
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/about"})
+	sm.AddURL(SitemapURL{Loc: "/hello", Group: "blog"})
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected a Strict error for a blog URL outside the scope of its sitemap")
+	}
+
+	sm.GroupBaseURLs["blog"] = GroupBaseURL{BaseURL: "ftp://blog.example.com"}
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected an error for an invalid group base URL")
+	}
+}
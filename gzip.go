@@ -0,0 +1,251 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gzipExt is appended to the names of sitemap files written with Gzip.
+const gzipExt = ".gz"
+
+// sitemapFileName returns the name a sitemap file or index called name is
+// referenced as: name itself, or name.gz with Gzip unless IndexPlain
+// references the uncompressed copies kept with KeepPlain.
+func (s *SitemapOptions) sitemapFileName(name string) string {
+	if s.Gzip && !s.indexPlain() {
+		return name + gzipExt
+	}
+	return name
+}
+
+// indexPlain reports whether the indexes reference the uncompressed copies
+// of gzipped files.
+func (s *SitemapOptions) indexPlain() bool {
+	return s.Gzip && s.KeepPlain && s.IndexPlain
+}
+
+// storedFiles returns the paths storeXMLFile writes the sitemap file or
+// index at filePath to, leaving out VerifyDir and Mirrors.
+func (s *SitemapOptions) storedFiles(filePath string) []string {
+	switch {
+	case !s.Gzip:
+		return []string{filePath}
+	case s.KeepPlain:
+		return []string{filePath, filePath + gzipExt}
+	}
+	return []string{filePath + gzipExt}
+}
+
+// verifyDirPath returns the path of VerifyDir, resolving relative paths
+// against Dir, or "" if no uncompressed copies are kept.
+func (s *SitemapOptions) verifyDirPath() string {
+	if !s.Gzip {
+		return ""
+	}
+	if s.VerifyDir == "" || filepath.IsAbs(s.VerifyDir) {
+		return s.VerifyDir
+	}
+	return filepath.Join(s.Dir, s.VerifyDir)
+}
+
+// Codec compresses sitemap files, such as gzip, zstd or brotli. Crawlers
+// only read gzip, so other codecs are for Mirrors.
+type Codec interface {
+	// Ext is appended to the names of compressed files, such as ".zst".
+	Ext() string
+	// NewWriter returns a writer compressing to w. Closing it flushes the
+	// compressed data but not w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// GzipCodec is the gzip Codec of compress/gzip.
+var GzipCodec Codec = gzipCodec{}
+
+// gzipCodec compresses with newWriter, or compress/gzip's default writer if
+// nil.
+type gzipCodec struct {
+	newWriter func(w io.Writer) io.WriteCloser
+}
+
+func (gzipCodec) Ext() string { return gzipExt }
+
+func (c gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.newWriter != nil {
+		return c.newWriter(w), nil
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// Mirror is a directory receiving a copy of every sitemap file and index
+// Write publishes, laid out as in Dir and compressed with Codec, for
+// internal mirrors and private consumers reading other formats than gzip.
+type Mirror struct {
+	// Dir is the directory of the copies. Relative paths are resolved
+	// against the Dir of the SitemapOptions.
+	Dir string
+	// Codec compresses the copies, which are named after the published
+	// files without .gz and with the Ext of Codec.
+	Codec Codec
+}
+
+// mirrorDirPath returns the path of the Dir of m, resolving relative paths
+// against Dir.
+func (s *SitemapOptions) mirrorDirPath(m Mirror) string {
+	if filepath.IsAbs(m.Dir) {
+		return m.Dir
+	}
+	return filepath.Join(s.Dir, m.Dir)
+}
+
+// compress returns data compressed with codec.
+func compress(codec Codec, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodedFile is a sitemap file or index ready to be stored: its XML and,
+// with Gzip, the compressed XML, followed by its copy for each of Mirrors.
+type encodedFile struct {
+	plain    []byte
+	gzipped  []byte
+	mirrored [][]byte
+}
+
+// encodeXMLFile compresses data with Gzip and the codecs of Mirrors.
+func (s *SitemapOptions) encodeXMLFile(data []byte) (encodedFile, error) {
+	defer s.since(phaseCompress, time.Now())
+	file := encodedFile{plain: data}
+	for _, mirror := range s.Mirrors {
+		if mirror.Codec == nil {
+			return encodedFile{}, fmt.Errorf("mirror %s has no Codec", mirror.Dir)
+		}
+		compressed, err := compress(mirror.Codec, data)
+		if err != nil {
+			return encodedFile{}, fmt.Errorf("failed to compress for mirror %s: %v", mirror.Dir, err)
+		}
+		file.mirrored = append(file.mirrored, compressed)
+	}
+	if !s.Gzip {
+		return file, nil
+	}
+	gzipped, err := compress(gzipCodec{newWriter: s.NewGzipWriter}, data)
+	if err != nil {
+		return encodedFile{}, err
+	}
+	file.gzipped = gzipped
+	return file, nil
+}
+
+// writeXMLFile writes the sitemap file or index at filePath, see
+// storeXMLFile.
+func (s *SitemapOptions) writeXMLFile(filePath string, data []byte) error {
+	file, err := s.encodeXMLFile(data)
+	if err != nil {
+		return err
+	}
+	return s.storeXMLFile(filePath, file)
+}
+
+// storeXMLFile writes file to filePath and its copies to Mirrors. With
+// Gzip, it writes filePath.gz instead, keeps filePath as well with
+// KeepPlain or else removes a plain file left by an earlier run, and
+// copies the uncompressed data to VerifyDir, if set.
+func (s *SitemapOptions) storeXMLFile(filePath string, file encodedFile) error {
+	defer s.since(phasePublish, time.Now())
+	if err := s.storeMirrored(filePath, file); err != nil {
+		return err
+	}
+	if !s.Gzip {
+		return s.tx.writeFile(filePath, file.plain)
+	}
+	if err := s.tx.writeFile(filePath+gzipExt, file.gzipped); err != nil {
+		return err
+	}
+	if s.KeepPlain {
+		if err := s.tx.writeFile(filePath, file.plain); err != nil {
+			return err
+		}
+	} else if err := s.tx.removeFile(filePath); err != nil {
+		return err
+	}
+
+	verifyDir := s.verifyDirPath()
+	if verifyDir == "" {
+		return nil
+	}
+	return s.storeCopy(verifyDir, filePath, file.plain)
+}
+
+// storeMirrored writes the copies of the file at filePath to Mirrors.
+func (s *SitemapOptions) storeMirrored(filePath string, file encodedFile) error {
+	for i, mirror := range s.Mirrors {
+		if err := s.storeCopy(s.mirrorDirPath(mirror), filePath+mirror.Codec.Ext(), file.mirrored[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeCopy writes data to the path in dir that filePath has in Dir.
+func (s *SitemapOptions) storeCopy(dir, filePath string, data []byte) error {
+	rel, err := filepath.Rel(s.Dir, filePath)
+	if err != nil {
+		return err
+	}
+	copyPath := filepath.Join(dir, rel)
+	if err := s.tx.mkdirAll(filepath.Dir(copyPath)); err != nil {
+		return err
+	}
+	return s.tx.writeFile(copyPath, data)
+}
+
+// readXMLFile reads the sitemap file or index written to filePath by
+// writeXMLFile, decompressing it if it is gzipped.
+func (s *SitemapOptions) readXMLFile(filePath string) ([]byte, error) {
+	if s.Gzip && !strings.HasSuffix(filePath, gzipExt) {
+		filePath += gzipExt
+	}
+	if s.tx != nil {
+		s.tx.file = filePath
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil || !strings.HasSuffix(filePath, gzipExt) {
+		return data, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// staleSitemaps returns the plain and gzipped sitemap files matching the
+// glob pattern, which names the plain files.
+func staleSitemaps(pattern string) ([]string, error) {
+	plain, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	gzipped, err := filepath.Glob(pattern + gzipExt)
+	if err != nil {
+		return nil, err
+	}
+	return append(plain, gzipped...), nil
+}
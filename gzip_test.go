@@ -0,0 +1,249 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGzipOnly(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	// Switching to Gzip replaces the plain files
+	sm.Gzip = true
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap.xml")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the plain sitemap to be removed, got %v", err)
+	}
+	if _, err := LoadURLSet(filepath.Join(dir, "sitemap.xml.gz")); err != nil {
+		t.Fatalf("Expected a gzipped sitemap: %v", err)
+	}
+
+	sm.Reset()
+	sm.MaxURLs = 1
+	sm.ShardDir = "shards"
+	sm.VerifyDir = "verify"
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	sm.AddURL(SitemapURL{Loc: "/b"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml.gz"))
+	if err != nil {
+		t.Fatalf("Expected a gzipped index: %v", err)
+	}
+	if len(index.Sitemaps) != 2 || index.Sitemaps[0].Loc != "https://www.example.com/shards/sitemap_1.xml.gz" {
+		t.Fatalf("Expected the index to reference the gzipped shards, got %+v", index.Sitemaps)
+	}
+	for _, name := range []string{"sitemap_index.xml", "shards/sitemap_1.xml", "shards/sitemap_2.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected no plain %s to be published, got %v", name, err)
+		}
+		plain, err := os.ReadFile(filepath.Join(dir, "verify", name))
+		if err != nil {
+			t.Fatalf("Expected an uncompressed copy of %s: %v", name, err)
+		}
+		gzipped, err := sm.readXMLFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(plain) != string(gzipped) {
+			t.Fatalf("Expected the copy of %s to match the published file", name)
+		}
+		if !strings.HasPrefix(string(plain), "<?xml") {
+			t.Fatalf("Expected plain XML in the copy of %s", name)
+		}
+	}
+}
+
+func TestKeepPlain(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.Gzip = true
+	sm.KeepPlain = true
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	for _, name := range []string{"sitemap_index.xml", "sitemap_1.xml", "sitemap_2.xml"} {
+		plain, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected a plain %s: %v", name, err)
+		}
+		gzipped, err := sm.readXMLFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(plain) != string(gzipped) {
+			t.Fatalf("Expected the variants of %s to hold the same XML", name)
+		}
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap index: %v", err)
+	}
+	if index.Sitemaps[0].Loc != "https://www.example.com/sitemap_1.xml.gz" {
+		t.Fatalf("Expected the index to reference the gzipped shards, got %+v", index.Sitemaps)
+	}
+
+	// IndexPlain references the plain variants instead
+	sm.IndexPlain = true
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	index, err = LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml.gz"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap index: %v", err)
+	}
+	if index.Sitemaps[0].Loc != "https://www.example.com/sitemap_1.xml" || index.Sitemaps[1].Loc != "https://www.example.com/sitemap_2.xml" {
+		t.Fatalf("Expected the index to reference the plain shards, got %+v", index.Sitemaps)
+	}
+
+	// WriteIndexOnly discovers the variant the index prefers
+	if err := sm.WriteIndexOnly("https://www.example.com/", nil); err != nil {
+		t.Fatalf("Error writing index: %v", err)
+	}
+	if index, err = LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml")); err != nil || len(index.Sitemaps) != 2 || index.Sitemaps[0].Loc != "https://www.example.com/sitemap_1.xml" {
+		t.Fatalf("Expected the discovered plain shards, got %+v (%v)", index, err)
+	}
+	sm.IndexPlain = false
+	if err := sm.WriteIndexOnly("https://www.example.com/", nil); err != nil {
+		t.Fatalf("Error writing index: %v", err)
+	}
+	if index, err = LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml")); err != nil || len(index.Sitemaps) != 2 || index.Sitemaps[0].Loc != "https://www.example.com/sitemap_1.xml.gz" {
+		t.Fatalf("Expected the discovered gzipped shards, got %+v (%v)", index, err)
+	}
+}
+
+func TestGzipIncrementalWriter(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewSitemapOptions(dir, "https://www.example.com")
+	opts.Gzip = true
+	w, err := NewIncrementalWriter(opts, "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("Error creating incremental writer: %v", err)
+	}
+	w.Upsert(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml.gz"))
+	if err != nil {
+		t.Fatalf("Expected a gzipped index: %v", err)
+	}
+	if len(index.Sitemaps) != 1 || index.Sitemaps[0].Loc != "https://www.example.com/sitemap_1.xml.gz" {
+		t.Fatalf("Expected the index to reference the gzipped sitemap, got %+v", index.Sitemaps)
+	}
+}
+
+func TestEncodeWorkers(t *testing.T) {
+	write := func(workers int) (string, int) {
+		dir := t.TempDir()
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.Gzip = true
+		sm.MaxURLs = 3
+		sm.EncodeWorkers = workers
+		var mu sync.Mutex
+		compressors := 0
+		sm.NewGzipWriter = func(w io.Writer) io.WriteCloser {
+			mu.Lock()
+			compressors++
+			mu.Unlock()
+			return gzip.NewWriter(w)
+		}
+		for i := 0; i < 20; i++ {
+			sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i), LastMod: "2024-06-01"})
+		}
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Error writing sitemap: %v", err)
+		}
+		var all strings.Builder
+		for i := 1; i <= 7; i++ {
+			data, err := os.ReadFile(filepath.Join(dir, "sitemap_"+strconv.Itoa(i)+".xml.gz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			all.Write(data)
+		}
+		return all.String(), compressors
+	}
+
+	sequential, _ := write(1)
+	parallel, compressors := write(4)
+	if parallel != sequential {
+		t.Fatal("Expected parallel encoding to produce the same files")
+	}
+	if compressors != 8 {
+		t.Fatalf("Expected NewGzipWriter for 7 sitemaps and the index, got %d calls", compressors)
+	}
+}
+
+// zlibCodec stands in for codecs such as zstd in tests.
+type zlibCodec struct{}
+
+func (zlibCodec) Ext() string { return ".zz" }
+
+func (zlibCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func TestMirrors(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Gzip = true
+	sm.ShardDir = "shards"
+	sm.MaxURLs = 1
+	sm.Mirrors = []Mirror{{Dir: "mirror", Codec: zlibCodec{}}}
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	// The published files stay gzipped
+	for _, name := range []string{"sitemap_index.xml.gz", "shards/sitemap_1.xml.gz", "shards/sitemap_2.xml.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected published file %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"sitemap_index.xml", filepath.Join("shards", "sitemap_2.xml")} {
+		f, err := os.Open(filepath.Join(dir, "mirror", name+".zz"))
+		if err != nil {
+			t.Fatalf("Expected mirrored copy of %s: %v", name, err)
+		}
+		zr, err := zlib.NewReader(f)
+		if err != nil {
+			t.Fatalf("Error reading mirrored copy of %s: %v", name, err)
+		}
+		copied, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Error reading mirrored copy of %s: %v", name, err)
+		}
+		published, err := sm.readXMLFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", name, err)
+		}
+		if string(copied) != string(published) {
+			t.Fatalf("Mirrored copy of %s differs from the published file", name)
+		}
+	}
+
+	sm.Mirrors = []Mirror{{Dir: "mirror"}}
+	if err := sm.Write("https://www.example.com/"); err == nil || !strings.Contains(err.Error(), "no Codec") {
+		t.Fatalf("Expected an error for a mirror without Codec, got %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler serves the files written by Write: sitemaps, the sitemap index,
+// their gzipped variants and the stylesheet. Only the base name of the
+// request path is used, so it can be mounted under any prefix.
+//
+// Handler is a plain http.Handler and mounts directly in net/http and chi:
+//
+//	mux.Handle("/sitemaps/", sm.Handler())
+//	r.Handle("/sitemaps/*", sm.Handler()) // chi
+//
+// and in gin through gin.WrapH:
+//
+//	router.GET("/sitemaps/*file", gin.WrapH(sm.Handler()))
+type Handler struct {
+	Dir        string
+	ShardDir   string // Subdirectory of Dir also searched for sitemap files
+	Stylesheet string
+	// PublicNames maps file names to the names they are also served under,
+	// as SitemapOptions.PublicNames.
+	PublicNames map[string]string
+	// FS, if set, is the file system Dir is read from instead of the disk,
+	// such as an embed.FS holding a sitemap set generated at build time.
+	// Dir is then a slash-separated path in FS, "." for its root.
+	FS fs.FS
+	// Cache serves files, and the gzip variants compressed on the fly, from
+	// memory until Invalidate or InvalidateAll is called after regeneration.
+	// Where each name resolves to, in Dir or ShardDir, and names with no
+	// file are remembered as well, so repeated requests never touch the
+	// disk.
+	Cache bool
+	// RefreshInterval, if set, serves files from memory and checks them for
+	// changes on disk at most this often, so files regenerated by another
+	// process are picked up without a disk read on every request.
+	RefreshInterval time.Duration
+
+	cacheMu sync.Mutex
+	files   map[string]*cacheEntry // By requested local name
+	missing int                    // Entries of names with no file
+}
+
+// maxMissingEntries caps the names with no file a caching Handler
+// remembers, so requests for arbitrary names cannot grow the cache without
+// bound. Further missing names are looked up on every request.
+const maxMissingEntries = 1024
+
+// NewHandler returns a Handler serving the sitemap files in dir.
+func NewHandler(dir string) *Handler {
+	return &Handler{
+		Dir:        dir,
+		Stylesheet: "sitemap.xsl",
+	}
+}
+
+// NewFSHandler returns a Handler serving the sitemap files in the directory
+// dir of fsys, for example one embedded with go:embed:
+//
+//	//go:embed sitemaps
+//	var sitemaps embed.FS
+//
+//	mux.Handle("/sitemaps/", sitemap.NewFSHandler(sitemaps, "sitemaps"))
+func NewFSHandler(fsys fs.FS, dir string) *Handler {
+	h := NewHandler(dir)
+	h.FS = fsys
+	return h
+}
+
+// Handler returns a Handler serving the files written by s.
+func (s *SitemapOptions) Handler() *Handler {
+	return &Handler{
+		Dir:         s.Dir,
+		ShardDir:    s.ShardDir,
+		Stylesheet:  s.Stylesheet,
+		PublicNames: s.PublicNames,
+	}
+}
+
+// HandlerFunc returns h as an http.HandlerFunc.
+func (h *Handler) HandlerFunc() http.HandlerFunc {
+	return h.ServeHTTP
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := localName(h.PublicNames, path.Base(r.URL.Path))
+	switch {
+	case name == path.Base(h.Stylesheet):
+		h.serveFile(w, r, name, "text/xsl; charset=utf-8", "")
+	case strings.HasSuffix(name, sitemapExt+".gz"):
+		h.serveGzipFile(w, r, name)
+	case strings.HasSuffix(name, sitemapExt):
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) && h.exists(name+".gz") {
+			h.serveFile(w, r, name+".gz", "application/xml; charset=utf-8", "gzip")
+			return
+		}
+		h.serveFile(w, r, name, "application/xml; charset=utf-8", "")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveGzipFile serves a .xml.gz file, compressing the plain sitemap on the
+// fly when only the uncompressed variant exists.
+func (h *Handler) serveGzipFile(w http.ResponseWriter, r *http.Request, name string) {
+	if h.exists(name) {
+		h.serveFile(w, r, name, "application/gzip", "")
+		return
+	}
+
+	plain := strings.TrimSuffix(name, ".gz")
+	if h.caching() {
+		file, ok := h.cachedFile(plain)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		http.ServeContent(w, r, name, file.modTime, bytes.NewReader(file.gzipped()))
+		return
+	}
+
+	f, err := h.open(h.filePath(plain))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	if r.Method == http.MethodHead {
+		return
+	}
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	io.Copy(gz, f)
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name, contentType, contentEncoding string) {
+	if h.caching() {
+		file, ok := h.cachedFile(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if contentEncoding != "" {
+			w.Header().Set("Content-Encoding", contentEncoding)
+		}
+		http.ServeContent(w, r, name, file.modTime, bytes.NewReader(file.data))
+		return
+	}
+
+	f, err := h.open(h.filePath(name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		w.Header().Set("Content-Encoding", contentEncoding)
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+func (h *Handler) exists(name string) bool {
+	if h.caching() {
+		_, ok := h.cachedFile(name)
+		return ok
+	}
+	info, err := h.stat(h.filePath(name))
+	return err == nil && !info.IsDir()
+}
+
+// filePath returns the path of name in Dir, or in ShardDir if it only
+// exists there.
+func (h *Handler) filePath(name string) string {
+	filePath := h.join(h.Dir, name)
+	if h.ShardDir == "" {
+		return filePath
+	}
+	if _, err := h.stat(filePath); errors.Is(err, fs.ErrNotExist) {
+		return h.join(h.Dir, h.ShardDir, name)
+	}
+	return filePath
+}
+
+// filePaths returns the paths name may have, in Dir and then in ShardDir.
+func (h *Handler) filePaths(name string) []string {
+	if h.ShardDir == "" {
+		return []string{h.join(h.Dir, name)}
+	}
+	return []string{h.join(h.Dir, name), h.join(h.Dir, h.ShardDir, name)}
+}
+
+// join joins path elements with the separator of the file system served.
+func (h *Handler) join(elem ...string) string {
+	if h.FS == nil {
+		return filepath.Join(elem...)
+	}
+	return path.Join(elem...)
+}
+
+// open opens filePath on the disk or in FS.
+func (h *Handler) open(filePath string) (fs.File, error) {
+	if h.FS == nil {
+		return os.Open(filePath)
+	}
+	return h.FS.Open(filePath)
+}
+
+// stat describes filePath on the disk or in FS.
+func (h *Handler) stat(filePath string) (fs.FileInfo, error) {
+	if h.FS == nil {
+		return os.Stat(filePath)
+	}
+	return fs.Stat(h.FS, filePath)
+}
+
+// readFile reads filePath from the disk or from FS.
+func (h *Handler) readFile(filePath string) ([]byte, error) {
+	if h.FS == nil {
+		return os.ReadFile(filePath)
+	}
+	return fs.ReadFile(h.FS, filePath)
+}
+
+// acceptsGzip reports whether the client accepts gzip content encoding.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		params = strings.ReplaceAll(params, " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// Invalidate drops the cached copies of the file called name and its gzip
+// variant, and where they were found, so the next request looks them up on
+// disk again.
+func (h *Handler) Invalidate(name string) {
+	plain := strings.TrimSuffix(path.Base(name), ".gz")
+	for _, variant := range []string{plain, plain + ".gz"} {
+		h.storeCachedFile(variant, nil)
+	}
+}
+
+// InvalidateAll drops every cached file and lookup.
+func (h *Handler) InvalidateAll() {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	h.files = nil
+	h.missing = 0
+}
+
+func (h *Handler) caching() bool {
+	return h.Cache || h.RefreshInterval > 0
+}
+
+// cachedFile is a file held in memory by a caching Handler.
+type cachedFile struct {
+	path    string
+	data    []byte
+	modTime time.Time
+	size    int64
+	gz      *lazyGzip
+}
+
+// cacheEntry is the cached lookup of a requested name: the file it resolves
+// to, or nil if there is none, and when that was last checked.
+type cacheEntry struct {
+	file    *cachedFile
+	checked time.Time
+}
+
+// lazyGzip holds the compressed content of a cached file, computed the
+// first time its gzip variant is requested.
+type lazyGzip struct {
+	once sync.Once
+	data []byte
+}
+
+// gzipped returns the gzip-compressed content of the file.
+func (f *cachedFile) gzipped() []byte {
+	f.gz.once.Do(func() {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(f.data)
+		gz.Close()
+		f.gz.data = buf.Bytes()
+	})
+	return f.gz.data
+}
+
+// cachedFile returns the in-memory copy of the file called name, which is
+// looked up in Dir and ShardDir only when the name is not cached yet. With
+// a RefreshInterval, the lookup is repeated once the interval has passed
+// since the last check and the file reloaded if it moved or its
+// modification time or size changed; otherwise the entry, found or not, is
+// kept until invalidated. Entries are replaced, never modified, so
+// concurrent requests see either the old or the new content in full.
+func (h *Handler) cachedFile(name string) (*cachedFile, bool) {
+	now := time.Now()
+	h.cacheMu.Lock()
+	entry := h.files[name]
+	h.cacheMu.Unlock()
+	if entry != nil && (h.RefreshInterval <= 0 || now.Sub(entry.checked) < h.RefreshInterval) {
+		return entry.file, entry.file != nil
+	}
+
+	var previous *cachedFile
+	if entry != nil {
+		previous = entry.file
+	}
+	file := h.loadFile(name, previous)
+	h.storeCachedFile(name, &cacheEntry{file: file, checked: now})
+	return file, file != nil
+}
+
+// loadFile reads the file called name from the first of its filePaths that
+// holds one, or returns nil if none does. previous, if not nil, is returned
+// instead when it is the same unchanged file.
+func (h *Handler) loadFile(name string, previous *cachedFile) *cachedFile {
+	for _, filePath := range h.filePaths(name) {
+		info, err := h.stat(filePath)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if previous != nil && previous.path == filePath && info.ModTime().Equal(previous.modTime) && info.Size() == previous.size {
+			return previous
+		}
+		data, err := h.readFile(filePath)
+		if err != nil {
+			return nil
+		}
+		return &cachedFile{path: filePath, data: data, modTime: info.ModTime(), size: int64(len(data)), gz: &lazyGzip{}}
+	}
+	return nil
+}
+
+// storeCachedFile replaces the cache entry for name, removing it if entry
+// is nil. Entries of missing names beyond maxMissingEntries are not kept.
+func (h *Handler) storeCachedFile(name string, entry *cacheEntry) {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	if old := h.files[name]; old != nil && old.file == nil {
+		h.missing--
+	}
+	if entry == nil || entry.file == nil && h.missing >= maxMissingEntries {
+		delete(h.files, name)
+		return
+	}
+	if h.files == nil {
+		h.files = make(map[string]*cacheEntry)
+	}
+	if entry.file == nil {
+		h.missing++
+	}
+	h.files[name] = entry
+}
@@ -0,0 +1,334 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/sitemaps/", sm.Handler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(path, acceptEncoding string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("Error requesting %s: %v", path, err)
+		}
+		return resp
+	}
+
+	resp := get("/sitemaps/sitemap.xml", "")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "<urlset") {
+		t.Fatalf("Unexpected sitemap response %d: %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Fatalf("Unexpected sitemap content type %s", ct)
+	}
+
+	resp = get("/sitemaps/sitemap.xsl", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/xsl") {
+		t.Fatalf("Unexpected stylesheet response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// The gz variant is compressed on the fly when only the plain file exists
+	resp = get("/sitemaps/sitemap.xml.gz", "")
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Gzip variant is not gzip encoded: %v", err)
+	}
+	body, _ = io.ReadAll(gz)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "<urlset") {
+		t.Fatalf("Unexpected gzip variant content: %s", body)
+	}
+
+	// Precompressed variants are preferred for clients accepting gzip
+	f, err := os.Create(filepath.Join(dir, "sitemap.xml.gz"))
+	if err != nil {
+		t.Fatalf("Error creating gzip variant: %v", err)
+	}
+	gzw := gzip.NewWriter(f)
+	gzw.Write([]byte("<urlset/>"))
+	gzw.Close()
+	f.Close()
+	resp = get("/sitemaps/sitemap.xml", "gzip")
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Precompressed variant not served with gzip content encoding")
+	}
+
+	for _, path := range []string{"/sitemaps/missing.xml", "/sitemaps/notes.txt", "/sitemaps/..%2f..%2fetc%2fpasswd"} {
+		resp = get(path, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected 404 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":                   false,
+		"gzip":               true,
+		"deflate, gzip":      true,
+		"gzip;q=0":           false,
+		"gzip; q=0.5, br":    true,
+		"br, identity;q=0.1": false,
+	}
+	for header, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if got := acceptsGzip(req); got != expected {
+			t.Fatalf("acceptsGzip(%q) = %v, expected %v", header, got, expected)
+		}
+	}
+}
+
+func TestHandlerRefreshInterval(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/first"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	get := func(h *Handler) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	cached := sm.Handler()
+	cached.RefreshInterval = time.Hour
+	polling := sm.Handler()
+	polling.RefreshInterval = time.Millisecond
+	for _, h := range []*Handler{cached, polling} {
+		if code, body := get(h); code != http.StatusOK || !strings.Contains(body, "/first") {
+			t.Fatalf("Unexpected first response %d: %s", code, body)
+		}
+	}
+
+	// Regenerate the files as an external job would
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/second-and-longer"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, body := get(cached); !strings.Contains(body, "/first") {
+		t.Fatalf("Cached handler re-read the file before the interval: %s", body)
+	}
+	if _, body := get(polling); !strings.Contains(body, "/second-and-longer") {
+		t.Fatalf("Polling handler did not pick up the new file: %s", body)
+	}
+
+	os.Remove(filepath.Join(dir, "sitemap.xml"))
+	time.Sleep(5 * time.Millisecond)
+	if code, _ := get(polling); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 after removal, got %d", code)
+	}
+}
+
+func TestHandlerCacheInvalidate(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/first"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	h := sm.Handler()
+	h.Cache = true
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Unexpected status for %s: %d", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	gunzip := func(body string) string {
+		gz, err := gzip.NewReader(strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Error reading gzip response: %v", err)
+		}
+		data, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("Error reading gzip response: %v", err)
+		}
+		return string(data)
+	}
+
+	if body := get("/sitemap.xml"); !strings.Contains(body, "/first") {
+		t.Fatalf("Unexpected first response: %s", body)
+	}
+	if body := gunzip(get("/sitemap.xml.gz")); !strings.Contains(body, "/first") {
+		t.Fatalf("Unexpected gzip response: %s", body)
+	}
+
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/second"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if body := get("/sitemap.xml"); !strings.Contains(body, "/first") {
+		t.Fatalf("Cache re-read the file before invalidation: %s", body)
+	}
+
+	h.Invalidate("sitemap.xml")
+	if body := get("/sitemap.xml"); !strings.Contains(body, "/second") {
+		t.Fatalf("Invalidate did not drop the cached file: %s", body)
+	}
+	if body := gunzip(get("/sitemap.xml.gz")); !strings.Contains(body, "/second") {
+		t.Fatalf("Invalidate did not drop the gzip variant: %s", body)
+	}
+
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/third"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	h.InvalidateAll()
+	if body := get("/sitemap.xml"); !strings.Contains(body, "/third") {
+		t.Fatalf("InvalidateAll did not drop the cached file: %s", body)
+	}
+}
+
+func TestFSHandler(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ShardDir = "shards"
+	sm.MaxURLs = 1
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	// Copy the generated set into an in-memory file system as go:embed would
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"sitemap_index.xml", "sitemap.xsl", "shards/sitemap_1.xml"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", name, err)
+		}
+		fsys["static/"+name] = &fstest.MapFile{Data: data}
+	}
+	os.RemoveAll(dir)
+
+	for _, cache := range []bool{false, true} {
+		h := NewFSHandler(fsys, "static")
+		h.ShardDir = "shards"
+		h.Cache = cache
+		for _, tc := range []struct {
+			path, contains string
+		}{
+			{"/sitemap_index.xml", "<sitemapindex"},
+			{"/sitemap_1.xml", "https://www.example.com/a"},
+			{"/sitemap_1.xml.gz", ""},
+			{"/sitemap.xsl", "xsl:stylesheet"},
+		} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200 for %s (cache %v), got %d", tc.path, cache, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tc.contains) {
+				t.Fatalf("Expected %s to contain %q", tc.path, tc.contains)
+			}
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sitemap_2.xml", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 for a missing file, got %d", rec.Code)
+		}
+	}
+}
+
+// countingFS counts the files opened in an fs.FS, including for fs.Stat.
+type countingFS struct {
+	fs.FS
+	opens atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opens.Add(1)
+	return c.FS.Open(name)
+}
+
+func TestHandlerCacheAvoidsDiskAccess(t *testing.T) {
+	fsys := &countingFS{FS: fstest.MapFS{
+		"static/sitemap_index.xml":    &fstest.MapFile{Data: []byte("<sitemapindex/>")},
+		"static/shards/sitemap_1.xml": &fstest.MapFile{Data: []byte("<urlset/>")},
+	}}
+	h := NewFSHandler(fsys, "static")
+	h.ShardDir = "shards"
+	h.Cache = true
+	get := func(target string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	requests := map[string]int{
+		"/sitemap_index.xml": http.StatusOK,
+		"/sitemap_1.xml":     http.StatusOK,
+		"/sitemap_1.xml.gz":  http.StatusOK,
+		"/sitemap_9.xml":     http.StatusNotFound,
+		"/sitemap_9.xml.gz":  http.StatusNotFound,
+		"/sitemap.xsl":       http.StatusNotFound,
+	}
+	for target, code := range requests {
+		if got := get(target); got != code {
+			t.Fatalf("Expected %d for %s, got %d", code, target, got)
+		}
+	}
+	opens := fsys.opens.Load()
+	for i := 0; i < 3; i++ {
+		for target, code := range requests {
+			if got := get(target); got != code {
+				t.Fatalf("Expected %d for %s, got %d", code, target, got)
+			}
+		}
+	}
+	if got := fsys.opens.Load(); got != opens {
+		t.Fatalf("Expected repeated hits to be served from memory, %d more files opened", got-opens)
+	}
+
+	// Invalidation looks the names up again
+	fsys.FS.(fstest.MapFS)["static/shards/sitemap_9.xml"] = &fstest.MapFile{Data: []byte("<urlset/>")}
+	if got := get("/sitemap_9.xml"); got != http.StatusNotFound {
+		t.Fatalf("Expected the missing file to stay cached, got %d", got)
+	}
+	h.Invalidate("sitemap_9.xml")
+	if got := get("/sitemap_9.xml"); got != http.StatusOK {
+		t.Fatalf("Expected the new file after Invalidate, got %d", got)
+	}
+}
@@ -0,0 +1,96 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// placeholderPattern matches a {name} placeholder in a cluster pattern.
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// HreflangCluster expands one logical page into its localized URLs, each
+// listing the full set of alternates including itself and an x-default, so
+// the cluster is reciprocal by construction.
+type HreflangCluster struct {
+	// Pattern is the loc of the page with placeholders, such as
+	// "/{lang}/products/{slug}". {lang} is replaced by the locale's path
+	// segment and other placeholders by the params passed to Expand.
+	Pattern string
+	// Locales are the hreflang codes of the localized versions, such as
+	// "en", "en-GB" or "fr".
+	Locales []string
+	// Paths maps a locale to its {lang} segment when it differs from the
+	// lowercased locale, such as "uk" for "en-GB".
+	Paths map[string]string
+	// XDefault is the locale the x-default alternate points to, or the
+	// first locale if empty.
+	XDefault string
+}
+
+// Expand returns one URL per locale, copying the other fields of page and
+// replacing its Loc and Alternates. Param values are path escaped.
+func (c HreflangCluster) Expand(page SitemapURL, params map[string]string) ([]SitemapURL, error) {
+	if len(c.Locales) == 0 {
+		return nil, fmt.Errorf("hreflang cluster %q has no locales", c.Pattern)
+	}
+	xDefault := c.XDefault
+	if xDefault == "" {
+		xDefault = c.Locales[0]
+	}
+
+	locs := make(map[string]string, len(c.Locales))
+	alternates := make([]Alternate, 0, len(c.Locales)+1)
+	for _, locale := range c.Locales {
+		if !hreflangPattern.MatchString(locale) || strings.EqualFold(locale, "x-default") {
+			return nil, fmt.Errorf("invalid locale %q in hreflang cluster %q", locale, c.Pattern)
+		}
+		if _, ok := locs[locale]; ok {
+			return nil, fmt.Errorf("duplicate locale %q in hreflang cluster %q", locale, c.Pattern)
+		}
+		loc, err := c.expandLoc(locale, params)
+		if err != nil {
+			return nil, err
+		}
+		locs[locale] = loc
+		alternates = append(alternates, Alternate{Rel: "alternate", Hreflang: locale, Href: loc})
+	}
+	defaultLoc, ok := locs[xDefault]
+	if !ok {
+		return nil, fmt.Errorf("x-default locale %q is not in hreflang cluster %q", xDefault, c.Pattern)
+	}
+	alternates = append(alternates, Alternate{Rel: "alternate", Hreflang: "x-default", Href: defaultLoc})
+
+	urls := make([]SitemapURL, len(c.Locales))
+	for i, locale := range c.Locales {
+		u := page
+		u.Loc = locs[locale]
+		u.Alternates = alternates
+		urls[i] = u
+	}
+	return urls, nil
+}
+
+// expandLoc substitutes the placeholders of the pattern for locale.
+func (c HreflangCluster) expandLoc(locale string, params map[string]string) (string, error) {
+	var missing string
+	loc := placeholderPattern.ReplaceAllStringFunc(c.Pattern, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		if name == "lang" {
+			if path, ok := c.Paths[locale]; ok {
+				return path
+			}
+			return strings.ToLower(locale)
+		}
+		value, ok := params[name]
+		if !ok {
+			missing = name
+		}
+		return url.PathEscape(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("missing value for {%s} in hreflang cluster %q", missing, c.Pattern)
+	}
+	return loc, nil
+}
@@ -0,0 +1,59 @@
+package sitemap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHreflangClusterExpand(t *testing.T) {
+	cluster := HreflangCluster{
+		Pattern:  "/{lang}/products/{slug}",
+		Locales:  []string{"en", "en-GB", "fr"},
+		Paths:    map[string]string{"en-GB": "uk"},
+		XDefault: "en",
+	}
+	urls, err := cluster.Expand(SitemapURL{LastMod: "2024-01-01"}, map[string]string{"slug": "red shoes"})
+	if err != nil {
+		t.Fatalf("Error expanding cluster: %v", err)
+	}
+	locs := []string{"/en/products/red%20shoes", "/uk/products/red%20shoes", "/fr/products/red%20shoes"}
+	if len(urls) != 3 {
+		t.Fatalf("Expected 3 URLs, got %d", len(urls))
+	}
+	for i, u := range urls {
+		if u.Loc != locs[i] || u.LastMod != "2024-01-01" || len(u.Alternates) != 4 {
+			t.Fatalf("Unexpected URL %d: %+v", i, u)
+		}
+	}
+	if last := urls[0].Alternates[3]; last.Hreflang != "x-default" || last.Href != locs[0] {
+		t.Fatalf("Unexpected x-default alternate %+v", last)
+	}
+
+	// The written cluster passes the hreflang check
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ValidateHreflang = true
+	sm.AddURLs(urls)
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if issues := sm.Report().HreflangIssues; len(issues) != 0 {
+		t.Fatalf("Expected a valid cluster, got %+v", issues)
+	}
+
+	for _, tc := range []struct {
+		cluster HreflangCluster
+		problem string
+	}{
+		{HreflangCluster{Pattern: "/{lang}/{slug}"}, "no locales"},
+		{HreflangCluster{Pattern: "/{lang}/{slug}", Locales: []string{"english"}}, "invalid locale"},
+		{HreflangCluster{Pattern: "/{lang}/{slug}", Locales: []string{"en", "en"}}, "duplicate locale"},
+		{HreflangCluster{Pattern: "/{lang}/{slug}", Locales: []string{"en"}, XDefault: "fr"}, "x-default"},
+		{HreflangCluster{Pattern: "/{lang}/{id}", Locales: []string{"en"}}, "missing value for {id}"},
+	} {
+		_, err := tc.cluster.Expand(SitemapURL{}, map[string]string{"slug": "shoes"})
+		if err == nil || !strings.Contains(err.Error(), tc.problem) {
+			t.Fatalf("Expected an error about %q, got %v", tc.problem, err)
+		}
+	}
+}
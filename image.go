@@ -0,0 +1,286 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	imageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+	// maxImagesPerURL is the most images Google reads for a single page.
+	maxImagesPerURL = 1000
+)
+
+// Image is an image on a page, written as an image:image element. A page
+// may list up to 1,000 images. The optional fields are no longer used by
+// Google but are still read by other search engines.
+type Image struct {
+	Loc         string `xml:"image:loc"`
+	Caption     string `xml:"image:caption,omitempty"`
+	GeoLocation string `xml:"image:geo_location,omitempty"` // such as "Limerick, Ireland"
+	Title       string `xml:"image:title,omitempty"`
+	// License is the URL of the image's license.
+	License string `xml:"image:license,omitempty"`
+}
+
+// validateImages drops images that are not absolute http(s) URLs, are
+// served from a host other than the page's or ImageHosts, or exceed the
+// per-page limit, and clears licenses that are not absolute URLs, recording
+// each problem in the report. Other licenses are put in canonical form, so
+// that spellings of the same URL become one reference. The images of urls
+// are replaced rather than modified so the caller's slices are untouched.
+func (s *SitemapOptions) validateImages(urls []SitemapURL, report *Report) {
+	for i := range urls {
+		if len(urls[i].Images) == 0 {
+			continue
+		}
+		page, err := url.Parse(urls[i].Loc)
+		if err != nil {
+			continue
+		}
+
+		valid := make([]Image, 0, len(urls[i].Images))
+		for _, img := range urls[i].Images {
+			if problem := s.imageProblem(page, img.Loc); problem != "" {
+				s.addIssue(&report.ImageIssues, WarningImage, Issue{Loc: urls[i].Loc, Problem: problem, Meta: urls[i].Meta})
+				continue
+			}
+			if img.License != "" {
+				license, ok := canonicalLicense(img.License)
+				if !ok {
+					s.addIssue(&report.ImageIssues, WarningImage, Issue{
+						Loc:     urls[i].Loc,
+						Problem: fmt.Sprintf("image %s: license %s is not an absolute http(s) URL", img.Loc, img.License),
+						Meta:    urls[i].Meta,
+					})
+				}
+				img.License = license
+			}
+			if len(valid) == maxImagesPerURL {
+				s.addIssue(&report.ImageIssues, WarningImage, Issue{
+					Loc:     urls[i].Loc,
+					Problem: fmt.Sprintf("image %s: more than %d images", img.Loc, maxImagesPerURL),
+					Meta:    urls[i].Meta,
+				})
+				continue
+			}
+			valid = append(valid, img)
+		}
+		urls[i].Images = valid
+	}
+}
+
+// canonicalLicense returns the license URL of an image with surrounding
+// space and any fragment removed and its scheme and host lowercased, or ""
+// and false if it is not an absolute http(s) URL.
+func canonicalLicense(license string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(license))
+	if err != nil {
+		return "", false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), true
+}
+
+// checkImageLicenses fetches, if CheckImageLicenses is set, every distinct
+// license URL of the images of urls not already in checked, and clears the
+// licenses that do not resolve, recording each in report. checked maps the
+// licenses fetched during the Write to their problem, "" if they resolve.
+func (s *SitemapOptions) checkImageLicenses(ctx context.Context, urls []SitemapURL, checked map[string]string, report *Report) error {
+	if !s.CheckImageLicenses {
+		return nil
+	}
+	var licenses []string
+	for _, u := range urls {
+		for _, img := range u.Images {
+			if _, ok := checked[img.License]; img.License != "" && !ok {
+				checked[img.License] = ""
+				licenses = append(licenses, img.License)
+			}
+		}
+	}
+	problems := make([]string, len(licenses))
+	err := s.parallel(ctx, len(licenses), func(i int) error {
+		problems[i] = s.licenseProblem(ctx, licenses[i])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, license := range licenses {
+		checked[license] = problems[i]
+	}
+
+	for i := range urls {
+		var images []Image
+		for j, img := range urls[i].Images {
+			problem := checked[img.License]
+			if img.License == "" || problem == "" {
+				continue
+			}
+			s.addIssue(&report.ImageIssues, WarningImage, Issue{
+				Loc:     urls[i].Loc,
+				Problem: fmt.Sprintf("image %s: license %s does not resolve: %s", img.Loc, img.License, problem),
+				Meta:    urls[i].Meta,
+			})
+			if images == nil {
+				images = append([]Image(nil), urls[i].Images...)
+			}
+			images[j].License = ""
+		}
+		if images != nil {
+			urls[i].Images = images
+		}
+	}
+	return nil
+}
+
+// licenseProblem requests the license at loc, with HEAD or, if the server
+// does not allow it, GET, and returns why it does not resolve, or "" if it
+// does.
+func (s *SitemapOptions) licenseProblem(ctx context.Context, loc string) string {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, loc, nil)
+		if err != nil {
+			return err.Error()
+		}
+		resp, err := s.doRequest(req)
+		if err != nil {
+			return err.Error()
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusMethodNotAllowed && method == http.MethodHead {
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return resp.Status
+		}
+		return ""
+	}
+	return ""
+}
+
+// imageProblem returns why the image at loc may not be listed on page, or
+// an empty string if it may.
+func (s *SitemapOptions) imageProblem(page *url.URL, loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return fmt.Sprintf("image %s: %v", loc, err)
+	}
+	if !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("image %s: not an absolute http(s) URL", loc)
+	}
+	if strings.EqualFold(u.Hostname(), page.Hostname()) {
+		return ""
+	}
+	for _, host := range s.ImageHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("image %s: host %s is not allowed", loc, u.Hostname())
+}
+
+// AttachImages adds the images of manifest, keyed by page URL, to the URLs
+// already added with a matching loc. Keys and image URLs may be relative to
+// BaseURL. It returns the number of URLs that received images.
+func (s *SitemapOptions) AttachImages(manifest map[string][]string) int {
+	byLoc := make(map[string][]Image, len(manifest))
+	for page, images := range manifest {
+		loc, err := s.resolveURL(page)
+		if err != nil {
+			continue
+		}
+		for _, img := range images {
+			// Unresolvable image URLs are kept and reported by Write
+			if resolved, err := s.resolveURL(img); err == nil {
+				img = resolved
+			}
+			byLoc[loc] = append(byLoc[loc], Image{Loc: img})
+		}
+	}
+
+	mu := s.lock()
+	defer mu.Unlock()
+	attached := 0
+	for i := range s.URLs {
+		images, ok := byLoc[s.URLs[i].Loc]
+		if !ok {
+			continue
+		}
+		merged := make([]Image, 0, len(s.URLs[i].Images)+len(images))
+		merged = append(merged, s.URLs[i].Images...)
+		s.URLs[i].Images = append(merged, images...)
+		attached++
+	}
+	return attached
+}
+
+// imgSrcPattern finds the src attribute of img tags in HTML.
+var imgSrcPattern = regexp.MustCompile(`(?is)<img\b[^>]*?\ssrc\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+// ScanImages builds an image manifest for AttachImages from a static export
+// rooted at root. Every .html file becomes a page, with index.html standing
+// for its directory, and the src of each img tag is resolved against the
+// page's path. External images are kept as absolute URLs; data URIs are
+// skipped.
+func ScanImages(root string) (map[string][]string, error) {
+	manifest := make(map[string][]string)
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(filePath), ".html") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		page := "/" + filepath.ToSlash(rel)
+		if path.Base(page) == "index.html" {
+			page = strings.TrimSuffix(page, "index.html")
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		pageURL := &url.URL{Path: page}
+		seen := make(map[string]bool)
+		for _, m := range imgSrcPattern.FindAllStringSubmatch(string(data), -1) {
+			src := strings.TrimSpace(m[1] + m[2] + m[3])
+			if src == "" || strings.HasPrefix(strings.ToLower(src), "data:") {
+				continue
+			}
+			ref, err := url.Parse(src)
+			if err != nil {
+				continue
+			}
+			img := pageURL.ResolveReference(ref).String()
+			if !seen[img] {
+				seen[img] = true
+				manifest[page] = append(manifest[page], img)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
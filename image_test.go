@@ -0,0 +1,210 @@
+package sitemap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestImageValidation(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ImageHosts = []string{"cdn.example.net"}
+	images := []Image{
+		{Loc: "https://www.example.com/a.jpg"},
+		{Loc: "https://cdn.example.net/b.jpg"},
+		{Loc: "/relative.jpg"},
+		{Loc: "https://elsewhere.org/c.jpg"},
+	}
+	sm.AddURL(SitemapURL{Loc: "/gallery", Images: images})
+
+	many := make([]Image, maxImagesPerURL+1)
+	for i := range many {
+		many[i].Loc = "https://www.example.com/" + strconv.Itoa(i) + ".jpg"
+	}
+	sm.AddURL(SitemapURL{Loc: "/huge", Images: many})
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(images) != 4 {
+		t.Fatal("caller's images were modified")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"`,
+		"<image:loc>https://www.example.com/a.jpg</image:loc>",
+		"<image:loc>https://cdn.example.net/b.jpg</image:loc>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("sitemap missing %s", want)
+		}
+	}
+	if strings.Contains(out, "relative.jpg") || strings.Contains(out, "elsewhere.org") {
+		t.Fatal("invalid images were written")
+	}
+	if got := strings.Count(out, "<image:image>"); got != 2+maxImagesPerURL {
+		t.Fatalf("got %d images, want %d", got, 2+maxImagesPerURL)
+	}
+
+	var problems []string
+	for _, issue := range sm.Report().ImageIssues {
+		problems = append(problems, issue.Problem)
+	}
+	want := []string{
+		"image /relative.jpg: not an absolute http(s) URL",
+		"image https://elsewhere.org/c.jpg: host elsewhere.org is not allowed",
+		"image https://www.example.com/1000.jpg: more than 1000 images",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got issues:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+func TestScanAndAttachImages(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"index.html":          `<html><img src="/img/logo.png"><img alt="x" src='hero.jpg'></html>`,
+		"products/index.html": `<IMG SRC="shoe.jpg"><img src="data:image/png;base64,AAAA"><img src="https://cdn.example.net/shoe-2.jpg">`,
+		"about.html":          `<p>no images</p>`,
+		"logo.png":            "",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, err := ScanImages(root)
+	if err != nil {
+		t.Fatalf("ScanImages: %v", err)
+	}
+	if got := strings.Join(manifest["/"], " "); got != "/img/logo.png /hero.jpg" {
+		t.Fatalf("images of /: %s", got)
+	}
+	if got := strings.Join(manifest["/products/"], " "); got != "/products/shoe.jpg https://cdn.example.net/shoe-2.jpg" {
+		t.Fatalf("images of /products/: %s", got)
+	}
+	if _, ok := manifest["/about.html"]; ok {
+		t.Fatal("page without images in manifest")
+	}
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddURL(SitemapURL{Loc: "/products/", Images: []Image{{Loc: "https://www.example.com/existing.jpg"}}})
+	sm.AddURL(SitemapURL{Loc: "/contact"})
+	if n := sm.AttachImages(manifest); n != 2 {
+		t.Fatalf("attached to %d URLs, want 2", n)
+	}
+	if got := sm.URLs[0].Images; len(got) != 2 || got[0].Loc != "https://www.example.com/img/logo.png" {
+		t.Fatalf("images of /: %+v", got)
+	}
+	if got := sm.URLs[1].Images; len(got) != 3 || got[0].Loc != "https://www.example.com/existing.jpg" {
+		t.Fatalf("images of /products/: %+v", got)
+	}
+}
+
+func TestImageFields(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	var images []Image
+	for i := 1; i <= 6; i++ {
+		images = append(images, Image{
+			Loc:         "https://www.example.com/product/" + strconv.Itoa(i) + ".jpg",
+			Caption:     "View " + strconv.Itoa(i) + " of the red sneaker",
+			GeoLocation: "Limerick, Ireland",
+			Title:       "Red sneaker",
+			License:     "https://www.example.com/image-license",
+		})
+	}
+	images[5].License = "not a url"
+	sm.AddURL(SitemapURL{Loc: "/product", Images: images})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	out := string(data)
+	if strings.Count(out, "<image:image>") != 6 {
+		t.Fatalf("Expected 6 image blocks:\n%s", out)
+	}
+	for _, want := range []string{
+		"<image:caption>View 1 of the red sneaker</image:caption>",
+		"<image:geo_location>Limerick, Ireland</image:geo_location>",
+		"<image:title>Red sneaker</image:title>",
+		"<image:license>https://www.example.com/image-license</image:license>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("Expected %s in output:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "<image:license>") != 5 || len(sm.Report().ImageIssues) != 1 {
+		t.Fatalf("Expected the invalid license to be dropped, got %+v", sm.Report().ImageIssues)
+	}
+}
+
+func TestImageLicenses(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case r.URL.Path == "/get-only" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.HTTPClient = server.Client()
+	sm.CheckImageLicenses = true
+	license := strings.Replace(server.URL, "http://", "HTTP://", 1) + "/license#terms"
+	sm.AddURL(SitemapURL{Loc: "/a", Images: []Image{
+		{Loc: "https://www.example.com/1.jpg", License: " " + license},
+		{Loc: "https://www.example.com/2.jpg", License: server.URL + "/license"},
+		{Loc: "https://www.example.com/3.jpg", License: server.URL + "/get-only"},
+		{Loc: "https://www.example.com/4.jpg", License: server.URL + "/missing"},
+	}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	out := string(data)
+	if strings.Count(out, "<image:license>"+server.URL+"/license</image:license>") != 2 {
+		t.Fatalf("Expected both spellings of the license as one reference:\n%s", out)
+	}
+	if !strings.Contains(out, "/get-only</image:license>") || strings.Contains(out, "/missing") {
+		t.Fatalf("Expected only the missing license to be cleared:\n%s", out)
+	}
+	issues := sm.Report().ImageIssues
+	if len(issues) != 1 || !strings.Contains(issues[0].Problem, "404") {
+		t.Fatalf("Expected one issue for the missing license, got %+v", issues)
+	}
+	// One request per distinct license, plus the GET after a refused HEAD
+	if n := requests.Load(); n != 4 {
+		t.Fatalf("Expected 4 requests, got %d", n)
+	}
+}
@@ -0,0 +1,279 @@
+package sitemap
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// IncrementalWriter maintains a sitemap index for long-running services.
+// URLs are upserted and removed over time, and Flush rewrites only the
+// sitemap files whose contents changed, plus the index. URLs keep the
+// sitemap file they were first assigned to; new URLs fill the first file
+// with room. It is safe for concurrent use.
+type IncrementalWriter struct {
+	opts           *SitemapOptions
+	baseSitemapURL string
+	rules          *urlRules
+
+	mu       sync.Mutex
+	urls     map[string]SitemapURL
+	shardOf  map[string]int
+	shards   []map[string]struct{}
+	lastMods []string
+	dirty    map[int]bool
+
+	// flushMu serializes Flush calls so files are never written concurrently
+	flushMu           sync.Mutex
+	stylesheetWritten bool
+}
+
+// NewIncrementalWriter returns an IncrementalWriter writing to opts.Dir with
+// the configuration of opts. Any URLs already in opts are upserted.
+func NewIncrementalWriter(opts *SitemapOptions, baseSitemapURL string) (*IncrementalWriter, error) {
+	if _, err := parseBaseURL(baseSitemapURL); err != nil {
+		return nil, fmt.Errorf("invalid base sitemap URL: %w", err)
+	}
+	if _, err := opts.checkLimits(); err != nil {
+		return nil, err
+	}
+	rules, err := opts.compileURLRules()
+	if err != nil {
+		return nil, err
+	}
+	w := &IncrementalWriter{
+		opts:           opts,
+		baseSitemapURL: baseSitemapURL,
+		rules:          rules,
+		urls:           make(map[string]SitemapURL),
+		shardOf:        make(map[string]int),
+		dirty:          make(map[int]bool),
+	}
+	for _, u := range opts.URLs {
+		if err := w.Upsert(u); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Upsert adds u or replaces the entry with the same loc. The sitemap file
+// holding it is rewritten on the next Flush if anything changed. u is
+// checked, cleaned and filtered as Write does, so values Write would
+// repair are repaired and problems Write reports go to OnWarning. A URL
+// Write would drop, such as one matching Exclude or expired, is returned
+// as a *ValidationError and removes the entry it would have replaced.
+func (w *IncrementalWriter) Upsert(u SitemapURL) error {
+	u = w.opts.normalizeURL(u)
+	if w.opts.OnAddURL != nil {
+		if err := w.opts.OnAddURL(&u); err != nil {
+			return err
+		}
+	}
+	var report Report
+	urls, err := w.opts.prepareURLsWith(w.rules, []SitemapURL{u}, &report, make(map[string]bool, 1))
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		excluded := report.Excluded[0]
+		w.Remove(excluded.Loc)
+		return &ValidationError{Loc: excluded.Loc, Problem: excluded.Reason}
+	}
+	u = urls[0]
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if i, ok := w.shardOf[u.Loc]; ok {
+		if fingerprint(w.urls[u.Loc]) != fingerprint(u) {
+			w.urls[u.Loc] = u
+			w.dirty[i] = true
+		}
+		return nil
+	}
+
+	i := w.shardWithRoom()
+	w.urls[u.Loc] = u
+	w.shardOf[u.Loc] = i
+	w.shards[i][u.Loc] = struct{}{}
+	w.dirty[i] = true
+	return nil
+}
+
+// Remove deletes the entry with the given loc, if present.
+func (w *IncrementalWriter) Remove(loc string) {
+	if resolved, err := w.opts.resolveURL(loc); err == nil {
+		loc = w.rules.queries.clean(resolved)
+		if canonical, err := w.opts.canonicalLoc("", loc); err == nil {
+			loc = canonical
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i, ok := w.shardOf[loc]
+	if !ok {
+		return
+	}
+	delete(w.urls, loc)
+	delete(w.shardOf, loc)
+	delete(w.shards[i], loc)
+	w.dirty[i] = true
+}
+
+// Len returns the number of URLs currently held.
+func (w *IncrementalWriter) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.urls)
+}
+
+// shardWithRoom returns the first sitemap file with room for another URL,
+// creating one if all are full. w.mu must be held.
+func (w *IncrementalWriter) shardWithRoom() int {
+	for i, shard := range w.shards {
+		if len(shard) < w.opts.MaxURLs {
+			return i
+		}
+	}
+	w.shards = append(w.shards, make(map[string]struct{}))
+	w.lastMods = append(w.lastMods, "")
+	return len(w.shards) - 1
+}
+
+// Flush writes the sitemap files that changed since the last Flush and
+// rewrites the index. Sitemap files left empty by removals are deleted, and
+// so is the index once no file is left. Like Write, Flush claims Dir while
+// it runs and restores the previous files if it fails.
+func (w *IncrementalWriter) Flush() error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	// Snapshot the dirty files and the index under the lock so upserts can
+	// continue while files are written
+	w.mu.Lock()
+	if len(w.dirty) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	pending := make(map[int][]SitemapURL, len(w.dirty))
+	for i := range w.dirty {
+		urls := make([]SitemapURL, 0, len(w.shards[i]))
+		for loc := range w.shards[i] {
+			urls = append(urls, w.urls[loc])
+		}
+		sort.Slice(urls, func(a, b int) bool { return urls[a].Loc < urls[b].Loc })
+		pending[i] = urls
+		w.lastMods[i] = ""
+		if len(urls) > 0 {
+			w.lastMods[i] = w.opts.sitemapLastMod(w.shardName(i), urls)
+		}
+	}
+	w.dirty = make(map[int]bool)
+	lastMods := append([]string(nil), w.lastMods...)
+	listed := make([]bool, len(w.shards))
+	for i, shard := range w.shards {
+		listed[i] = len(shard) > 0
+	}
+	w.mu.Unlock()
+
+	if err := w.writeFiles(pending, lastMods, listed); err != nil {
+		// Retry every pending file on the next Flush
+		w.mu.Lock()
+		for i := range pending {
+			w.dirty[i] = true
+		}
+		w.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// writeFiles writes the pending sitemap files and the index listing the
+// files for which listed is true, with their lastMods, in a transaction
+// holding the lock of Dir. An empty lastmod leaves out the lastmod element,
+// not the file.
+func (w *IncrementalWriter) writeFiles(pending map[int][]SitemapURL, lastMods []string, listed []bool) error {
+	s := w.opts
+	unlock, err := lockDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	stylesheetWritten := w.stylesheetWritten
+	err = s.runTx(&writeTx{}, func() error {
+		if err := s.tx.mkdirAll(s.shardDir()); err != nil {
+			return err
+		}
+		if !stylesheetWritten {
+			if err := s.writeStylesheet(); err != nil {
+				return err
+			}
+			stylesheetWritten = true
+		}
+		return w.writeTxFiles(pending, lastMods, listed)
+	})
+	if err != nil {
+		return err
+	}
+	w.stylesheetWritten = stylesheetWritten
+	return nil
+}
+
+// writeTxFiles writes the files of writeFiles in the running transaction.
+func (w *IncrementalWriter) writeTxFiles(pending map[int][]SitemapURL, lastMods []string, listed []bool) error {
+	s := w.opts
+	for i, urls := range pending {
+		filePath := filepath.Join(s.shardDir(), w.shardName(i))
+		if len(urls) == 0 {
+			for _, stored := range s.storedFiles(filePath) {
+				if err := s.tx.removeFile(stored); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := s.writeSitemapFile(filePath, urls); err != nil {
+			return err
+		}
+		if err := s.validateXMLFile(filePath, false); err != nil {
+			return err
+		}
+	}
+
+	shardBaseURL, err := s.shardBaseURL(w.baseSitemapURL)
+	if err != nil {
+		return err
+	}
+	var sitemaps []Sitemap
+	for i, lastMod := range lastMods {
+		if !listed[i] {
+			continue
+		}
+		sitemapURL, err := s.resolveSitemapURL(shardBaseURL, publicName(s.PublicNames, s.sitemapFileName(w.shardName(i))))
+		if err != nil {
+			return err
+		}
+		sitemaps = append(sitemaps, Sitemap{Loc: sitemapURL, LastMod: lastMod})
+	}
+	indexPath := filepath.Join(s.Dir, "sitemap_index.xml")
+	if len(sitemaps) == 0 {
+		for _, stored := range s.storedFiles(indexPath) {
+			if err := s.tx.removeFile(stored); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := s.writeIndexFile(w.baseSitemapURL, sitemaps); err != nil {
+		return err
+	}
+	return s.validateXMLFile(indexPath, true)
+}
+
+func (w *IncrementalWriter) shardName(i int) string {
+	return fmt.Sprintf("sitemap_%d.xml", i+1)
+}
@@ -0,0 +1,201 @@
+package sitemap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrementalWriter(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewSitemapOptions(dir, "https://www.example.com")
+	opts.MaxURLs = 10
+
+	w, err := NewIncrementalWriter(opts, "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("Error creating incremental writer: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 3; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				w.Upsert(SitemapURL{Loc: "/g" + strconv.Itoa(g) + "/" + strconv.Itoa(i), LastMod: "2024-01-01"})
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	if w.Len() != 30 {
+		t.Fatalf("Expected 30 URLs, got %d", w.Len())
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "sitemap_"+strconv.Itoa(i)+".xml")); err != nil {
+			t.Fatalf("Expected sitemap_%d.xml to be written: %v", i, err)
+		}
+	}
+
+	// Updating one URL only rewrites the file holding it
+	modTimes := func() map[string]time.Time {
+		times := make(map[string]time.Time)
+		for i := 1; i <= 3; i++ {
+			name := "sitemap_" + strconv.Itoa(i) + ".xml"
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				times[name] = info.ModTime()
+			}
+		}
+		return times
+	}
+	before := modTimes()
+	time.Sleep(20 * time.Millisecond)
+
+	target := w.urls["https://www.example.com/g0/0"]
+	name := w.shardName(w.shardOf[target.Loc])
+	if err := w.Upsert(SitemapURL{Loc: "/g0/0", LastMod: "2024-02-01"}); err != nil {
+		t.Fatalf("Error upserting: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	after := modTimes()
+	for file, modTime := range after {
+		changed := !modTime.Equal(before[file])
+		if changed != (file == name) {
+			t.Fatalf("File %s rewritten=%v, expected only %s to change", file, changed, name)
+		}
+	}
+
+	// A file emptied by removals is deleted and dropped from the index
+	for loc := range w.shards[2] {
+		w.Remove(loc)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap_3.xml")); !os.IsNotExist(err) {
+		t.Fatalf("Emptied sitemap file was not removed")
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	if strings.Contains(string(index), "sitemap_3.xml") || !strings.Contains(string(index), "sitemap_2.xml") {
+		t.Fatalf("Index does not reflect the removed sitemap file:\n%s", index)
+	}
+	if w.Len() != 20 {
+		t.Fatalf("Expected 20 URLs after removals, got %d", w.Len())
+	}
+}
+
+func TestIncrementalWriterWithoutLastMods(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewSitemapOptions(dir, "https://www.example.com")
+	opts.LastModStrategy = LastModContent
+	w, err := NewIncrementalWriter(opts, "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("Error creating incremental writer: %v", err)
+	}
+	w.Upsert(SitemapURL{Loc: "/a"})
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap index: %v", err)
+	}
+	if len(index.Sitemaps) != 1 || index.Sitemaps[0].LastMod != "" {
+		t.Fatalf("Expected the file listed without a lastmod, got %+v", index.Sitemaps)
+	}
+}
+
+func TestIncrementalWriterRemovesLastURL(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewSitemapOptions(dir, "https://www.example.com")
+	opts.MaxURLs = 1
+	w, err := NewIncrementalWriter(opts, "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("Error creating incremental writer: %v", err)
+	}
+	w.Upsert(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	w.Upsert(SitemapURL{Loc: "/b", LastMod: "2024-01-01"})
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+
+	// Flush claims Dir like Write
+	unlock, err := lockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Remove("/a")
+	if err := w.Flush(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked, got %v", err)
+	}
+	unlock()
+
+	w.Remove("/b")
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	for _, name := range []string{"sitemap_1.xml", "sitemap_2.xml", "sitemap_index.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be removed once no URL is left, got %v", name, err)
+		}
+	}
+}
+
+func TestIncrementalWriterChecksUpserts(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewSitemapOptions(dir, "https://www.example.com").WithExclude("/private/*")
+	w, err := NewIncrementalWriter(opts, "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("Error creating incremental writer: %v", err)
+	}
+
+	// An invalid priority is dropped as by Write instead of failing every
+	// later Flush
+	if err := w.Upsert(SitemapURL{Loc: "/bad", Priority: "1.5"}); err != nil {
+		t.Fatalf("Error upserting: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Upsert(SitemapURL{Loc: "/page-" + strconv.Itoa(i)}); err != nil {
+			t.Fatalf("Error upserting: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("Error flushing: %v", err)
+		}
+	}
+	urlSet, err := LoadURLSet(filepath.Join(dir, "sitemap_1.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	if len(urlSet.URLs) != 4 || urlSet.URLs[0].Loc != "https://www.example.com/bad" || urlSet.URLs[0].Priority != "" {
+		t.Fatalf("Expected the 4 URLs with the bad priority omitted, got %+v", urlSet.URLs)
+	}
+
+	opts.Strict = true
+	var validation *ValidationError
+	if err := w.Upsert(SitemapURL{Loc: "/bad", Priority: "1.5"}); !errors.As(err, &validation) {
+		t.Fatalf("Expected a ValidationError in Strict mode, got %v", err)
+	}
+
+	// Filtered URLs are rejected and drop the entry they would replace
+	if err := w.Upsert(SitemapURL{Loc: "/private/a"}); !errors.As(err, &validation) || validation.Problem != "exclude: /private/*" {
+		t.Fatalf("Expected the excluded URL to be rejected, got %v", err)
+	}
+	if err := w.Upsert(SitemapURL{Loc: "/page-0", ExpiresAt: time.Now().Add(-time.Hour)}); err == nil {
+		t.Fatalf("Expected the expired URL to be rejected")
+	}
+	if w.Len() != 3 {
+		t.Fatalf("Expected the expired URL to be removed, got %d URLs", w.Len())
+	}
+}
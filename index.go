@@ -0,0 +1,264 @@
+package sitemap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxIndexEntries is the most sitemaps the protocol allows in an index.
+	maxIndexEntries = 50000
+	// nestedIndexPrefix names the nested indexes of an oversized index.
+	nestedIndexPrefix = "sitemap_index_"
+)
+
+// indexLimit returns the most sitemaps one index may reference.
+func (s *SitemapOptions) indexLimit() int {
+	if s.MaxIndexEntries > 0 {
+		return s.MaxIndexEntries
+	}
+	return maxIndexEntries
+}
+
+// checkIndexSize returns an error if n sitemaps cannot be referenced from
+// the index, either because they exceed the limit and NestedIndexes is not
+// set or because they exceed even one level of nesting.
+func (s *SitemapOptions) checkIndexSize(n int) error {
+	limit := s.indexLimit()
+	if n <= limit {
+		return nil
+	}
+	if !s.NestedIndexes {
+		return errorf(ErrTooManyURLs, "sitemap index would reference %d sitemaps, more than the limit of %d; raise MaxURLs or set NestedIndexes", n, limit)
+	}
+	if n > limit*limit {
+		return errorf(ErrTooManyURLs, "sitemap index would reference %d sitemaps, more than the limit of %d even with nested indexes", n, limit*limit)
+	}
+	return nil
+}
+
+// nestIndexes writes sitemaps exceeding the index limit to nested indexes
+// and returns the entries for the top-level index: the sitemaps themselves
+// if they fit, or the nested indexes. Nested indexes left over from a
+// previous run are removed.
+func (s *SitemapOptions) nestIndexes(baseSitemapURL string, sitemaps []Sitemap) ([]Sitemap, error) {
+	if err := s.checkIndexSize(len(sitemaps)); err != nil {
+		return nil, err
+	}
+	stale, err := staleSitemaps(filepath.Join(s.Dir, nestedIndexPrefix+"*"+sitemapExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range stale {
+		if err := s.tx.removeFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := s.indexLimit()
+	if len(sitemaps) <= limit {
+		return sitemaps, nil
+	}
+	var nested []Sitemap
+	for start := 0; start < len(sitemaps); start += limit {
+		chunk := sitemaps[start:min(start+limit, len(sitemaps))]
+		name := fmt.Sprintf("%s%d%s", nestedIndexPrefix, len(nested)+1, sitemapExt)
+		if err := s.writeIndexXML(filepath.Join(s.Dir, name), chunk); err != nil {
+			return nil, err
+		}
+		loc, err := s.resolveSitemapURL(baseSitemapURL, s.sitemapFileName(name))
+		if err != nil {
+			return nil, err
+		}
+		nested = append(nested, Sitemap{Loc: loc, LastMod: s.latestLastMod(chunk)})
+	}
+	return nested, nil
+}
+
+// latestLastMod returns the most recent lastmod of sitemaps, or an empty
+// string if none has one.
+func (s *SitemapOptions) latestLastMod(sitemaps []Sitemap) string {
+	latest := ""
+	var latestTime time.Time
+	for _, sitemap := range sitemaps {
+		t, err := s.parseLastMod(sitemap.LastMod)
+		if err != nil {
+			continue
+		}
+		if latest == "" || t.After(latestTime) {
+			latest, latestTime = sitemap.LastMod, t
+		}
+	}
+	return latest
+}
+
+// isNestedIndex reports whether the file name is a nested index.
+func isNestedIndex(name string) bool {
+	return strings.HasPrefix(name, nestedIndexPrefix)
+}
+
+// IndexEntry is a sitemap file referenced by WriteIndexOnly.
+type IndexEntry struct {
+	// Name is the file name in ShardDir, or the absolute URL of a sitemap
+	// served from elsewhere.
+	Name string
+	// LastMod is the lastmod of the entry. If empty, it is computed from
+	// the URLs of the local file as for Write.
+	LastMod string
+}
+
+// WriteIndexOnly (re)writes only sitemap_index.xml, referencing sitemap
+// files produced by other jobs, followed by ExternalSitemaps. If entries is
+// nil, the .xml and .xml.gz files in ShardDir are discovered and listed in
+// natural name order. Failures restore the previous index as for Write.
+func (s *SitemapOptions) WriteIndexOnly(baseSitemapURL string, entries []IndexEntry) error {
+	if _, err := parseBaseURL(baseSitemapURL); err != nil {
+		return fmt.Errorf("invalid base sitemap URL: %w", err)
+	}
+	unlock, err := lockDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if entries == nil {
+		discovered, err := s.discoverIndexEntries()
+		if err != nil {
+			return err
+		}
+		entries = discovered
+	}
+	if len(entries) == 0 && len(s.ExternalSitemaps) == 0 {
+		return fmt.Errorf("no sitemap files to index in %s", s.shardDir())
+	}
+
+	shardBaseURL, err := s.shardBaseURL(baseSitemapURL)
+	if err != nil {
+		return err
+	}
+	sitemaps := make([]Sitemap, 0, len(entries))
+	for _, entry := range entries {
+		sitemap, err := s.indexEntrySitemap(shardBaseURL, entry)
+		if err != nil {
+			return err
+		}
+		sitemaps = append(sitemaps, sitemap)
+	}
+	external, err := s.externalSitemaps()
+	if err != nil {
+		return err
+	}
+	sitemaps = append(sitemaps, external...)
+
+	tx := &writeTx{}
+	err = s.runTx(tx, func() error {
+		if err := s.writeIndexFile(baseSitemapURL, sitemaps); err != nil {
+			return err
+		}
+		if err := s.validateXMLFile(filepath.Join(s.Dir, "sitemap_index.xml"), true); err != nil {
+			return err
+		}
+		return s.describeSet()
+	})
+	if err != nil {
+		return err
+	}
+	return s.afterWrite(baseSitemapURL, tx)
+}
+
+// indexEntrySitemap returns the index entry for a local or remote sitemap.
+func (s *SitemapOptions) indexEntrySitemap(shardBaseURL string, entry IndexEntry) (Sitemap, error) {
+	if isHTTPURL(entry.Name) {
+		return Sitemap{Loc: entry.Name, LastMod: entry.LastMod}, nil
+	}
+	name := filepath.Base(entry.Name)
+	filePath := filepath.Join(s.shardDir(), name)
+	lastMod := entry.LastMod
+	if lastMod == "" {
+		urlSet, err := LoadURLSet(filePath)
+		if err != nil {
+			return Sitemap{}, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		lastMod = s.sitemapLastMod(name, urlSet.URLs)
+	} else if _, err := os.Stat(filePath); err != nil {
+		return Sitemap{}, err
+	}
+	loc, err := s.resolveSitemapURL(shardBaseURL, publicName(s.PublicNames, name))
+	if err != nil {
+		return Sitemap{}, err
+	}
+	return Sitemap{Loc: loc, LastMod: lastMod}, nil
+}
+
+// discoverIndexEntries lists the sitemap files in ShardDir, skipping
+// indexes and the .gz variant of files also present uncompressed, or the
+// uncompressed variant with Gzip and KeepPlain unless IndexPlain is set.
+func (s *SitemapOptions) discoverIndexEntries() ([]IndexEntry, error) {
+	files, err := os.ReadDir(s.shardDir())
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, f := range files {
+		name := f.Name()
+		plain := strings.TrimSuffix(name, ".gz")
+		if f.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(plain, sitemapExt) {
+			continue
+		}
+		if plain == "sitemap_index.xml" || isNestedIndex(plain) {
+			continue
+		}
+		names[name] = true
+	}
+	preferGzip := s.Gzip && s.KeepPlain && !s.IndexPlain
+	var sorted []string
+	for name := range names {
+		plain, gz := strings.CutSuffix(name, ".gz")
+		if preferGzip && !gz && names[name+".gz"] || !preferGzip && gz && names[plain] {
+			continue
+		}
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return naturalLess(sorted[i], sorted[j]) })
+
+	entries := make([]IndexEntry, len(sorted))
+	for i, name := range sorted {
+		entries[i] = IndexEntry{Name: name}
+	}
+	return entries, nil
+}
+
+// naturalLess orders names with runs of digits compared by value, so
+// sitemap_2.xml sorts before sitemap_10.xml.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, _ := strconv.ParseUint(da, 10, 64)
+			nb, _ := strconv.ParseUint(db, 10, 64)
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// leadingDigits returns the run of ASCII digits at the start of s.
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
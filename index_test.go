@@ -0,0 +1,116 @@
+package sitemap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIndexEntryLimit(t *testing.T) {
+	build := func(dir string) *SitemapOptions {
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.MaxURLs = 1
+		sm.MaxIndexEntries = 2
+		for i := 0; i < 5; i++ {
+			sm.AddURL(SitemapURL{Loc: "/page-" + strconv.Itoa(i), LastMod: "2024-01-0" + strconv.Itoa(i+1)})
+		}
+		return sm
+	}
+
+	dir := t.TempDir()
+	err := build(dir).Write("https://www.example.com/")
+	if err == nil || !strings.Contains(err.Error(), "5 sitemaps, more than the limit of 2") {
+		t.Fatalf("expected a limit error, got %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "sitemap_*.xml")); len(files) != 0 {
+		t.Fatalf("files written before failing: %v", files)
+	}
+
+	sm := build(dir)
+	sm.NestedIndexes = true
+	err = sm.Write("https://www.example.com/")
+	if err == nil || !strings.Contains(err.Error(), "even with nested indexes") {
+		t.Fatalf("expected a nesting limit error, got %v", err)
+	}
+
+	sm.MaxIndexEntries = 3
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<loc>https://www.example.com/sitemap_index_1.xml</loc>\n    <lastmod>2024-01-03</lastmod>",
+		"<loc>https://www.example.com/sitemap_index_2.xml</loc>\n    <lastmod>2024-01-05</lastmod>",
+	} {
+		if !strings.Contains(string(index), want) {
+			t.Fatalf("index missing %q:\n%s", want, index)
+		}
+	}
+	nested, err := os.ReadFile(filepath.Join(dir, "sitemap_index_2.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(nested), "<sitemap>") != 2 || !strings.Contains(string(nested), "sitemap_5.xml") {
+		t.Fatalf("unexpected nested index:\n%s", nested)
+	}
+}
+
+func TestWriteIndexOnly(t *testing.T) {
+	dir := t.TempDir()
+	job := NewSitemapOptions(dir, "https://www.example.com")
+	job.MaxURLs = 1
+	for i := 1; i <= 10; i++ {
+		job.AddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i), LastMod: fmt.Sprintf("2024-01-%02d", i)})
+	}
+	if err := job.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing shards: %v", err)
+	}
+	indexPath := filepath.Join(dir, "sitemap_index.xml")
+	if err := os.Remove(indexPath); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	if err := sm.WriteIndexOnly("https://www.example.com/", nil); err != nil {
+		t.Fatalf("Error writing index: %v", err)
+	}
+	index, err := LoadSitemapIndex(indexPath)
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	if len(index.Sitemaps) != 10 {
+		t.Fatalf("Expected 10 discovered sitemaps, got %+v", index.Sitemaps)
+	}
+	for i, sitemap := range index.Sitemaps {
+		if sitemap.Loc != fmt.Sprintf("https://www.example.com/sitemap_%d.xml", i+1) || sitemap.LastMod != fmt.Sprintf("2024-01-%02d", i+1) {
+			t.Fatalf("Unexpected entry %d: %+v", i, sitemap)
+		}
+	}
+
+	err = sm.WriteIndexOnly("https://www.example.com/", []IndexEntry{
+		{Name: "sitemap_2.xml", LastMod: "2024-05-01"},
+		{Name: "https://shop.example.com/sitemap.xml", LastMod: "2024-05-02"},
+	})
+	if err != nil {
+		t.Fatalf("Error writing index: %v", err)
+	}
+	if index, err = LoadSitemapIndex(indexPath); err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	if len(index.Sitemaps) != 2 || index.Sitemaps[0].LastMod != "2024-05-01" || index.Sitemaps[1].Loc != "https://shop.example.com/sitemap.xml" {
+		t.Fatalf("Unexpected index entries: %+v", index.Sitemaps)
+	}
+
+	if err := sm.WriteIndexOnly("https://www.example.com/", []IndexEntry{{Name: "missing.xml"}}); err == nil {
+		t.Fatalf("Expected an error for a missing sitemap file")
+	}
+	if index, err = LoadSitemapIndex(indexPath); err != nil || len(index.Sitemaps) != 2 {
+		t.Fatalf("Expected the previous index to be kept: %v", err)
+	}
+}
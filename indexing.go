@@ -0,0 +1,98 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+const indexingAPIEndpoint = "https://indexing.googleapis.com/v3/urlNotifications:publish"
+
+// IndexingAPI publishes URL notifications to the Google Indexing API, which
+// Google only permits for pages with job postings or livestream videos.
+type IndexingAPI struct {
+	// Client must attach OAuth 2.0 credentials with the
+	// https://www.googleapis.com/auth/indexing scope, for example a client
+	// from golang.org/x/oauth2/google.
+	Client *http.Client
+	// Endpoint overrides the publish endpoint.
+	Endpoint string
+	// Match selects the locs eligible for the API; nil selects all.
+	Match func(loc string) bool
+}
+
+// indexingNotification is the request body of the publish endpoint.
+type indexingNotification struct {
+	URL  string `json:"url"`
+	Type string `json:"type"`
+}
+
+// Notify sends URL_UPDATED for the locs added or modified and URL_DELETED
+// for the locs removed by the last successful Write of s, relative to its
+// PreviousState. Notifications are sent through the rate limiter and
+// concurrency cap of s, on up to Concurrency workers. It stops at the first
+// failed notification and returns the number of notifications sent.
+func (api *IndexingAPI) Notify(ctx context.Context, s *SitemapOptions) (int, error) {
+	state := s.State()
+	if state == nil {
+		return 0, fmt.Errorf("no successful Write to notify about")
+	}
+	changed, removed := state.Diff(s.PreviousState)
+
+	var notifications []indexingNotification
+	for _, loc := range changed {
+		if api.Match == nil || api.Match(loc) {
+			notifications = append(notifications, indexingNotification{URL: loc, Type: "URL_UPDATED"})
+		}
+	}
+	for _, loc := range removed {
+		if api.Match == nil || api.Match(loc) {
+			notifications = append(notifications, indexingNotification{URL: loc, Type: "URL_DELETED"})
+		}
+	}
+
+	var sent atomic.Int64
+	err := s.parallel(ctx, len(notifications), func(i int) error {
+		if err := api.publish(ctx, s, notifications[i]); err != nil {
+			return err
+		}
+		sent.Add(1)
+		return nil
+	})
+	return int(sent.Load()), err
+}
+
+func (api *IndexingAPI) publish(ctx context.Context, s *SitemapOptions, n indexingNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	endpoint := api.Endpoint
+	if endpoint == "" {
+		endpoint = indexingAPIEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := api.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := s.doRequestWith(client, req)
+	if err != nil {
+		return fmt.Errorf("failed to notify %s for %s: %v", n.Type, n.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to notify %s for %s: %s: %s", n.Type, n.URL, resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIndexingAPINotify(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n indexingNotification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("decoding notification: %v", err)
+		}
+		mu.Lock()
+		got = append(got, n.Type+" "+n.URL)
+		mu.Unlock()
+		if strings.HasSuffix(n.URL, "/jobs/fail") {
+			http.Error(w, `{"error": "quota"}`, http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURLs([]SitemapURL{{Loc: "/jobs/1", LastMod: "2024-01-01"}, {Loc: "/jobs/2", LastMod: "2024-01-01"}, {Loc: "/about"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	sm.Reset()
+	sm.AddURLs([]SitemapURL{{Loc: "/jobs/1", LastMod: "2024-01-01"}, {Loc: "/jobs/3", LastMod: "2024-01-02"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	api := &IndexingAPI{
+		Client:   server.Client(),
+		Endpoint: server.URL,
+		Match:    func(loc string) bool { return strings.Contains(loc, "/jobs/") },
+	}
+	sent, err := api.Notify(context.Background(), sm)
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	want := "URL_UPDATED https://www.example.com/jobs/3\nURL_DELETED https://www.example.com/jobs/2"
+	if sent != 2 || strings.Join(got, "\n") != want {
+		t.Fatalf("sent %d notifications:\n%s", sent, strings.Join(got, "\n"))
+	}
+
+	sm.Reset()
+	sm.AddURLs([]SitemapURL{{Loc: "/jobs/fail"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := api.Notify(context.Background(), sm); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+}
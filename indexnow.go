@@ -0,0 +1,190 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+const (
+	indexNowEndpoint = "https://api.indexnow.org/indexnow"
+	// indexNowMaxBatch is the most URLs IndexNow accepts per request.
+	indexNowMaxBatch = 10000
+)
+
+// indexNowKeyPattern matches the keys IndexNow accepts.
+var indexNowKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9-]{8,128}$`)
+
+// IndexNow submits changed URLs to IndexNow, which shares them with the
+// participating search engines. The key must be served as a text file at
+// KeyLocation, or at /<key>.txt on each host if KeyLocation is empty.
+type IndexNow struct {
+	Key         string
+	KeyLocation string
+	// Client sends the requests; the client of s if nil.
+	Client *http.Client
+	// Endpoint overrides https://api.indexnow.org/indexnow.
+	Endpoint string
+	// BatchSize is the most URLs per request, capped at and defaulting to
+	// 10,000, the IndexNow limit.
+	BatchSize int
+	// Retries is how many more times a batch failing with a network error,
+	// 429 or 5xx is sent. The failed batches of a round are retried
+	// together after RetryDelay, one second if zero, doubling each round.
+	Retries    int
+	RetryDelay time.Duration
+	// Match selects the locs to submit; nil selects all.
+	Match func(loc string) bool
+}
+
+// BatchResult is the outcome of one batch of URLs submitted.
+type BatchResult struct {
+	Host     string
+	URLs     []string
+	Attempts int
+	Status   int   // HTTP status of the last attempt, 0 if it got none
+	Err      error // nil if the batch was accepted
+}
+
+// indexNowRequest is the request body of the IndexNow endpoint.
+type indexNowRequest struct {
+	Host        string   `json:"host"`
+	Key         string   `json:"key"`
+	KeyLocation string   `json:"keyLocation,omitempty"`
+	URLList     []string `json:"urlList"`
+}
+
+// Submit sends the locs added, modified or removed by the last successful
+// Write of s, relative to its PreviousState, in batches of at most
+// BatchSize URLs of one host. Batches are sent through the rate limiter and
+// concurrency cap of s, on up to Concurrency workers, and only the batches
+// that failed are retried. It returns the outcome of every batch and the
+// error of the first batch that still failed, if any.
+func (n *IndexNow) Submit(ctx context.Context, s *SitemapOptions) ([]BatchResult, error) {
+	if !indexNowKeyPattern.MatchString(n.Key) {
+		return nil, fmt.Errorf("invalid IndexNow key %q: want 8 to 128 letters, digits or dashes", n.Key)
+	}
+	state := s.State()
+	if state == nil {
+		return nil, fmt.Errorf("no successful Write to submit")
+	}
+	changed, removed := state.Diff(s.PreviousState)
+
+	results := n.batches(append(changed, removed...))
+	delay := n.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	pending := make([]int, len(results))
+	for i := range results {
+		pending[i] = i
+	}
+	for round := 0; len(pending) > 0; round++ {
+		if round > 0 {
+			timer := time.NewTimer(delay << (round - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return results, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		retry := make([]bool, len(pending))
+		s.parallel(ctx, len(pending), func(i int) error {
+			retry[i] = n.send(ctx, s, &results[pending[i]])
+			return nil
+		})
+		var next []int
+		for i, p := range pending {
+			if retry[i] && round < n.Retries && ctx.Err() == nil {
+				next = append(next, p)
+			}
+		}
+		pending = next
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			return results, r.Err
+		}
+	}
+	return results, nil
+}
+
+// batches splits the matching locs by host into batches of at most
+// BatchSize, keeping the order of locs.
+func (n *IndexNow) batches(locs []string) []BatchResult {
+	size := n.BatchSize
+	if size <= 0 || size > indexNowMaxBatch {
+		size = indexNowMaxBatch
+	}
+	var results []BatchResult
+	open := make(map[string]int) // index of the batch filling up per host
+	for _, loc := range locs {
+		if n.Match != nil && !n.Match(loc) {
+			continue
+		}
+		u, err := url.Parse(loc)
+		if err != nil {
+			continue
+		}
+		i, ok := open[u.Host]
+		if !ok || len(results[i].URLs) == size {
+			i = len(results)
+			open[u.Host] = i
+			results = append(results, BatchResult{Host: u.Host})
+		}
+		results[i].URLs = append(results[i].URLs, loc)
+	}
+	return results
+}
+
+// send submits the batch of r, recording the outcome in r, and reports
+// whether a failure is worth retrying.
+func (n *IndexNow) send(ctx context.Context, s *SitemapOptions, r *BatchResult) bool {
+	r.Attempts++
+	r.Status = 0
+	body, err := json.Marshal(indexNowRequest{Host: r.Host, Key: n.Key, KeyLocation: n.KeyLocation, URLList: r.URLs})
+	if err != nil {
+		r.Err = err
+		return false
+	}
+	endpoint := n.Endpoint
+	if endpoint == "" {
+		endpoint = indexNowEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		r.Err = err
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	client := n.Client
+	if client == nil {
+		client = s.httpClient()
+	}
+	resp, err := s.doRequestWith(client, req)
+	if err != nil {
+		r.Err = fmt.Errorf("failed to submit %d URLs of %s: %v", len(r.URLs), r.Host, err)
+		return true
+	}
+	defer resp.Body.Close()
+	r.Status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		r.Err = fmt.Errorf("failed to submit %d URLs of %s: %s: %s", len(r.URLs), r.Host, resp.Status, bytes.TrimSpace(detail))
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	}
+	r.Err = nil
+	return false
+}
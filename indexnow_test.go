@@ -0,0 +1,69 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIndexNowSubmit(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	failures := map[string]int{"https://www.example.com/c": 1, "https://www.example.com/e": 5}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req indexNowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Key != "0123456789abcdef" || req.Host != "www.example.com" {
+			t.Errorf("unexpected request %+v", req)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, strings.Join(req.URLList, ","))
+		if failures[req.URLList[0]] > 0 {
+			failures[req.URLList[0]]--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}, {Loc: "/c"}, {Loc: "/d"}, {Loc: "/e"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	n := &IndexNow{Key: "0123456789abcdef", Client: server.Client(), Endpoint: server.URL, BatchSize: 2, Retries: 1, RetryDelay: time.Millisecond}
+	results, err := n.Submit(context.Background(), sm)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Expected the batch failing twice to be returned, got %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 batches, got %+v", results)
+	}
+	wantAttempts := []int{1, 2, 2}
+	for i, r := range results {
+		if r.Attempts != wantAttempts[i] || (i < 2) != (r.Err == nil) {
+			t.Fatalf("Unexpected outcome of batch %d: %+v", i, r)
+		}
+	}
+	if results[0].Status != http.StatusAccepted || results[2].Status != http.StatusServiceUnavailable {
+		t.Fatalf("Unexpected statuses: %d and %d", results[0].Status, results[2].Status)
+	}
+	// The accepted batch is not sent again
+	if strings.Count(strings.Join(got, "\n"), "/a,") != 1 || len(got) != 5 {
+		t.Fatalf("Unexpected requests:\n%s", strings.Join(got, "\n"))
+	}
+
+	if _, err := (&IndexNow{Key: "short"}).Submit(context.Background(), sm); err == nil {
+		t.Fatal("Expected an error for an invalid key")
+	}
+}
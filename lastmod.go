@@ -0,0 +1,165 @@
+package sitemap
+
+import "time"
+
+// LastModFormat controls the precision of the lastmod values written to
+// sitemaps and the sitemap index.
+type LastModFormat int
+
+const (
+	// LastModDate writes date-only values, e.g. 2024-06-01.
+	LastModDate LastModFormat = iota
+	// LastModSeconds writes RFC3339 timestamps with second precision, using
+	// Z for UTC, e.g. 2024-06-01T12:30:00Z.
+	LastModSeconds
+	// LastModOffset writes RFC3339 timestamps with an explicit numeric
+	// offset even for UTC, e.g. 2024-06-01T12:30:00+00:00.
+	LastModOffset
+)
+
+// LastModStrategy controls the lastmod of URLs that do not carry a usable
+// one. Emitting the generation time on every run trains crawlers to ignore
+// the signal, so sets regenerated often may prefer LastModContent.
+type LastModStrategy int
+
+const (
+	// LastModGenerated replaces a missing or invalid lastmod with the
+	// generation time.
+	LastModGenerated LastModStrategy = iota
+	// LastModContent keeps only the lastmod given for the content and omits
+	// missing or invalid values.
+	LastModContent
+	// LastModDaily is LastModGenerated with every lastmod truncated to the
+	// start of its day in Location, so values change at most once a day.
+	LastModDaily
+)
+
+// lastModStrategy returns the strategy for URLs of the given group.
+func (s *SitemapOptions) lastModStrategy(group string) LastModStrategy {
+	if name := groupFileName(group); name != "" {
+		for g, strategy := range s.GroupLastModStrategies {
+			if groupFileName(g) == name {
+				return strategy
+			}
+		}
+	}
+	return s.LastModStrategy
+}
+
+// lastModFor returns the lastmod value for a URL of the given group whose
+// lastmod is value: the parsed value capped at now, or the fallback the
+// strategy picks. It returns "" if the lastmod is omitted.
+func (s *SitemapOptions) lastModFor(group, value string, now time.Time) string {
+	strategy := s.lastModStrategy(group)
+	t, err := s.parseLastMod(value)
+	switch {
+	case value == "" || err != nil:
+		if strategy == LastModContent {
+			return ""
+		}
+		t = now
+	case t.After(now):
+		t = now
+	}
+	if strategy == LastModDaily {
+		year, month, day := t.In(s.location()).Date()
+		t = time.Date(year, month, day, 0, 0, 0, 0, s.location())
+	}
+	return s.formatLastMod(t)
+}
+
+// isGeneratedLastMod reports whether lastModFor replaces value with the
+// generation time for a URL of the given group.
+func (s *SitemapOptions) isGeneratedLastMod(group, value string) bool {
+	if s.lastModStrategy(group) == LastModContent {
+		return false
+	}
+	_, err := s.parseLastMod(value)
+	return value == "" || err != nil
+}
+
+// lastModLayouts are the W3C datetime variants accepted as input lastmod.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// layout returns the time layout used to format lastmod values.
+func (f LastModFormat) layout() string {
+	switch f {
+	case LastModSeconds:
+		return time.RFC3339
+	case LastModOffset:
+		return "2006-01-02T15:04:05-07:00"
+	default:
+		return "2006-01-02"
+	}
+}
+
+// location returns the timezone used for generated timestamps.
+func (s *SitemapOptions) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// formatLastMod formats t according to the configured format and timezone.
+func (s *SitemapOptions) formatLastMod(t time.Time) string {
+	return t.In(s.location()).Format(s.LastModFormat.layout())
+}
+
+// parseLastMod parses a lastmod value in any of the accepted W3C datetime
+// variants. Date-only values are interpreted in the configured timezone.
+func (s *SitemapOptions) parseLastMod(value string) (time.Time, error) {
+	var err error
+	for _, layout := range lastModLayouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, value, s.location())
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// sitemapLastMod returns the lastmod of a sitemap in the index: the value
+// from the IndexLastMod hook if set, otherwise the most recent lastmod of the
+// URLs it contains, falling back to the write time when none carry one
+// unless their group's LastModStrategy is LastModContent. With a
+// PreviousState, a generated lastmod counts as the time the URL last
+// changed, so a rewritten sitemap of unchanged URLs keeps its lastmod.
+func (s *SitemapOptions) sitemapLastMod(sitemapName string, urls []SitemapURL) string {
+	if s.IndexLastMod != nil {
+		if lastMod := s.IndexLastMod(sitemapName, urls); lastMod != "" {
+			return lastMod
+		}
+	}
+
+	var latest time.Time
+	for _, u := range urls {
+		lastMod := u.LastMod
+		if u.lastModGenerated && s.PreviousState != nil && !s.generated.IsZero() {
+			// Unchanged URLs keep the time the previous run recorded
+			since := s.PreviousState.changedSince(u.Loc, fingerprint(u), s.generated)
+			lastMod = s.lastModFor(u.Group, since.Format(time.RFC3339), s.now())
+		}
+		if lastMod == "" {
+			continue
+		}
+		t, err := s.parseLastMod(lastMod)
+		if err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	if latest.IsZero() {
+		group := ""
+		if len(urls) > 0 {
+			group = urls[0].Group
+		}
+		return s.lastModFor(group, "", s.now())
+	}
+	return s.formatLastMod(latest)
+}
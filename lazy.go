@@ -0,0 +1,74 @@
+package sitemap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// idPlaceholder is replaced by the ID in templates expanded without Expand.
+const idPlaceholder = "{id}"
+
+// idEntry is a URL added with AddID, expanded at write time.
+type idEntry struct {
+	id       int64
+	template string
+}
+
+// AddID adds the URL of id, built from template, as an entry of a few
+// bytes that is expanded into a SitemapURL only when Write runs, for
+// catalogs whose URLs derive from a numeric ID. Expand does the expansion
+// if set; otherwise {id} in template is replaced by id to make the loc, as
+// in AddID(42, "/products/{id}"). Templates are kept as passed, so entries
+// sharing one string share its memory.
+//
+// Expanded URLs go through the same normalization and OnAddURL as those
+// of AddURL. They are not flushed over MemoryLimit, and every Write expands
+// them anew, so they are not kept in memory between runs.
+func (s *SitemapOptions) AddID(id int64, template string) {
+	mu := s.lock()
+	defer mu.Unlock()
+	s.ids = append(s.ids, idEntry{id: id, template: template})
+}
+
+// expandID returns the SitemapURL of an entry added with AddID.
+func (s *SitemapOptions) expandID(entry idEntry) (SitemapURL, error) {
+	if s.Expand != nil {
+		u, err := s.Expand(entry.id, entry.template)
+		if err != nil {
+			return SitemapURL{}, fmt.Errorf("failed to expand ID %d: %v", entry.id, err)
+		}
+		return u, nil
+	}
+	if !strings.Contains(entry.template, idPlaceholder) {
+		return SitemapURL{}, fmt.Errorf("template %q of ID %d has no %s and no Expand is set", entry.template, entry.id, idPlaceholder)
+	}
+	return SitemapURL{Loc: strings.ReplaceAll(entry.template, idPlaceholder, strconv.FormatInt(entry.id, 10))}, nil
+}
+
+// bufferedURLs returns the URLs added with AddURL followed by the entries
+// added with AddID, expanded and normalized as AddURL does. URLs rejected
+// by OnAddURL are excluded in report. Without such entries, the URLs
+// themselves are returned rather than a copy.
+func (s *SitemapOptions) bufferedURLs(report *Report) ([]SitemapURL, error) {
+	if len(s.ids) == 0 {
+		return s.URLs, nil
+	}
+	urls := make([]SitemapURL, len(s.URLs), len(s.URLs)+len(s.ids))
+	copy(urls, s.URLs)
+	for _, entry := range s.ids {
+		u, err := s.expandID(entry)
+		if err != nil {
+			return nil, err
+		}
+		u = s.normalizeURL(u)
+		if s.OnAddURL != nil {
+			if err := s.OnAddURL(&u); err != nil {
+				s.exclude(report, ExcludedURL{Loc: u.Loc, Reason: "rejected: " + err.Error(), Meta: u.Meta})
+				continue
+			}
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
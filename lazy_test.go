@@ -0,0 +1,67 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddID(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddID(42, "/products/{id}")
+	sm.AddID(7, "/products/{id}")
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	urlSet, err := LoadURLSet(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap: %v", err)
+	}
+	if len(urlSet.URLs) != 3 || urlSet.URLs[1].Loc != "https://www.example.com/products/42" || urlSet.URLs[2].Loc != "https://www.example.com/products/7" {
+		t.Fatalf("Expected the IDs expanded after the URLs, got %+v", urlSet.URLs)
+	}
+
+	// Expand builds the URLs, and OnAddURL still applies to them
+	sm.Expand = func(id int64, template string) (SitemapURL, error) {
+		return SitemapURL{Loc: fmt.Sprintf(template, id), Priority: "0.8"}, nil
+	}
+	sm.OnAddURL = func(u *SitemapURL) error {
+		if u.Loc == "https://www.example.com/p/7" {
+			return errors.New("discontinued")
+		}
+		return nil
+	}
+	sm.Reset()
+	sm.AddID(42, "/p/%d")
+	sm.AddID(7, "/p/%d")
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	urlSet, err = LoadURLSet(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap: %v", err)
+	}
+	if len(urlSet.URLs) != 1 || urlSet.URLs[0].Loc != "https://www.example.com/p/42" || urlSet.URLs[0].Priority != "0.8" {
+		t.Fatalf("Expected the URL built by Expand, got %+v", urlSet.URLs)
+	}
+	if excluded := sm.Report().Excluded; len(excluded) != 1 || excluded[0].Reason != "rejected: discontinued" {
+		t.Fatalf("Expected the rejected ID to be excluded, got %+v", excluded)
+	}
+
+	sm.Expand = func(id int64, template string) (SitemapURL, error) {
+		return SitemapURL{}, errors.New("catalog unavailable")
+	}
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected the Expand error to fail the Write")
+	}
+	sm.Expand = nil
+	sm.OnAddURL = nil
+	sm.Reset()
+	sm.AddID(1, "/products")
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected a template without {id} to fail the Write")
+	}
+}
@@ -0,0 +1,54 @@
+package sitemap
+
+// Limits of the sitemap protocol, which MaxURLs, MaxFileSize,
+// MaxIndexEntries and GroupLimits may lower but not raise.
+const (
+	protocolMaxURLs     = 50000
+	protocolMaxFileSize = 50 * 1024 * 1024
+)
+
+// Limits are the effective limits a Write applied.
+type Limits struct {
+	MaxURLs         int // URLs per sitemap file
+	MaxFileSize     int // bytes per sitemap file
+	MaxIndexEntries int // sitemaps per index
+	// GroupLimits are the limits of the groups with GroupLimits, keyed
+	// like GroupLimits, with MaxURLs filled in.
+	GroupLimits map[string]GroupLimit
+}
+
+// maxFileSize returns the most bytes a sitemap file may hold.
+func (s *SitemapOptions) maxFileSize() int {
+	if s.MaxFileSize > 0 {
+		return s.MaxFileSize
+	}
+	return protocolMaxFileSize
+}
+
+// checkLimits returns an error matching ErrInvalidLimit if a configured
+// limit is beyond the protocol, and otherwise the effective limits.
+func (s *SitemapOptions) checkLimits() (Limits, error) {
+	if s.MaxURLs <= 0 || s.MaxURLs > protocolMaxURLs {
+		return Limits{}, errorf(ErrInvalidLimit, "MaxURLs of %d is outside 1 to %d", s.MaxURLs, protocolMaxURLs)
+	}
+	if s.MaxFileSize > protocolMaxFileSize {
+		return Limits{}, errorf(ErrInvalidLimit, "MaxFileSize of %d is more than the %d bytes allowed", s.MaxFileSize, protocolMaxFileSize)
+	}
+	if s.MaxIndexEntries > maxIndexEntries {
+		return Limits{}, errorf(ErrInvalidLimit, "MaxIndexEntries of %d is more than the %d allowed", s.MaxIndexEntries, maxIndexEntries)
+	}
+	limits := Limits{MaxURLs: s.MaxURLs, MaxFileSize: s.maxFileSize(), MaxIndexEntries: s.indexLimit()}
+	for group, limit := range s.GroupLimits {
+		if limit.MaxURLs > protocolMaxURLs {
+			return Limits{}, errorf(ErrInvalidLimit, "MaxURLs of %d for group '%s' is more than the %d allowed", limit.MaxURLs, group, protocolMaxURLs)
+		}
+		if limit.MaxFileSize > protocolMaxFileSize {
+			return Limits{}, errorf(ErrInvalidLimit, "MaxFileSize of %d for group '%s' is more than the %d bytes allowed", limit.MaxFileSize, group, protocolMaxFileSize)
+		}
+		if limits.GroupLimits == nil {
+			limits.GroupLimits = make(map[string]GroupLimit, len(s.GroupLimits))
+		}
+		limits.GroupLimits[group] = s.groupLimit(group)
+	}
+	return limits, nil
+}
@@ -0,0 +1,37 @@
+package sitemap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLimitsAreEnforced(t *testing.T) {
+	for name, configure := range map[string]func(sm *SitemapOptions){
+		"MaxURLs":         func(sm *SitemapOptions) { sm.MaxURLs = 100000 },
+		"zero MaxURLs":    func(sm *SitemapOptions) { sm.MaxURLs = 0 },
+		"MaxFileSize":     func(sm *SitemapOptions) { sm.MaxFileSize = 100 * 1024 * 1024 },
+		"MaxIndexEntries": func(sm *SitemapOptions) { sm.MaxIndexEntries = 60000 },
+		"GroupLimits":     func(sm *SitemapOptions) { sm.GroupLimits = map[string]GroupLimit{"shop": {MaxURLs: 50001}} },
+	} {
+		sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+		configure(sm)
+		sm.AddURL(SitemapURL{Loc: "/"})
+		if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("%s: expected ErrInvalidLimit, got %v", name, err)
+		}
+	}
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.MaxURLs = 50000
+	sm.MaxFileSize = 0
+	sm.GroupLimits = map[string]GroupLimit{"shop": {MaxFileSize: 1024}}
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	limits := sm.Report().Limits
+	if limits.MaxURLs != 50000 || limits.MaxFileSize != 52428800 || limits.MaxIndexEntries != 50000 ||
+		limits.GroupLimits["shop"] != (GroupLimit{MaxURLs: 50000, MaxFileSize: 1024}) {
+		t.Fatalf("Unexpected effective limits %+v", limits)
+	}
+}
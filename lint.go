@@ -0,0 +1,315 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/libxml2/xsd"
+)
+
+const (
+	// lintMaxLocLength is the longest loc the protocol allows.
+	lintMaxLocLength = 2048
+	// lintMaxDepth is the deepest nesting of indexes Lint follows.
+	lintMaxDepth = 3
+)
+
+// LintReport lists the protocol problems Lint found in a sitemap or sitemap
+// index and the sitemap files it references. It encodes to JSON for other
+// tooling.
+type LintReport struct {
+	Sitemaps []string    `json:"sitemaps"` // sitemaps checked, in order
+	URLs     int         `json:"urls"`
+	Issues   []LintIssue `json:"issues"`
+}
+
+// LintIssue is one problem found by Lint.
+type LintIssue struct {
+	Sitemap string `json:"sitemap"`
+	Loc     string `json:"loc,omitempty"` // URL or index entry concerned, if any
+	Problem string `json:"problem"`
+}
+
+// Lint checks the sitemap or sitemap index at urlOrPath, an http(s) URL or
+// a local file, gzipped or not, against the protocol: the XSD, the size and
+// entry limits, absolute locs of at most 2,048 characters on the host and
+// under the directory of the sitemap, lastmod, changefreq and priority
+// values, and duplicate locs. The sitemaps of an index are checked too,
+// fetched from their locs or, for a local index, read from the files of the
+// same name next to it. BaseURL, if set, is the host local files must list.
+//
+// Lint changes nothing and works on any sitemap, not only those written by
+// this package. Only failing to read urlOrPath itself and ctx ending are
+// returned as errors; every other problem is recorded in the report.
+func (s *SitemapOptions) Lint(ctx context.Context, urlOrPath string) (*LintReport, error) {
+	data, err := s.lintRead(ctx, urlOrPath)
+	if err != nil {
+		return nil, err
+	}
+	report := &LintReport{}
+	s.lintData(ctx, urlOrPath, data, 0, report)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// lintRead returns the uncompressed content of source, reading at most one
+// byte more than the protocol allows.
+func (s *SitemapOptions) lintRead(ctx context.Context, source string) ([]byte, error) {
+	var r io.ReadCloser
+	if isRemote(source) {
+		body, err := s.fetch(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		r = body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	rc, err := maybeGunzip(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %v", source, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, protocolMaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", source, err)
+	}
+	return data, nil
+}
+
+// isRemote reports whether source is an http(s) URL rather than a path.
+func isRemote(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// lintData checks the sitemap or index read from source, at the given
+// nesting depth, adding its findings to report.
+func (s *SitemapOptions) lintData(ctx context.Context, source string, data []byte, depth int, report *LintReport) {
+	report.Sitemaps = append(report.Sitemaps, source)
+	issue := func(loc, format string, args ...any) {
+		report.Issues = append(report.Issues, LintIssue{Sitemap: source, Loc: loc, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if len(data) > protocolMaxFileSize {
+		issue("", "more than %d bytes uncompressed", protocolMaxFileSize)
+		return
+	}
+	root, err := rootElement(data)
+	if err != nil {
+		issue("", "not well-formed XML: %v", err)
+		return
+	}
+	isIndex := root.Local == "sitemapindex"
+	if !isIndex && root.Local != "urlset" {
+		issue("", "root element <%s> is neither urlset nor sitemapindex", root.Local)
+		return
+	}
+	if root.Space != "http://www.sitemaps.org/schemas/sitemap/0.9" {
+		issue("", "root element <%s> is in namespace %q, not http://www.sitemaps.org/schemas/sitemap/0.9", root.Local, root.Space)
+	}
+	if err := validateXML(data, isIndex); err != nil {
+		var violations []error
+		if se, ok := err.(schemaError); ok {
+			if sve, ok := se.error.(xsd.SchemaValidationError); ok {
+				violations = sve.Errors()
+			}
+		}
+		for _, violation := range violations {
+			issue("", "%s", strings.TrimSpace(violation.Error()))
+		}
+		if len(violations) == 0 {
+			issue("", "%v", err)
+		}
+	}
+
+	scope := s.lintScope(source)
+	if isIndex {
+		var index SitemapIndex
+		if err := xml.Unmarshal(data, &index); err != nil {
+			issue("", "failed to parse sitemap index: %v", err)
+			return
+		}
+		s.lintIndex(ctx, source, index.Sitemaps, scope, depth, report, issue)
+		return
+	}
+
+	var urlSet URLSet
+	if err := xml.Unmarshal(data, &urlSet); err != nil {
+		issue("", "failed to parse sitemap: %v", err)
+		return
+	}
+	report.URLs += len(urlSet.URLs)
+	if len(urlSet.URLs) == 0 {
+		issue("", "lists no URLs")
+	}
+	if len(urlSet.URLs) > protocolMaxURLs {
+		issue("", "lists %d URLs, more than %d", len(urlSet.URLs), protocolMaxURLs)
+	}
+	seen := make(map[string]bool, len(urlSet.URLs))
+	for _, u := range urlSet.URLs {
+		loc := strings.TrimSpace(u.Loc)
+		if problem := lintLoc(loc, scope, true); problem != "" {
+			issue(loc, "%s", problem)
+		}
+		if seen[loc] {
+			issue(loc, "duplicate loc")
+		}
+		seen[loc] = true
+		if u.LastMod != "" {
+			if _, err := s.parseLastMod(strings.TrimSpace(u.LastMod)); err != nil {
+				issue(loc, "invalid lastmod '%s'", u.LastMod)
+			}
+		}
+		if u.ChangeFreq != "" && !changeFreqs[strings.TrimSpace(u.ChangeFreq)] {
+			issue(loc, "invalid changefreq '%s'", u.ChangeFreq)
+		}
+		if u.Priority != "" {
+			if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err != nil || p < 0 || p > 1 {
+				issue(loc, "invalid priority '%s'", u.Priority)
+			}
+		}
+	}
+}
+
+// lintIndex checks the entries of the index read from source, then the
+// sitemaps they reference on up to Concurrency workers.
+func (s *SitemapOptions) lintIndex(ctx context.Context, source string, sitemaps []Sitemap, scope *url.URL, depth int, report *LintReport, issue func(loc, format string, args ...any)) {
+	if len(sitemaps) == 0 {
+		issue("", "references no sitemaps")
+	}
+	if len(sitemaps) > maxIndexEntries {
+		issue("", "references %d sitemaps, more than %d", len(sitemaps), maxIndexEntries)
+	}
+	var children []string
+	seen := make(map[string]bool, len(sitemaps))
+	for _, sitemap := range sitemaps {
+		loc := strings.TrimSpace(sitemap.Loc)
+		if problem := lintLoc(loc, scope, false); problem != "" {
+			issue(loc, "%s", problem)
+			continue
+		}
+		if sitemap.LastMod != "" {
+			if _, err := s.parseLastMod(strings.TrimSpace(sitemap.LastMod)); err != nil {
+				issue(loc, "invalid lastmod '%s'", sitemap.LastMod)
+			}
+		}
+		if seen[loc] {
+			issue(loc, "duplicate loc")
+			continue
+		}
+		seen[loc] = true
+		children = append(children, loc)
+	}
+	if depth == lintMaxDepth {
+		if len(children) > 0 {
+			issue("", "indexes nested more than %d deep are not checked", lintMaxDepth)
+		}
+		return
+	}
+
+	// Check each child into a report of its own, merged in index order
+	reports := make([]LintReport, len(children))
+	s.parallel(ctx, len(children), func(i int) error {
+		child := children[i]
+		if !isRemote(source) {
+			u, _ := url.Parse(child)
+			child = filepath.Join(filepath.Dir(source), path.Base(u.Path))
+		}
+		data, err := s.lintRead(ctx, child)
+		if err != nil {
+			reports[i].Issues = []LintIssue{{Sitemap: source, Loc: children[i], Problem: err.Error()}}
+			return nil
+		}
+		s.lintData(ctx, child, data, depth+1, &reports[i])
+		return nil
+	})
+	for _, r := range reports {
+		report.Sitemaps = append(report.Sitemaps, r.Sitemaps...)
+		report.URLs += r.URLs
+		report.Issues = append(report.Issues, r.Issues...)
+	}
+}
+
+// lintScope returns the URL the locs of source must be on: source itself
+// if fetched, otherwise BaseURL if set, or nil.
+func (s *SitemapOptions) lintScope(source string) *url.URL {
+	raw := source
+	if !isRemote(source) {
+		if s.BaseURL == "" {
+			return nil
+		}
+		raw = s.BaseURL
+	}
+	scope, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	if !isRemote(source) {
+		// A local file has no directory of its own on the host
+		scope.Path = "/"
+	}
+	return scope
+}
+
+// lintLoc returns the problem with loc, or "" if there is none. Locs must
+// be on the host of scope, if not nil, and, if inScope, under its directory.
+func lintLoc(loc string, scope *url.URL, inScope bool) string {
+	if loc == "" {
+		return "missing loc"
+	}
+	if len(loc) > lintMaxLocLength {
+		return fmt.Sprintf("loc is %d characters, more than %d", len(loc), lintMaxLocLength)
+	}
+	u, err := url.Parse(loc)
+	if err != nil {
+		return fmt.Sprintf("invalid loc: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "loc is not an absolute http(s) URL"
+	}
+	if scope == nil {
+		return ""
+	}
+	if !strings.EqualFold(u.Host, scope.Host) {
+		return fmt.Sprintf("loc is on %s, not %s", u.Host, scope.Host)
+	}
+	dir := path.Dir(scope.Path)
+	if strings.HasSuffix(scope.Path, "/") {
+		dir = strings.TrimSuffix(scope.Path, "/")
+	}
+	if inScope && dir != "/" && dir != "." && !strings.HasPrefix(u.Path, dir+"/") {
+		return fmt.Sprintf("loc is outside %s/, the directory of the sitemap", dir)
+	}
+	return ""
+}
+
+// rootElement returns the name of the root element of the XML in data.
+func rootElement(data []byte) (xml.Name, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
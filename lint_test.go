@@ -0,0 +1,80 @@
+package sitemap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/b", Priority: "0.8"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	linter := NewSitemapOptions("", "https://www.example.com")
+	report, err := linter.Lint(context.Background(), filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(report.Issues) != 0 || len(report.Sitemaps) != 3 || report.URLs != 2 {
+		t.Fatalf("Expected a clean report of 3 sitemaps and 2 URLs, got %+v", report)
+	}
+
+	bad := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://www.example.com/docs/a</loc><lastmod>yesterday</lastmod></url>
+  <url><loc>https://www.example.com/docs/a</loc><priority>2</priority></url>
+  <url><loc>https://other.example.com/docs/b</loc></url>
+  <url><loc>https://www.example.com/blog/c</loc><changefreq>sometimes</changefreq></url>
+  <url><loc>/relative</loc></url>
+</urlset>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.ReplaceAll(bad, "https://www.example.com", "http://"+r.Host)))
+	}))
+	defer server.Close()
+	linter.HTTPClient = server.Client()
+	report, err = linter.Lint(context.Background(), server.URL+"/docs/sitemap.xml")
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	problems := make(map[string]bool)
+	for _, issue := range report.Issues {
+		if issue.Sitemap != server.URL+"/docs/sitemap.xml" {
+			t.Fatalf("Unexpected sitemap in %+v", issue)
+		}
+		problems[strings.Fields(issue.Problem)[0]+" "+strings.TrimPrefix(issue.Loc, server.URL)] = true
+	}
+	for _, want := range []string{
+		"invalid /docs/a", "duplicate /docs/a", "loc https://other.example.com/docs/b",
+		"loc /blog/c", "invalid /blog/c", "loc /relative",
+		// The XSD rejects the lastmod, changefreq and priority too
+		"Element ",
+	} {
+		found := false
+		for problem := range problems {
+			found = found || strings.HasPrefix(problem, want)
+		}
+		if !found {
+			t.Errorf("Expected a problem %q, got %v", want, report.Issues)
+		}
+	}
+
+	if _, err := linter.Lint(context.Background(), filepath.Join(dir, "missing.xml")); err == nil {
+		t.Fatal("Expected an error for a missing sitemap")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "feed.xml"), []byte("<rss/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := linter.Lint(context.Background(), filepath.Join(dir, "feed.xml")); len(report.Issues) != 1 {
+		t.Fatalf("Expected the root element to be reported, got %+v", report.Issues)
+	}
+}
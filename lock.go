@@ -0,0 +1,43 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	lockedDirsMu sync.Mutex
+	lockedDirs   = make(map[string]bool) // Dirs written in this process
+)
+
+// lockDir claims dir for one Write, both within this process and, where the
+// platform supports file locks, against other processes such as an
+// overlapping cron run. It returns an error matching ErrLocked if dir is
+// already claimed, and otherwise a function releasing it.
+func lockDir(dir string) (func(), error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	lockedDirsMu.Lock()
+	defer lockedDirsMu.Unlock()
+	if lockedDirs[abs] {
+		return nil, errorf(ErrLocked, "another Write is writing to %s in this process", dir)
+	}
+
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, err
+	}
+	unflock, err := flockDir(abs)
+	if err != nil {
+		return nil, err
+	}
+	lockedDirs[abs] = true
+	return func() {
+		unflock()
+		lockedDirsMu.Lock()
+		delete(lockedDirs, abs)
+		lockedDirsMu.Unlock()
+	}, nil
+}
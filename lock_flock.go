@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd
+
+package sitemap
+
+import (
+	"os"
+	"syscall"
+)
+
+// flockDir takes an exclusive lock on the directory dir itself, so no lock
+// file is left behind. The kernel releases the lock if the process dies, so
+// a crashed run never leaves the directory locked.
+func flockDir(dir string) (func(), error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errorf(ErrLocked, "another process is writing to %s", dir)
+		}
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package sitemap
+
+// flockDir is a no-op where directory locks are not available to the
+// package, so only Writes within one process are kept apart.
+func flockDir(dir string) (func(), error) {
+	return func() {}, nil
+}
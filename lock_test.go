@@ -0,0 +1,41 @@
+package sitemap
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestWriteLocksDir(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/a"})
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		t.Fatalf("lockDir: %v", err)
+	}
+	if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked while another Write holds the directory, got %v", err)
+	}
+	if err := sm.TouchLastMod("https://www.example.com/", sm.now()); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked from TouchLastMod, got %v", err)
+	}
+	unlock()
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" {
+		return
+	}
+	// A lock of another process, such as an overlapping cron run
+	unflock, err := flockDir(dir)
+	if err != nil {
+		t.Fatalf("flockDir: %v", err)
+	}
+	defer unflock()
+	if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked while another process holds the directory, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tenant is one site whose sitemaps a Manager maintains.
+type Tenant struct {
+	// ID names the tenant's subdirectory of the Manager's Dir. It may only
+	// contain ASCII letters, digits, '-', '_' and '.'.
+	ID             string
+	BaseURL        string
+	BaseSitemapURL string
+	// Source adds the tenant's URLs for a rebuild.
+	Source func(ctx context.Context, sm *SitemapOptions) error
+}
+
+// Manager maintains independent sitemap sets for many tenants, such as the
+// customer sites of a SaaS platform. Each tenant is written to its own
+// subdirectory of Dir, and rebuilds across all tenants share a pool of
+// Workers. It is safe for concurrent use.
+type Manager struct {
+	Dir string
+	// Configure, if set, customizes the options of every tenant before its
+	// URLs are added, for settings shared by all tenants.
+	Configure func(t Tenant, sm *SitemapOptions)
+
+	mu      sync.Mutex
+	tenants map[string]*managedTenant
+	workers chan struct{}
+}
+
+// managedTenant is a registered tenant and the state of its last rebuild.
+type managedTenant struct {
+	Tenant
+	mu     sync.Mutex // Serializes rebuilds of the tenant
+	state  *State
+	report *Report
+}
+
+// NewManager returns a Manager writing below dir and running at most workers
+// rebuilds at a time (1 if workers is less than 1).
+func NewManager(dir string, workers int) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Manager{
+		Dir:     dir,
+		tenants: make(map[string]*managedTenant),
+		workers: make(chan struct{}, workers),
+	}
+}
+
+// AddTenant registers t, replacing any tenant with the same ID.
+func (m *Manager) AddTenant(t Tenant) error {
+	if !validTenantID(t.ID) {
+		return fmt.Errorf("invalid tenant ID '%s'", t.ID)
+	}
+	if _, err := parseBaseURL(t.BaseURL); err != nil {
+		return fmt.Errorf("tenant %s: %w", t.ID, err)
+	}
+	if t.Source == nil {
+		return fmt.Errorf("tenant %s: no Source", t.ID)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenants[t.ID] = &managedTenant{Tenant: t}
+	return nil
+}
+
+// RemoveTenant unregisters the tenant with the given ID. Its files are left
+// in place.
+func (m *Manager) RemoveTenant(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tenants, id)
+}
+
+// Tenants returns the IDs of the registered tenants, sorted.
+func (m *Manager) Tenants() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.tenants))
+	for id := range m.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Report returns the report of the tenant's last successful rebuild, or nil.
+func (m *Manager) Report(id string) *Report {
+	m.mu.Lock()
+	t := m.tenants[id]
+	m.mu.Unlock()
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.report
+}
+
+// Rebuild regenerates the sitemaps of one tenant, waiting for a free worker.
+func (m *Manager) Rebuild(ctx context.Context, id string) error {
+	m.mu.Lock()
+	t := m.tenants[id]
+	m.mu.Unlock()
+	if t == nil {
+		return fmt.Errorf("unknown tenant '%s'", id)
+	}
+
+	select {
+	case m.workers <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-m.workers }()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sm := NewSitemapOptions(filepath.Join(m.Dir, t.ID), t.BaseURL)
+	if m.Configure != nil {
+		m.Configure(t.Tenant, sm)
+	}
+	if t.state != nil {
+		sm.PreviousState = t.state
+	}
+	if err := t.Source(ctx, sm); err != nil {
+		return fmt.Errorf("tenant %s: %v", t.ID, err)
+	}
+	baseSitemapURL := t.BaseSitemapURL
+	if baseSitemapURL == "" {
+		baseSitemapURL = t.BaseURL
+	}
+	if err := sm.WriteContext(ctx, baseSitemapURL); err != nil {
+		return fmt.Errorf("tenant %s: %w", t.ID, err)
+	}
+	t.state = sm.State()
+	t.report = sm.Report()
+	return nil
+}
+
+// RebuildAll regenerates every tenant, as many at a time as there are
+// workers. A failing tenant does not stop the others; the returned error
+// joins the errors of all failed tenants.
+func (m *Manager) RebuildAll(ctx context.Context) error {
+	ids := m.Tenants()
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.Rebuild(ctx, id)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Handler returns an http.Handler serving each tenant's files to requests
+// for the host of its BaseURL.
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		m.mu.Lock()
+		var dir string
+		for _, t := range m.tenants {
+			if base, err := parseBaseURL(t.BaseURL); err == nil && strings.EqualFold(base.Hostname(), host) {
+				dir = filepath.Join(m.Dir, t.ID)
+				break
+			}
+		}
+		m.mu.Unlock()
+		if dir == "" {
+			http.NotFound(w, r)
+			return
+		}
+		NewHandler(dir).ServeHTTP(w, r)
+	})
+}
+
+// validTenantID reports whether id is safe to use as a directory name.
+func validTenantID(id string) bool {
+	if id == "" || id == "." || id == ".." {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, 2)
+	m.Configure = func(_ Tenant, sm *SitemapOptions) { sm.DefaultChangeFreq = "daily" }
+
+	var running, peak atomic.Int32
+	source := func(locs ...string) func(context.Context, *SitemapOptions) error {
+		return func(ctx context.Context, sm *SitemapOptions) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			for _, loc := range locs {
+				sm.AddURL(SitemapURL{Loc: loc})
+			}
+			return nil
+		}
+	}
+	for _, tenant := range []Tenant{
+		{ID: "acme", BaseURL: "https://acme.example.com", Source: source("/", "/pricing")},
+		{ID: "globex", BaseURL: "https://globex.example.com", Source: source("/")},
+		{ID: "initech", BaseURL: "https://initech.example.com", Source: source("/tps")},
+		{ID: "broken", BaseURL: "https://broken.example.com", Source: func(context.Context, *SitemapOptions) error {
+			return errors.New("database unavailable")
+		}},
+	} {
+		if err := m.AddTenant(tenant); err != nil {
+			t.Fatalf("AddTenant(%s): %v", tenant.ID, err)
+		}
+	}
+	if err := m.AddTenant(Tenant{ID: "../escape", BaseURL: "https://x.example.com", Source: source()}); err == nil {
+		t.Fatal("expected an error for an unsafe tenant ID")
+	}
+
+	err := m.RebuildAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "tenant broken: database unavailable") {
+		t.Fatalf("expected the broken tenant's error, got %v", err)
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("%d rebuilds ran at once with 2 workers", peak.Load())
+	}
+	if r := m.Report("acme"); r == nil || r.URLs != 2 {
+		t.Fatalf("unexpected acme report: %+v", r)
+	}
+
+	if err := m.Rebuild(context.Background(), "globex"); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if err := m.Rebuild(context.Background(), "unknown"); err == nil {
+		t.Fatal("expected an error for an unknown tenant")
+	}
+
+	req := httptest.NewRequest("GET", "http://acme.example.com:8080/sitemap.xml", nil)
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != 200 || !strings.Contains(string(body), "https://acme.example.com/pricing") || !strings.Contains(string(body), "daily") {
+		t.Fatalf("acme sitemap: %d\n%s", rec.Code, body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "initech", "sitemap.xml")); err != nil {
+		t.Fatalf("initech sitemap: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "http://other.example.com/sitemap.xml", nil))
+	if rec.Code != 404 {
+		t.Fatalf("unknown host got %d", rec.Code)
+	}
+}
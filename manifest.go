@@ -0,0 +1,248 @@
+package sitemap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manifest describes the files of a sitemap set, written to ManifestFile
+// for deployment tooling to check and sync them.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile describes one generated file.
+type ManifestFile struct {
+	Name    string `json:"name"`           // Path relative to Dir, slash separated
+	URLs    int    `json:"urls,omitempty"` // URLs of a sitemap or sitemaps of an index
+	Bytes   int64  `json:"bytes"`
+	SHA256  string `json:"sha256"`
+	LastMod string `json:"lastmod,omitempty"` // Most recent lastmod of the entries
+}
+
+// writeManifest writes the Manifest of the sitemap files and stylesheets in
+// Dir and ShardDir to ManifestFile, if set.
+func (s *SitemapOptions) writeManifest() error {
+	if s.ManifestFile == "" {
+		return nil
+	}
+	manifestPath := s.ManifestFile
+	if !filepath.IsAbs(manifestPath) {
+		manifestPath = filepath.Join(s.Dir, manifestPath)
+	}
+
+	files, err := s.setFiles()
+	if err != nil {
+		return err
+	}
+	manifest := Manifest{Files: []ManifestFile{}}
+	for _, filePath := range files {
+		file, err := s.manifestFile(filePath)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.tx.writeFile(manifestPath, append(data, '\n'))
+}
+
+// setFiles lists the paths of the files of the published sitemap set: its
+// root, sitemap_index.xml or else sitemap.xml, the nested indexes and
+// sitemap files the indexes reference in Dir and ShardDir, and the
+// stylesheets. Files left in Dir by earlier runs that the root does not
+// reference are not part of the set. Gzipped files are listed with their
+// uncompressed variant if it was kept.
+func (s *SitemapOptions) setFiles() ([]string, error) {
+	var files []string
+	add := func(filePath string) bool {
+		found := false
+		for _, variant := range []string{filePath, filePath + gzipExt} {
+			if info, err := os.Stat(variant); err == nil && !info.IsDir() {
+				files = append(files, variant)
+				found = true
+			}
+		}
+		return found
+	}
+
+	indexPath := filepath.Join(s.Dir, "sitemap_index.xml")
+	if add(indexPath) {
+		indexed, err := s.indexedFiles(indexPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, filePath := range indexed {
+			add(filePath)
+		}
+	} else if !add(filepath.Join(s.Dir, "sitemap.xml")) {
+		return nil, nil
+	}
+	add(filepath.Join(s.Dir, s.Stylesheet))
+	if s.ShardDir != "" {
+		add(filepath.Join(s.shardDir(), s.Stylesheet))
+	}
+	return files, nil
+}
+
+// indexedFiles returns the uncompressed paths of the local nested indexes
+// and sitemap files referenced by the index at indexPath, which may be
+// gzipped, following nested indexes. ExternalSitemaps are skipped.
+func (s *SitemapOptions) indexedFiles(indexPath string) ([]string, error) {
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		indexPath += gzipExt
+	}
+	index, err := LoadSitemapIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	external := make(map[string]bool, len(s.ExternalSitemaps))
+	for _, sitemap := range s.ExternalSitemaps {
+		external[strings.TrimSpace(sitemap.Loc)] = true
+	}
+	var files []string
+	for _, sitemap := range index.Sitemaps {
+		if external[sitemap.Loc] {
+			continue
+		}
+		filePath, ok := s.localSitemapPath(sitemap.Loc)
+		if !ok {
+			continue
+		}
+		filePath = strings.TrimSuffix(filePath, gzipExt)
+		files = append(files, filePath)
+		if isNestedIndex(filepath.Base(filePath)) {
+			nested, err := s.indexedFiles(filePath)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, nested...)
+		}
+	}
+	return files, nil
+}
+
+// manifestFile describes the file at filePath.
+func (s *SitemapOptions) manifestFile(filePath string) (ManifestFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	sum := sha256.Sum256(data)
+	name, err := filepath.Rel(s.Dir, filePath)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	file := ManifestFile{
+		Name:   filepath.ToSlash(name),
+		Bytes:  int64(len(data)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), ".gz")
+	switch {
+	case !strings.HasSuffix(base, sitemapExt):
+	case base == "sitemap_index.xml" || isNestedIndex(base):
+		index, err := ParseSitemapIndex(bytes.NewReader(data))
+		if err != nil {
+			return ManifestFile{}, err
+		}
+		file.URLs = len(index.Sitemaps)
+		file.LastMod = s.latestLastMod(index.Sitemaps)
+	default:
+		urlSet, err := ParseURLSet(bytes.NewReader(data))
+		if err != nil {
+			return ManifestFile{}, err
+		}
+		file.URLs = len(urlSet.URLs)
+		var latest time.Time
+		for _, u := range urlSet.URLs {
+			if t, err := s.parseLastMod(u.LastMod); err == nil && (file.LastMod == "" || t.After(latest)) {
+				file.LastMod, latest = u.LastMod, t
+			}
+		}
+	}
+	return file, nil
+}
+
+// LoadManifest reads a Manifest written to ManifestFile.
+func LoadManifest(filePath string) (*Manifest, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest '%s': %v", filePath, err)
+	}
+	return &manifest, nil
+}
+
+// SyncPlan lists the files to upload to and delete from a remote copy of a
+// sitemap set, by name relative to Dir.
+type SyncPlan struct {
+	// Upload lists the new and changed files. Sitemaps and stylesheets come
+	// before the indexes, so uploading in order never publishes an index
+	// referencing a missing file.
+	Upload []string
+	// Delete lists the files that are gone. Delete them after uploading.
+	Delete []string
+}
+
+// SyncPlan compares m with the Manifest of the files already on the remote
+// target. A nil previous Manifest uploads every file.
+func (m *Manifest) SyncPlan(previous *Manifest) SyncPlan {
+	plan := SyncPlan{Upload: []string{}, Delete: []string{}}
+	old := make(map[string]string)
+	if previous != nil {
+		for _, file := range previous.Files {
+			old[file.Name] = file.SHA256
+		}
+	}
+	current := make(map[string]bool, len(m.Files))
+	for _, file := range m.Files {
+		current[file.Name] = true
+		if sum, ok := old[file.Name]; !ok || sum != file.SHA256 {
+			plan.Upload = append(plan.Upload, file.Name)
+		}
+	}
+	for name := range old {
+		if !current[name] {
+			plan.Delete = append(plan.Delete, name)
+		}
+	}
+	sort.SliceStable(plan.Upload, func(i, j int) bool {
+		return uploadRank(plan.Upload[i]) < uploadRank(plan.Upload[j])
+	})
+	sort.Strings(plan.Delete)
+	return plan
+}
+
+// uploadRank orders sitemaps before nested indexes before the top index.
+func uploadRank(name string) int {
+	base := strings.TrimSuffix(path.Base(name), ".gz")
+	switch {
+	case base == "sitemap_index.xml":
+		return 2
+	case isNestedIndex(base):
+		return 1
+	default:
+		return 0
+	}
+}
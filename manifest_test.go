@@ -0,0 +1,140 @@
+package sitemap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestManifestFile(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ShardDir = "shards"
+	sm.MaxURLs = 2
+	sm.ManifestFile = "manifest.json"
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/a", LastMod: "2024-01-01"},
+		{Loc: "/b", LastMod: "2024-03-01"},
+		{Loc: "/c", LastMod: "2024-02-01"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	readManifest := func() map[string]ManifestFile {
+		data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+		if err != nil {
+			t.Fatalf("Error reading manifest: %v", err)
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("Error decoding manifest: %v", err)
+		}
+		files := map[string]ManifestFile{}
+		for _, file := range manifest.Files {
+			files[file.Name] = file
+		}
+		return files
+	}
+	files := readManifest()
+	if len(files) != 5 {
+		t.Fatalf("Expected the index, 2 shards and 2 stylesheets, got %+v", files)
+	}
+	expected := map[string][2]any{
+		"sitemap_index.xml":    {2, "2024-03-01"},
+		"shards/sitemap_1.xml": {2, "2024-03-01"},
+		"shards/sitemap_2.xml": {1, "2024-02-01"},
+		"sitemap.xsl":          {0, ""},
+	}
+	for name, want := range expected {
+		file := files[name]
+		if file.URLs != want[0] || file.LastMod != want[1] {
+			t.Fatalf("Unexpected manifest entry for %s: %+v", name, file)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "shards", "sitemap_1.xml"))
+	if err != nil {
+		t.Fatalf("Error reading shard: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if file := files["shards/sitemap_1.xml"]; file.Bytes != int64(len(data)) || file.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Unexpected size or checksum for the first shard: %+v", file)
+	}
+
+	// Touching lastmods keeps the manifest current
+	if err := sm.TouchLastMod("https://www.example.com/", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "/c"); err != nil {
+		t.Fatalf("Error touching lastmod: %v", err)
+	}
+	if file := readManifest()["shards/sitemap_2.xml"]; file.LastMod != "2024-05-01" {
+		t.Fatalf("Expected the manifest to follow TouchLastMod, got %+v", file)
+	}
+
+	// Shards left over from a larger run are not part of the set
+	sm.Reset()
+	for _, loc := range []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h", "/i", "/j"} {
+		sm.AddURL(SitemapURL{Loc: loc})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	sm.Reset()
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}, {Loc: "/c"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shards", "sitemap_5.xml")); err != nil {
+		t.Fatalf("Expected the leftover shard on disk: %v", err)
+	}
+	if files := readManifest(); len(files) != 5 || files["shards/sitemap_3.xml"].Name != "" || files["shards/sitemap_5.xml"].Name != "" {
+		t.Fatalf("Expected only the files of the last Write, got %+v", files)
+	}
+}
+
+func TestManifestSyncPlan(t *testing.T) {
+	previous := &Manifest{Files: []ManifestFile{
+		{Name: "shards/sitemap_1.xml", SHA256: "a"},
+		{Name: "shards/sitemap_2.xml", SHA256: "b"},
+		{Name: "shards/sitemap_3.xml", SHA256: "c"},
+		{Name: "sitemap_index.xml", SHA256: "d"},
+	}}
+	current := &Manifest{Files: []ManifestFile{
+		{Name: "shards/sitemap_1.xml", SHA256: "a"},
+		{Name: "shards/sitemap_2.xml", SHA256: "changed"},
+		{Name: "sitemap.xsl", SHA256: "new"},
+		{Name: "sitemap_index.xml", SHA256: "changed"},
+		{Name: "sitemap_index_1.xml", SHA256: "new"},
+	}}
+
+	plan := current.SyncPlan(previous)
+	upload := []string{"shards/sitemap_2.xml", "sitemap.xsl", "sitemap_index_1.xml", "sitemap_index.xml"}
+	if !slices.Equal(plan.Upload, upload) {
+		t.Fatalf("Expected uploads %v, got %v", upload, plan.Upload)
+	}
+	if !slices.Equal(plan.Delete, []string{"shards/sitemap_3.xml"}) {
+		t.Fatalf("Expected the removed shard to be deleted, got %v", plan.Delete)
+	}
+	if first := current.SyncPlan(nil); len(first.Upload) != 5 || len(first.Delete) != 0 {
+		t.Fatalf("Expected every file to be uploaded without a previous manifest, got %+v", first)
+	}
+
+	// Manifests round-trip through ManifestFile
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ManifestFile = "manifest.json"
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	loaded, err := LoadManifest(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Error loading manifest: %v", err)
+	}
+	if plan := loaded.SyncPlan(loaded); len(plan.Upload) != 0 || len(plan.Delete) != 0 {
+		t.Fatalf("Expected nothing to sync against itself, got %+v", plan)
+	}
+}
@@ -0,0 +1,36 @@
+package sitemap
+
+import (
+	"encoding/xml"
+)
+
+// URLMarshaler writes the url element of an entry itself, for example to
+// add elements of other namespaces or to control their order, while Write
+// still handles sharding, limits and the index. The element is validated
+// like any other, so it must still follow the sitemap schema: loc first,
+// and elements of other namespaces after the core ones.
+//
+// The encoder is the one writing the sitemap file. u is the entry as
+// prepared by Write, with its loc resolved and its values cleaned.
+type URLMarshaler interface {
+	MarshalSitemapURL(e *xml.Encoder, u SitemapURL) error
+}
+
+// URLMarshalerFunc adapts a function to URLMarshaler.
+type URLMarshalerFunc func(e *xml.Encoder, u SitemapURL) error
+
+func (f URLMarshalerFunc) MarshalSitemapURL(e *xml.Encoder, u SitemapURL) error {
+	return f(e, u)
+}
+
+// plainURL has the fields of SitemapURL without its MarshalXML method.
+type plainURL SitemapURL
+
+// MarshalXML hands the entry to its Marshaler, if any, and otherwise
+// encodes it as usual.
+func (u SitemapURL) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if u.Marshaler != nil {
+		return u.Marshaler.MarshalSitemapURL(e, u)
+	}
+	return e.EncodeElement(plainURL(u), start)
+}
@@ -0,0 +1,116 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestURLMarshaler(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	custom := URLMarshalerFunc(func(e *xml.Encoder, u SitemapURL) error {
+		url := xml.StartElement{Name: xml.Name{Local: "url"}}
+		ext := xml.StartElement{
+			Name: xml.Name{Local: "shop:stock"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:shop"}, Value: "https://www.example.com/schemas/shop"}},
+		}
+		tokens := []xml.Token{
+			url,
+			xml.StartElement{Name: xml.Name{Local: "loc"}}, xml.CharData(u.Loc), xml.EndElement{Name: xml.Name{Local: "loc"}},
+			ext, xml.CharData(u.Meta["stock"]), ext.End(),
+			url.End(),
+		}
+		for _, token := range tokens {
+			if err := e.EncodeToken(token); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sm.AddURL(SitemapURL{Loc: "/product", LastMod: "2024-01-01", Marshaler: custom, Meta: map[string]string{"stock": "12"}})
+	sm.AddURL(SitemapURL{Loc: "/about", LastMod: "2024-01-01"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	output := string(data)
+	if !strings.Contains(output, `<shop:stock xmlns:shop="https://www.example.com/schemas/shop">12</shop:stock>`) {
+		t.Fatalf("Expected the custom element, got %s", output)
+	}
+	if strings.Count(output, "<lastmod>") != 1 {
+		t.Fatalf("Expected the default encoding only for /about, got %s", output)
+	}
+}
+
+func TestElementOrder(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+
+	// Set the fields back to front
+	var u SitemapURL
+	u.PageMap = &PageMap{DataObjects: []DataObject{{Type: "document", Attributes: []Attribute{{Name: "title", Value: "Page"}}}}}
+	u.Videos = []Video{{ThumbnailLoc: "https://www.example.com/thumb.jpg", Title: "Video", Description: "A video", ContentLoc: "https://www.example.com/video.mp4"}}
+	u.Images = []Image{{Loc: "https://www.example.com/image.jpg"}}
+	u.Alternates = []Alternate{{Rel: "alternate", Hreflang: "en", Href: "https://www.example.com/page"}}
+	u.Priority = "0.5"
+	u.ChangeFreq = "daily"
+	u.LastMod = "2024-01-01"
+	u.Loc = "/page"
+	sm.AddURL(u)
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	var children []string
+	depth := 0
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 3 {
+				children = append(children, token.Name.Local)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	want := "loc lastmod changefreq priority link image video PageMap"
+	if got := strings.Join(children, " "); got != want {
+		t.Fatalf("Expected elements %s, got %s", want, got)
+	}
+}
+
+func TestURLMarshalerOutOfOrder(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	swapped := URLMarshalerFunc(func(e *xml.Encoder, u SitemapURL) error {
+		element := struct {
+			XMLName xml.Name `xml:"url"`
+			LastMod string   `xml:"lastmod"`
+			Loc     string   `xml:"loc"`
+		}{LastMod: u.LastMod, Loc: u.Loc}
+		return e.Encode(element)
+	})
+	sm.AddURL(SitemapURL{Loc: "/page", LastMod: "2024-01-01", Marshaler: swapped})
+	err := sm.Write("https://www.example.com/")
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Expected a validation error for lastmod before loc, got %v", err)
+	}
+}
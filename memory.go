@@ -0,0 +1,197 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// urlOverhead approximates the memory a SitemapURL takes beyond its strings:
+// the struct itself and its slice and map headers.
+const urlOverhead = 256
+
+// WithMemoryLimit caps the approximate memory held by buffered URLs at
+// bytes. Once AddURL crosses the limit, the buffered URLs are prepared and
+// written to sitemap files right away, and Write later adds the remaining
+// URLs and an index referencing all files. Only the locs and fingerprints of
+// flushed URLs stay in memory, for deduplication and the State.
+//
+// Grouped URLs and news articles are never flushed. Flushed files may hold
+// fewer than MaxURLs entries, hreflang validation only covers the URLs still
+// buffered at Write, and Clone copies only those. Flushing requires
+// ShardSequential; a failure is returned by the next Write, which restores
+// the previous files. The first flush claims Dir until Write or Reset, so
+// other Writes to it fail with ErrLocked meanwhile. It returns s for
+// chaining.
+func (s *SitemapOptions) WithMemoryLimit(bytes int64) *SitemapOptions {
+	s.MemoryLimit = bytes
+	return s
+}
+
+// flushSession holds the progress of a run whose URLs are being flushed
+// over MemoryLimit. Write picks it up to finish the run.
+type flushSession struct {
+	tx       *writeTx
+	report   Report
+	seen     map[string]bool // Locs written so far
+	shards   []flushedShard
+	recent   []SitemapURL // Recently changed URLs, capped at MaxURLs
+	state    *State
+	urls     int               // URLs written so far
+	flushed  int               // URLs taken from the buffer so far
+	hosts    map[string]int    // URLs written so far by host, with MaxHostURLs
+	licenses map[string]string // Image licenses checked so far and their problem
+	err      error
+	archived bool   // The previous set was copied to ArchiveDir
+	unlock   func() // Releases the claim on Dir held until Write or Reset
+}
+
+// flushedShard is a sitemap file written by a flush.
+type flushedShard struct {
+	name    string
+	lastMod string
+}
+
+// flushable reports whether u may be written before Write.
+func flushable(u SitemapURL) bool {
+	return u.News == nil && groupFileName(u.Group) == ""
+}
+
+// approxURLSize estimates the memory held by u.
+func approxURLSize(u SitemapURL) int64 {
+	size := urlOverhead + len(u.Loc) + len(u.LastMod) + len(u.ChangeFreq) + len(u.Priority)
+	for _, alt := range u.Alternates {
+		size += 64 + len(alt.Rel) + len(alt.Hreflang) + len(alt.Href)
+	}
+	for _, img := range u.Images {
+		size += 96 + len(img.Loc) + len(img.Caption) + len(img.GeoLocation) + len(img.Title) + len(img.License)
+	}
+	for _, video := range u.Videos {
+		size += 512 + len(video.Title) + len(video.Description) + len(video.ThumbnailLoc) + len(video.ContentLoc) + len(video.PlayerLoc)
+	}
+	if u.PageMap != nil {
+		for _, obj := range u.PageMap.DataObjects {
+			size += 64 + len(obj.Type) + len(obj.ID)
+			for _, attr := range obj.Attributes {
+				size += 32 + len(attr.Name) + len(attr.Value)
+			}
+		}
+	}
+	for key, value := range u.Meta {
+		size += 32 + len(key) + len(value)
+	}
+	return int64(size)
+}
+
+// flushURLs writes the buffered URLs that may be flushed to sitemap files
+// and drops them from memory. The first flush claims Dir as Write does,
+// until the run is finished by Write or dropped by Reset. After a failure,
+// URLs are buffered again and the error is kept for Write. s.mu must be
+// held.
+func (s *SitemapOptions) flushURLs() {
+	if s.flush == nil {
+		s.flush = &flushSession{tx: &writeTx{}, seen: make(map[string]bool)}
+		s.generated = s.now()
+		s.flush.unlock, s.flush.err = lockDir(s.Dir)
+	}
+	f := s.flush
+	if f.err != nil {
+		return
+	}
+	if s.ShardStrategy != ShardSequential {
+		f.err = fmt.Errorf("MemoryLimit requires ShardSequential")
+		return
+	}
+
+	var batch []SitemapURL
+	kept := make([]SitemapURL, 0)
+	for _, u := range s.URLs {
+		if flushable(u) {
+			batch = append(batch, u)
+		} else {
+			kept = append(kept, u)
+		}
+	}
+	s.tx = f.tx
+	err := s.flushBatch(f, batch)
+	s.tx = nil
+	if err != nil {
+		f.err = err
+		return
+	}
+	f.flushed += len(batch)
+	s.URLs = kept
+	s.buffered = 0
+}
+
+// flushBatch prepares batch like Write and writes it to the next sequential
+// sitemap files.
+func (s *SitemapOptions) flushBatch(f *flushSession, batch []SitemapURL) error {
+	if _, err := s.checkLimits(); err != nil {
+		return err
+	}
+	if err := s.prepareShardNames(); err != nil {
+		return err
+	}
+	if err := s.tx.mkdirAll(s.shardDir()); err != nil {
+		return err
+	}
+	if err := s.loadPreviousState(); err != nil {
+		return err
+	}
+	if !f.archived {
+		if err := s.archiveSet(); err != nil {
+			return err
+		}
+		f.archived = true
+	}
+	collect := time.Now()
+	urls, err := s.prepareURLs(batch, &f.report, f.seen)
+	if err != nil {
+		return err
+	}
+	if f.hosts == nil && len(s.MaxHostURLs) > 0 {
+		f.hosts = make(map[string]int)
+	}
+	if urls, err = s.applyBudgets(urls, f.urls, f.hosts, &f.report); err != nil {
+		return err
+	}
+	if f.licenses == nil {
+		f.licenses = make(map[string]string)
+	}
+	if err := s.checkImageLicenses(context.Background(), urls, f.licenses, &f.report); err != nil {
+		return err
+	}
+	s.since(phaseCollect, collect)
+
+	f.recent = s.capRecent(append(f.recent, s.recentURLs(urls)...), &f.report)
+
+	err = s.writeShards(s.sequentialShards("", len(f.shards)+1, s.groupLimit(""), urls), func(shard shard) error {
+		f.report.recordStats(shard.name, s.urlStats(shard.urls))
+		f.shards = append(f.shards, flushedShard{name: shard.name, lastMod: s.sitemapLastMod(shard.name, shard.urls)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.urls += len(urls)
+	if f.state == nil {
+		f.state = NewState(urls)
+	} else {
+		f.state.merge(NewState(urls))
+	}
+	return nil
+}
+
+// discardFlushed abandons a flushed run, restoring the previous files and
+// releasing Dir. s.mu must be held.
+func (s *SitemapOptions) discardFlushed() {
+	if s.flush != nil {
+		s.flush.tx.rollback()
+		if s.flush.unlock != nil {
+			s.flush.unlock()
+		}
+		s.flush = nil
+	}
+	s.buffered = 0
+}
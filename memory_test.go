@@ -0,0 +1,158 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com").WithMemoryLimit(3 * urlOverhead)
+	sm.MaxURLs = 2
+	for i := 0; i < 7; i++ {
+		sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i)})
+	}
+	sm.AddURL(SitemapURL{Loc: "/page-1"})
+	sm.AddURL(SitemapURL{Loc: "/docs/intro", Group: "docs"})
+	if len(sm.URLs) > 3 {
+		t.Fatalf("Expected the buffered URLs to be flushed, %d still held", len(sm.URLs))
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, sitemap := range index.Sitemaps {
+		name := path.Base(sitemap.Loc)
+		names = append(names, name)
+		urlSet, err := LoadURLSet(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", name, err)
+		}
+		for _, u := range urlSet.URLs {
+			if seen[u.Loc] {
+				t.Fatalf("Duplicate loc %s in %v", u.Loc, names)
+			}
+			seen[u.Loc] = true
+		}
+	}
+	if len(seen) != 8 || names[len(names)-1] != "sitemap_docs_1.xml" {
+		t.Fatalf("Expected 8 unique URLs with the group last, got %d in %v", len(seen), names)
+	}
+	for i, name := range names[:len(names)-1] {
+		if name != fmt.Sprintf("sitemap_%d.xml", i+1) {
+			t.Fatalf("Expected sequentially numbered sitemaps, got %v", names)
+		}
+	}
+	if sm.Report().URLs != 8 || len(sm.State().URLs) != 8 {
+		t.Fatalf("Expected 8 URLs in the report and state, got %d and %d", sm.Report().URLs, len(sm.State().URLs))
+	}
+	if sm.Report().Excluded[0].Reason != "duplicate" {
+		t.Fatalf("Expected the duplicate across flushes to be excluded, got %+v", sm.Report().Excluded)
+	}
+
+	// The flushed URLs are gone, so a repeated Write would lose them
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatalf("Expected a repeated Write after flushing to fail")
+	}
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/page-0"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap after Reset: %v", err)
+	}
+}
+
+func TestMemoryLimitRequiresSequentialShards(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com").WithMemoryLimit(1)
+	sm.ShardStrategy = ShardByHash
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatalf("Expected an error for MemoryLimit with ShardByHash")
+	}
+}
+
+func TestMemoryLimitLocksDir(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com").WithMemoryLimit(urlOverhead)
+	sm.ShardDir = "shards"
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	sm.AddURL(SitemapURL{Loc: "/b"})
+
+	// The flushed run holds Dir until Write
+	other := NewSitemapOptions(dir, "https://www.example.com")
+	other.AddURL(SitemapURL{Loc: "/other"})
+	if err := other.Write("https://www.example.com/"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked during a flushed run, got %v", err)
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if err := other.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap after the flushed run: %v", err)
+	}
+
+	// Reset rolls back a flushed run, including the directories it created
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com").WithMemoryLimit(urlOverhead)
+	sm.ShardDir = "shards"
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	sm.AddURL(SitemapURL{Loc: "/b"})
+	sm.Reset()
+	if _, err := os.Stat(filepath.Join(sm.Dir, "shards")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the shard directory to be removed, got %v", err)
+	}
+	unlock, err := lockDir(sm.Dir)
+	if err != nil {
+		t.Fatalf("Expected Reset to release Dir: %v", err)
+	}
+	unlock()
+}
+
+func TestTryAddURL(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com").WithMemoryLimit(2 * urlOverhead)
+	sm.MaxURLs = 2
+	sm.MaxTotalURLs = 5
+	added := 0
+	for i := 0; i < 10; i++ {
+		if err := sm.TryAddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i)}); err != nil {
+			if !errors.Is(err, ErrTooManyURLs) {
+				t.Fatalf("Expected ErrTooManyURLs, got %v", err)
+			}
+			break
+		}
+		added++
+	}
+	if added != 5 {
+		t.Fatalf("Expected TryAddURL to accept 5 URLs across flushes, accepted %d", added)
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	// Write enforces the limit for URLs added without TryAddURL
+	sm.Reset()
+	sm.MemoryLimit = 0
+	for i := 0; i < 6; i++ {
+		sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i)})
+	}
+	if err := sm.Write("https://www.example.com/"); !errors.Is(err, ErrTooManyURLs) {
+		t.Fatalf("Expected ErrTooManyURLs from Write, got %v", err)
+	}
+
+	// A failed flush stops producers
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com").WithMemoryLimit(1)
+	sm.ShardStrategy = ShardByHash
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.TryAddURL(SitemapURL{Loc: "/next"}); err == nil {
+		t.Fatal("Expected the failed flush to be returned")
+	}
+}
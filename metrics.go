@@ -0,0 +1,74 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeMetrics writes the metrics of the Write started at start to
+// MetricsFile, if set, in the Prometheus text format read by the
+// node_exporter textfile collector:
+//
+//	sitemap_urls_total                     URLs written
+//	sitemap_shards_total                   sitemap files written
+//	sitemap_bytes_total                    bytes of the files in the set
+//	sitemap_duration_seconds               duration of the Write
+//	sitemap_last_success_timestamp_seconds Unix time of the Write
+//	sitemap_phase_seconds{phase="..."}     Timings of the Write by phase
+//
+// The file is replaced atomically, so the collector never reads a partial
+// one. A failed Write leaves it untouched, so alerting on a stale
+// sitemap_last_success_timestamp_seconds catches failing runs.
+func (s *SitemapOptions) writeMetrics(start time.Time) error {
+	if s.MetricsFile == "" || s.report == nil {
+		return nil
+	}
+	metricsPath := s.MetricsFile
+	if !filepath.IsAbs(metricsPath) {
+		metricsPath = filepath.Join(s.Dir, metricsPath)
+	}
+
+	files, err := s.setFiles()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, filePath := range files {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+	}
+
+	var buf bytes.Buffer
+	metric := func(name, help string, value any) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+	metric("sitemap_urls_total", "URLs written by the last successful run.", s.report.URLs)
+	metric("sitemap_shards_total", "Sitemap files written by the last successful run.", len(s.report.SitemapStats))
+	metric("sitemap_bytes_total", "Bytes of the sitemap files and stylesheets after the last successful run.", size)
+	metric("sitemap_duration_seconds", "Duration of the last successful run.", time.Since(start).Seconds())
+	metric("sitemap_last_success_timestamp_seconds", "Unix time of the last successful run.", s.now().Unix())
+	timings := s.report.Timings
+	fmt.Fprintf(&buf, "# HELP sitemap_phase_seconds Time the last successful run spent in each phase.\n# TYPE sitemap_phase_seconds gauge\n")
+	for _, phase := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"collect", timings.Collect},
+		{"encode", timings.Encode},
+		{"compress", timings.Compress},
+		{"publish", timings.Publish},
+		{"validate", timings.Validate},
+	} {
+		fmt.Fprintf(&buf, "sitemap_phase_seconds{phase=%q} %v\n", phase.name, phase.duration.Seconds())
+	}
+	if err := writeFileAtomic(metricsPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsFile(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.MetricsFile = "sitemap.prom"
+	sm.Now = func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}, {Loc: "/c"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.prom"))
+	if err != nil {
+		t.Fatalf("Error reading metrics file: %v", err)
+	}
+	metrics := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, " ")
+		metrics[name] = value
+	}
+	if metrics["sitemap_urls_total"] != "3" || metrics["sitemap_shards_total"] != "2" || metrics["sitemap_last_success_timestamp_seconds"] != "1717243200" {
+		t.Fatalf("Unexpected metrics: %v", metrics)
+	}
+	if metrics["sitemap_bytes_total"] == "0" || metrics["sitemap_duration_seconds"] == "" || metrics[`sitemap_phase_seconds{phase="encode"}`] == "" {
+		t.Fatalf("Expected bytes and duration metrics, got %v", metrics)
+	}
+
+	// A failed Write leaves the metrics of the last successful one
+	sm.AddURL(SitemapURL{Loc: "https://other.example.com/"})
+	sm.Strict = true
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("Expected the strict Write to fail")
+	}
+	after, err := os.ReadFile(filepath.Join(dir, "sitemap.prom"))
+	if err != nil || string(after) != string(data) {
+		t.Fatalf("Expected the metrics file untouched, err %v", err)
+	}
+}
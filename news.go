@@ -0,0 +1,93 @@
+package sitemap
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const (
+	newsNamespace = "http://www.google.com/schemas/sitemap-news/0.9"
+	// maxNewsURLs is the most articles Google accepts in one news sitemap.
+	maxNewsURLs = 1000
+	// newsMaxAge is how long an article may stay in a news sitemap.
+	newsMaxAge = 48 * time.Hour
+)
+
+// News is the Google News extension of a URL.
+type News struct {
+	Publication     NewsPublication `xml:"news:publication"`
+	PublicationDate string          `xml:"news:publication_date"`
+	Title           string          `xml:"news:title"`
+}
+
+// NewsPublication identifies the publication an article belongs to.
+type NewsPublication struct {
+	Name     string `xml:"news:name"`
+	Language string `xml:"news:language"`
+}
+
+// NewsOverflow selects how more than 1,000 fresh news articles are handled.
+type NewsOverflow int
+
+const (
+	// NewsSplit writes the articles to as many news sitemaps as needed.
+	NewsSplit NewsOverflow = iota
+	// NewsError fails the Write.
+	NewsError
+)
+
+// splitNews separates the articles published within the last 48 hours from
+// the other urls. Older articles stay in the regular sitemaps without their
+// news element, as Google rejects them in news sitemaps.
+func (s *SitemapOptions) splitNews(urls []SitemapURL) (news, rest []SitemapURL, err error) {
+	now := s.now()
+	rest = urls[:0:0]
+	for _, u := range urls {
+		if u.News == nil {
+			rest = append(rest, u)
+			continue
+		}
+		published, err := s.parseLastMod(u.News.PublicationDate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid news publication date for %s: %v", u.Loc, err)
+		}
+		if now.Sub(published) > newsMaxAge {
+			u.News = nil
+			rest = append(rest, u)
+			continue
+		}
+		news = append(news, u)
+	}
+	return news, rest, nil
+}
+
+// newsShards groups the news articles into sitemaps of at most 1,000 URLs,
+// named sitemap_news.xml, sitemap_news_2.xml and so on, and removes news
+// sitemaps left over from a previous run.
+func (s *SitemapOptions) newsShards(news []SitemapURL) ([]shard, error) {
+	if len(news) > maxNewsURLs && s.NewsOverflow == NewsError {
+		return nil, errorf(ErrTooManyURLs, "%d news articles exceed the limit of %d per news sitemap", len(news), maxNewsURLs)
+	}
+
+	stale, err := staleSitemaps(filepath.Join(s.shardDir(), "sitemap_news*"+sitemapExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range stale {
+		if err := s.tx.removeFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var shards []shard
+	for start := 0; start < len(news); start += maxNewsURLs {
+		end := min(start+maxNewsURLs, len(news))
+		name := "sitemap_news" + sitemapExt
+		if start > 0 {
+			name = fmt.Sprintf("sitemap_news_%d%s", start/maxNewsURLs+1, sitemapExt)
+		}
+		shards = append(shards, shard{name: name, urls: news[start:end]})
+	}
+	return shards, nil
+}
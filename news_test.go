@@ -0,0 +1,90 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newsURL(loc string, published time.Time) SitemapURL {
+	return SitemapURL{Loc: loc, News: &News{
+		Publication:     NewsPublication{Name: "Example Times", Language: "en"},
+		PublicationDate: published.Format(time.RFC3339),
+		Title:           "Article " + loc,
+	}}
+}
+
+func TestNewsSitemap(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return now }
+	sm.AddURL(newsURL("/fresh", now.Add(-time.Hour)))
+	sm.AddURL(newsURL("/stale", now.Add(-72*time.Hour)))
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	news, err := os.ReadFile(filepath.Join(dir, "sitemap_news.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`xmlns:news="http://www.google.com/schemas/sitemap-news/0.9"`,
+		"<loc>https://www.example.com/fresh</loc>",
+		"<news:name>Example Times</news:name>",
+		"<news:title>Article /fresh</news:title>",
+	} {
+		if !strings.Contains(string(news), want) {
+			t.Fatalf("news sitemap missing %s:\n%s", want, news)
+		}
+	}
+	if strings.Contains(string(news), "/stale") {
+		t.Fatalf("stale article in news sitemap:\n%s", news)
+	}
+
+	regular, err := os.ReadFile(filepath.Join(dir, "sitemap_1.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(regular), "/stale") || strings.Contains(string(regular), "news:news") {
+		t.Fatalf("stale article should be a regular URL:\n%s", regular)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "https://www.example.com/sitemap_news.xml") {
+		t.Fatalf("index does not reference the news sitemap:\n%s", index)
+	}
+}
+
+func TestNewsOverflow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	build := func(dir string, overflow NewsOverflow) *SitemapOptions {
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.Now = func() time.Time { return now }
+		sm.NewsOverflow = overflow
+		for i := 0; i <= maxNewsURLs; i++ {
+			sm.AddURL(newsURL("/article-"+strconv.Itoa(i), now.Add(-time.Minute)))
+		}
+		return sm
+	}
+
+	if err := build(t.TempDir(), NewsError).Write("https://www.example.com/"); err == nil {
+		t.Fatal("expected an error for too many news articles")
+	}
+
+	dir := t.TempDir()
+	if err := build(dir, NewsSplit).Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, name := range []string{"sitemap_news.xml", "sitemap_news_2.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+}
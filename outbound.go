@@ -0,0 +1,186 @@
+package sitemap
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces outbound requests. Wait blocks until a request to host
+// may proceed or ctx is done.
+type RateLimiter interface {
+	Wait(ctx context.Context, host string) error
+}
+
+// hostRateLimiter is a per-host rate limiter using the generic cell rate
+// algorithm, which behaves like a token bucket without a refill goroutine.
+type hostRateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	next     map[string]time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing perSecond requests per second
+// to each host, with bursts of up to burst requests.
+func NewRateLimiter(perSecond float64, burst int) RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &hostRateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    burst,
+		next:     make(map[string]time.Time),
+	}
+}
+
+func (l *hostRateLimiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	tat := l.next[host]
+	if tat.Before(now) {
+		tat = now
+	}
+	wait := tat.Sub(now) - time.Duration(l.burst-1)*l.interval
+	l.next[host] = tat.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// httpClient returns the configured HTTP client or http.DefaultClient.
+func (s *SitemapOptions) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return s.HTTPClient
+}
+
+// doRequest sends an outbound request through the shared client and rate
+// limiter. All networked features go through it so proxies, transports and
+// per-host limits configured by the caller apply uniformly.
+func (s *SitemapOptions) doRequest(req *http.Request) (*http.Response, error) {
+	return s.doRequestWith(s.httpClient(), req)
+}
+
+// doRequestWith sends req through the rate limiter and concurrency cap
+// using client, a variant of the configured client. The request holds its
+// slot until the response body is closed.
+func (s *SitemapOptions) doRequestWith(client *http.Client, req *http.Request) (*http.Response, error) {
+	if s.RateLimiter != nil {
+		if err := s.RateLimiter.Wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+	release, err := s.acquireSlot(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// acquireSlot waits until fewer than Concurrency requests are in flight and
+// returns the function releasing the slot taken.
+func (s *SitemapOptions) acquireSlot(ctx context.Context) (func(), error) {
+	slots := s.requestSlots()
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// requestSlots returns the channel holding a token per request in flight,
+// or nil without a cap. Clones share it until their Concurrency changes.
+func (s *SitemapOptions) requestSlots() chan struct{} {
+	if s.Concurrency < 1 {
+		return nil
+	}
+	initMu.Lock()
+	defer initMu.Unlock()
+	if cap(s.slots) != s.Concurrency {
+		s.slots = make(chan struct{}, s.Concurrency)
+	}
+	return s.slots
+}
+
+// releasingBody releases a request slot once when the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// workers returns the number of parallel workers for networked features.
+func (s *SitemapOptions) workers() int {
+	return max(s.Concurrency, 1)
+}
+
+// parallel calls fn for each index below n on up to workers goroutines. It
+// starts no further calls after one fails or ctx is done and returns the
+// first error, or the error of ctx if it ended the calls early.
+func (s *SitemapOptions) parallel(ctx context.Context, n int, fn func(i int) error) error {
+	var (
+		mu       sync.Mutex
+		next     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range min(s.workers(), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if firstErr == nil && next < n {
+					firstErr = ctx.Err()
+				}
+				if firstErr != nil || next == n {
+					mu.Unlock()
+					return
+				}
+				i := next
+				next++
+				mu.Unlock()
+
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
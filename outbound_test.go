@@ -0,0 +1,142 @@
+package sitemap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(ctx, "a.example.com"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// Two requests pass as a burst, the other two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("Rate limiter did not pace requests, took %v", elapsed)
+	}
+
+	// Other hosts have their own budget
+	start = time.Now()
+	if err := limiter.Wait(ctx, "b.example.com"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Fatalf("Independent host was throttled for %v", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	limiter.Wait(cancelled, "c.example.com")
+	limiter.Wait(cancelled, "c.example.com")
+	if err := limiter.Wait(cancelled, "c.example.com"); err == nil {
+		t.Fatalf("Expected error from cancelled context")
+	}
+}
+
+func TestDoRequestUsesClientAndLimiter(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.HTTPClient = server.Client()
+	sm.RateLimiter = NewRateLimiter(1000, 1)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	resp, err := sm.doRequest(req)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	resp.Body.Close()
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("Expected 1 request to reach the server, got %d", hits)
+	}
+}
+
+func TestConcurrencyCapsRequestsInFlight(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.HTTPClient = server.Client()
+	sm.Concurrency = 2
+	clone := sm.Clone()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		// Clones share the cap
+		client := sm
+		if i%2 == 1 {
+			client = clone
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp, err := client.doRequest(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	if peak := atomic.LoadInt32(&peak); peak != 2 {
+		t.Fatalf("Expected at most 2 requests in flight, peaked at %d", peak)
+	}
+}
+
+func TestParallelStopsWhenContextDone(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.Concurrency = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int64
+	err := sm.parallel(ctx, 100, func(i int) error {
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled || calls.Load() > 4 {
+		t.Fatalf("Expected the calls to stop with context.Canceled, got %v after %d calls", err, calls.Load())
+	}
+	if err := sm.parallel(context.Background(), 3, func(int) error { return nil }); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
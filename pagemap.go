@@ -0,0 +1,23 @@
+package sitemap
+
+const pageMapNamespace = "http://www.google.com/schemas/sitemap-pagemap/1.0"
+
+// PageMap holds the structured data Google Programmable Search indexes for
+// a page, written as a pagemap:PageMap element.
+type PageMap struct {
+	DataObjects []DataObject `xml:"pagemap:DataObject"`
+}
+
+// DataObject is a typed group of attributes, such as a document or a
+// product.
+type DataObject struct {
+	Type       string      `xml:"type,attr"`
+	ID         string      `xml:"id,attr,omitempty"`
+	Attributes []Attribute `xml:"pagemap:Attribute"`
+}
+
+// Attribute is a named value of a DataObject.
+type Attribute struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
@@ -0,0 +1,41 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPageMap(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/restaurants/hibachi", PageMap: &PageMap{
+		DataObjects: []DataObject{{
+			Type: "document",
+			ID:   "hibachi",
+			Attributes: []Attribute{
+				{Name: "name", Value: "Dragon & Phoenix"},
+				{Name: "review", Value: "3.5"},
+			},
+		}},
+	}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`xmlns:pagemap="http://www.google.com/schemas/sitemap-pagemap/1.0"`,
+		`<pagemap:DataObject type="document" id="hibachi">`,
+		`<pagemap:Attribute name="name">Dragon &amp; Phoenix</pagemap:Attribute>`,
+		`<pagemap:Attribute name="review">3.5</pagemap:Attribute>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("sitemap missing %s:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// ParseURLSet decodes a sitemap read from r, decompressing it first if it
+// is gzipped. Only the core elements of each URL are decoded.
+func ParseURLSet(r io.Reader) (*URLSet, error) {
+	var urlSet URLSet
+	if err := decodeMaybeGzip(r, &urlSet); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %v", err)
+	}
+	return &urlSet, nil
+}
+
+// ParseSitemapIndex decodes a sitemap index read from r, decompressing it
+// first if it is gzipped.
+func ParseSitemapIndex(r io.Reader) (*SitemapIndex, error) {
+	var index SitemapIndex
+	if err := decodeMaybeGzip(r, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap index: %v", err)
+	}
+	return &index, nil
+}
+
+// LoadURLSet reads a sitemap file such as sitemap.xml or sitemap.xml.gz.
+func LoadURLSet(filePath string) (*URLSet, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseURLSet(f)
+}
+
+// LoadSitemapIndex reads a sitemap index file, gzipped or not.
+func LoadSitemapIndex(filePath string) (*SitemapIndex, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSitemapIndex(f)
+}
+
+// FetchURLSet downloads and decodes the sitemap at loc. Both gzip content
+// encoding and gzipped .xml.gz bodies are handled.
+func (s *SitemapOptions) FetchURLSet(ctx context.Context, loc string) (*URLSet, error) {
+	body, err := s.fetch(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ParseURLSet(body)
+}
+
+// FetchSitemapIndex downloads and decodes the sitemap index at loc.
+func (s *SitemapOptions) FetchSitemapIndex(ctx context.Context, loc string) (*SitemapIndex, error) {
+	body, err := s.fetch(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ParseSitemapIndex(body)
+}
+
+// fetch returns the body of a successful GET of loc.
+func (s *SitemapOptions) fetch(ctx context.Context, loc string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", loc, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: %s", loc, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// decodeMaybeGzip decodes the XML read from r into v, decompressing it
+// first if it is gzipped.
+func decodeMaybeGzip(r io.Reader, v any) error {
+	rc, err := maybeGunzip(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
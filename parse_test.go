@@ -0,0 +1,93 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadGzipped(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzPath := filepath.Join(dir, "sitemap.xml.gz")
+	if err := os.WriteFile(gzPath, gzipBytes(t, data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"sitemap.xml", "sitemap.xml.gz"} {
+		urlSet, err := LoadURLSet(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("LoadURLSet(%s): %v", name, err)
+		}
+		if len(urlSet.URLs) != 1 || urlSet.URLs[0].Loc != "https://www.example.com/a" {
+			t.Fatalf("LoadURLSet(%s) = %+v", name, urlSet.URLs)
+		}
+	}
+}
+
+func TestFetchGzipped(t *testing.T) {
+	index := []byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>https://www.example.com/sitemap_1.xml</loc></sitemap></sitemapindex>`)
+	urlSet := []byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://www.example.com/a</loc></url></urlset>`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap_index.xml":
+			// Gzip content encoding set by the server
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipBytes(t, index))
+		case "/sitemap_1.xml.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(gzipBytes(t, urlSet))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	// Disable transparent decompression so the package has to handle it
+	sm.HTTPClient = &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	ctx := context.Background()
+
+	gotIndex, err := sm.FetchSitemapIndex(ctx, server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("FetchSitemapIndex: %v", err)
+	}
+	if len(gotIndex.Sitemaps) != 1 {
+		t.Fatalf("unexpected index: %+v", gotIndex)
+	}
+	gotSet, err := sm.FetchURLSet(ctx, server.URL+"/sitemap_1.xml.gz")
+	if err != nil {
+		t.Fatalf("FetchURLSet: %v", err)
+	}
+	if len(gotSet.URLs) != 1 || gotSet.URLs[0].Loc != "https://www.example.com/a" {
+		t.Fatalf("unexpected sitemap: %+v", gotSet.URLs)
+	}
+	if _, err := sm.FetchURLSet(ctx, server.URL+"/missing.xml"); err == nil {
+		t.Fatal("expected an error for a 404")
+	}
+}
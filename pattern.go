@@ -0,0 +1,156 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Pattern matches URLs for include and exclude rules.
+type Pattern struct {
+	source string
+	re     *regexp.Regexp
+	path   bool // Match against the path and query instead of the full loc
+}
+
+// NewPattern compiles a pattern. Patterns prefixed with "regexp:" are
+// regular expressions matched against the full loc. Other patterns are
+// globs where '*' matches any run of characters, including '/', and '?'
+// matches a single character. Globs starting with '/' are matched against
+// the URL path and query; others against the full loc.
+func NewPattern(pattern string) (*Pattern, error) {
+	if expr, ok := strings.CutPrefix(pattern, "regexp:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+		}
+		return &Pattern{source: pattern, re: re}, nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return &Pattern{
+		source: pattern,
+		re:     regexp.MustCompile(expr.String()),
+		path:   strings.HasPrefix(pattern, "/"),
+	}, nil
+}
+
+// Match reports whether loc matches the pattern.
+func (p *Pattern) Match(loc string) bool {
+	if !p.path {
+		return p.re.MatchString(loc)
+	}
+	u, err := url.Parse(loc)
+	if err != nil {
+		return false
+	}
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	return p.re.MatchString(target)
+}
+
+// String returns the source of the pattern.
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// compilePatterns compiles every pattern in patterns.
+func compilePatterns(patterns []string) ([]*Pattern, error) {
+	compiled := make([]*Pattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := NewPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+// WithInclude adds patterns of which a URL must match at least one to be
+// written. It returns s for chaining; invalid patterns are reported by Write.
+func (s *SitemapOptions) WithInclude(patterns ...string) *SitemapOptions {
+	s.Include = append(s.Include, patterns...)
+	return s
+}
+
+// WithExclude adds patterns dropping matching URLs at write time. It returns
+// s for chaining; invalid patterns are reported by Write.
+func (s *SitemapOptions) WithExclude(patterns ...string) *SitemapOptions {
+	s.Exclude = append(s.Exclude, patterns...)
+	return s
+}
+
+// filterURLs returns the urls that are not expired, excluded by Robots,
+// Include or Exclude, or duplicates, recording dropped URLs in report.
+func (s *SitemapOptions) filterURLs(rules *urlRules, urls []SitemapURL, report *Report, seen map[string]bool) []SitemapURL {
+	now := s.now()
+	kept := make([]SitemapURL, 0, len(urls))
+	for _, u := range urls {
+		if reason := s.exclusionReason(u, now, rules.include, rules.exclude); reason != "" {
+			s.exclude(report, ExcludedURL{Loc: u.Loc, Reason: reason, Meta: u.Meta})
+			continue
+		}
+		// The first entry for a loc wins
+		if seen[u.Loc] {
+			s.exclude(report, ExcludedURL{Loc: u.Loc, Reason: "duplicate", Meta: u.Meta})
+			continue
+		}
+		seen[u.Loc] = true
+		kept = append(kept, u)
+	}
+	return kept
+}
+
+// exclusionReason returns why u is excluded, or "" if it is kept.
+func (s *SitemapOptions) exclusionReason(u SitemapURL, now time.Time, include, exclude []*Pattern) string {
+	if !u.ExpiresAt.IsZero() && !now.Before(u.ExpiresAt) {
+		return "expired: " + u.ExpiresAt.Format(time.RFC3339)
+	}
+	loc := u.Loc
+	if s.Robots != nil {
+		if rule, allowed := s.Robots.match(loc); !allowed {
+			return "robots.txt: Disallow: " + rule
+		}
+	}
+	if len(include) > 0 {
+		matched := false
+		for _, p := range include {
+			if p.Match(loc) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "include: no pattern matched"
+		}
+	}
+	for _, p := range exclude {
+		if p.Match(loc) {
+			return "exclude: " + p.String()
+		}
+	}
+	if s.Filter != nil && !s.Filter(u) {
+		return "filter"
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package sitemap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		pattern, loc string
+		expected     bool
+	}{
+		{"/blog/*", "https://www.example.com/blog/a/b", true},
+		{"/blog/*", "https://www.example.com/blog", false},
+		{"/page?", "https://www.example.com/page1", true},
+		{"/search*", "https://www.example.com/search?q=x", true},
+		{"https://staging.example.com/*", "https://staging.example.com/x", true},
+		{"https://staging.example.com/*", "https://www.example.com/x", false},
+		{"regexp:[?&]utm_", "https://www.example.com/?utm_source=x", true},
+		{"regexp:^https://www\\.example\\.com/p/\\d+$", "https://www.example.com/p/12", true},
+	}
+	for _, tt := range tests {
+		p, err := NewPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("Error compiling %q: %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.loc); got != tt.expected {
+			t.Fatalf("Pattern %q on %s = %v, expected %v", tt.pattern, tt.loc, got, tt.expected)
+		}
+	}
+}
+
+func TestIncludeExclude(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com").
+		WithInclude("/products/*", "/blog/*").
+		WithExclude("/blog/drafts/*")
+
+	for _, loc := range []string{"/products/1", "/blog/post", "/blog/drafts/wip", "/staging/test"} {
+		sm.AddURL(SitemapURL{Loc: loc})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	report := sm.Report()
+	if report.URLs != 2 || len(report.Excluded) != 2 {
+		t.Fatalf("Expected 2 written and 2 excluded URLs, got %+v", report)
+	}
+	if report.Excluded[0].Reason != "exclude: /blog/drafts/*" || report.Excluded[1].Reason != "include: no pattern matched" {
+		t.Fatalf("Unexpected exclusion reasons: %+v", report.Excluded)
+	}
+}
+
+func TestExpiredURLsAreOmitted(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.Now = func() time.Time { return now }
+
+	sm.AddURL(SitemapURL{Loc: "/permanent"})
+	sm.AddURL(SitemapURL{Loc: "/events/past", ExpiresAt: now.Add(-time.Hour)})
+	sm.AddURL(SitemapURL{Loc: "/events/now", ExpiresAt: now})
+	sm.AddURL(SitemapURL{Loc: "/events/future", ExpiresAt: now.Add(time.Hour)})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	report := sm.Report()
+	if report.URLs != 2 || len(report.Excluded) != 2 {
+		t.Fatalf("Expected 2 written and 2 expired URLs, got %+v", report)
+	}
+	if report.Excluded[0].Loc != "https://www.example.com/events/past" || !strings.HasPrefix(report.Excluded[0].Reason, "expired: ") {
+		t.Fatalf("Unexpected exclusion: %+v", report.Excluded[0])
+	}
+}
+
+func TestFilterAndMeta(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/jobs/1", Meta: map[string]string{"type": "posting"}})
+	sm.AddURL(SitemapURL{Loc: "/draft", Meta: map[string]string{"type": "article", "status": "draft"}})
+	sm.AddURL(SitemapURL{Loc: "/about"})
+	sm.Filter = func(u SitemapURL) bool {
+		return u.Meta["status"] != "draft"
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	if strings.Contains(string(data), "/draft") || strings.Contains(string(data), "posting") {
+		t.Fatalf("Expected the filtered URL and no meta in the output: %s", data)
+	}
+	excluded := sm.Report().Excluded
+	if len(excluded) != 1 || excluded[0].Reason != "filter" || excluded[0].Meta["type"] != "article" {
+		t.Fatalf("Unexpected exclusions: %+v", excluded)
+	}
+	if meta := sm.State().Meta("https://www.example.com/jobs/1"); meta["type"] != "posting" {
+		t.Fatalf("Expected the meta in the state, got %v", meta)
+	}
+}
+
+func TestOnAddURL(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.OnAddURL = func(u *SitemapURL) error {
+		if strings.Contains(u.Loc, "?") {
+			return errors.New("query strings are not allowed")
+		}
+		u.Loc = strings.ToLower(u.Loc)
+		u.Meta = map[string]string{"source": "feed"}
+		return nil
+	}
+	sm.AddURL(SitemapURL{Loc: "/Products/Shoes"})
+	sm.AddURL(SitemapURL{Loc: "/search?q=shoes"})
+	if len(sm.URLs) != 1 || sm.URLs[0].Loc != "https://www.example.com/products/shoes" || sm.URLs[0].Meta["source"] != "feed" {
+		t.Fatalf("Expected only the lowercased, annotated URL to be added, got %+v", sm.URLs)
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	excluded := sm.Report().Excluded
+	if len(excluded) != 1 || excluded[0].Loc != "https://www.example.com/search?q=shoes" || excluded[0].Reason != "rejected: query strings are not allowed" {
+		t.Fatalf("Expected the rejected URL in the report, got %+v", excluded)
+	}
+
+	w, err := NewIncrementalWriter(NewSitemapOptions(t.TempDir(), "https://www.example.com"), "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("Error creating writer: %v", err)
+	}
+	w.opts.OnAddURL = sm.OnAddURL
+	if err := w.Upsert(SitemapURL{Loc: "/search?q=boots"}); err == nil {
+		t.Fatalf("Expected Upsert to return the rejection")
+	}
+}
@@ -0,0 +1,69 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Pinger notifies search engines or other consumers that a sitemap changed
+// by requesting their ping endpoints. It only pings when the last Write
+// changed URLs, so frequent regenerations that change nothing do not spam
+// the endpoints and trip their rate limits.
+type Pinger struct {
+	// Endpoints are the ping URLs, such as https://example.org/ping. Each
+	// receives the sitemap URL in its sitemap query parameter.
+	Endpoints []string
+	// Force pings even if the last Write changed nothing.
+	Force bool
+}
+
+// Ping sends a GET request with sitemapURL to every endpoint if the last
+// successful Write of s added, modified or removed URLs relative to its
+// PreviousState, or always with Force. A Write without PreviousState counts
+// as a change. Requests go through the rate limiter and concurrency cap of
+// s, on up to Concurrency workers. It reports whether the endpoints were
+// pinged and returns the first failed request.
+func (p *Pinger) Ping(ctx context.Context, s *SitemapOptions, sitemapURL string) (bool, error) {
+	state := s.State()
+	if state == nil {
+		return false, fmt.Errorf("no successful Write to ping about")
+	}
+	if !p.Force {
+		changed, removed := state.Diff(s.PreviousState)
+		if len(changed) == 0 && len(removed) == 0 {
+			return false, nil
+		}
+	}
+
+	err := s.parallel(ctx, len(p.Endpoints), func(i int) error {
+		return s.ping(ctx, p.Endpoints[i], sitemapURL)
+	})
+	return err == nil, err
+}
+
+// ping requests endpoint with sitemapURL in its sitemap query parameter.
+func (s *SitemapOptions) ping(ctx context.Context, endpoint, sitemapURL string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid ping endpoint '%s': %v", endpoint, err)
+	}
+	query := u.Query()
+	query.Set("sitemap", sitemapURL)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to ping %s: %v", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to ping %s: %s", u.Host, resp.Status)
+	}
+	return nil
+}
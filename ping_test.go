@@ -0,0 +1,54 @@
+package sitemap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPingOnlyOnChange(t *testing.T) {
+	var pings atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("sitemap"); got != "https://www.example.com/sitemap.xml" {
+			t.Errorf("unexpected sitemap parameter %q", got)
+		}
+		pings.Add(1)
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.HTTPClient = server.Client()
+	pinger := &Pinger{Endpoints: []string{server.URL + "/ping", server.URL + "/other?key=1"}}
+	write := func() bool {
+		t.Helper()
+		sm.Reset()
+		sm.AddURL(SitemapURL{Loc: "/", LastMod: "2024-01-01"})
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		pinged, err := pinger.Ping(context.Background(), sm, "https://www.example.com/sitemap.xml")
+		if err != nil {
+			t.Fatalf("Ping: %v", err)
+		}
+		return pinged
+	}
+
+	if !write() || pings.Load() != 2 {
+		t.Fatalf("Expected the first Write to ping both endpoints, got %d pings", pings.Load())
+	}
+	if write() || pings.Load() != 2 {
+		t.Fatalf("Expected an unchanged Write not to ping, got %d pings", pings.Load())
+	}
+	pinger.Force = true
+	if !write() || pings.Load() != 4 {
+		t.Fatalf("Expected Force to ping, got %d pings", pings.Load())
+	}
+
+	pinger.Endpoints = []string{server.URL + "/missing"}
+	server.Config.Handler = http.NotFoundHandler()
+	if _, err := pinger.Ping(context.Background(), sm, "https://www.example.com/sitemap.xml"); err == nil {
+		t.Fatal("Expected a failed ping to be returned")
+	}
+}
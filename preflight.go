@@ -0,0 +1,61 @@
+package sitemap
+
+import (
+	"fmt"
+)
+
+// indexEntryOverhead estimates the bytes of one sitemap element in an
+// index besides its loc.
+const indexEntryOverhead = 96
+
+// preflight estimates the size of the files about to be written, records
+// it in the report and, if CheckFreeSpace or SpaceCheck is set, fails
+// before any file is written when there is no room for them. The files of
+// the previous run are kept as backups until the run completes, so the
+// full estimate must fit next to them.
+func (s *SitemapOptions) preflight(urls []SitemapURL, extra []shard, report *Report) error {
+	if !s.CheckFreeSpace && s.SpaceCheck == nil {
+		return nil
+	}
+	stylesheet, err := s.renderStylesheet()
+	if err != nil {
+		return err
+	}
+	size := int64(len(stylesheet))
+	if s.ShardDir != "" {
+		size *= 2
+	}
+	shards := append(s.shards(urls), extra...)
+	for _, shard := range shards {
+		size += int64(s.shardOverhead())
+		for _, u := range shard.urls {
+			size += int64(s.entrySize(u))
+		}
+	}
+	if len(shards) > 1 || len(s.ExternalSitemaps) > 0 {
+		size += int64(s.shardOverhead())
+		for _, shard := range shards {
+			size += int64(indexEntryOverhead + len(s.BaseURL) + len(s.ShardDir) + len(shard.name))
+		}
+		for _, sitemap := range s.ExternalSitemaps {
+			size += int64(indexEntryOverhead + len(sitemap.Loc))
+		}
+	}
+	report.EstimatedBytes = size
+
+	if s.CheckFreeSpace {
+		available, ok, err := freeSpace(s.Dir)
+		if err != nil {
+			return fmt.Errorf("failed to check free space in %s: %v", s.Dir, err)
+		}
+		if ok && available < size {
+			return fmt.Errorf("not enough space in %s: the sitemap files need about %d bytes, %d are available", s.Dir, size, available)
+		}
+	}
+	if s.SpaceCheck != nil {
+		if err := s.SpaceCheck(size); err != nil {
+			return fmt.Errorf("space check failed: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package sitemap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpaceCheck(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 50
+	sm.CheckFreeSpace = true
+	var estimate int64
+	sm.SpaceCheck = func(bytes int64) error {
+		estimate = bytes
+		return nil
+	}
+	for i := 0; i < 120; i++ {
+		sm.AddURL(SitemapURL{Loc: "/page/" + strings.Repeat("x", i%10), ChangeFreq: "daily"})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if sm.Report().EstimatedBytes != estimate || estimate == 0 {
+		t.Fatalf("report estimate %d, SpaceCheck got %d", sm.Report().EstimatedBytes, estimate)
+	}
+
+	var written int64
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+		if !strings.HasPrefix(entry.Name(), ".") {
+			written += info.Size()
+		}
+	}
+	if estimate < written {
+		t.Fatalf("estimate %d is below the %d bytes written", estimate, written)
+	}
+}
+
+func TestSpaceCheckFailsBeforeWriting(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.SpaceCheck = func(int64) error {
+		return errors.New("quota exceeded")
+	}
+	sm.AddURL(SitemapURL{Loc: "/"})
+	err := sm.Write("https://www.example.com/")
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("expected the quota error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap.xml")); !os.IsNotExist(err) {
+		t.Fatalf("expected no sitemap to be written, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package sitemap
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// publicName returns the name the sitemap file called name, possibly with
+// .gz, is referenced by according to names, which maps plain local file
+// names to public ones.
+func publicName(names map[string]string, name string) string {
+	plain, gz := strings.CutSuffix(name, gzipExt)
+	if public, ok := names[plain]; ok {
+		plain = public
+	}
+	if gz {
+		return plain + gzipExt
+	}
+	return plain
+}
+
+// localName returns the local file name of the sitemap referenced as name,
+// undoing publicName.
+func localName(names map[string]string, name string) string {
+	plain, gz := strings.CutSuffix(name, gzipExt)
+	for local, public := range names {
+		if public == plain {
+			plain = local
+			break
+		}
+	}
+	if gz {
+		return plain + gzipExt
+	}
+	return plain
+}
+
+// checkPublicNames returns an error if PublicNames maps a file to a name
+// that is not a plain .xml file name or that another file has or is
+// referenced by.
+func (s *SitemapOptions) checkPublicNames() error {
+	used := make(map[string]string, len(s.PublicNames))
+	for local, public := range s.PublicNames {
+		if public == "" || public != path.Base(public) || strings.Contains(public, `\`) || !strings.HasSuffix(public, sitemapExt) {
+			return fmt.Errorf("invalid public name '%s' for %s: must be a file name ending in %s", public, local, sitemapExt)
+		}
+		if other, ok := used[public]; ok {
+			return fmt.Errorf("public name '%s' is used for both %s and %s", public, other, local)
+		}
+		used[public] = local
+	}
+	for local, public := range s.PublicNames {
+		if _, ok := s.PublicNames[public]; ok && public != local {
+			return fmt.Errorf("public name '%s' of %s is the local name of another file", public, local)
+		}
+	}
+	return nil
+}
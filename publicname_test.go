@@ -0,0 +1,62 @@
+package sitemap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPublicNames(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.PublicNames = map[string]string{"sitemap_1.xml": "sitemap-products.xml"}
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap index: %v", err)
+	}
+	if len(index.Sitemaps) != 2 || index.Sitemaps[0].Loc != "https://www.example.com/sitemap-products.xml" || index.Sitemaps[1].Loc != "https://www.example.com/sitemap_2.xml" {
+		t.Fatalf("Expected the index to reference the public name, got %+v", index.Sitemaps)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap_1.xml")); err != nil {
+		t.Fatalf("Expected the file to keep its local name: %v", err)
+	}
+
+	// The public name is served and resolved to the local file
+	rec := httptest.NewRecorder()
+	sm.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sitemap-products.xml", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the public name to be served, got %d", rec.Code)
+	}
+	touched := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := sm.TouchLastMod("https://www.example.com/", touched, "/a"); err != nil {
+		t.Fatalf("Error touching lastmod: %v", err)
+	}
+	index, err = LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error loading sitemap index: %v", err)
+	}
+	if index.Sitemaps[0].LastMod != "2024-06-01" {
+		t.Fatalf("Expected the entry of the renamed file to be touched, got %+v", index.Sitemaps)
+	}
+
+	for _, names := range []map[string]string{
+		{"sitemap_1.xml": "feeds/products.xml"},
+		{"sitemap_1.xml": "products.txt"},
+		{"sitemap_1.xml": "products.xml", "sitemap_2.xml": "products.xml"},
+		{"sitemap_1.xml": "sitemap_2.xml", "sitemap_2.xml": "legacy.xml"},
+	} {
+		sm.PublicNames = names
+		if err := sm.Write("https://www.example.com/"); err == nil {
+			t.Fatalf("Expected an error for public names %v", names)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package sitemap
+
+import (
+	"path/filepath"
+)
+
+// publishedURLs returns the public URLs of the files changed by tx: files in
+// ShardDir are served from the shard base URL, all others from
+// baseSitemapURL.
+func (s *SitemapOptions) publishedURLs(baseSitemapURL string, tx *writeTx) ([]string, error) {
+	shardBaseURL, err := s.shardBaseURL(baseSitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, change := range tx.changes {
+		// Files outside the served directories, such as archived sets, have
+		// no public URL
+		dir := filepath.Dir(change.path)
+		if dir != filepath.Clean(s.Dir) && dir != s.shardDir() {
+			continue
+		}
+		base := baseSitemapURL
+		if s.ShardDir != "" && dir == s.shardDir() {
+			base = shardBaseURL
+		}
+		loc, err := s.resolveSitemapURL(base, publicName(s.PublicNames, filepath.Base(change.path)))
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, loc)
+	}
+	return urls, nil
+}
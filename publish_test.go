@@ -0,0 +1,51 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAfterWrite(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.ShardDir = "shards"
+	for i := 0; i < 3; i++ {
+		sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i)})
+	}
+
+	var published []string
+	sm.AfterWrite = func(urls []string) error {
+		published = urls
+		return nil
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	for _, want := range []string{
+		"https://www.example.com/sitemap_index.xml",
+		"https://www.example.com/sitemap.xsl",
+		"https://www.example.com/shards/sitemap_1.xml",
+		"https://www.example.com/shards/sitemap_2.xml",
+		"https://www.example.com/shards/sitemap.xsl",
+	} {
+		if !slices.Contains(published, want) {
+			t.Fatalf("Expected %s in published URLs, got %v", want, published)
+		}
+	}
+
+	sm.AfterWrite = func(urls []string) error {
+		return errors.New("purge failed")
+	}
+	err := sm.Write("https://www.example.com/")
+	if err == nil || !strings.Contains(err.Error(), "purge failed") {
+		t.Fatalf("Expected the hook error, got %v", err)
+	}
+	if _, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml")); err != nil {
+		t.Fatalf("Expected the written index to stay in place: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package purge removes freshly published sitemap files from CDN caches, so
+// crawlers never fetch a stale cached index. Use AfterWrite to purge the
+// files of every Write:
+//
+//	sm.AfterWrite = purge.AfterWrite(&purge.Cloudflare{ZoneID: zone, Token: token}, 0)
+package purge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+	fastlyEndpoint     = "https://api.fastly.com"
+
+	// cloudflareBatchSize is the most files one Cloudflare purge request
+	// may list.
+	cloudflareBatchSize = 30
+)
+
+// Purger purges URLs from a CDN cache.
+type Purger interface {
+	Purge(ctx context.Context, urls []string) error
+}
+
+// AfterWrite returns a SitemapOptions.AfterWrite hook purging the written
+// files with p, giving up after timeout (one minute if zero).
+func AfterWrite(p Purger, timeout time.Duration) func(urls []string) error {
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return func(urls []string) error {
+		if len(urls) == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return p.Purge(ctx, urls)
+	}
+}
+
+// Cloudflare purges URLs from a Cloudflare zone.
+type Cloudflare struct {
+	ZoneID string
+	// Token is an API token with the Cache Purge permission for the zone.
+	Token  string
+	Client *http.Client // http.DefaultClient if nil
+	// Endpoint overrides the API base URL.
+	Endpoint string
+}
+
+// cloudflareResponse is the envelope of Cloudflare API responses.
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Purge purges urls in batches of 30, the most one request may list.
+func (c *Cloudflare) Purge(ctx context.Context, urls []string) error {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = cloudflareEndpoint
+	}
+	endpoint = strings.TrimRight(endpoint, "/") + "/zones/" + url.PathEscape(c.ZoneID) + "/purge_cache"
+
+	for start := 0; start < len(urls); start += cloudflareBatchSize {
+		batch := urls[start:min(start+cloudflareBatchSize, len(urls))]
+		body, err := json.Marshal(map[string][]string{"files": batch})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client(c.Client).Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to purge Cloudflare cache: %v", err)
+		}
+		var result cloudflareResponse
+		err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to purge Cloudflare cache: %s: %v", resp.Status, err)
+		}
+		if !result.Success {
+			var messages []string
+			for _, e := range result.Errors {
+				messages = append(messages, fmt.Sprintf("%d %s", e.Code, e.Message))
+			}
+			return fmt.Errorf("failed to purge Cloudflare cache: %s: %s", resp.Status, strings.Join(messages, "; "))
+		}
+	}
+	return nil
+}
+
+// Fastly purges URLs from a Fastly service, one request per URL.
+type Fastly struct {
+	// Key is an API token with the purge_select scope.
+	Key    string
+	Client *http.Client // http.DefaultClient if nil
+	// Endpoint overrides the API base URL.
+	Endpoint string
+	// Soft marks the content stale instead of evicting it.
+	Soft bool
+}
+
+// Purge purges urls, stopping at the first failure.
+func (f *Fastly) Purge(ctx context.Context, urls []string) error {
+	endpoint := f.Endpoint
+	if endpoint == "" {
+		endpoint = fastlyEndpoint
+	}
+	endpoint = strings.TrimRight(endpoint, "/") + "/purge/"
+
+	for _, loc := range urls {
+		u, err := url.Parse(loc)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid URL to purge '%s'", loc)
+		}
+		// The API takes the cached URL without its scheme
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+u.Host+u.RequestURI(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Fastly-Key", f.Key)
+		req.Header.Set("Accept", "application/json")
+		if f.Soft {
+			req.Header.Set("Fastly-Soft-Purge", "1")
+		}
+
+		resp, err := client(f.Client).Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to purge %s from Fastly: %v", loc, err)
+		}
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to purge %s from Fastly: %s: %s", loc, resp.Status, bytes.TrimSpace(detail))
+		}
+	}
+	return nil
+}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
@@ -0,0 +1,84 @@
+package purge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCloudflare(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones/zone-1/purge_cache" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Files []string `json:"files"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Error decoding request: %v", err)
+		}
+		batches = append(batches, body.Files)
+		fmt.Fprint(w, `{"success":true,"errors":[]}`)
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 31; i++ {
+		urls = append(urls, fmt.Sprintf("https://www.example.com/sitemap_%d.xml", i+1))
+	}
+	hook := AfterWrite(&Cloudflare{ZoneID: "zone-1", Token: "secret", Endpoint: server.URL}, 0)
+	if err := hook(urls); err != nil {
+		t.Fatalf("Error purging: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 30 || len(batches[1]) != 1 {
+		t.Fatalf("Expected batches of 30 and 1, got %d batches", len(batches))
+	}
+}
+
+func TestCloudflareError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":1012,"message":"Request must contain one of ..."}]}`)
+	}))
+	defer server.Close()
+
+	cf := &Cloudflare{ZoneID: "zone-1", Endpoint: server.URL}
+	err := cf.Purge(context.Background(), []string{"https://www.example.com/sitemap.xml"})
+	if err == nil || !strings.Contains(err.Error(), "1012") {
+		t.Fatalf("Expected the API error, got %v", err)
+	}
+}
+
+func TestFastly(t *testing.T) {
+	var purged []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Fastly-Key") != "secret" || r.Header.Get("Fastly-Soft-Purge") != "1" {
+			t.Errorf("Unexpected request %s with key %q", r.Method, r.Header.Get("Fastly-Key"))
+		}
+		purged = append(purged, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "missing.xml") {
+			http.Error(w, `{"msg":"not found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	}))
+	defer server.Close()
+
+	fastly := &Fastly{Key: "secret", Endpoint: server.URL, Soft: true}
+	if err := fastly.Purge(context.Background(), []string{"https://www.example.com/sitemap_index.xml"}); err != nil {
+		t.Fatalf("Error purging: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != "/purge/www.example.com/sitemap_index.xml" {
+		t.Fatalf("Unexpected purge requests: %v", purged)
+	}
+
+	err := fastly.Purge(context.Background(), []string{"https://www.example.com/missing.xml"})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected a 404 error, got %v", err)
+	}
+}
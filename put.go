@@ -0,0 +1,153 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// PutTarget is an HTTP endpoint, such as a WebDAV server or an object store,
+// receiving the sitemap files by PUT requests.
+type PutTarget struct {
+	// URL is the base URL the files are sent below, with the paths they
+	// would have below Dir, such as https://dav.example.com/sitemaps/.
+	URL string
+	// Authorize, if set, is called for every request before it is sent, to
+	// add headers such as Authorization.
+	Authorize func(req *http.Request) error
+}
+
+// WritePut publishes the files Write would produce by sending each to
+// target as soon as it is generated, without touching the local disk, for
+// containers with read-only filesystems. Each file is validated before it
+// is sent. baseSitemapURL is used as for Write, Dir only names the paths
+// below target.URL and the report and State are kept as for Write.
+//
+// Files already sent stay published if WritePut fails, and files of an
+// earlier run are neither removed nor restored. Options that keep files on
+// disk, such as StateFile, ManifestFile or ArchiveDir, are not supported;
+// pass the previous State as PreviousState instead. AfterWrite is not
+// called.
+func (s *SitemapOptions) WritePut(ctx context.Context, target PutTarget, baseSitemapURL string) error {
+	if s.released {
+		return errReleased
+	}
+	if err := s.checkPutOptions(); err != nil {
+		return err
+	}
+	if _, err := parseBaseURL(target.URL); err != nil {
+		return fmt.Errorf("invalid PUT target URL: %w", err)
+	}
+	tx := &writeTx{put: func(filePath string, data []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.validatePut(filePath, data); err != nil {
+			return err
+		}
+		return s.put(ctx, target, filePath, data)
+	}}
+	err := s.runTx(tx, func() error {
+		return s.write(ctx, baseSitemapURL)
+	})
+	if err == errSkippedEmpty {
+		return nil
+	}
+	return err
+}
+
+// checkPutOptions returns an error if an option set on s needs the local
+// disk, which WritePut does not use.
+func (s *SitemapOptions) checkPutOptions() error {
+	unsupported := []struct {
+		name string
+		set  bool
+	}{
+		{"StateFile", s.StateFile != ""},
+		{"ManifestFile", s.ManifestFile != ""},
+		{"ChecksumFiles", s.ChecksumFiles},
+		{"MetricsFile", s.MetricsFile != ""},
+		{"ReviewFile", s.ReviewFile != ""},
+		{"ArchiveDir", s.ArchiveDir != ""},
+		{"VerifyDir", s.VerifyDir != ""},
+		{"Mirrors", len(s.Mirrors) > 0},
+		{"CheckFreeSpace", s.CheckFreeSpace},
+		{"MemoryLimit", s.MemoryLimit > 0},
+	}
+	for _, option := range unsupported {
+		if option.set {
+			return fmt.Errorf("WritePut does not support %s", option.name)
+		}
+	}
+	return nil
+}
+
+// validatePut validates the sitemap file or index at filePath before
+// WritePut sends it. Other files, such as the stylesheet, are not checked.
+func (s *SitemapOptions) validatePut(filePath string, data []byte) error {
+	name := strings.TrimSuffix(filepath.Base(filePath), gzipExt)
+	if !strings.HasSuffix(name, sitemapExt) || s.tx.empty && name == "sitemap.xml" {
+		return nil
+	}
+	if strings.HasSuffix(filePath, gzipExt) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		if data, err = io.ReadAll(gz); err != nil {
+			return err
+		}
+	}
+	return validateXMLData(filePath, data, name == "sitemap_index.xml" || isNestedIndex(name))
+}
+
+// put sends the file at filePath to target.
+func (s *SitemapOptions) put(ctx context.Context, target PutTarget, filePath string, data []byte) error {
+	rel, err := filepath.Rel(s.Dir, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside Dir and cannot be sent to the PUT target", filePath)
+	}
+	loc := strings.TrimSuffix(target.URL, "/") + "/" + filepath.ToSlash(rel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, loc, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", putContentType(rel))
+	if target.Authorize != nil {
+		if err := target.Authorize(req); err != nil {
+			return err
+		}
+	}
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to PUT %s: %s: %s", loc, resp.Status, bytes.TrimSpace(detail))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// putContentType returns the Content-Type of a file sent by WritePut.
+func putContentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, gzipExt):
+		return "application/gzip"
+	case strings.HasSuffix(name, sitemapExt):
+		return "application/xml"
+	case strings.HasSuffix(name, ".xsl"):
+		return "text/xsl"
+	case strings.HasSuffix(name, ".json"):
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
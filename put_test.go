@@ -0,0 +1,68 @@
+package sitemap
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWritePut(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = r.Header.Get("Content-Type") + " " + string(body[:min(len(body), 2)])
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "sitemaps")
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Gzip = true
+	sm.MaxURLs = 1
+	sm.AddURLs([]SitemapURL{{Loc: "/a"}, {Loc: "/b"}})
+	target := PutTarget{
+		URL: server.URL + "/dav/",
+		Authorize: func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer secret")
+			return nil
+		},
+	}
+	if err := sm.WritePut(context.Background(), target, "https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	for _, name := range []string{"/dav/sitemap_index.xml.gz", "/dav/sitemap_1.xml.gz", "/dav/sitemap_2.xml.gz"} {
+		if got := received[name]; got != "application/gzip \x1f\x8b" {
+			t.Fatalf("Expected gzipped %s to be sent, got %q", name, got)
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected nothing to be written to disk, stat returned %v", err)
+	}
+	if sm.Report() == nil || sm.Report().URLs != 2 {
+		t.Fatalf("Expected the report of the run, got %+v", sm.Report())
+	}
+
+	// A rejected request fails the run
+	target.Authorize = nil
+	if err := sm.WritePut(context.Background(), target, "https://www.example.com/"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Expected the 403 to fail the run, got %v", err)
+	}
+	sm.StateFile = "sitemap.state"
+	if err := sm.WritePut(context.Background(), target, "https://www.example.com/"); err == nil || !strings.Contains(err.Error(), "StateFile") {
+		t.Fatalf("Expected StateFile to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package sitemap
+
+import (
+	"net/url"
+	"strings"
+)
+
+// QueryAllowlist keeps only the query parameters named in Keys on locs
+// matching Pattern. See NewPattern for the pattern syntax.
+type QueryAllowlist struct {
+	Pattern string
+	Keys    []string
+}
+
+// queryCleaner applies StripQueryParams and QueryAllowlists to locs.
+type queryCleaner struct {
+	strip      []string
+	allowlists []compiledAllowlist
+}
+
+type compiledAllowlist struct {
+	pattern *Pattern
+	keys    map[string]bool
+}
+
+// queryCleaner compiles the query rules of s, or returns nil if there are
+// none.
+func (s *SitemapOptions) queryCleaner() (*queryCleaner, error) {
+	if len(s.StripQueryParams) == 0 && len(s.QueryAllowlists) == 0 {
+		return nil, nil
+	}
+	c := &queryCleaner{strip: s.StripQueryParams}
+	for _, allowlist := range s.QueryAllowlists {
+		p, err := NewPattern(allowlist.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		keys := make(map[string]bool, len(allowlist.Keys))
+		for _, key := range allowlist.Keys {
+			keys[key] = true
+		}
+		c.allowlists = append(c.allowlists, compiledAllowlist{pattern: p, keys: keys})
+	}
+	return c, nil
+}
+
+// clean removes the disallowed query parameters from loc. The query is
+// re-encoded, sorted by key, only if a parameter was removed.
+func (c *queryCleaner) clean(loc string) string {
+	if c == nil {
+		return loc
+	}
+	u, err := url.Parse(loc)
+	if err != nil || u.RawQuery == "" {
+		return loc
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return loc
+	}
+
+	var allowed map[string]bool
+	for _, allowlist := range c.allowlists {
+		if allowlist.pattern.Match(loc) {
+			allowed = allowlist.keys
+			break
+		}
+	}
+	removed := false
+	for key := range query {
+		if (allowed != nil && !allowed[key]) || c.stripped(key) {
+			query.Del(key)
+			removed = true
+		}
+	}
+	if !removed {
+		return loc
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// stripped reports whether key matches one of the StripQueryParams.
+func (c *queryCleaner) stripped(key string) bool {
+	for _, param := range c.strip {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if len(key) >= len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(key, param) {
+			return true
+		}
+	}
+	return false
+}
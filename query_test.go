@@ -0,0 +1,81 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueryCleaning(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.StripQueryParams = []string{"utm_*", "fbclid"}
+	sm.QueryAllowlists = []QueryAllowlist{{Pattern: "/search*", Keys: []string{"q", "page"}}}
+	for _, loc := range []string{
+		"/shoes?utm_source=mail&UTM_Medium=x",
+		"/shoes?fbclid=abc",
+		"/shoes?color=red&utm_campaign=spring",
+		"/search?q=boots&sort=price&page=2",
+		"/plain?b=2&a=1",
+	} {
+		sm.AddURL(SitemapURL{Loc: loc})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		"<loc>https://www.example.com/shoes</loc>",
+		"<loc>https://www.example.com/shoes?color=red</loc>",
+		"<loc>https://www.example.com/search?page=2&amp;q=boots</loc>",
+		"<loc>https://www.example.com/plain?b=2&amp;a=1</loc>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("sitemap missing %s:\n%s", want, out)
+		}
+	}
+	if got := strings.Count(out, "<url>"); got != 4 {
+		t.Fatalf("got %d URLs, want 4:\n%s", got, out)
+	}
+	excluded := sm.Report().Excluded
+	if len(excluded) != 1 || excluded[0].Loc != "https://www.example.com/shoes" || excluded[0].Reason != "duplicate" {
+		t.Fatalf("unexpected exclusions: %+v", excluded)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.StripQueryParams = []string{"utm_*"}
+	sm.Canonicalize = func(loc string) string {
+		return strings.Replace(loc, "://m.example.com/", "://www.example.com/", 1)
+	}
+	sm.AddURLs([]SitemapURL{
+		{Loc: "https://m.example.com/page?utm_source=feed", Absolute: true},
+		{Loc: "/page"},
+		{Loc: "/other", Alternates: []Alternate{{Hreflang: "en", Href: "https://m.example.com/other"}}},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	urlSet, err := LoadURLSet(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	if len(urlSet.URLs) != 2 || urlSet.URLs[0].Loc != "https://www.example.com/page" {
+		t.Fatalf("Expected the mobile URL to be merged into its canonical URL, got %+v", urlSet.URLs)
+	}
+	if excluded := sm.Report().Excluded; len(excluded) != 1 || excluded[0].Reason != "duplicate" {
+		t.Fatalf("Expected the second /page to be a duplicate, got %+v", excluded)
+	}
+	if href := sm.URLs[2].Alternates[0].Href; href != "https://www.example.com/other" {
+		t.Fatalf("Expected a canonical alternate href, got %s", href)
+	}
+}
@@ -0,0 +1,56 @@
+package sitemap
+
+// Report describes the outcome of the last successful Write.
+type Report struct {
+	URLs     int           // URLs written
+	Limits   Limits        // Effective limits of the files
+	Excluded []ExcludedURL // URLs dropped as expired, duplicate, by filters or from the recent sitemap
+	// EstimatedBytes is the estimated size of the files, computed when
+	// CheckFreeSpace or SpaceCheck is set.
+	EstimatedBytes int64
+	// HreflangIssues lists broken alternate clusters when ValidateHreflang
+	// is set.
+	HreflangIssues []Issue
+	// ImageIssues lists images that were dropped as invalid.
+	ImageIssues []Issue
+	// VideoIssues lists videos that were dropped and video fields that
+	// were cleared as invalid.
+	VideoIssues []Issue
+	// ExternalIssues lists the external sitemaps found broken when
+	// CheckExternal is set, and ExternalURLs the number of URLs or
+	// sitemaps each checked one lists, keyed by loc.
+	ExternalIssues []Issue
+	ExternalURLs   map[string]int
+	// Stats summarizes all URLs written and SitemapStats each sitemap file,
+	// keyed by file name.
+	Stats        Stats
+	SitemapStats map[string]Stats
+	// Changes lists the URLs added, updated and removed since PreviousState,
+	// or is nil if there is none.
+	Changes *Changes
+	// Warnings lists values that were repaired or dropped; in Strict mode
+	// they fail the Write instead.
+	Warnings []Issue
+	// Timings is the time spent in each phase of generating the files.
+	Timings Timings
+}
+
+// Issue is a validation problem found for the URL at Loc.
+type Issue struct {
+	Loc     string
+	Problem string
+	Meta    map[string]string // Meta of the URL, if the issue concerns one
+}
+
+// ExcludedURL is a URL dropped from the output and the rule that dropped it.
+type ExcludedURL struct {
+	Loc    string
+	Reason string
+	Meta   map[string]string
+}
+
+// Report returns the report of the last successful Write, or nil if Write
+// has not succeeded yet.
+func (s *SitemapOptions) Report() *Report {
+	return s.report
+}
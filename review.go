@@ -0,0 +1,282 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reviewRows is the most rows of each table in a rendered Review.
+const reviewRows = 20
+
+// Review summarizes a sitemap set for an SEO review, from the Report and
+// State of a Write.
+type Review struct {
+	URLs int // URLs written
+	// MissingPriority and MissingChangeFreq count the URLs written without
+	// a priority or changefreq.
+	MissingPriority   int
+	MissingChangeFreq int
+	// Sections counts the URLs by first path segment, biggest first.
+	Sections []ReviewSection
+	// Sitemaps describes each sitemap file, oldest lastmod first.
+	Sitemaps []ReviewSitemap
+	// DuplicateTitles lists the titles, from Meta["title"], shared by more
+	// than one URL, most shared first.
+	DuplicateTitles []DuplicateTitle
+}
+
+// ReviewSection is the number of URLs below a path such as /blog/.
+type ReviewSection struct {
+	Path string
+	URLs int
+}
+
+// ReviewSitemap summarizes one sitemap file of a Review.
+type ReviewSitemap struct {
+	Name string
+	URLs int
+	// LastMods counts the URLs with a lastmod and OldestLastMod is the age
+	// of the oldest one at write time.
+	LastMods          int
+	OldestLastMod     time.Duration
+	MissingPriority   int
+	MissingChangeFreq int
+}
+
+// DuplicateTitle is a title shared by the URLs at Locs, sorted.
+type DuplicateTitle struct {
+	Title string
+	Locs  []string
+}
+
+// NewReview builds the Review of a Write from its Report and State, as
+// returned by Report and State. Sections and titles come from the State
+// and are left empty if it is nil; titles are only known for States built
+// by Write in this process.
+func NewReview(report *Report, state *State) *Review {
+	r := &Review{
+		URLs:              report.URLs,
+		MissingPriority:   report.Stats.Priority[""],
+		MissingChangeFreq: report.Stats.ChangeFreq[""],
+	}
+	for name, st := range report.SitemapStats {
+		r.Sitemaps = append(r.Sitemaps, ReviewSitemap{
+			Name:              name,
+			URLs:              st.URLs,
+			LastMods:          st.LastModAge.Count,
+			OldestLastMod:     st.LastModAge.Max,
+			MissingPriority:   st.Priority[""],
+			MissingChangeFreq: st.ChangeFreq[""],
+		})
+	}
+	sort.Slice(r.Sitemaps, func(i, j int) bool {
+		a, b := r.Sitemaps[i], r.Sitemaps[j]
+		if a.OldestLastMod != b.OldestLastMod {
+			return a.OldestLastMod > b.OldestLastMod
+		}
+		return naturalLess(a.Name, b.Name)
+	})
+	if state == nil {
+		return r
+	}
+
+	sections := make(map[string]int)
+	titles := make(map[string][]string)
+	for loc := range state.URLs {
+		sections[sectionPath(loc)]++
+		if title := strings.TrimSpace(state.Meta(loc)["title"]); title != "" {
+			titles[title] = append(titles[title], loc)
+		}
+	}
+	for path, n := range sections {
+		r.Sections = append(r.Sections, ReviewSection{Path: path, URLs: n})
+	}
+	sort.Slice(r.Sections, func(i, j int) bool {
+		a, b := r.Sections[i], r.Sections[j]
+		if a.URLs != b.URLs {
+			return a.URLs > b.URLs
+		}
+		return a.Path < b.Path
+	})
+	for title, locs := range titles {
+		if len(locs) > 1 {
+			sort.Strings(locs)
+			r.DuplicateTitles = append(r.DuplicateTitles, DuplicateTitle{Title: title, Locs: locs})
+		}
+	}
+	sort.Slice(r.DuplicateTitles, func(i, j int) bool {
+		a, b := r.DuplicateTitles[i], r.DuplicateTitles[j]
+		if len(a.Locs) != len(b.Locs) {
+			return len(a.Locs) > len(b.Locs)
+		}
+		return a.Title < b.Title
+	})
+	return r
+}
+
+// sectionPath returns the first path segment of loc, such as /blog/, or /
+// for pages at the root.
+func sectionPath(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return "/"
+	}
+	first, _, nested := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if !nested || first == "" {
+		return "/"
+	}
+	return "/" + first + "/"
+}
+
+// formatAge formats the age of a lastmod in whole days.
+func formatAge(age time.Duration) string {
+	days := int(age.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+// Markdown renders the review as a Markdown document. Each table lists at
+// most 20 rows.
+func (r *Review) Markdown() []byte {
+	var buf bytes.Buffer
+	cell := func(value string) string {
+		return strings.ReplaceAll(value, "|", `\|`)
+	}
+	more := func(n int) {
+		if n > reviewRows {
+			fmt.Fprintf(&buf, "\nand %d more.\n", n-reviewRows)
+		}
+	}
+
+	fmt.Fprintf(&buf, "# Sitemap review\n\n")
+	fmt.Fprintf(&buf, "- URLs: %d\n- Missing priority: %d\n- Missing changefreq: %d\n", r.URLs, r.MissingPriority, r.MissingChangeFreq)
+
+	if len(r.Sections) > 0 {
+		fmt.Fprintf(&buf, "\n## Biggest sections\n\n| Section | URLs |\n| --- | ---: |\n")
+		for _, section := range r.Sections[:min(len(r.Sections), reviewRows)] {
+			fmt.Fprintf(&buf, "| %s | %d |\n", cell(section.Path), section.URLs)
+		}
+		more(len(r.Sections))
+	}
+
+	fmt.Fprintf(&buf, "\n## Oldest lastmods\n\n| Sitemap | URLs | Oldest lastmod | Missing priority | Missing changefreq |\n| --- | ---: | ---: | ---: | ---: |\n")
+	for _, sitemap := range r.Sitemaps[:min(len(r.Sitemaps), reviewRows)] {
+		oldest := "none"
+		if sitemap.LastMods > 0 {
+			oldest = formatAge(sitemap.OldestLastMod)
+		}
+		fmt.Fprintf(&buf, "| %s | %d | %s | %d | %d |\n", cell(sitemap.Name), sitemap.URLs, oldest, sitemap.MissingPriority, sitemap.MissingChangeFreq)
+	}
+	more(len(r.Sitemaps))
+
+	if len(r.DuplicateTitles) > 0 {
+		fmt.Fprintf(&buf, "\n## Duplicate titles\n\n| Title | URLs | Example |\n| --- | ---: | --- |\n")
+		for _, dup := range r.DuplicateTitles[:min(len(r.DuplicateTitles), reviewRows)] {
+			fmt.Fprintf(&buf, "| %s | %d | %s |\n", cell(dup.Title), len(dup.Locs), cell(dup.Locs[0]))
+		}
+		more(len(r.DuplicateTitles))
+	}
+	return buf.Bytes()
+}
+
+// reviewTemplate renders a Review as a standalone HTML page.
+var reviewTemplate = template.Must(template.New("review").Funcs(template.FuncMap{
+	"age":  formatAge,
+	"rows": func(n int) int { return min(n, reviewRows) },
+	"more": func(n int) int { return n - reviewRows },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sitemap review</title>
+<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:1em}th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}</style>
+</head>
+<body>
+<h1>Sitemap review</h1>
+<ul>
+<li>URLs: {{.URLs}}</li>
+<li>Missing priority: {{.MissingPriority}}</li>
+<li>Missing changefreq: {{.MissingChangeFreq}}</li>
+</ul>
+{{- if .Sections}}
+<h2>Biggest sections</h2>
+<table>
+<tr><th>Section</th><th>URLs</th></tr>
+{{- range slice .Sections 0 (rows (len .Sections))}}
+<tr><td>{{.Path}}</td><td>{{.URLs}}</td></tr>
+{{- end}}
+</table>
+{{- if gt (more (len .Sections)) 0}}
+<p>and {{more (len .Sections)}} more.</p>
+{{- end}}
+{{- end}}
+<h2>Oldest lastmods</h2>
+<table>
+<tr><th>Sitemap</th><th>URLs</th><th>Oldest lastmod</th><th>Missing priority</th><th>Missing changefreq</th></tr>
+{{- range slice .Sitemaps 0 (rows (len .Sitemaps))}}
+<tr><td>{{.Name}}</td><td>{{.URLs}}</td><td>{{if .LastMods}}{{age .OldestLastMod}}{{else}}none{{end}}</td><td>{{.MissingPriority}}</td><td>{{.MissingChangeFreq}}</td></tr>
+{{- end}}
+</table>
+{{- if gt (more (len .Sitemaps)) 0}}
+<p>and {{more (len .Sitemaps)}} more.</p>
+{{- end}}
+{{- if .DuplicateTitles}}
+<h2>Duplicate titles</h2>
+<table>
+<tr><th>Title</th><th>URLs</th><th>Example</th></tr>
+{{- range slice .DuplicateTitles 0 (rows (len .DuplicateTitles))}}
+<tr><td>{{.Title}}</td><td>{{len .Locs}}</td><td>{{index .Locs 0}}</td></tr>
+{{- end}}
+</table>
+{{- if gt (more (len .DuplicateTitles)) 0}}
+<p>and {{more (len .DuplicateTitles)}} more.</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML renders the review as a standalone HTML page. Each table lists at
+// most 20 rows.
+func (r *Review) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := reviewTemplate.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeReview writes the Review of the last Write to ReviewFile, if set,
+// as HTML if its name ends in .html or .htm and as Markdown otherwise.
+func (s *SitemapOptions) writeReview() error {
+	if s.ReviewFile == "" || s.report == nil {
+		return nil
+	}
+	reviewPath := s.ReviewFile
+	if !filepath.IsAbs(reviewPath) {
+		reviewPath = filepath.Join(s.Dir, reviewPath)
+	}
+	review := NewReview(s.report, s.state)
+	var data []byte
+	switch strings.ToLower(filepath.Ext(reviewPath)) {
+	case ".html", ".htm":
+		var err error
+		if data, err = review.HTML(); err != nil {
+			return fmt.Errorf("failed to render review: %v", err)
+		}
+	default:
+		data = review.Markdown()
+	}
+	if err := writeFileAtomic(reviewPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write review file: %v", err)
+	}
+	return nil
+}
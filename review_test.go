@@ -0,0 +1,59 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReview(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.ReviewFile = "review.md"
+	sm.Now = func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/blog/a", LastMod: "2024-05-01", Priority: "0.5", Meta: map[string]string{"title": "Post"}},
+		{Loc: "/blog/b", LastMod: "2024-05-31", Meta: map[string]string{"title": "Post"}},
+		{Loc: "/blog/c", LastMod: "2023-06-02", ChangeFreq: "daily"},
+		{Loc: "/about", LastMod: "2024-05-31"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	review := NewReview(sm.Report(), sm.State())
+	if review.URLs != 4 || review.MissingPriority != 3 || review.MissingChangeFreq != 3 {
+		t.Fatalf("Unexpected totals: %+v", review)
+	}
+	if len(review.Sections) != 2 || review.Sections[0] != (ReviewSection{Path: "/blog/", URLs: 3}) {
+		t.Fatalf("Unexpected sections: %+v", review.Sections)
+	}
+	if len(review.Sitemaps) != 2 || review.Sitemaps[0].Name != "sitemap_2.xml" || review.Sitemaps[0].OldestLastMod < 364*24*time.Hour {
+		t.Fatalf("Expected the sitemap with the oldest lastmod first, got %+v", review.Sitemaps)
+	}
+	if len(review.DuplicateTitles) != 1 || review.DuplicateTitles[0].Title != "Post" || len(review.DuplicateTitles[0].Locs) != 2 {
+		t.Fatalf("Unexpected duplicate titles: %+v", review.DuplicateTitles)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "review.md"))
+	if err != nil {
+		t.Fatalf("Error reading review file: %v", err)
+	}
+	for _, want := range []string{"- URLs: 4", "| /blog/ | 3 |", "| sitemap_2.xml | 2 | 365 days | 2 | 1 |", "| Post | 2 | https://www.example.com/blog/a |"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("Expected %q in the review, got:\n%s", want, data)
+		}
+	}
+
+	review.DuplicateTitles[0].Title = "<script>"
+	page, err := review.HTML()
+	if err != nil {
+		t.Fatalf("Error rendering review: %v", err)
+	}
+	if !strings.Contains(string(page), "<td>&lt;script&gt;</td>") || !strings.Contains(string(page), "<td>/blog/</td><td>3</td>") {
+		t.Fatalf("Unexpected HTML review:\n%s", page)
+	}
+}
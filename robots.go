@@ -0,0 +1,211 @@
+package sitemap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots holds the robots.txt rules that apply to one user agent.
+type Robots struct {
+	rules      []robotsRule
+	CrawlDelay time.Duration // Crawl-delay of the matched group, if any
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsGroup is a set of rules shared by consecutive User-agent lines.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// ParseRobots parses a robots.txt file and returns the rules applying to
+// userAgent. The most specific matching User-agent group is used, falling
+// back to the '*' group; with no matching group everything is allowed.
+func ParseRobots(r io.Reader, userAgent string) (*Robots, error) {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share the rules that follow
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if value == "" {
+				// An empty Disallow allows everything
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read robots.txt: %v", err)
+	}
+
+	robots := &Robots{}
+	token := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+	best := -1
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			specificity := -1
+			if agent == "*" {
+				specificity = 0
+			} else if agent != "" && strings.HasPrefix(token, agent) {
+				specificity = len(agent)
+			}
+			if specificity < 0 {
+				continue
+			}
+			if specificity > best {
+				best = specificity
+				robots.rules = nil
+				robots.CrawlDelay = 0
+			}
+			if specificity == best {
+				// Groups for the same agent are merged
+				robots.rules = append(robots.rules, group.rules...)
+				if group.crawlDelay > 0 {
+					robots.CrawlDelay = group.crawlDelay
+				}
+			}
+		}
+	}
+	return robots, nil
+}
+
+// FetchRobots downloads and parses BaseURL's /robots.txt for userAgent using
+// the shared HTTP client. A missing robots.txt allows everything.
+func (s *SitemapOptions) FetchRobots(ctx context.Context, userAgent string) (*Robots, error) {
+	base, err := parseBaseURL(s.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	robotsURL := base.ResolveReference(&url.URL{Path: "/robots.txt"}).String()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", robotsURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return ParseRobots(resp.Body, userAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &Robots{}, nil
+	default:
+		return nil, fmt.Errorf("failed to fetch %s: %s", robotsURL, resp.Status)
+	}
+}
+
+// Allowed reports whether the rules allow crawling loc.
+func (r *Robots) Allowed(loc string) bool {
+	_, allowed := r.match(loc)
+	return allowed
+}
+
+// match returns the pattern of the rule deciding loc and whether it allows
+// it. The longest matching pattern wins and Allow wins ties.
+func (r *Robots) match(loc string) (string, bool) {
+	target := "/"
+	if u, err := url.Parse(loc); err == nil {
+		target = u.EscapedPath()
+		if target == "" {
+			target = "/"
+		}
+		if u.RawQuery != "" {
+			target += "?" + u.RawQuery
+		}
+	}
+
+	decided := ""
+	allowed := true
+	length := -1
+	for _, rule := range r.rules {
+		if !robotsPatternMatch(rule.pattern, target) {
+			continue
+		}
+		if len(rule.pattern) > length || (len(rule.pattern) == length && rule.allow) {
+			length = len(rule.pattern)
+			allowed = rule.allow
+			decided = rule.pattern
+		}
+	}
+	return decided, allowed
+}
+
+// robotsPatternMatch matches a robots.txt path pattern, where '*' matches
+// any run of characters and a trailing '$' anchors the end of the path.
+func robotsPatternMatch(pattern, target string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(target, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, part := range parts[1:] {
+		i := strings.Index(target[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+	if !anchored {
+		return true
+	}
+	// The last literal part must end the target
+	last := parts[len(parts)-1]
+	if len(parts) == 1 {
+		return pos == len(target)
+	}
+	return strings.HasSuffix(target, last)
+}
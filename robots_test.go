@@ -0,0 +1,99 @@
+package sitemap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `# Example robots.txt
+User-agent: *
+Disallow: /admin/
+Disallow: /*.pdf$
+Allow: /admin/public/
+Crawl-delay: 2
+
+User-agent: Googlebot
+User-agent: Bingbot
+Disallow: /private
+Disallow:
+
+Sitemap: https://www.example.com/sitemap_index.xml
+`
+
+func TestParseRobots(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader(testRobots), "sitemapbot/1.0")
+	if err != nil {
+		t.Fatalf("Error parsing robots.txt: %v", err)
+	}
+	if robots.CrawlDelay != 2*time.Second {
+		t.Fatalf("Expected crawl delay of 2s, got %v", robots.CrawlDelay)
+	}
+	tests := map[string]bool{
+		"https://www.example.com/":                     true,
+		"https://www.example.com/admin/users":          false,
+		"https://www.example.com/admin/public/help":    true,
+		"https://www.example.com/files/report.pdf":     false,
+		"https://www.example.com/files/report.pdf?v=1": true,
+		"https://www.example.com/private":              true,
+	}
+	for loc, expected := range tests {
+		if got := robots.Allowed(loc); got != expected {
+			t.Fatalf("Allowed(%s) = %v, expected %v", loc, got, expected)
+		}
+	}
+
+	// The specific group replaces the '*' group entirely
+	googlebot, err := ParseRobots(strings.NewReader(testRobots), "Googlebot/2.1")
+	if err != nil {
+		t.Fatalf("Error parsing robots.txt: %v", err)
+	}
+	if googlebot.Allowed("https://www.example.com/private/x") || !googlebot.Allowed("https://www.example.com/admin/") {
+		t.Fatalf("Googlebot group not applied")
+	}
+}
+
+func TestRobotsAndExcludeFilters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testRobots))
+	}))
+	defer server.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), server.URL)
+	robots, err := sm.FetchRobots(context.Background(), "sitemapbot")
+	if err != nil {
+		t.Fatalf("Error fetching robots.txt: %v", err)
+	}
+	sm.Robots = robots
+	sm.Exclude = []string{"/preview/*", "regexp:[?&]sessionid="}
+
+	for _, loc := range []string{"/", "/admin/users", "/preview/draft", "/shop?sessionid=1", "/about"} {
+		sm.AddURL(SitemapURL{Loc: loc})
+	}
+	if err := sm.Write(server.URL); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	report := sm.Report()
+	if report.URLs != 2 || len(report.Excluded) != 3 {
+		t.Fatalf("Expected 2 written and 3 excluded URLs, got %+v", report)
+	}
+	expected := []string{"robots.txt: Disallow: /admin/", "exclude: /preview/*", "exclude: regexp:[?&]sessionid="}
+	for i, excluded := range report.Excluded {
+		if excluded.Reason != expected[i] {
+			t.Fatalf("Excluded %s for %q, expected %q", excluded.Loc, excluded.Reason, expected[i])
+		}
+	}
+
+	sm.Exclude = []string{"regexp:("}
+	if err := sm.Write(server.URL); err == nil {
+		t.Fatalf("Expected error for invalid exclude pattern")
+	}
+}
@@ -0,0 +1,60 @@
+package sitemap
+
+// SectionRule sets the priority and changefreq of the URLs matching Pattern
+// that carry none, such as 0.7 weekly for "/blog/*".
+type SectionRule struct {
+	Pattern    string // See NewPattern
+	Priority   string
+	ChangeFreq string
+}
+
+// compiledSection is a SectionRule with its pattern compiled.
+type compiledSection struct {
+	SectionRule
+	pattern *Pattern
+}
+
+// WithSectionRule appends a rule giving the URLs matching pattern the
+// priority and changefreq if they carry none. It returns s for chaining;
+// invalid patterns are reported by Write.
+func (s *SitemapOptions) WithSectionRule(pattern string, priority float64, changeFreq string) *SitemapOptions {
+	s.SectionRules = append(s.SectionRules, SectionRule{
+		Pattern:    pattern,
+		Priority:   formatPriority(priority),
+		ChangeFreq: changeFreq,
+	})
+	return s
+}
+
+// sectionRules compiles SectionRules.
+func (s *SitemapOptions) sectionRules() ([]compiledSection, error) {
+	rules := make([]compiledSection, 0, len(s.SectionRules))
+	for _, rule := range s.SectionRules {
+		p, err := NewPattern(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, compiledSection{SectionRule: rule, pattern: p})
+	}
+	return rules, nil
+}
+
+// applySections fills the empty priority and changefreq of u from the first
+// rule matching its loc.
+func applySections(u *SitemapURL, rules []compiledSection) {
+	if u.Priority != "" && u.ChangeFreq != "" {
+		return
+	}
+	for _, rule := range rules {
+		if !rule.pattern.Match(u.Loc) {
+			continue
+		}
+		if u.Priority == "" {
+			u.Priority = rule.Priority
+		}
+		if u.ChangeFreq == "" {
+			u.ChangeFreq = rule.ChangeFreq
+		}
+		return
+	}
+}
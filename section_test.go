@@ -0,0 +1,42 @@
+package sitemap
+
+import (
+	"testing"
+)
+
+func TestSectionRules(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com").
+		WithSectionRule("/", 1.0, "daily").
+		WithSectionRule("/blog/*", 0.7, "weekly").
+		WithSectionRule("/archive/*", 0.3, "yearly").
+		WithDefaultChangeFreq("monthly")
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/"},
+		{Loc: "/blog/post"},
+		{Loc: "/blog/pinned", Priority: "0.9"},
+		{Loc: "/archive/2010"},
+		{Loc: "/about"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	expected := map[string][2]string{
+		"https://www.example.com/":             {"1", "daily"},
+		"https://www.example.com/blog/post":    {"0.7", "weekly"},
+		"https://www.example.com/blog/pinned":  {"0.9", "weekly"},
+		"https://www.example.com/archive/2010": {"0.3", "yearly"},
+		"https://www.example.com/about":        {"", "monthly"},
+	}
+	for _, u := range sm.URLs {
+		if got := [2]string{u.Priority, u.ChangeFreq}; got != expected[u.Loc] {
+			t.Fatalf("Expected %s to get %v, got %v", u.Loc, expected[u.Loc], got)
+		}
+	}
+
+	sm.SectionRules = []SectionRule{{Pattern: "regexp:("}}
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatalf("Expected an error for an invalid section pattern")
+	}
+}
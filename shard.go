@@ -0,0 +1,394 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShardStrategy selects how URLs are assigned to sitemap files when they do
+// not fit in a single sitemap.
+type ShardStrategy int
+
+const (
+	// ShardSequential fills sitemap files in insertion order.
+	ShardSequential ShardStrategy = iota
+	// ShardByHash assigns each URL to a file by a hash of its loc, so adding
+	// or removing a few URLs leaves the contents of other files unchanged.
+	ShardByHash
+	// ShardByRecency fills sitemap files newest lastmod first, URLs without
+	// a lastmod last, so crawlers reading the index in order discover fresh
+	// content first. With FreshWindow, URLs modified within it get files of
+	// their own, sitemap_fresh_N.xml, ahead of all others.
+	ShardByRecency
+	// ShardByDate gives each day its own files, named
+	// sitemap-2006-01-02.xml, or sitemap-<group>-2006-01-02.xml for a group,
+	// with -2, -3 and so on appended when a day holds more than MaxURLs,
+	// the layout of large news archives and forums. Days are listed newest
+	// first, and the files are always indexed; ShardNameTemplate only names
+	// the numbered files of URLs without a date. See ShardDate.
+	ShardByDate
+)
+
+// freshGroup names the files of URLs within FreshWindow.
+const freshGroup = "fresh"
+
+// miscGroup names the files of groups inlined under MinGroupURLs.
+const miscGroup = "misc"
+
+// shard is one sitemap file of an index.
+type shard struct {
+	name  string
+	group string // sanitized group of the URLs, if any
+	urls  []SitemapURL
+}
+
+// writeShards writes the sitemap files of shards to ShardDir in order,
+// calling done after each. Up to EncodeWorkers files are encoded at once.
+func (s *SitemapOptions) writeShards(shards []shard, done func(shard shard) error) error {
+	workers := max(s.EncodeWorkers, 1)
+	for start := 0; start < len(shards); start += workers {
+		batch := shards[start:min(start+workers, len(shards))]
+		files := make([]encodedFile, len(batch))
+		errs := make([]error, len(batch))
+		if len(batch) == 1 {
+			files[0], errs[0] = s.encodeSitemapFile(batch[0].name, batch[0].urls)
+		} else {
+			var wg sync.WaitGroup
+			for i, shard := range batch {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					files[i], errs[i] = s.encodeSitemapFile(shard.name, shard.urls)
+				}()
+			}
+			wg.Wait()
+		}
+		for i, shard := range batch {
+			if errs[i] != nil {
+				return errs[i]
+			}
+			if err := s.storeSitemapFile(filepath.Join(s.shardDir(), shard.name), files[i]); err != nil {
+				return err
+			}
+			if err := done(shard); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shards splits urls into sitemap files according to ShardStrategy. URLs
+// with a Group get files of their own named sitemap_<group>_N.xml, unless
+// ShardNameTemplate names them; groups follow the ungrouped files in order
+// of first appearance, or alphabetically if SortGroups is set. Files within
+// a group are numbered in ascending order, and GroupLimits may give each
+// group its own limits.
+func (s *SitemapOptions) shards(urls []SitemapURL) []shard {
+	var shards []shard
+	if s.ShardStrategy == ShardByRecency {
+		var fresh []SitemapURL
+		fresh, urls = s.recencyOrder(urls)
+		shards = s.sequentialShards(freshGroup, 1, s.groupLimit(freshGroup), fresh)
+	}
+	for _, group := range s.groupURLs(urls) {
+		limit := s.groupLimit(group.name)
+		switch s.ShardStrategy {
+		case ShardByHash:
+			shards = append(shards, s.hashShards(group.name, limit.MaxURLs, group.urls)...)
+		case ShardByDate:
+			shards = append(shards, s.dateShards(group.name, limit, group.urls)...)
+		default:
+			// Number ungrouped files after those flushed over MemoryLimit
+			first := 1
+			if group.name == "" && s.flush != nil {
+				first += len(s.flush.shards)
+			}
+			shards = append(shards, s.sequentialShards(group.name, first, limit, group.urls)...)
+		}
+	}
+	return shards
+}
+
+// GroupLimit overrides the limits of the sitemap files of one group.
+type GroupLimit struct {
+	MaxURLs int // URLs per file; MaxURLs if zero
+	// MaxFileSize, if set, also starts a new file before the encoded
+	// entries would exceed this many bytes. It is only applied with
+	// ShardSequential.
+	MaxFileSize int
+}
+
+// groupLimit returns the limits for the files of the sanitized group name.
+func (s *SitemapOptions) groupLimit(name string) GroupLimit {
+	var limit GroupLimit
+	if name != "" {
+		for group, l := range s.GroupLimits {
+			if groupFileName(group) == name {
+				limit = l
+				break
+			}
+		}
+	}
+	if limit.MaxURLs <= 0 {
+		limit.MaxURLs = s.MaxURLs
+	}
+	return limit
+}
+
+// urlGroup holds the URLs of one group.
+type urlGroup struct {
+	name string
+	urls []SitemapURL
+}
+
+// groupURLs partitions urls by their sanitized Group, ungrouped URLs first.
+// Groups of fewer than MinGroupURLs URLs are merged into one misc group,
+// last, unless their files are served from a SitemapBaseURL of their own.
+func (s *SitemapOptions) groupURLs(urls []SitemapURL) []urlGroup {
+	index := make(map[string]int)
+	groups := []urlGroup{{}}
+	for _, u := range urls {
+		name := groupFileName(u.Group)
+		i, ok := index[name]
+		if !ok && name != "" {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, urlGroup{name: name})
+		}
+		groups[i].urls = append(groups[i].urls, u)
+	}
+	if s.SortGroups {
+		named := groups[1:]
+		sort.Slice(named, func(a, b int) bool { return named[a].name < named[b].name })
+	}
+	if s.MinGroupURLs > 0 {
+		kept := groups[:1]
+		misc := urlGroup{name: miscGroup}
+		for _, group := range groups[1:] {
+			if len(group.urls) < s.MinGroupURLs && s.groupBase(group.name).SitemapBaseURL == "" {
+				misc.urls = append(misc.urls, group.urls...)
+				continue
+			}
+			kept = append(kept, group)
+		}
+		groups = kept
+		if len(misc.urls) > 0 {
+			groups = append(groups, misc)
+		}
+	}
+	if len(groups[0].urls) == 0 {
+		groups = groups[1:]
+	}
+	return groups
+}
+
+// reservedGroups would produce file names used by other sitemaps.
+var reservedGroups = map[string]bool{"news": true, "recent": true, "index": true, freshGroup: true, miscGroup: true}
+
+// checkGroup returns an error if group is reserved.
+func checkGroup(group string) error {
+	if name := groupFileName(group); reservedGroups[strings.ToLower(name)] {
+		return fmt.Errorf("group name '%s' is reserved", group)
+	}
+	return nil
+}
+
+// hasGroups reports whether any of urls has a Group.
+func hasGroups(urls []SitemapURL) bool {
+	for _, u := range urls {
+		if groupFileName(u.Group) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// groupFileName makes a group name safe for use in a file name, replacing
+// anything but ASCII letters, digits, '-' and '_' with '-'.
+func groupFileName(group string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(group))
+}
+
+func (s *SitemapOptions) sequentialShards(group string, first int, limit GroupLimit, urls []SitemapURL) []shard {
+	var shards []shard
+	start, size := 0, 0
+	overhead := s.shardOverhead()
+	for i, u := range urls {
+		entrySize := 0
+		if limit.MaxFileSize > 0 {
+			entrySize = s.entrySize(u)
+		}
+		full := i-start == limit.MaxURLs ||
+			(limit.MaxFileSize > 0 && i > start && overhead+size+entrySize > limit.MaxFileSize)
+		if full {
+			shards = append(shards, shard{
+				name:  s.shardName(group, first+len(shards)),
+				group: group,
+				urls:  urls[start:i],
+			})
+			start, size = i, 0
+		}
+		size += entrySize
+	}
+	if start < len(urls) {
+		shards = append(shards, shard{
+			name:  s.shardName(group, first+len(shards)),
+			group: group,
+			urls:  urls[start:],
+		})
+	}
+	return shards
+}
+
+// shardOverhead estimates the bytes of a sitemap file besides its entries:
+// the header and the urlset element with every extension namespace.
+func (s *SitemapOptions) shardOverhead() int {
+	return s.fileHeader(s.MaxURLs).Len() + 512
+}
+
+// entrySize returns the encoded size of u within a sitemap file, including
+// the indentation of its lines.
+func (s *SitemapOptions) entrySize(u SitemapURL) int {
+	data, err := s.marshalXML(u)
+	if err != nil {
+		return 0
+	}
+	lines := 1 + bytes.Count(data, []byte("\n"))
+	return len(data) + lines*(len(s.lineBreak())+len(s.indent()))
+}
+
+// hashShards buckets URLs by a hash of their loc. The bucket count is a
+// power of two, so growing the set splits buckets instead of reshuffling
+// them, and it is doubled until no bucket exceeds MaxURLs. Empty buckets
+// produce no file.
+func (s *SitemapOptions) hashShards(group string, maxURLs int, urls []SitemapURL) []shard {
+	bucketCount := 1
+	for bucketCount*maxURLs < len(urls) {
+		bucketCount *= 2
+	}
+
+	for {
+		buckets := make([][]SitemapURL, bucketCount)
+		overflow := false
+		for _, u := range urls {
+			i := locHash(u.Loc) % uint64(bucketCount)
+			buckets[i] = append(buckets[i], u)
+			if len(buckets[i]) > maxURLs {
+				overflow = true
+				break
+			}
+		}
+		if overflow {
+			bucketCount *= 2
+			continue
+		}
+
+		var shards []shard
+		for i, bucket := range buckets {
+			if len(bucket) == 0 {
+				continue
+			}
+			shards = append(shards, shard{
+				name:  s.shardName(group, i+1),
+				group: group,
+				urls:  bucket,
+			})
+		}
+		return shards
+	}
+}
+
+// recencyOrder returns urls sorted newest lastmod first, split into those
+// modified within FreshWindow of the Write and the rest.
+func (s *SitemapOptions) recencyOrder(urls []SitemapURL) (fresh, rest []SitemapURL) {
+	times := make([]time.Time, len(urls))
+	order := make([]int, len(urls))
+	for i, u := range urls {
+		order[i] = i
+		if u.LastMod != "" {
+			if t, err := s.parseLastMod(u.LastMod); err == nil {
+				times[i] = t
+			}
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return times[order[a]].After(times[order[b]]) })
+
+	cutoff := s.generated.Add(-s.FreshWindow)
+	for _, i := range order {
+		if s.FreshWindow > 0 && !times[i].IsZero() && !times[i].Before(cutoff) {
+			fresh = append(fresh, urls[i])
+		} else {
+			rest = append(rest, urls[i])
+		}
+	}
+	return fresh, rest
+}
+
+// dateShards gives the URLs of each day their own files, newest day first,
+// followed by the numbered files of URLs without a date.
+func (s *SitemapOptions) dateShards(group string, limit GroupLimit, urls []SitemapURL) []shard {
+	byDay := make(map[string][]SitemapURL)
+	var days []string
+	var undated []SitemapURL
+	for _, u := range urls {
+		date := s.shardDate(u)
+		if date.IsZero() {
+			undated = append(undated, u)
+			continue
+		}
+		day := date.In(s.location()).Format(time.DateOnly)
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], u)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+
+	var shards []shard
+	for _, day := range days {
+		prefix := "sitemap-" + day
+		if group != "" {
+			prefix = "sitemap-" + group + "-" + day
+		}
+		for i, sh := range s.sequentialShards(group, 1, limit, byDay[day]) {
+			sh.name = prefix + sitemapExt
+			if i > 0 {
+				sh.name = fmt.Sprintf("%s-%d%s", prefix, i+1, sitemapExt)
+			}
+			shards = append(shards, sh)
+		}
+	}
+	return append(shards, s.sequentialShards(group, 1, limit, undated)...)
+}
+
+// shardDate returns the date u is filed under with ShardByDate.
+func (s *SitemapOptions) shardDate(u SitemapURL) time.Time {
+	if s.ShardDate != nil {
+		return s.ShardDate(u)
+	}
+	t, err := s.parseLastMod(strings.TrimSpace(u.LastMod))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func locHash(loc string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(loc))
+	return h.Sum64()
+}
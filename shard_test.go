@@ -0,0 +1,258 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashShardsAreStable(t *testing.T) {
+	build := func(count int, skip int) map[string]string {
+		sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+		sm.MaxURLs = 100
+		sm.ShardStrategy = ShardByHash
+		for i := 0; i < count; i++ {
+			if i == skip {
+				continue
+			}
+			sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+		}
+		assignment := make(map[string]string)
+		for _, shard := range sm.shards(sm.URLs) {
+			if len(shard.urls) > sm.MaxURLs {
+				t.Fatalf("Shard %s holds %d URLs, more than MaxURLs", shard.name, len(shard.urls))
+			}
+			for _, u := range shard.urls {
+				assignment[u.Loc] = shard.name
+			}
+		}
+		return assignment
+	}
+
+	before := build(300, -1)
+	after := build(300, 7)
+	if len(before) != 300 || len(after) != 299 {
+		t.Fatalf("Expected every URL to be assigned, got %d and %d", len(before), len(after))
+	}
+	for loc, name := range after {
+		if before[loc] != name {
+			t.Fatalf("Removing one URL moved %s from %s to %s", loc, before[loc], name)
+		}
+	}
+}
+
+func TestIndexOrderAndGroups(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	for i := 0; i < 10; i++ {
+		sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	sm.AddURL(SitemapURL{Loc: "/shop/1", Group: "shop"})
+	sm.AddURL(SitemapURL{Loc: "/blog/1", Group: "blog"})
+	sm.AddURL(SitemapURL{Loc: "/shop/2", Group: "shop"})
+
+	locs := func() []string {
+		data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if loc, ok := strings.CutPrefix(strings.TrimSpace(line), "<loc>https://www.example.com/"); ok {
+				names = append(names, strings.TrimSuffix(loc, ".xml</loc>"))
+			}
+		}
+		return names
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := "sitemap_1 sitemap_2 sitemap_3 sitemap_4 sitemap_5 sitemap_6 sitemap_7 sitemap_8 sitemap_9 sitemap_10 " +
+		"sitemap_shop_1 sitemap_shop_2 sitemap_blog_1"
+	if got := strings.Join(locs(), " "); got != want {
+		t.Fatalf("index order:\n got %s\nwant %s", got, want)
+	}
+
+	sm.SortGroups = true
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := strings.Join(locs()[10:], " "); got != "sitemap_blog_1 sitemap_shop_1 sitemap_shop_2" {
+		t.Fatalf("sorted groups: %s", got)
+	}
+
+	sm.AddURL(SitemapURL{Loc: "/x", Group: "news"})
+	if err := sm.Write("https://www.example.com/"); err == nil {
+		t.Fatal("expected an error for a reserved group name")
+	}
+}
+
+func TestGroupLimits(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.GroupLimits = map[string]GroupLimit{
+		"products": {MaxURLs: 2},
+		"docs":     {MaxFileSize: 1200},
+	}
+	for i := 0; i < 5; i++ {
+		sm.AddURL(SitemapURL{Loc: "/products/" + strconv.Itoa(i), Group: "products"})
+		sm.AddURL(SitemapURL{Loc: "/docs/" + strings.Repeat("x", 200) + strconv.Itoa(i), Group: "docs"})
+		sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	for _, name := range []string{"sitemap_1.xml", "sitemap_products_1.xml", "sitemap_products_2.xml", "sitemap_products_3.xml", "sitemap_docs_1.xml", "sitemap_docs_2.xml"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s: %v", name, err)
+		}
+		if strings.HasPrefix(name, "sitemap_docs_") && len(data) > 1200 {
+			t.Fatalf("%s is %d bytes, over the group's limit", name, len(data))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap_2.xml")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the ungrouped URLs in a single file")
+	}
+}
+
+func TestShardByRecency(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.ShardStrategy = ShardByRecency
+	sm.LastModStrategy = LastModContent
+	sm.Now = func() time.Time { return time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC) }
+	sm.AddURL(SitemapURL{Loc: "/old", LastMod: "2023-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/none"})
+	sm.AddURL(SitemapURL{Loc: "/new", LastMod: "2024-06-10"})
+	sm.AddURL(SitemapURL{Loc: "/week", LastMod: "2024-06-03"})
+	sm.AddURL(SitemapURL{Loc: "/yesterday", LastMod: "2024-06-09"})
+
+	order := func() string {
+		var locs []string
+		for _, shard := range sm.shards(sm.URLs) {
+			var names []string
+			for _, u := range shard.urls {
+				names = append(names, strings.TrimPrefix(u.Loc, "https://www.example.com"))
+			}
+			locs = append(locs, shard.name+"="+strings.Join(names, ","))
+		}
+		return strings.Join(locs, " ")
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	want := "sitemap_1.xml=/new,/yesterday sitemap_2.xml=/week,/old sitemap_3.xml=/none"
+	if got := order(); got != want {
+		t.Fatalf("shards:\n got %s\nwant %s", got, want)
+	}
+
+	sm.FreshWindow = 48 * time.Hour
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	want = "sitemap_fresh_1.xml=/new,/yesterday sitemap_1.xml=/week,/old sitemap_2.xml=/none"
+	if got := order(); got != want {
+		t.Fatalf("shards with FreshWindow:\n got %s\nwant %s", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh, rest := strings.Index(string(data), "sitemap_fresh_1.xml"), strings.Index(string(data), "sitemap_1.xml"); fresh < 0 || fresh > rest {
+		t.Fatalf("Expected the fresh sitemap first in the index:\n%s", data)
+	}
+}
+
+func TestMinGroupURLs(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MinGroupURLs = 3
+	for i := 0; i < 3; i++ {
+		sm.AddURL(SitemapURL{Loc: "/products/" + strconv.Itoa(i), Group: "products"})
+	}
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/about"},
+		{Loc: "/legal/terms", Group: "legal"},
+		{Loc: "/press/1", Group: "press"},
+		{Loc: "/press/2", Group: "press"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	want := []string{"sitemap_1.xml", "sitemap_products_1.xml", "sitemap_misc_1.xml"}
+	if got := regexp.MustCompile(`sitemap_[a-z0-9_]+\.xml`).FindAllString(string(index), -1); !slices.Equal(got, want) {
+		t.Fatalf("Expected index entries %v, got %v", want, got)
+	}
+	misc, err := os.ReadFile(filepath.Join(dir, "sitemap_misc_1.xml"))
+	if err != nil {
+		t.Fatalf("Error reading misc sitemap: %v", err)
+	}
+	for _, loc := range []string{"/legal/terms", "/press/1", "/press/2"} {
+		if !strings.Contains(string(misc), "https://www.example.com"+loc+"<") {
+			t.Fatalf("Expected %s in the misc sitemap:\n%s", loc, misc)
+		}
+	}
+
+	if err := checkGroup("misc"); err == nil {
+		t.Fatal("Expected the misc group to be reserved")
+	}
+}
+
+func TestShardByDate(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.ShardStrategy = ShardByDate
+	sm.MaxURLs = 2
+	sm.LastModStrategy = LastModContent
+	sm.ShardDate = func(u SitemapURL) time.Time {
+		if published, err := time.Parse(time.DateOnly, u.Meta["published"]); err == nil {
+			return published
+		}
+		t, _ := time.Parse(time.DateOnly, u.LastMod[:min(len(u.LastMod), 10)])
+		return t
+	}
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/a", LastMod: "2024-06-01T10:00:00Z"},
+		{Loc: "/b", LastMod: "2024-06-02"},
+		{Loc: "/c", LastMod: "2024-06-01"},
+		{Loc: "/d", LastMod: "2024-06-01"},
+		{Loc: "/e"},
+		{Loc: "/f", LastMod: "2024-06-03", Meta: map[string]string{"published": "2024-06-02"}},
+		{Loc: "/g", LastMod: "2024-06-02", Group: "forum"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	want := []string{"sitemap-2024-06-02.xml", "sitemap-2024-06-01.xml", "sitemap-2024-06-01-2.xml", "sitemap_1.xml", "sitemap-forum-2024-06-02.xml"}
+	if got := regexp.MustCompile(`sitemap[-_][a-z0-9_-]+\.xml`).FindAllString(string(data), -1); !slices.Equal(got, want) {
+		t.Fatalf("Expected index entries %v, got %v", want, got)
+	}
+	day, err := os.ReadFile(filepath.Join(dir, "sitemap-2024-06-02.xml"))
+	if err != nil {
+		t.Fatalf("Error reading day sitemap: %v", err)
+	}
+	if !strings.Contains(string(day), "/b<") || !strings.Contains(string(day), "/f<") {
+		t.Fatalf("Expected /b and the article published that day in its file:\n%s", day)
+	}
+}
@@ -0,0 +1,96 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ShardName is the data ShardNameTemplate is executed with.
+type ShardName struct {
+	Date  string    // Day the Write started, as 2006-01-02
+	Time  time.Time // Time the Write started, for other layouts
+	Group string    // Sanitized Group of the file's URLs, empty if ungrouped
+	Index int       // Number of the file within its group, from 1
+}
+
+// prepareShardNames parses ShardNameTemplate, if set, and fixes the time
+// the files of the running Write are named by, which for URLs flushed over
+// MemoryLimit is when the first flush started. It fails if the template is
+// invalid, does not produce a usable file name or names different files
+// alike.
+func (s *SitemapOptions) prepareShardNames() error {
+	if s.flush == nil {
+		s.generated = s.now()
+	}
+	s.shardTemplate = nil
+	if s.ShardNameTemplate == "" {
+		return nil
+	}
+	tmpl, err := template.New("shard").Option("missingkey=error").Parse(s.ShardNameTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid ShardNameTemplate: %v", err)
+	}
+	var names [2]string
+	for i := range names {
+		if names[i], err = executeShardName(tmpl, s.shardNameData("", i+1)); err != nil {
+			return fmt.Errorf("invalid ShardNameTemplate: %v", err)
+		}
+	}
+	if names[0] == names[1] {
+		return fmt.Errorf("invalid ShardNameTemplate: every file is named %s; use {{.Index}}", names[0])
+	}
+	s.shardTemplate = tmpl
+	return nil
+}
+
+// shardName names the n-th sitemap file of the sanitized group name.
+func (s *SitemapOptions) shardName(group string, n int) string {
+	if s.shardTemplate != nil {
+		// The template was checked by prepareShardNames
+		if name, err := executeShardName(s.shardTemplate, s.shardNameData(group, n)); err == nil {
+			return name
+		}
+	}
+	if group != "" {
+		return fmt.Sprintf("sitemap_%s_%d%s", group, n, sitemapExt)
+	}
+	return fmt.Sprintf("sitemap_%d%s", n, sitemapExt)
+}
+
+func (s *SitemapOptions) shardNameData(group string, n int) ShardName {
+	generated := s.generated
+	if generated.IsZero() {
+		generated = s.now()
+	}
+	return ShardName{
+		Date:  generated.Format(time.DateOnly),
+		Time:  generated,
+		Group: group,
+		Index: n,
+	}
+}
+
+// executeShardName runs tmpl and checks the result is a plain file name
+// that does not collide with the index, news or recent sitemaps.
+func executeShardName(tmpl *template.Template, data ShardName) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(buf.String())
+	if !strings.HasSuffix(name, sitemapExt) {
+		name += sitemapExt
+	}
+	base := strings.TrimSuffix(name, sitemapExt)
+	switch {
+	case base == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("%q is not a file name", name)
+	case name == "sitemap_index.xml" || isNestedIndex(name) || name == recentSitemapName ||
+		strings.HasPrefix(name, "sitemap_news"):
+		return "", fmt.Errorf("%q is reserved", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,67 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShardNameTemplate(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.Now = func() time.Time { return day }
+	sm.ShardNameTemplate = "sitemap-{{.Date}}{{with .Group}}-{{.}}{{end}}-{{.Index}}"
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddURL(SitemapURL{Loc: "/shop/1", Group: "products"})
+	sm.AddURL(SitemapURL{Loc: "/shop/2", Group: "products"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	for _, name := range []string{"sitemap-2024-06-01-1.xml", "sitemap-2024-06-01-products-1.xml", "sitemap-2024-06-01-products-2.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to be written: %v", name, err)
+		}
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "https://www.example.com/sitemap-2024-06-01-products-2.xml") {
+		t.Fatalf("Expected the index to reference the templated names, got %s", index)
+	}
+
+	// The next day's set is written beside the previous one
+	day = day.AddDate(0, 0, 1)
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	for _, name := range []string{"sitemap-2024-06-01-1.xml", "sitemap-2024-06-02-1.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+	}
+}
+
+func TestShardNameTemplateInvalid(t *testing.T) {
+	for _, tmpl := range []string{
+		"sitemap-{{.Date",
+		"sitemap-{{.Missing}}",
+		"sitemap-{{.Date}}",
+		"shards/{{.Index}}",
+		"sitemap_index",
+		"sitemap_news_{{.Index}}",
+	} {
+		sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+		sm.MaxURLs = 1
+		sm.ShardNameTemplate = tmpl
+		sm.AddURL(SitemapURL{Loc: "/a"})
+		sm.AddURL(SitemapURL{Loc: "/b"})
+		if err := sm.Write("https://www.example.com/"); err == nil {
+			t.Fatalf("Expected ShardNameTemplate %q to be rejected", tmpl)
+		}
+	}
+}
@@ -2,12 +2,19 @@ package sitemap
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/lestrrat-go/libxml2"
@@ -16,14 +23,22 @@ import (
 
 const (
 	sitemapExt = ".xml"
-	// Reduced max URLs by 1/3 for safety
+	// Default MaxURLs, a third below the protocol's 50,000 so that files of
+	// URLs with long locs or extensions stay under MaxFileSize
 	maxURLsPerSitemap = 33333
+	// Sitemap of URLs changed since the previous run
+	recentSitemapName = "sitemap_recent.xml"
+	// Priority assumed by crawlers when none is given
+	defaultPriority = 0.5
 	// Sitemap XSD schema for validation
 	sitemapXSD = `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
            xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
            targetNamespace="http://www.sitemaps.org/schemas/sitemap/0.9"
            elementFormDefault="qualified">
+  <xs:simpleType name="tLastmod">
+    <xs:union memberTypes="xs:date xs:dateTime" />
+  </xs:simpleType>
   <xs:element name="urlset">
     <xs:complexType>
       <xs:sequence>
@@ -31,7 +46,7 @@ const (
           <xs:complexType>
             <xs:sequence>
               <xs:element name="loc" type="xs:anyURI" />
-              <xs:element name="lastmod" type="xs:date" minOccurs="0" />
+              <xs:element name="lastmod" type="tLastmod" minOccurs="0" />
               <xs:element name="changefreq" minOccurs="0">
                 <xs:simpleType>
                   <xs:restriction base="xs:string">
@@ -53,6 +68,7 @@ const (
                   </xs:restriction>
                 </xs:simpleType>
               </xs:element>
+              <xs:any namespace="##other" processContents="lax" minOccurs="0" maxOccurs="unbounded" />
             </xs:sequence>
           </xs:complexType>
         </xs:element>
@@ -67,6 +83,9 @@ const (
            xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
            targetNamespace="http://www.sitemaps.org/schemas/sitemap/0.9"
            elementFormDefault="qualified">
+  <xs:simpleType name="tLastmod">
+    <xs:union memberTypes="xs:date xs:dateTime" />
+  </xs:simpleType>
   <xs:element name="sitemapindex">
     <xs:complexType>
       <xs:sequence>
@@ -74,7 +93,7 @@ const (
           <xs:complexType>
             <xs:sequence>
               <xs:element name="loc" type="xs:anyURI" />
-              <xs:element name="lastmod" type="xs:date" minOccurs="0" />
+              <xs:element name="lastmod" type="tLastmod" minOccurs="0" />
             </xs:sequence>
           </xs:complexType>
         </xs:element>
@@ -82,59 +101,87 @@ const (
     </xs:complexType>
   </xs:element>
 </xs:schema>
-`
-	// Stylesheet content
-	sitemapXSL = `<?xml version="1.0" encoding="UTF-8"?>
-<xsl:stylesheet version="2.0"
-    xmlns:xsl="http://www.w3.org/1999/XSL/Transform"
-    xmlns:s="http://www.sitemaps.org/schemas/sitemap/0.9">
-    <xsl:output method="html" encoding="UTF-8" indent="yes"/>
-    <xsl:template match="/">
-        <html>
-        <head>
-            <title>Sitemap</title>
-            <style type="text/css">
-                body { font-family: Arial, sans-serif; }
-                table { border-collapse: collapse; width: 100%; }
-                th, td { text-align: left; padding: 8px; border-bottom: 1px solid #ddd; }
-                tr:hover {background-color: #f5f5f5;}
-            </style>
-        </head>
-        <body>
-            <h1>Sitemap</h1>
-            <table>
-                <tr>
-                    <th>URL</th>
-                    <th>Last Modified</th>
-                </tr>
-                <xsl:for-each select="//s:url | //s:sitemap">
-                    <tr>
-                        <td><a href="{s:loc}"><xsl:value-of select="s:loc"/></a></td>
-                        <td><xsl:value-of select="s:lastmod"/></td>
-                    </tr>
-                </xsl:for-each>
-            </table>
-        </body>
-        </html>
-    </xsl:template>
-</xsl:stylesheet>
 `
 )
 
-// SitemapURL represents a single URL entry in the sitemap.
+// initMu guards lazy creation of SitemapOptions.mu.
+var initMu sync.Mutex
+
+// SitemapURL represents a single URL entry in the sitemap. Its elements are
+// written in the order of the fields, whatever order they were set in: loc,
+// lastmod, changefreq and priority, then the extensions, as the protocol
+// schema requires and strict consumers check. Keep that order when adding
+// fields. Entries with a Marshaler must follow it themselves.
 type SitemapURL struct {
 	XMLName    xml.Name `xml:"url"`
 	Loc        string   `xml:"loc"`
 	LastMod    string   `xml:"lastmod,omitempty"`
 	ChangeFreq string   `xml:"changefreq,omitempty"`
 	Priority   string   `xml:"priority,omitempty"`
+	// Alternates are the localized versions of the page, including itself.
+	Alternates []Alternate `xml:"xhtml:link,omitempty"`
+	// MobileAlternate is the separate mobile version of the page, such as
+	// on an m-dot host, written as an alternate with the MobileMedia query.
+	MobileAlternate string `xml:"-"`
+	// News marks the URL as a news article for Google News.
+	News *News `xml:"news:news,omitempty"`
+	// Images are the images on the page.
+	Images []Image `xml:"image:image,omitempty"`
+	// Videos are the videos on the page.
+	Videos []Video `xml:"video:video,omitempty"`
+	// PageMap is structured data for Google Programmable Search.
+	PageMap *PageMap `xml:"pagemap:PageMap,omitempty"`
+	// Group places the URL in sitemap files of its own, named
+	// sitemap_<group>_N.xml, such as one set per site section. The names
+	// news, recent, index and fresh are reserved.
+	Group string `xml:"-"`
+	// Absolute marks Loc as a full URL, possibly on another allowed host,
+	// that is never joined to BaseURL. A missing scheme defaults to the base
+	// URL's, and the loc is exempt from the host mismatch check.
+	Absolute bool `xml:"-"`
+	// ExpiresAt, if set, omits the URL from writes at or after this time.
+	ExpiresAt time.Time `xml:"-"`
+	// Marshaler, if set, writes the url element of this entry instead of
+	// the default encoding.
+	Marshaler URLMarshaler `xml:"-"`
+	// Meta is caller data that is never written. It is passed to Filter and
+	// hooks such as IndexLastMod and copied to the report and the State.
+	Meta map[string]string `xml:"-"`
+
+	lastModGenerated bool // LastMod was set by the LastModStrategy
 }
 
 // URLSet represents a collection of SitemapURLs.
 type URLSet struct {
-	XMLName xml.Name     `xml:"urlset"`
-	Xmlns   string       `xml:"xmlns,attr"`
-	URLs    []SitemapURL `xml:"url"`
+	XMLName      xml.Name     `xml:"urlset"`
+	Xmlns        string       `xml:"xmlns,attr"`
+	XmlnsXhtml   string       `xml:"xmlns:xhtml,attr,omitempty"`
+	XmlnsNews    string       `xml:"xmlns:news,attr,omitempty"`
+	XmlnsImage   string       `xml:"xmlns:image,attr,omitempty"`
+	XmlnsPageMap string       `xml:"xmlns:pagemap,attr,omitempty"`
+	XmlnsVideo   string       `xml:"xmlns:video,attr,omitempty"`
+	URLs         []SitemapURL `xml:"url"`
+}
+
+// setNamespaces declares the namespaces of the extensions used by the URLs.
+func (us *URLSet) setNamespaces() {
+	for _, u := range us.URLs {
+		if len(u.Alternates) > 0 {
+			us.XmlnsXhtml = xhtmlNamespace
+		}
+		if u.News != nil {
+			us.XmlnsNews = newsNamespace
+		}
+		if len(u.Images) > 0 {
+			us.XmlnsImage = imageNamespace
+		}
+		if u.PageMap != nil {
+			us.XmlnsPageMap = pageMapNamespace
+		}
+		if len(u.Videos) > 0 {
+			us.XmlnsVideo = videoNamespace
+		}
+	}
 }
 
 // Sitemap represents a sitemap file entry in the sitemap index.
@@ -153,46 +200,425 @@ type SitemapIndex struct {
 
 // SitemapOptions holds configuration for generating sitemaps.
 type SitemapOptions struct {
+	// MaxFileSize is the most bytes a sitemap file may hold, at most and
+	// by default 50MB. Write fails with ErrShardTooLarge rather than write
+	// a larger file. MaxURLs is the most URLs a sitemap file holds, 33,333
+	// by default rather than the protocol's 50,000: full files of URLs with
+	// images, videos or alternates would otherwise exceed MaxFileSize and
+	// fail the Write. Set it up to 50,000 for lighter entries. Write fails
+	// with ErrInvalidLimit if either is beyond the protocol's limit.
 	MaxFileSize int
 	MaxURLs     int
 	Dir         string
 	BaseURL     string
 	URLs        []SitemapURL
 	Stylesheet  string // Holds the stylesheet filename
+	// Now returns the current time. It is used wherever the package
+	// substitutes the current date, so tests can produce stable output.
+	Now func() time.Time
+	// LastModFormat selects the precision of emitted lastmod values.
+	LastModFormat LastModFormat
+	// LastModStrategy selects the lastmod of URLs added without a usable
+	// one, see LastModStrategy.
+	LastModStrategy LastModStrategy
+	// GroupLastModStrategies override LastModStrategy for the URL groups
+	// they are keyed by.
+	GroupLastModStrategies map[string]LastModStrategy
+	// Location is the timezone used for generated timestamps (UTC if nil).
+	Location *time.Location
+	// Indent is the string nested elements are indented with, two spaces if
+	// empty.
+	Indent string
+	// Compact writes elements without indentation or line breaks.
+	Compact bool
+	// CRLF ends lines with "\r\n" instead of "\n".
+	CRLF bool
+	// Declaration controls the XML declaration of generated files, by
+	// default <?xml version="1.0" encoding="UTF-8"?>.
+	Declaration Declaration
+	// GeneratorComment adds a comment with the generator version, generation
+	// time and entry count to each file. Leave disabled for byte-stable output.
+	GeneratorComment bool
+	// DefaultChangeFreq and DefaultPriority are written for URLs leaving
+	// those fields empty.
+	DefaultChangeFreq string
+	DefaultPriority   string
+	// SectionRules fill in the priority and changefreq of URLs leaving them
+	// empty, before the defaults apply. The first rule matching a loc wins,
+	// so list more specific patterns first. See WithSectionRule.
+	SectionRules []SectionRule
+	// OmitDefaults drops values equal to the protocol default (priority 0.5)
+	// to shrink output.
+	OmitDefaults bool
+	// Branding customizes the bundled stylesheet.
+	Branding StylesheetBranding
+	// StylesheetTemplate replaces the bundled DefaultStylesheet. It is
+	// rendered as a text/template with Branding as data and an "xml"
+	// escaping function.
+	StylesheetTemplate string
+	// IndexLastMod overrides the lastmod written for a sitemap in the index.
+	// Returning an empty string falls back to the computed value.
+	IndexLastMod func(sitemapName string, urls []SitemapURL) string
+	// HTTPClient is used for all outbound requests (http.DefaultClient if nil).
+	HTTPClient *http.Client
+	// RateLimiter, if set, paces all outbound requests per host.
+	RateLimiter RateLimiter
+	// Concurrency, if set, caps the outbound requests in flight at once
+	// across all networked features, including those of clones, and is the
+	// number of parallel workers of Audit and IndexingAPI.Notify. Requests
+	// are sent one at a time by default and without a cap.
+	Concurrency int
+	// PreviousState is the State of the previous run, used to detect added
+	// and modified URLs.
+	PreviousState *State
+	// RecentSitemap writes URLs added or modified since PreviousState to an
+	// additional sitemap_recent.xml referenced from the index. Changed URLs
+	// beyond MaxURLs are left out of it and listed in Report.Excluded with
+	// the reason "recent sitemap full", though the other files still hold
+	// them.
+	RecentSitemap bool
+	// StateFile, if set, persists the State after each Write and loads it as
+	// PreviousState on the next run. Relative paths are resolved against Dir.
+	StateFile string
+	// ChangesFile, if set, receives the Changes of each Write that has a
+	// PreviousState as JSON, such as changes.json. Relative paths are
+	// resolved against Dir.
+	ChangesFile string
+	// ManifestFile, if set, receives a JSON Manifest describing every
+	// sitemap file and stylesheet after each Write, WriteIndexOnly and
+	// TouchLastMod, such as manifest.json. Relative paths are resolved
+	// against Dir.
+	ManifestFile string
+	// ChecksumFiles writes a <name>.sha256 file, in the format of sha256sum,
+	// next to every sitemap file and stylesheet after each Write,
+	// WriteIndexOnly and TouchLastMod, for VerifyChecksums to check copies.
+	ChecksumFiles bool
+	// MetricsFile, if set, receives metrics of each successful Write in the
+	// Prometheus text format, for the node_exporter textfile collector; its
+	// name must end in .prom. Relative paths are resolved against Dir.
+	MetricsFile string
+	// ReviewFile, if set, receives a Review of each successful Write for SEO
+	// review, as HTML if its name ends in .html and as Markdown otherwise.
+	// Relative paths are resolved against Dir.
+	ReviewFile string
+	// ArchiveDir, if set, receives a copy of the sitemap set and state file
+	// in place before each Write replaces them, in a subdirectory named by
+	// the time of the Write such as archive/20240601T120000Z. Relative paths
+	// are resolved against Dir.
+	ArchiveDir string
+	// ArchiveRetain is the number of archived sets kept in ArchiveDir; older
+	// ones are removed after each Write. All are kept if zero.
+	ArchiveRetain int
+	// Robots, if set, drops URLs disallowed by the site's robots.txt.
+	Robots *Robots
+	// Include, if not empty, drops URLs matching none of these patterns.
+	// See NewPattern for the pattern syntax.
+	Include []string
+	// Exclude drops URLs matching any of these patterns.
+	Exclude []string
+	// Filter, if set, drops URLs for which it returns false.
+	Filter func(u SitemapURL) bool
+	// OnAddURL, if set, is called by AddURL and IncrementalWriter.Upsert
+	// with each URL after its lastmod and loc are normalized. It may modify
+	// the URL, for example to lowercase the path or add Meta, or reject it
+	// with an error. AddURL then drops the URL and the next Write lists it
+	// in Report.Excluded; Upsert returns the error.
+	OnAddURL func(u *SitemapURL) error
+	// Expand, if set, expands the entries added with AddID into their
+	// URLs when Write runs, given the ID and template they were added
+	// with. Its error fails the Write.
+	Expand func(id int64, template string) (SitemapURL, error)
+	// OnWarning, if set, is called with each URL dropped and each value
+	// repaired as soon as it is found, rather than only in the Report of
+	// the finished Write: by AddURL for URLs rejected by OnAddURL or
+	// flushed over MemoryLimit, and by Write for the rest. It may forward
+	// to a channel to log problems of a long run as they happen, but must
+	// not call into s. Warnings of a failed Write are not withdrawn.
+	OnWarning func(w Warning)
+	// SkippedExamples is the number of dropped URLs Skipped keeps as
+	// examples of each reason.
+	SkippedExamples int
+	// RequireRootSitemap fails Write if the sitemap files are not served
+	// from the root of their host. Otherwise URLs outside the directory of
+	// the file listing them, which consumers following the protocol's
+	// scoping rule ignore, are reported as warnings.
+	RequireRootSitemap bool
+	// Strict turns problems that are otherwise repaired or reported as
+	// warnings, such as an unknown changefreq, a priority outside 0.0-1.0 or
+	// a loc on another host, into Write errors.
+	Strict bool
+	// InvalidChars selects how characters invalid in XML, and control
+	// characters in URLs, are handled at write time, such as bytes of a
+	// user-generated slug that would make a sitemap file unparseable.
+	InvalidChars InvalidCharPolicy
+	// StripQueryParams removes these query parameters from locs at write
+	// time. A trailing '*' matches a prefix, as in "utm_*".
+	StripQueryParams []string
+	// QueryAllowlists keep only the listed query parameters on locs matching
+	// their pattern; the first matching allowlist applies.
+	QueryAllowlists []QueryAllowlist
+	// Canonicalize, if set, maps each resolved and query-cleaned loc and
+	// alternate href to its canonical URL, such as a mobile host to the
+	// desktop one, before duplicates are dropped. Relative results are
+	// resolved against BaseURL.
+	Canonicalize func(loc string) string
+	// ValidateHreflang checks that alternate clusters are reciprocal and
+	// complete, recording problems in the report.
+	ValidateHreflang bool
+	// XDefaultLocale, if set, adds an x-default alternate to every hreflang
+	// cluster lacking one, pointing at the alternate of this locale, such
+	// as "en", or else at the first one of its language, such as "en-US".
+	// Clusters without either are left as they are.
+	XDefaultLocale string
+	// NewsOverflow controls what happens when more than 1,000 news articles
+	// are fresh: NewsSplit spreads them over several news sitemaps, NewsError
+	// fails the Write.
+	NewsOverflow NewsOverflow
+	// ImageHosts are the hosts, besides the page's own, that images may be
+	// served from, such as a CDN.
+	ImageHosts []string
+	// ShardStrategy selects how URLs are assigned to sitemap files.
+	ShardStrategy ShardStrategy
+	// FreshWindow, with ShardByRecency, moves URLs modified within this long
+	// before the Write into sitemap_fresh_N.xml files listed first in the
+	// index.
+	FreshWindow time.Duration
+	// ShardDate, with ShardByDate, returns the date a URL is filed under,
+	// such as a publication date kept in Meta; by default its lastmod. URLs
+	// with a zero date go to the numbered files.
+	ShardDate func(u SitemapURL) time.Time
+	// GroupLimits override the file limits for the groups they are keyed by,
+	// such as a lower MaxURLs for a group of heavy entries.
+	GroupLimits map[string]GroupLimit
+	// GroupBaseURLs give the groups they are keyed by a base URL and a
+	// sitemap base URL of their own, such as another host for a blog.
+	GroupBaseURLs map[string]GroupBaseURL
+	// MobileMedia is the media query of MobileAlternate links, by default
+	// "only screen and (max-width: 640px)".
+	MobileMedia string
+	// SortGroups orders the sitemap files of URL groups alphabetically by
+	// group instead of by first appearance.
+	SortGroups bool
+	// MinGroupURLs, if set, writes the URLs of groups with fewer URLs than
+	// this together to sitemap_misc_N.xml files, after all other groups,
+	// instead of giving each a nearly empty file. Groups with a
+	// SitemapBaseURL keep files of their own.
+	MinGroupURLs int
+	// ShardNameTemplate, if set, is a text/template naming the sitemap files
+	// of an index, executed with a ShardName such as
+	// "sitemap-{{.Date}}-{{.Group}}-{{.Index}}". The .xml extension is added
+	// if missing. Files of earlier runs are left in place, so dated names
+	// keep historical sets side by side.
+	ShardNameTemplate string
+	// PublicNames maps the names of sitemap files, such as
+	// sitemap_products_1.xml, to the names the index references them by,
+	// such as the sitemap-products.xml another tool published, so URLs
+	// already registered with search engines keep working after a
+	// migration. Files keep their names on disk; Handler serves them under
+	// both. With Gzip, .gz is appended to either name.
+	PublicNames map[string]string
+	// ShardDir is a subdirectory of Dir receiving the sitemap files of an
+	// index, while the index itself stays in Dir.
+	ShardDir string
+	// ShardBaseURL is the base URL the sitemap files of an index are served
+	// from. Defaults to ShardDir resolved against baseSitemapURL.
+	ShardBaseURL string
+	// Gzip makes Write publish the sitemap files and indexes gzipped only,
+	// as sitemap_index.xml.gz and so on, with the index referencing those
+	// names. The uncompressed files of an earlier run are removed.
+	Gzip bool
+	// KeepPlain, with Gzip, writes every sitemap file and index
+	// uncompressed too, next to its .gz file and from the same encoded XML,
+	// for people browsing the set while crawlers get the .gz files.
+	KeepPlain bool
+	// IndexPlain, with Gzip and KeepPlain, makes the indexes reference the
+	// uncompressed files rather than the .gz ones.
+	IndexPlain bool
+	// NewGzipWriter, if set, creates the compressors used with Gzip, such
+	// as a faster implementation than compress/gzip's default.
+	NewGzipWriter func(w io.Writer) io.WriteCloser
+	// EncodeWorkers is the number of sitemap files encoded, and compressed
+	// with Gzip, at once (1 if zero). With more than one, hooks called while
+	// encoding, such as URL Marshalers and Now, must be safe for concurrent
+	// use. Files are still written in order.
+	EncodeWorkers int
+	// VerifyDir, if set with Gzip, also receives uncompressed copies of the
+	// files Write publishes, laid out as in Dir, for tooling that checks the
+	// plain XML. Relative paths are resolved against Dir.
+	VerifyDir string
+	// Mirrors receive copies of the sitemap files and indexes compressed with
+	// other codecs, such as zstd for internal consumers, while the
+	// published files stay plain or gzipped.
+	Mirrors []Mirror
+	// MaxIndexEntries is the most sitemaps one index references (50,000 if
+	// zero). Larger sets fail to write unless NestedIndexes is set.
+	MaxIndexEntries int
+	// MaxTotalURLs, if set, is the most URLs a Write may publish. Beyond
+	// it, Write fails with ErrTooManyURLs or drops URLs as Overflow says.
+	// With OverflowError, TryAddURL stops accepting URLs once as many were
+	// added.
+	MaxTotalURLs int
+	// MaxHostURLs sets the most URLs a Write may publish for the hosts it
+	// lists, such as {"shop.example.com": 1000000}, enforced like
+	// MaxTotalURLs.
+	MaxHostURLs map[string]int
+	// Overflow controls the URLs beyond MaxTotalURLs and MaxHostURLs. URLs
+	// flushed over MemoryLimit are kept, so the policy then only chooses
+	// among those still in memory.
+	Overflow OverflowPolicy
+	// Empty controls what Write does when no URL is left to write, after
+	// filtering, and no ExternalSitemaps are listed.
+	Empty EmptyPolicy
+	// NestedIndexes splits an oversized index into sitemap_index_N.xml files
+	// referenced from sitemap_index.xml.
+	NestedIndexes bool
+	// ExternalSitemaps are sitemaps published elsewhere that the index
+	// references after the generated ones. See AddExternalSitemap.
+	ExternalSitemaps []Sitemap
+	// CheckExternal, if set, fetches the ExternalSitemaps at write time and
+	// warns about, drops or fails on broken ones, so the index does not
+	// point at missing sitemaps. Requests go through HTTPClient.
+	CheckExternal ExternalCheck
+	// CheckImageLicenses, if set, makes Write request every distinct image
+	// license URL and clear the licenses that do not resolve, as search
+	// engines ignore image blocks with broken ones. Each problem is
+	// recorded in Report.ImageIssues. Requests go through HTTPClient.
+	CheckImageLicenses bool
+	// CheckFreeSpace makes Write estimate the size of its files and fail
+	// before writing any if the filesystem of Dir has less room.
+	CheckFreeSpace bool
+	// SpaceCheck, if set, is called with the estimated size of the files
+	// before any is written, for example to check a remote storage quota.
+	// Its error fails the Write.
+	SpaceCheck func(estimatedBytes int64) error
+	// AfterWrite, if set, is called after a successful Write with the public
+	// URLs of the files it wrote or removed, for example to purge them from a
+	// CDN. Its error is returned by Write, but the new files stay in place.
+	AfterWrite func(urls []string) error
+
+	// MemoryLimit, if set, caps the approximate size of the buffered URLs.
+	// See WithMemoryLimit.
+	MemoryLimit int64
+
+	state    *State
+	report   *Report
+	tx       *writeTx      // Files changed by the running Write
+	flush    *flushSession // URLs flushed over MemoryLimit since the last Write
+	buffered int64         // Approximate size of the URLs that may be flushed
+	mu       *sync.Mutex   // Guards URLs, see lock
+	slots    chan struct{} // Outbound requests in flight, see acquireSlot
+
+	rejected       []ExcludedURL      // URLs rejected by OnAddURL since Reset
+	ids            []idEntry          // Entries added with AddID since Reset
+	shardTemplate  *template.Template // Parsed ShardNameTemplate of the running Write
+	brokenExternal map[string]bool    // ExternalSitemaps dropped by CheckExternal
+	generated      time.Time          // Start of the running Write, see ShardName
+	previousLoaded bool               // StateFile was read for PreviousState
+	released       bool               // A Write dropped the URLs flushed over MemoryLimit
 }
 
 // NewSitemapOptions initializes a new SitemapOptions instance.
 func NewSitemapOptions(dir string, baseURL string) *SitemapOptions {
 	return &SitemapOptions{
-		MaxFileSize: 52428800, // 50MB
-		MaxURLs:     maxURLsPerSitemap,
-		Dir:         dir,
-		BaseURL:     strings.TrimRight(baseURL, "/"),
-		URLs:        []SitemapURL{},
-		Stylesheet:  "sitemap.xsl", // Default stylesheet filename
+		MaxFileSize:   52428800, // 50MB
+		MaxURLs:       maxURLsPerSitemap,
+		Dir:           dir,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		URLs:          []SitemapURL{},
+		Stylesheet:    "sitemap.xsl", // Default stylesheet filename
+		Now:           time.Now,
+		LastModFormat: LastModDate,
+		Location:      time.UTC,
+		mu:            &sync.Mutex{},
+	}
+}
+
+// New initializes a new SitemapOptions instance like NewSitemapOptions,
+// returning an error if baseURL is malformed.
+func New(dir string, baseURL string) (*SitemapOptions, error) {
+	if _, err := parseBaseURL(baseURL); err != nil {
+		return nil, err
+	}
+	return NewSitemapOptions(dir, baseURL), nil
+}
+
+// now returns the current time according to the configured clock and timezone.
+func (s *SitemapOptions) now() time.Time {
+	if s.Now == nil {
+		return time.Now().In(s.location())
 	}
+	return s.Now().In(s.location())
 }
 
 // AddURL adds a single SitemapURL to the sitemap, ensuring it's valid.
 func (s *SitemapOptions) AddURL(url SitemapURL) {
-	if url.LastMod == "" {
-		url.LastMod = time.Now().UTC().Format("2006-01-02")
-	} else {
-		timeLastMod, err := time.Parse("2006-01-02", url.LastMod)
-		if err != nil || timeLastMod.After(time.Now().UTC()) {
-			url.LastMod = time.Now().UTC().Format("2006-01-02")
+	url = s.normalizeURL(url)
+	if s.OnAddURL != nil {
+		if err := s.OnAddURL(&url); err != nil {
+			rejected := ExcludedURL{Loc: url.Loc, Reason: "rejected: " + err.Error(), Meta: url.Meta}
+			s.warn(WarningExcluded, Issue{Loc: rejected.Loc, Problem: rejected.Reason, Meta: rejected.Meta})
+			mu := s.lock()
+			defer mu.Unlock()
+			s.rejected = append(s.rejected, rejected)
+			return
 		}
 	}
-	if !strings.HasPrefix(url.Loc, "http://") || !strings.HasPrefix(url.Loc, "https://") {
-		if strings.HasPrefix(url.Loc, "/") && strings.HasSuffix(s.BaseURL, "/") {
-			url.Loc = "https://" + s.BaseURL + url.Loc
-		} else if !strings.HasPrefix(url.Loc, "/") && !strings.HasSuffix(s.BaseURL, "/") {
-			url.Loc = "https://" + s.BaseURL + "/" + url.Loc
-		} else {
-			url.Loc = "https://" + s.BaseURL + url.Loc
+	mu := s.lock()
+	defer mu.Unlock()
+	s.URLs = append(s.URLs, url)
+	if s.MemoryLimit > 0 && flushable(url) {
+		s.buffered += approxURLSize(url)
+		if s.buffered >= s.MemoryLimit {
+			s.flushURLs()
 		}
 	}
-	s.URLs = append(s.URLs, url)
+}
+
+// TryAddURL adds url like AddURL unless a Write is bound to fail, so that
+// producers can stop early instead of buffering URLs for nothing. It returns
+// an error matching ErrTooManyURLs once MaxTotalURLs URLs were added since
+// Reset with OverflowError, counting duplicates and URLs dropped at write
+// time, and the error of a failed flush over MemoryLimit. URLs rejected by
+// OnAddURL are recorded as by AddURL and are not an error.
+func (s *SitemapOptions) TryAddURL(url SitemapURL) error {
+	mu := s.lock()
+	err := s.checkBudget()
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.AddURL(url)
+	return nil
+}
+
+// checkBudget returns why no more URLs should be added. s.mu must be held.
+func (s *SitemapOptions) checkBudget() error {
+	if s.released {
+		return errReleased
+	}
+	added := len(s.URLs) + len(s.ids)
+	if s.flush != nil {
+		if s.flush.err != nil {
+			return s.flush.err
+		}
+		added += s.flush.flushed
+	}
+	if s.MaxTotalURLs > 0 && added >= s.MaxTotalURLs && s.Overflow == OverflowError {
+		return errorf(ErrTooManyURLs, "%d URLs were added, the MaxTotalURLs limit", added)
+	}
+	return nil
+}
+
+// normalizeURL replaces a missing, invalid or future lastmod according to
+// the URL's LastModStrategy and resolves the loc against BaseURL.
+func (s *SitemapOptions) normalizeURL(url SitemapURL) SitemapURL {
+	url.lastModGenerated = s.isGeneratedLastMod(url.Group, url.LastMod)
+	url.LastMod = s.lastModFor(url.Group, url.LastMod, s.now())
+	// Invalid locs are kept as is and reported by Write
+	if fullURL, err := s.resolveLoc(url); err == nil {
+		url.Loc = fullURL
+	}
+	return url
 }
 
 // AddURLs adds multiple SitemapURLs to the sitemap, ensuring they're valid.
@@ -202,64 +628,616 @@ func (s *SitemapOptions) AddURLs(urls []SitemapURL) {
 	}
 }
 
+// WithDefaultChangeFreq sets the changefreq written for URLs without one.
+// It returns s for chaining.
+func (s *SitemapOptions) WithDefaultChangeFreq(changeFreq string) *SitemapOptions {
+	s.DefaultChangeFreq = changeFreq
+	return s
+}
+
+// WithDefaultPriority sets the priority written for URLs without one. It
+// returns s for chaining.
+func (s *SitemapOptions) WithDefaultPriority(priority float64) *SitemapOptions {
+	s.DefaultPriority = formatPriority(priority)
+	return s
+}
+
+// formatPriority formats a priority without trailing zeros.
+func formatPriority(priority float64) string {
+	return strconv.FormatFloat(priority, 'f', -1, 64)
+}
+
+// Reset clears the accumulated URLs while retaining the configuration, so
+// an instance can be reused for periodic regeneration. The State of the last
+// successful Write becomes PreviousState for the next run.
+func (s *SitemapOptions) Reset() {
+	mu := s.lock()
+	defer mu.Unlock()
+	s.URLs = []SitemapURL{}
+	s.ids = nil
+	s.rejected = nil
+	s.discardFlushed()
+	s.released = false
+	s.previousLoaded = false
+	if s.state != nil {
+		s.PreviousState = s.state
+		s.state = nil
+	}
+}
+
+// Clone returns an independent copy of s holding a snapshot of the current
+// URLs. One goroutine can keep adding URLs to s while another writes the
+// clone. Configuration values such as hooks and maps are shared shallowly.
+func (s *SitemapOptions) Clone() *SitemapOptions {
+	s.requestSlots()
+	mu := s.lock()
+	defer mu.Unlock()
+	c := *s
+	c.URLs = append([]SitemapURL(nil), s.URLs...)
+	c.rejected = append([]ExcludedURL(nil), s.rejected...)
+	c.ids = append([]idEntry(nil), s.ids...)
+	c.tx = nil
+	c.flush = nil
+	c.buffered = 0
+	c.mu = &sync.Mutex{}
+	return &c
+}
+
+// lock locks and returns the mutex guarding URLs, creating it for instances
+// that were not built by NewSitemapOptions.
+func (s *SitemapOptions) lock() *sync.Mutex {
+	initMu.Lock()
+	if s.mu == nil {
+		s.mu = &sync.Mutex{}
+	}
+	mu := s.mu
+	initMu.Unlock()
+	mu.Lock()
+	return mu
+}
+
 // Write generates the sitemap files based on the current URLs.
 // baseSitemapURL is the base URL where the sitemap files will be accessible.
+// Write must not run concurrently with AddURL on the same instance; write a
+// Clone instead.
+//
+// Write keeps the URLs, so calling it again regenerates the same files,
+// including any URLs added since, relative to the same PreviousState. Call
+// Reset to start the next run from an empty set with the State just written
+// as PreviousState. URLs flushed over MemoryLimit are dropped once written,
+// so after such a run Write fails until Reset is called.
+//
+// If Write fails after it started replacing files, the files of the previous
+// run are restored and a *WriteError identifies the failed file.
+//
+// Write, like WriteIndexOnly, TouchLastMod and Rollback, claims Dir while it
+// runs; an overlapping call for the same Dir, in this process or, on Linux,
+// macOS and FreeBSD, in another, fails with an error matching ErrLocked.
 func (s *SitemapOptions) Write(baseSitemapURL string) error {
-	// Ensure the directory exists
-	if _, err := os.Stat(s.Dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(s.Dir, 0755); err != nil {
+	return s.WriteContext(context.Background(), baseSitemapURL)
+}
+
+// WriteContext is Write with a context bounding the requests of
+// CheckExternal. Once ctx is done, no further sitemap file is written and
+// the files of the previous run are restored.
+func (s *SitemapOptions) WriteContext(ctx context.Context, baseSitemapURL string) error {
+	if s.released {
+		return errReleased
+	}
+	// A run flushed over MemoryLimit already holds Dir
+	var unlock func()
+	var err error
+	if s.flush != nil && s.flush.unlock != nil {
+		unlock = s.flush.unlock
+	} else if unlock, err = lockDir(s.Dir); err != nil {
+		return err
+	}
+	defer unlock()
+	start := time.Now()
+	tx := &writeTx{}
+	if s.flush != nil {
+		tx = s.flush.tx
+		s.released = true
+	}
+	err = s.runTx(tx, func() error {
+		return s.write(ctx, baseSitemapURL)
+	})
+	s.flush = nil
+	s.buffered = 0
+	if err == errSkippedEmpty {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.pruneArchives(); err != nil {
+		return err
+	}
+	if err := s.afterWrite(baseSitemapURL, tx); err != nil {
+		return err
+	}
+	if err := s.writeMetrics(start); err != nil {
+		return err
+	}
+	return s.writeReview()
+}
+
+// runTx runs fn with the files it changes recorded in tx. If fn fails after
+// it started replacing files, they are restored and a *WriteError is
+// returned.
+func (s *SitemapOptions) runTx(tx *writeTx, fn func() error) error {
+	s.tx = tx
+	err := fn()
+	s.tx = nil
+	if err != nil {
+		if tx.file == "" {
 			return err
 		}
+		if rollbackErr := tx.rollback(); rollbackErr != nil {
+			err = fmt.Errorf("%w (restoring the previous files failed: %v)", err, rollbackErr)
+		}
+		return &WriteError{File: tx.file, Completed: tx.completed, Err: err}
 	}
+	tx.commit()
+	return nil
+}
 
-	// Write the stylesheet into the sitemap directory
-	if err := s.writeStylesheet(); err != nil {
+// afterWrite calls the AfterWrite hook, if set, with the files changed by
+// tx.
+func (s *SitemapOptions) afterWrite(baseSitemapURL string, tx *writeTx) error {
+	if s.AfterWrite == nil {
+		return nil
+	}
+	urls, err := s.publishedURLs(baseSitemapURL, tx)
+	if err != nil {
+		return err
+	}
+	if err := s.AfterWrite(urls); err != nil {
+		return fmt.Errorf("after-write hook failed: %v", err)
+	}
+	return nil
+}
+
+func (s *SitemapOptions) write(ctx context.Context, baseSitemapURL string) error {
+	if _, err := parseBaseURL(baseSitemapURL); err != nil {
+		return fmt.Errorf("invalid base sitemap URL: %w", err)
+	}
+	limits, err := s.checkLimits()
+	if err != nil {
+		return err
+	}
+	if err := s.prepareShardNames(); err != nil {
+		return err
+	}
+	if err := s.checkPublicNames(); err != nil {
+		return err
+	}
+
+	// Ensure the directories exist, unless the files are published remotely
+	for _, dir := range []string{s.Dir, s.shardDir()} {
+		if s.tx.remote() {
+			break
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Load the previous run's state before anything is overwritten
+	if err := s.loadPreviousState(); err != nil {
+		return err
+	}
+
+	// Continue the run started by flushing URLs over MemoryLimit, if any
+	flush := s.flush
+	if flush == nil {
+		flush = &flushSession{}
+	}
+	if flush.err != nil {
+		return flush.err
+	}
+	report := &flush.report
+	report.Limits = limits
+	report.Excluded = append(report.Excluded, s.rejected...)
+	if flush.seen == nil {
+		flush.seen = make(map[string]bool, len(s.URLs)+len(s.ids))
+	}
+
+	collect := time.Now()
+	urls, err := s.bufferedURLs(report)
+	if err != nil {
+		return err
+	}
+	urls, err = s.prepareURLs(urls, report, flush.seen)
+	if err != nil {
+		return err
+	}
+	if urls, err = s.applyBudgets(urls, flush.urls, flush.hosts, report); err != nil {
+		return err
+	}
+	if flush.licenses == nil {
+		flush.licenses = make(map[string]string)
+	}
+	if err := s.checkImageLicenses(ctx, urls, flush.licenses, report); err != nil {
+		return err
+	}
+	if skip, err := s.skipEmpty(flush.urls+len(urls), report); skip || err != nil {
+		if skip {
+			report.Stats.finish()
+			report.Timings = s.tx.phases.timings()
+			s.report = report
+			return errSkippedEmpty
+		}
 		return err
 	}
 
-	// Prepare URLs
-	for i := range s.URLs {
-		fullURL, err := s.resolveURL(s.URLs[i].Loc)
+	// Archive the published set before anything is overwritten
+	if !flush.archived {
+		if err := s.archiveSet(); err != nil {
+			return err
+		}
+		flush.archived = true
+	}
+
+	// Move fresh news articles into their own sitemaps
+	news, urls, err := s.splitNews(urls)
+	if err != nil {
+		return err
+	}
+	extra, err := s.newsShards(news)
+	if err != nil {
+		return err
+	}
+
+	// Remove a recent sitemap left by a previous run if none is written now
+	recent := s.capRecent(append(flush.recent, s.recentURLs(urls)...), report)
+	if len(recent) == 0 {
+		stale, err := staleSitemaps(filepath.Join(s.shardDir(), recentSitemapName))
 		if err != nil {
 			return err
 		}
-		s.URLs[i].Loc = fullURL
+		for _, name := range stale {
+			if err := s.tx.removeFile(name); err != nil {
+				return err
+			}
+		}
+	} else {
+		extra = append(extra, shard{name: recentSitemapName, urls: recent})
+	}
+
+	// Check that the sitemap files may list their URLs
+	single := len(urls) <= s.MaxURLs && len(extra) == 0 && len(s.ExternalSitemaps) == 0 && !hasGroups(urls) && len(flush.shards) == 0 && s.ShardStrategy != ShardByDate
+	scope := baseSitemapURL
+	if !single {
+		if scope, err = s.shardBaseURL(baseSitemapURL); err != nil {
+			return err
+		}
+	}
+	if err := s.checkGroupScopes(append(urls, news...), scope, report); err != nil {
+		return err
+	}
+
+	if err := s.checkExternalSitemaps(ctx, report); err != nil {
+		return err
+	}
+
+	// Fail before writing anything if the files will not fit
+	if err := s.preflight(urls, extra, report); err != nil {
+		return err
+	}
+	s.since(phaseCollect, collect)
+
+	// Write the stylesheet into the sitemap directory
+	if err := s.writeStylesheet(); err != nil {
+		return err
 	}
 
 	// Decide whether to create a sitemap index or a single sitemap
-	if len(s.URLs) <= s.MaxURLs {
+	if err := s.removeOtherRoot(single); err != nil {
+		return err
+	}
+	if single {
 		// Generate sitemap file
-		err := s.writeSitemapFile("sitemap.xml", s.URLs)
+		s.tx.empty = len(urls) == 0
+		err := s.writeSitemapFile(filepath.Join(s.Dir, "sitemap.xml"), urls)
 		if err != nil {
 			return err
 		}
-		// Validate the generated sitemap file
-		return s.validateXMLFile(path.Join(s.Dir, "sitemap.xml"), false)
+		report.recordStats("sitemap.xml", s.urlStats(urls))
+		// Validate the generated sitemap file, unless it is the empty
+		// urlset of EmptyWrite, which the XSD rejects
+		if !s.tx.empty {
+			if err := s.validateXMLFile(filepath.Join(s.Dir, "sitemap.xml"), false); err != nil {
+				return err
+			}
+		}
 	} else {
 		// Generate sitemap index
-		err := s.writeSitemapIndex(baseSitemapURL)
+		err := s.writeSitemapIndex(ctx, baseSitemapURL, urls, extra, report)
 		if err != nil {
 			return err
 		}
 		// Validate the sitemap index and all sitemap files
-		return s.validateSitemapIndexAndFiles()
+		if err := s.validateSitemapIndexAndFiles(); err != nil {
+			return err
+		}
+	}
+
+	urls = append(urls, news...)
+	if s.ValidateHreflang {
+		for _, issue := range CheckHreflang(urls) {
+			s.addIssue(&report.HreflangIssues, WarningHreflang, issue)
+		}
 	}
+	report.URLs = flush.urls + len(urls)
+	report.Stats.finish()
+	state := NewState(urls)
+	state.merge(flush.state)
+	state.trackSince(s.PreviousState, s.generated)
+	if s.PreviousState != nil {
+		changes := state.Changes(s.PreviousState)
+		report.Changes = &changes
+		if err := s.writeChangesFile(changes); err != nil {
+			return err
+		}
+	}
+	if err := s.describeSet(); err != nil {
+		return err
+	}
+	if statePath := s.stateFilePath(); statePath != "" {
+		if err := state.Save(statePath); err != nil {
+			return err
+		}
+	}
+	report.Timings = s.tx.phases.timings()
+	s.report = report
+	s.state = state
+	return nil
 }
 
-func (s *SitemapOptions) resolveURL(loc string) (string, error) {
-	base, err := url.Parse(s.BaseURL)
+// removeOtherRoot removes the root left in Dir by an earlier run of the
+// other shape, so that the set has a single root: sitemap_index.xml and its
+// nested indexes when a single sitemap is written, or sitemap.xml when an
+// index is.
+func (s *SitemapOptions) removeOtherRoot(single bool) error {
+	patterns := []string{filepath.Join(s.Dir, "sitemap.xml")}
+	if single {
+		patterns = []string{filepath.Join(s.Dir, "sitemap_index.xml"), filepath.Join(s.Dir, nestedIndexPrefix+"*"+sitemapExt)}
+	}
+	for _, pattern := range patterns {
+		stale, err := staleSitemaps(pattern)
+		if err != nil {
+			return err
+		}
+		for _, name := range stale {
+			if err := s.tx.removeFile(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// urlRules are the compiled options prepareURLs applies to every URL.
+type urlRules struct {
+	queries  *queryCleaner
+	sections []compiledSection
+	include  []*Pattern
+	exclude  []*Pattern
+}
+
+// compileURLRules compiles the query, section and filter options of s,
+// returning an error for invalid ones.
+func (s *SitemapOptions) compileURLRules() (*urlRules, error) {
+	if err := s.checkGroupBaseURLs(); err != nil {
+		return nil, err
+	}
+	rules := &urlRules{}
+	var err error
+	if rules.queries, err = s.queryCleaner(); err != nil {
+		return nil, err
+	}
+	if rules.sections, err = s.sectionRules(); err != nil {
+		return nil, err
+	}
+	if rules.include, err = compilePatterns(s.Include); err != nil {
+		return nil, err
+	}
+	if rules.exclude, err = compilePatterns(s.Exclude); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// prepareURLs resolves and cleans the locs and values of urls in place,
+// then drops excluded URLs, locs already in seen and invalid images. It
+// returns the URLs to write.
+func (s *SitemapOptions) prepareURLs(urls []SitemapURL, report *Report, seen map[string]bool) ([]SitemapURL, error) {
+	rules, err := s.compileURLRules()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return s.prepareURLsWith(rules, urls, report, seen)
+}
+
+// prepareURLsWith prepares urls like prepareURLs with rules compiled
+// beforehand.
+func (s *SitemapOptions) prepareURLsWith(rules *urlRules, urls []SitemapURL, report *Report, seen map[string]bool) ([]SitemapURL, error) {
+	urls = s.cleanURLChars(urls, report)
+	for i := range urls {
+		fullURL, err := s.resolveLoc(urls[i])
+		if err != nil {
+			return nil, err
+		}
+		urls[i].Loc, err = s.canonicalLoc(urls[i].Group, rules.queries.clean(fullURL))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkGroup(urls[i].Group); err != nil {
+			return nil, err
+		}
+		s.cleanOptionalFields(&urls[i], rules.sections)
+		if err := s.checkChangeFreq(&urls[i], report); err != nil {
+			return nil, err
+		}
+		if err := s.checkPriority(&urls[i], report); err != nil {
+			return nil, err
+		}
+		if !urls[i].Absolute {
+			base, _ := parseBaseURL(s.baseURL(urls[i].Group))
+			if err := s.checkOrigin(urls[i], base, report); err != nil {
+				return nil, err
+			}
+		}
+		alternates, err := s.urlAlternates(urls[i])
+		if err != nil {
+			return nil, err
+		}
+		urls[i].Alternates = alternates
+	}
+
+	// Drop excluded URLs
+	kept := s.filterURLs(rules, urls, report, seen)
+
+	// Drop invalid images and videos
+	s.validateImages(kept, report)
+	s.validateVideos(kept, report)
+	return kept, nil
+}
+
+// cleanOptionalFields trims the optional fields of u so that blank values
+// produce no element at all, fills in the values of the first matching
+// section rule, then DefaultChangeFreq and DefaultPriority, and drops the
+// default priority if OmitDefaults is set.
+func (s *SitemapOptions) cleanOptionalFields(u *SitemapURL, sections []compiledSection) {
+	u.LastMod = strings.TrimSpace(u.LastMod)
+	u.ChangeFreq = strings.TrimSpace(u.ChangeFreq)
+	u.Priority = strings.TrimSpace(u.Priority)
+	applySections(u, sections)
+	if u.ChangeFreq == "" {
+		u.ChangeFreq = strings.TrimSpace(s.DefaultChangeFreq)
+	}
+	if u.Priority == "" {
+		u.Priority = strings.TrimSpace(s.DefaultPriority)
+	}
+	if s.OmitDefaults && u.Priority != "" {
+		if p, err := strconv.ParseFloat(u.Priority, 64); err == nil && p == defaultPriority {
+			u.Priority = ""
+		}
+	}
+}
+
+// parseBaseURL parses and validates a base URL. A missing scheme defaults
+// to https; anything other than an http(s) URL with a host is rejected.
+// The returned URL's path always ends in a slash so references resolve
+// below it.
+func parseBaseURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, errorf(ErrInvalidBaseURL, "base URL is empty")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	base, err := url.Parse(raw)
+	if err != nil {
+		return nil, errorf(ErrInvalidBaseURL, "invalid base URL '%s': %v", raw, err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, errorf(ErrInvalidBaseURL, "invalid base URL '%s': scheme must be http or https", raw)
+	}
+	if base.Hostname() == "" {
+		return nil, errorf(ErrInvalidBaseURL, "invalid base URL '%s': missing host", raw)
+	}
+	if port := base.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, errorf(ErrInvalidBaseURL, "invalid base URL '%s': invalid port", raw)
+		}
+	}
+	if base.RawQuery != "" || base.Fragment != "" {
+		return nil, errorf(ErrInvalidBaseURL, "invalid base URL '%s': query and fragment are not allowed", raw)
+	}
+	base.Path = strings.TrimRight(base.Path, "/") + "/"
+	if base.RawPath != "" {
+		base.RawPath = strings.TrimRight(base.RawPath, "/") + "/"
+	}
+	return base, nil
+}
+
+// resolveURL returns loc as an absolute URL. Relative locs are resolved
+// below BaseURL, so both "about" and "/about" keep the base URL's path.
+func (s *SitemapOptions) resolveURL(loc string) (string, error) {
+	return s.resolveGroupURL("", loc)
+}
+
+// resolveGroupURL resolves loc like resolveURL, against the base URL of
+// the given group.
+func (s *SitemapOptions) resolveGroupURL(group, loc string) (string, error) {
 	ref, err := url.Parse(loc)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL '%s': %v", loc, err)
+	}
+	if ref.IsAbs() {
+		return ref.String(), nil
+	}
+	base, err := parseBaseURL(s.baseURL(group))
 	if err != nil {
 		return "", err
 	}
+	if ref.Host != "" {
+		// Protocol-relative reference to another host
+		ref.Scheme = base.Scheme
+		return ref.String(), nil
+	}
+	ref.Path = strings.TrimLeft(ref.Path, "/")
+	ref.RawPath = strings.TrimLeft(ref.RawPath, "/")
 	return base.ResolveReference(ref).String(), nil
 }
 
+// canonicalLoc applies the Canonicalize hook to loc, a URL of the given
+// group, if set.
+func (s *SitemapOptions) canonicalLoc(group, loc string) (string, error) {
+	if s.Canonicalize == nil {
+		return loc, nil
+	}
+	canonical, err := s.resolveGroupURL(group, s.Canonicalize(loc))
+	if err != nil {
+		return "", fmt.Errorf("invalid canonical URL for %s: %v", loc, err)
+	}
+	return canonical, nil
+}
+
+// resolveLoc resolves the loc of u against the base URL of its group,
+// except that Absolute locs must name a host and get the base URL's scheme
+// if they lack one.
+func (s *SitemapOptions) resolveLoc(u SitemapURL) (string, error) {
+	if !u.Absolute {
+		return s.resolveGroupURL(u.Group, u.Loc)
+	}
+	loc := strings.TrimSpace(u.Loc)
+	if !strings.Contains(loc, "://") {
+		scheme := "https"
+		if base, err := parseBaseURL(s.baseURL(u.Group)); err == nil {
+			scheme = base.Scheme
+		}
+		loc = scheme + "://" + strings.TrimPrefix(loc, "//")
+	}
+	ref, err := url.Parse(loc)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL '%s': %v", u.Loc, err)
+	}
+	if ref.Host == "" {
+		return "", fmt.Errorf("invalid absolute URL '%s': missing host", u.Loc)
+	}
+	return ref.String(), nil
+}
+
+// resolveSitemapURL returns the URL of a sitemap file served below
+// baseSitemapURL.
 func (s *SitemapOptions) resolveSitemapURL(baseSitemapURL, sitemapName string) (string, error) {
-	base, err := url.Parse(strings.TrimRight(baseSitemapURL, "/") + "/")
+	base, err := parseBaseURL(baseSitemapURL)
 	if err != nil {
 		return "", err
 	}
@@ -270,81 +1248,217 @@ func (s *SitemapOptions) resolveSitemapURL(baseSitemapURL, sitemapName string) (
 	return base.ResolveReference(ref).String(), nil
 }
 
+// shardDir returns the directory sitemap files of an index are written to.
+func (s *SitemapOptions) shardDir() string {
+	return filepath.Join(s.Dir, s.ShardDir)
+}
+
+// shardBaseURL returns the base URL sitemap files of an index are served
+// from: ShardBaseURL if set, otherwise ShardDir resolved against
+// baseSitemapURL.
+func (s *SitemapOptions) shardBaseURL(baseSitemapURL string) (string, error) {
+	if s.ShardBaseURL != "" {
+		return s.ShardBaseURL, nil
+	}
+	if s.ShardDir == "" {
+		return baseSitemapURL, nil
+	}
+	return s.resolveSitemapURL(baseSitemapURL, filepath.ToSlash(s.ShardDir)+"/")
+}
+
+// writeStylesheet writes the stylesheet next to the index and, when shards
+// live in a subdirectory, next to the shards so relative references resolve.
 func (s *SitemapOptions) writeStylesheet() error {
-	filePath := path.Join(s.Dir, s.Stylesheet)
-	return os.WriteFile(filePath, []byte(sitemapXSL), 0644)
+	stylesheet, err := s.renderStylesheet()
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(s.Dir, s.Stylesheet)
+	if err := s.tx.writeFile(filePath, stylesheet); err != nil {
+		return err
+	}
+	if s.ShardDir == "" {
+		return nil
+	}
+	return s.tx.writeFile(filepath.Join(s.shardDir(), s.Stylesheet), stylesheet)
 }
 
-func (s *SitemapOptions) writeSitemapFile(filename string, urls []SitemapURL) error {
+// fileHeader returns a buffer holding the XML header, the stylesheet
+// reference and, if enabled, the generator comment for a file with count
+// entries.
+func (s *SitemapOptions) fileHeader(count int) *bytes.Buffer {
+	buffer := bytes.NewBufferString(s.declaration())
+	buffer.WriteString(fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s"?>`, s.Stylesheet) + s.newline())
+	buffer.WriteString(s.generatorComment(count))
+	return buffer
+}
+
+func (s *SitemapOptions) writeSitemapFile(filePath string, urls []SitemapURL) error {
+	file, err := s.encodeSitemapFile(filepath.Base(filePath), urls)
+	if err != nil {
+		return err
+	}
+	return s.storeSitemapFile(filePath, file)
+}
+
+// encodeSitemapFile encodes the sitemap file called name listing urls. It
+// is safe to call concurrently.
+func (s *SitemapOptions) encodeSitemapFile(name string, urls []SitemapURL) (encodedFile, error) {
 	urlSet := URLSet{
 		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
 		URLs:  urls,
 	}
+	urlSet.setNamespaces()
 
-	data, err := xml.MarshalIndent(urlSet, "", "  ")
+	start := time.Now()
+	data, err := s.marshalXML(urlSet)
 	if err != nil {
-		return err
+		return encodedFile{}, err
 	}
 
-	// Add XML header and stylesheet with correct URL
-	buffer := bytes.NewBufferString(xml.Header)
-	buffer.WriteString(fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s"?>`+"\n", s.Stylesheet))
+	buffer := s.fileHeader(len(urls))
 	buffer.Write(data)
+	s.since(phaseEncode, start)
+	if buffer.Len() > s.maxFileSize() {
+		return encodedFile{}, errorf(ErrShardTooLarge, "%s would be %d bytes, more than MaxFileSize of %d; lower MaxURLs", name, buffer.Len(), s.maxFileSize())
+	}
+	return s.encodeXMLFile(buffer.Bytes())
+}
 
-	filePath := path.Join(s.Dir, filename)
-	return os.WriteFile(filePath, buffer.Bytes(), 0644)
+// storeSitemapFile writes an encoded sitemap file to filePath.
+func (s *SitemapOptions) storeSitemapFile(filePath string, file encodedFile) error {
+	if err := s.storeXMLFile(filePath, file); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		s.tx.completed++
+	}
+	return nil
 }
 
-func (s *SitemapOptions) writeSitemapIndex(baseSitemapURL string) error {
+// writeSitemapIndex writes the shards of urls followed by the extra sitemaps,
+// such as news and recent changes, and the index referencing them all. The
+// stats of each file are recorded in report.
+func (s *SitemapOptions) writeSitemapIndex(ctx context.Context, baseSitemapURL string, urls []SitemapURL, extra []shard, report *Report) error {
 	index := SitemapIndex{
 		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
 	}
 
-	fileCount := (len(s.URLs) + s.MaxURLs - 1) / s.MaxURLs
-	for i := 0; i < fileCount; i++ {
-		sitemapName := fmt.Sprintf("sitemap_%d.xml", i+1)
-		start := i * s.MaxURLs
-		end := start + s.MaxURLs
-		if end > len(s.URLs) {
-			end = len(s.URLs)
-		}
-		urlsSlice := s.URLs[start:end]
-		err := s.writeSitemapFile(sitemapName, urlsSlice)
+	shardBaseURL, err := s.shardBaseURL(baseSitemapURL)
+	if err != nil {
+		return err
+	}
+	external, err := s.externalSitemaps()
+	if err != nil {
+		return err
+	}
+
+	// Fail before writing any file if the index cannot hold every sitemap
+	shards := append(s.shards(urls), extra...)
+	var flushed []flushedShard
+	if s.flush != nil {
+		flushed = s.flush.shards
+	}
+	if err := s.checkIndexSize(len(flushed) + len(shards) + len(external)); err != nil {
+		return err
+	}
+
+	// Sitemaps flushed over MemoryLimit come first
+	for _, shard := range flushed {
+		sitemapURL, err := s.resolveSitemapURL(shardBaseURL, publicName(s.PublicNames, s.sitemapFileName(shard.name)))
 		if err != nil {
 			return err
 		}
-		sitemapURL, err := s.resolveSitemapURL(baseSitemapURL, sitemapName)
+		index.Sitemaps = append(index.Sitemaps, Sitemap{Loc: sitemapURL, LastMod: shard.lastMod})
+	}
+
+	err = s.writeShards(shards, func(shard shard) error {
+		report.recordStats(shard.name, s.urlStats(shard.urls))
+		base := shardBaseURL
+		if groupBase := s.groupBase(shard.group).SitemapBaseURL; groupBase != "" {
+			base = groupBase
+		}
+		sitemapURL, err := s.resolveSitemapURL(base, publicName(s.PublicNames, s.sitemapFileName(shard.name)))
 		if err != nil {
 			return err
 		}
 		index.Sitemaps = append(index.Sitemaps, Sitemap{
 			Loc:     sitemapURL,
-			LastMod: time.Now().UTC().Format("2006-01-02"),
+			LastMod: s.sitemapLastMod(shard.name, shard.urls),
 		})
+		return ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+	index.Sitemaps = append(index.Sitemaps, external...)
+
+	return s.writeIndexFile(baseSitemapURL, index.Sitemaps)
+}
+
+// writeIndexFile writes sitemap_index.xml referencing the given sitemaps,
+// cascading to nested indexes if there are more than one index may hold.
+func (s *SitemapOptions) writeIndexFile(baseSitemapURL string, sitemaps []Sitemap) error {
+	sitemaps, err := s.nestIndexes(baseSitemapURL, sitemaps)
+	if err != nil {
+		return err
+	}
+	return s.writeIndexXML(filepath.Join(s.Dir, "sitemap_index.xml"), sitemaps)
+}
+
+// writeIndexXML writes an index file referencing the given sitemaps.
+func (s *SitemapOptions) writeIndexXML(filePath string, sitemaps []Sitemap) error {
+	index := SitemapIndex{
+		Xmlns:    "http://www.sitemaps.org/schemas/sitemap/0.9",
+		Sitemaps: sitemaps,
 	}
 
-	data, err := xml.MarshalIndent(index, "", "  ")
+	start := time.Now()
+	data, err := s.marshalXML(index)
 	if err != nil {
 		return err
 	}
 
-	// Add XML header and stylesheet with correct URL
-	buffer := bytes.NewBufferString(xml.Header)
-	buffer.WriteString(fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s"?>`+"\n", s.Stylesheet))
+	buffer := s.fileHeader(len(index.Sitemaps))
 	buffer.Write(data)
+	s.since(phaseEncode, start)
 
-	filePath := path.Join(s.Dir, "sitemap_index.xml")
-	return os.WriteFile(filePath, buffer.Bytes(), 0644)
+	return s.writeXMLFile(filePath, buffer.Bytes())
 }
 
 // validateXMLFile validates the given XML file against the sitemap XSD.
 // If isIndex is true, validates against the sitemap index XSD.
 func (s *SitemapOptions) validateXMLFile(filePath string, isIndex bool) error {
-	data, err := os.ReadFile(filePath)
+	// Published files were validated by WritePut before they were sent
+	if s.tx.remote() {
+		return nil
+	}
+	defer s.since(phaseValidate, time.Now())
+	data, err := s.readXMLFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read XML file for validation: %v", err)
 	}
+	return validateXMLData(filePath, data, isIndex)
+}
 
+// validateXMLData validates data, the XML of the file at filePath, as
+// validateXMLFile does.
+func validateXMLData(filePath string, data []byte, isIndex bool) error {
+	if err := validateXML(data, isIndex); err != nil {
+		if _, ok := err.(schemaError); ok {
+			return &ValidationError{File: filePath, Problem: err.Error()}
+		}
+		return err
+	}
+	return nil
+}
+
+// schemaError is a violation of the sitemap XSD.
+type schemaError struct{ error }
+
+// validateXML validates data against the sitemap XSD, or the sitemap index
+// XSD if isIndex is true. Violations are returned as a schemaError.
+func validateXML(data []byte, isIndex bool) error {
 	schemaData := sitemapXSD
 	if isIndex {
 		schemaData = sitemapIndexXSD
@@ -366,20 +1480,28 @@ func (s *SitemapOptions) validateXMLFile(filePath string, isIndex bool) error {
 
 	// Validate the XML against the schema
 	if err := schema.Validate(doc); err != nil {
-		return fmt.Errorf("XML validation against schema failed: %v", err)
+		return schemaError{err}
 	}
 	return nil
 }
 
 func (s *SitemapOptions) validateSitemapIndexAndFiles() error {
+	return s.validateIndexAndFiles(filepath.Join(s.Dir, "sitemap_index.xml"))
+}
+
+// validateIndexAndFiles validates the index at indexFilePath, the nested
+// indexes it references and their sitemap files.
+func (s *SitemapOptions) validateIndexAndFiles(indexFilePath string) error {
+	if s.tx.remote() {
+		return nil
+	}
 	// Validate sitemap index
-	indexFilePath := path.Join(s.Dir, "sitemap_index.xml")
 	if err := s.validateXMLFile(indexFilePath, true); err != nil {
 		return err
 	}
 
 	// Read the sitemap index to get the list of sitemaps
-	indexData, err := os.ReadFile(indexFilePath)
+	indexData, err := s.readXMLFile(indexFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to read sitemap index for validation: %v", err)
 	}
@@ -389,15 +1511,29 @@ func (s *SitemapOptions) validateSitemapIndexAndFiles() error {
 		return fmt.Errorf("XML unmarshalling failed for sitemap index: %v", err)
 	}
 
+	external := make(map[string]bool, len(s.ExternalSitemaps))
+	for _, sitemap := range s.ExternalSitemaps {
+		external[strings.TrimSpace(sitemap.Loc)] = true
+	}
+
 	// Validate each sitemap file listed in the index
 	for _, sitemap := range index.Sitemaps {
+		if external[sitemap.Loc] {
+			continue
+		}
 		// Extract the filename from the sitemap location
 		sitemapURL, err := url.Parse(sitemap.Loc)
 		if err != nil {
 			return fmt.Errorf("invalid sitemap URL '%s': %v", sitemap.Loc, err)
 		}
-		sitemapFile := path.Base(sitemapURL.Path)
-		sitemapFilePath := path.Join(s.Dir, sitemapFile)
+		sitemapFile := localName(s.PublicNames, path.Base(sitemapURL.Path))
+		if isNestedIndex(sitemapFile) {
+			if err := s.validateIndexAndFiles(filepath.Join(s.Dir, sitemapFile)); err != nil {
+				return err
+			}
+			continue
+		}
+		sitemapFilePath := filepath.Join(s.shardDir(), sitemapFile)
 
 		// Validate the sitemap file
 		if err := s.validateXMLFile(sitemapFilePath, false); err != nil {
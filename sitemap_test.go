@@ -1,11 +1,13 @@
 package sitemap
 
 import (
+	"encoding/xml"
 	"os"
-	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSitemapGeneration(t *testing.T) {
@@ -47,7 +49,7 @@ func TestSitemapGeneration(t *testing.T) {
 	}
 
 	// Check if sitemap index was created
-	indexFile := path.Join(dir, "sitemap_index.xml")
+	indexFile := filepath.Join(dir, "sitemap_index.xml")
 	if _, err := os.Stat(indexFile); os.IsNotExist(err) {
 		t.Fatalf("Sitemap index not created")
 	}
@@ -74,7 +76,7 @@ func TestSitemapGeneration(t *testing.T) {
 	}
 
 	// Check if the stylesheet file is written to the sitemap directory
-	stylesheetFile := path.Join(dir, "sitemap.xsl")
+	stylesheetFile := filepath.Join(dir, "sitemap.xsl")
 	if _, err := os.Stat(stylesheetFile); os.IsNotExist(err) {
 		t.Fatalf("Stylesheet file not found in sitemap directory")
 	}
@@ -82,3 +84,295 @@ func TestSitemapGeneration(t *testing.T) {
 	// Clean up after test
 	os.RemoveAll(dir)
 }
+
+func TestInjectableClock(t *testing.T) {
+	dir := t.TempDir()
+	fixed := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return fixed }
+
+	sm.AddURL(SitemapURL{Loc: "/", LastMod: "invalid-date"})
+	sm.AddURL(SitemapURL{Loc: "/future", LastMod: "2999-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/old", LastMod: "2020-01-01"})
+
+	expected := []string{"2024-06-01", "2024-06-01", "2020-01-01"}
+	for i, u := range sm.URLs {
+		if u.LastMod != expected[i] {
+			t.Fatalf("URL %s: expected lastmod %s, got %s", u.Loc, expected[i], u.LastMod)
+		}
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	if strings.Contains(string(data), time.Now().UTC().Format("2006-01-02")) {
+		t.Fatalf("Sitemap contains wall-clock date despite injected clock")
+	}
+}
+
+func TestLastModFormats(t *testing.T) {
+	fixed := time.Date(2024, 6, 1, 22, 30, 0, 0, time.UTC)
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		format   LastModFormat
+		location *time.Location
+		expected string
+	}{
+		{LastModDate, time.UTC, "2024-06-01"},
+		{LastModDate, tokyo, "2024-06-02"},
+		{LastModSeconds, time.UTC, "2024-06-01T22:30:00Z"},
+		{LastModSeconds, tokyo, "2024-06-02T07:30:00+09:00"},
+		{LastModOffset, time.UTC, "2024-06-01T22:30:00+00:00"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.Now = func() time.Time { return fixed }
+		sm.LastModFormat = tt.format
+		sm.Location = tt.location
+
+		sm.AddURL(SitemapURL{Loc: "/"})
+		sm.AddURL(SitemapURL{Loc: "/explicit", LastMod: "2024-06-01T22:30:00Z"})
+		for _, u := range sm.URLs {
+			if u.LastMod != tt.expected {
+				t.Fatalf("Format %d in %s: expected lastmod %s, got %s", tt.format, tt.location, tt.expected, u.LastMod)
+			}
+		}
+
+		// Timestamps must pass schema validation
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Error writing sitemap with format %d: %v", tt.format, err)
+		}
+	}
+}
+
+func TestLastModStrategies(t *testing.T) {
+	dir := t.TempDir()
+	fixed := time.Date(2024, 6, 1, 22, 30, 0, 0, time.UTC)
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return fixed }
+	sm.LastModFormat = LastModSeconds
+	sm.LastModStrategy = LastModDaily
+	sm.GroupLastModStrategies = map[string]LastModStrategy{"blog": LastModContent}
+	sm.MaxURLs = 2
+
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddURL(SitemapURL{Loc: "/explicit", LastMod: "2024-05-20T08:15:00Z"})
+	sm.AddURL(SitemapURL{Loc: "/blog/new", Group: "blog"})
+	sm.AddURL(SitemapURL{Loc: "/blog/post", Group: "blog", LastMod: "2024-05-20T08:15:00Z"})
+	expected := []string{"2024-06-01T00:00:00Z", "2024-05-20T00:00:00Z", "", "2024-05-20T08:15:00Z"}
+	for i, u := range sm.URLs {
+		if u.LastMod != expected[i] {
+			t.Fatalf("URL %s: expected lastmod %q, got %q", u.Loc, expected[i], u.LastMod)
+		}
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_blog_1.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "<lastmod>") != 1 {
+		t.Fatalf("Expected the blog post without a lastmod to omit it, got %s", data)
+	}
+
+	// A shard without any lastmod gets none in the index either
+	sm.Reset()
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddURL(SitemapURL{Loc: "/blog/new", Group: "blog"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sitemap := range index.Sitemaps {
+		if strings.HasSuffix(sitemap.Loc, "sitemap_blog_1.xml") && sitemap.LastMod != "" {
+			t.Fatalf("Expected no lastmod for the blog sitemap, got %s", sitemap.LastMod)
+		}
+	}
+}
+
+func TestGeneratorComment(t *testing.T) {
+	fixed := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	write := func(enabled bool) string {
+		dir := t.TempDir()
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.Now = func() time.Time { return fixed }
+		sm.GeneratorComment = enabled
+		sm.AddURL(SitemapURL{Loc: "/"})
+		sm.AddURL(SitemapURL{Loc: "/about"})
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Error writing sitemap: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+		if err != nil {
+			t.Fatalf("Error reading sitemap: %v", err)
+		}
+		return string(data)
+	}
+
+	withComment := write(true)
+	if !strings.Contains(withComment, "<!-- generator="+generatorName) ||
+		!strings.Contains(withComment, "generated=2024-06-01T12:00:00Z entries=2") {
+		t.Fatalf("Generator comment missing or incomplete:\n%s", withComment)
+	}
+
+	withoutComment := write(false)
+	if strings.Contains(withoutComment, "<!--") {
+		t.Fatalf("Generator comment written while disabled")
+	}
+	if withoutComment != write(false) {
+		t.Fatalf("Output is not byte-stable without generator comment")
+	}
+}
+
+func TestOmitEmptyOptionalElements(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.OmitDefaults = true
+
+	// Bypass AddURL so lastmod stays blank as well
+	sm.URLs = append(sm.URLs,
+		SitemapURL{Loc: "https://www.example.com/"},
+		SitemapURL{Loc: "https://www.example.com/blank", LastMod: " ", ChangeFreq: " ", Priority: "  "},
+		SitemapURL{Loc: "https://www.example.com/default", Priority: "0.50"},
+		SitemapURL{Loc: "https://www.example.com/kept", Priority: "0.8"},
+	)
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	content := string(data)
+	for _, element := range []string{"lastmod", "changefreq"} {
+		if strings.Contains(content, "<"+element) {
+			t.Fatalf("Empty %s element written:\n%s", element, content)
+		}
+	}
+	if strings.Count(content, "<priority>") != 1 || !strings.Contains(content, "<priority>0.8</priority>") {
+		t.Fatalf("Expected only the non-default priority to be written:\n%s", content)
+	}
+}
+
+func TestIndexLastMod(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+	sm.MaxURLs = 2
+
+	sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-10"})
+	sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-03-05"})
+	sm.AddURL(SitemapURL{Loc: "/c", LastMod: "2023-12-31"})
+	sm.AddURL(SitemapURL{Loc: "/d", LastMod: "2023-11-01"})
+	sm.AddURL(SitemapURL{Loc: "/e", LastMod: "2022-01-01"})
+	sm.IndexLastMod = func(sitemapName string, urls []SitemapURL) string {
+		if sitemapName == "sitemap_3.xml" {
+			return "2024-05-01"
+		}
+		return ""
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap index: %v", err)
+	}
+	var index SitemapIndex
+	if err := xml.Unmarshal(data, &index); err != nil {
+		t.Fatalf("Error parsing sitemap index: %v", err)
+	}
+
+	expected := []string{"2024-03-05", "2023-12-31", "2024-05-01"}
+	if len(index.Sitemaps) != len(expected) {
+		t.Fatalf("Expected %d sitemaps in index, got %d", len(expected), len(index.Sitemaps))
+	}
+	for i, sitemap := range index.Sitemaps {
+		if sitemap.LastMod != expected[i] {
+			t.Fatalf("Sitemap %s: expected lastmod %s, got %s", sitemap.Loc, expected[i], sitemap.LastMod)
+		}
+	}
+}
+
+func TestShardDir(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.ShardDir = "sitemaps"
+	for i := 0; i < 3; i++ {
+		sm.AddURL(SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+
+	for _, file := range []string{"sitemap_index.xml", "sitemap.xsl", "sitemaps/sitemap_1.xml", "sitemaps/sitemap_2.xml", "sitemaps/sitemap.xsl"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			t.Fatalf("Expected %s to be written: %v", file, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap index: %v", err)
+	}
+	if !strings.Contains(string(data), "<loc>https://www.example.com/sitemaps/sitemap_1.xml</loc>") {
+		t.Fatalf("Index does not reference shards under the shard directory:\n%s", data)
+	}
+
+	// A separate shard base URL, e.g. a CDN host
+	sm.ShardBaseURL = "https://cdn.example.com/static/sitemaps/"
+	if err := sm.Write("https://www.example.com"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap index: %v", err)
+	}
+	if !strings.Contains(string(data), "<loc>https://cdn.example.com/static/sitemaps/sitemap_2.xml</loc>") {
+		t.Fatalf("Index does not use the shard base URL:\n%s", data)
+	}
+}
+
+func TestDefaultChangeFreqAndPriority(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com").
+		WithDefaultChangeFreq("weekly").
+		WithDefaultPriority(0.3)
+	sm.AddURL(SitemapURL{Loc: "/defaults"})
+	sm.AddURL(SitemapURL{Loc: "/explicit", ChangeFreq: "daily", Priority: "0.9"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urlSet URLSet
+	if err := xml.Unmarshal(data, &urlSet); err != nil {
+		t.Fatal(err)
+	}
+	got := urlSet.URLs
+	if got[0].ChangeFreq != "weekly" || got[0].Priority != "0.3" {
+		t.Fatalf("defaults not applied: %+v", got[0])
+	}
+	if got[1].ChangeFreq != "daily" || got[1].Priority != "0.9" {
+		t.Fatalf("explicit values overridden: %+v", got[1])
+	}
+}
@@ -0,0 +1,69 @@
+package sitemapparse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coffyg/sitemap"
+)
+
+// DryRun is what writing a SitemapOptions would change in a published set.
+type DryRun struct {
+	sitemap.Changes
+	URLs     int             // URLs the Write would publish
+	LiveURLs int             // URLs published now
+	Report   *sitemap.Report // report of the prepared URLs
+}
+
+// Diff prepares the URLs buffered in s as a Write would, without writing
+// anything, fetches the sitemap or index published at loc with f, or a
+// default Fetcher if nil, and returns what the Write would add, update and
+// remove there.
+func Diff(ctx context.Context, s *sitemap.SitemapOptions, f *Fetcher, loc string) (*DryRun, error) {
+	planned, report, err := s.DryRun()
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		f = &Fetcher{}
+	}
+	var live []sitemap.SitemapURL
+	err = f.Fetch(ctx, loc, func(entry Entry) error {
+		if entry.URL != nil {
+			live = append(live, *entry.URL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the published sitemaps: %v", err)
+	}
+	liveState := sitemap.NewState(live)
+	return &DryRun{
+		Changes:  planned.Changes(liveState),
+		URLs:     len(planned.URLs),
+		LiveURLs: len(liveState.URLs),
+		Report:   report,
+	}, nil
+}
+
+// Summary describes the changes in a few lines, listing up to max locs of
+// each kind.
+func (d *DryRun) Summary(max int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d URLs published, %d after the write: %d added, %d updated, %d removed, %d excluded\n",
+		d.LiveURLs, d.URLs, len(d.Added), len(d.Updated), len(d.Removed), len(d.Report.Excluded))
+	for _, kind := range []struct {
+		name string
+		locs []string
+	}{{"added", d.Added}, {"updated", d.Updated}, {"removed", d.Removed}} {
+		for i, loc := range kind.locs {
+			if i == max {
+				fmt.Fprintf(&b, "  ... %d more %s\n", len(kind.locs)-max, kind.name)
+				break
+			}
+			fmt.Fprintf(&b, "  %s %s\n", kind.name, loc)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package sitemapparse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/coffyg/sitemap"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	published := sitemap.NewSitemapOptions(dir, server.URL)
+	published.MaxURLs = 2
+	published.AddURLs([]sitemap.SitemapURL{
+		{Loc: "/a", LastMod: "2024-01-01"},
+		{Loc: "/b", LastMod: "2024-01-01", PageMap: &sitemap.PageMap{DataObjects: []sitemap.DataObject{{Type: "document", Attributes: []sitemap.Attribute{{Name: "title", Value: "B"}}}}}},
+		{Loc: "/c", LastMod: "2024-01-01"},
+	})
+	if err := published.Write(server.URL + "/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	sm := sitemap.NewSitemapOptions(t.TempDir(), server.URL)
+	sm.AddURLs([]sitemap.SitemapURL{
+		{Loc: "/a", LastMod: "2024-02-01"},
+		{Loc: "/b", LastMod: "2024-01-01", PageMap: &sitemap.PageMap{DataObjects: []sitemap.DataObject{{Type: "document", Attributes: []sitemap.Attribute{{Name: "title", Value: "B"}}}}}},
+		{Loc: "/d", LastMod: "2024-01-01"},
+	})
+	d, err := Diff(context.Background(), sm, nil, server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if d.URLs != 3 || d.LiveURLs != 3 {
+		t.Fatalf("Expected 3 URLs on both sides, got %d and %d", d.URLs, d.LiveURLs)
+	}
+	if !slices.Equal(d.Added, []string{server.URL + "/d"}) || !slices.Equal(d.Updated, []string{server.URL + "/a"}) || !slices.Equal(d.Removed, []string{server.URL + "/c"}) {
+		t.Fatalf("Unexpected changes: %+v", d.Changes)
+	}
+	summary := d.Summary(0)
+	if !strings.HasPrefix(summary, "3 URLs published, 3 after the write: 1 added, 1 updated, 1 removed, 0 excluded\n") || !strings.Contains(summary, "... 1 more added") {
+		t.Fatalf("Unexpected summary:\n%s", summary)
+	}
+}
@@ -0,0 +1,295 @@
+package sitemapparse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coffyg/sitemap"
+)
+
+const (
+	defaultFetchConcurrency = 4
+	defaultRetryDelay       = time.Second
+	defaultMaxDepth         = 3
+	// throttleChunk is the most bytes read at once under BytesPerSecond.
+	throttleChunk = 32 * 1024
+)
+
+// Fetcher downloads a sitemap or sitemap index, the indexes it nests and
+// their sitemaps, several at a time, streaming the entries of all of them
+// to one callback. Each download is decoded as it arrives, so memory does
+// not grow with the size of the site.
+type Fetcher struct {
+	// Client sends the requests; http.DefaultClient if nil.
+	Client *http.Client
+	// UserAgent, if set, is sent with every request.
+	UserAgent string
+	// Concurrency is the most downloads in flight, 4 if zero.
+	Concurrency int
+	// RateLimiter, if set, paces requests per host, see
+	// sitemap.NewRateLimiter.
+	RateLimiter sitemap.RateLimiter
+	// BytesPerSecond, if set, caps the bandwidth of all downloads
+	// together.
+	BytesPerSecond int64
+	// Retries is how many times a request failing with a network error,
+	// 429 or 5xx is retried. Retries wait RetryDelay, one second if zero,
+	// doubling each time, or as long as a Retry-After header asks. A
+	// download failing after its entries started streaming is not retried.
+	Retries    int
+	RetryDelay time.Duration
+	// MaxDepth is how deep indexes may nest below the first one, 3 if zero.
+	MaxDepth int
+}
+
+// Fetch downloads the sitemap or index at loc and calls fn with every url
+// entry of every sitemap it leads to, and with the sitemap entries of the
+// indexes. Entries of different sitemaps interleave; fn is called by one
+// goroutine at a time, with Entry.From set. Each sitemap is downloaded
+// once. The first error, from a download or from fn, stops the fetch and
+// is returned.
+func (f *Fetcher) Fetch(ctx context.Context, loc string, fn func(Entry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := f.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultFetchConcurrency
+	}
+	run := &fetchRun{
+		f:      f,
+		ctx:    ctx,
+		cancel: cancel,
+		fn:     fn,
+		slots:  make(chan struct{}, concurrency),
+		seen:   map[string]bool{loc: true},
+	}
+	if f.BytesPerSecond > 0 {
+		run.bandwidth = &bandwidth{perSecond: f.BytesPerSecond}
+	}
+	run.wg.Add(1)
+	go run.fetch(loc, 0)
+	run.wg.Wait()
+	return run.err
+}
+
+// fetchRun is the state of one Fetch.
+type fetchRun struct {
+	f         *Fetcher
+	ctx       context.Context
+	cancel    context.CancelFunc
+	fn        func(Entry) error
+	slots     chan struct{}
+	bandwidth *bandwidth
+	wg        sync.WaitGroup
+
+	mu   sync.Mutex // Guards seen and err, and serializes fn
+	seen map[string]bool
+	err  error
+}
+
+// fail records the first error and stops the run.
+func (r *fetchRun) fail(err error) {
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mu.Unlock()
+	r.cancel()
+}
+
+// fetch downloads the sitemap or index at loc, at the given depth below
+// the first, and fetches the sitemaps an index references on goroutines of
+// their own.
+func (r *fetchRun) fetch(loc string, depth int) {
+	defer r.wg.Done()
+	select {
+	case r.slots <- struct{}{}:
+	case <-r.ctx.Done():
+		return
+	}
+	children, err := r.stream(loc)
+	<-r.slots
+	if err != nil {
+		r.fail(err)
+		return
+	}
+
+	maxDepth := r.f.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+	if len(children) > 0 && depth == maxDepth {
+		r.fail(fmt.Errorf("%s: indexes nested more than %d deep", loc, maxDepth))
+		return
+	}
+	for _, child := range children {
+		r.mu.Lock()
+		seen := r.seen[child]
+		r.seen[child] = true
+		r.mu.Unlock()
+		if !seen {
+			r.wg.Add(1)
+			go r.fetch(child, depth+1)
+		}
+	}
+}
+
+// stream passes the entries of the download of loc to fn and returns the
+// sitemaps it references if it is an index.
+func (r *fetchRun) stream(loc string) ([]string, error) {
+	body, err := r.get(loc)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var reader io.Reader = body
+	if r.bandwidth != nil {
+		reader = &throttledReader{ctx: r.ctx, r: body, b: r.bandwidth}
+	}
+	dec, err := NewDecoder(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", loc, err)
+	}
+	var children []string
+	for {
+		entry, err := dec.Next()
+		if err == io.EOF {
+			return children, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", loc, err)
+		}
+		entry.From = loc
+		if entry.Sitemap != nil {
+			children = append(children, entry.Sitemap.Loc)
+		}
+		r.mu.Lock()
+		err = r.ctx.Err()
+		if err == nil {
+			err = r.fn(entry)
+		}
+		r.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// get returns the body of a successful GET of loc, retrying as configured.
+func (r *fetchRun) get(loc string) (io.ReadCloser, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sitemap URL '%s': %v", loc, err)
+	}
+	client := r.f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	delay := r.f.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		if r.f.RateLimiter != nil {
+			if err := r.f.RateLimiter.Wait(r.ctx, u.Host); err != nil {
+				return nil, err
+			}
+		}
+		req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, loc, nil)
+		if err != nil {
+			return nil, err
+		}
+		if r.f.UserAgent != "" {
+			req.Header.Set("User-Agent", r.f.UserAgent)
+		}
+		resp, err := client.Do(req)
+		retry := err != nil
+		if err == nil {
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return resp.Body, nil
+			}
+			resp.Body.Close()
+			err = fmt.Errorf("failed to fetch %s: %s", loc, resp.Status)
+			retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		} else {
+			err = fmt.Errorf("failed to fetch %s: %v", loc, err)
+		}
+		if !retry || attempt == r.f.Retries || r.ctx.Err() != nil {
+			return nil, err
+		}
+
+		wait := delay << attempt
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return nil, r.ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// bandwidth paces the bytes read by all downloads of a run.
+type bandwidth struct {
+	mu        sync.Mutex
+	perSecond int64
+	next      time.Time // when the bytes read so far are paid for
+}
+
+// wait blocks until n more bytes fit the bandwidth.
+func (b *bandwidth) wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(time.Duration(int64(n) * int64(time.Second) / b.perSecond))
+	wait := b.next.Sub(now)
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader reads from r within bandwidth b.
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	b   *bandwidth
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.b.wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,100 @@
+package sitemapparse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcher(t *testing.T) {
+	var (
+		inFlight, maxInFlight atomic.Int64
+		mu                    sync.Mutex
+		requests              = make(map[string]int)
+	)
+	server := httptest.NewUnstartedServer(nil)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		mu.Lock()
+		requests[r.URL.Path]++
+		attempt := requests[r.URL.Path]
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+
+		base := "http://" + r.Host
+		switch {
+		case r.URL.Path == "/sitemap_index.xml":
+			fmt.Fprintf(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]s/nested.xml</loc></sitemap>
+<sitemap><loc>%[1]s/sitemap_1.xml</loc></sitemap>
+<sitemap><loc>%[1]s/sitemap_1.xml</loc></sitemap>
+</sitemapindex>`, base)
+		case r.URL.Path == "/nested.xml":
+			fmt.Fprintf(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]s/sitemap_2.xml</loc></sitemap>
+<sitemap><loc>%[1]s/sitemap_3.xml</loc></sitemap>
+<sitemap><loc>%[1]s/sitemap_4.xml</loc></sitemap>
+</sitemapindex>`, base)
+		case r.URL.Path == "/sitemap_3.xml" && attempt == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.HasPrefix(r.URL.Path, "/sitemap_"):
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s%s/page</loc></url></urlset>`, base, strings.TrimSuffix(r.URL.Path, ".xml"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server.Start()
+	defer server.Close()
+
+	f := &Fetcher{Client: server.Client(), Concurrency: 2, Retries: 1, RetryDelay: time.Millisecond, BytesPerSecond: 1 << 20}
+	from := make(map[string]string)
+	sitemaps := 0
+	err := f.Fetch(context.Background(), server.URL+"/sitemap_index.xml", func(entry Entry) error {
+		if entry.Sitemap != nil {
+			sitemaps++
+			return nil
+		}
+		from[strings.TrimPrefix(entry.URL.Loc, server.URL)] = strings.TrimPrefix(entry.From, server.URL)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if sitemaps != 6 || len(from) != 4 || from["/sitemap_3/page"] != "/sitemap_3.xml" {
+		t.Fatalf("Expected 6 sitemap entries and 4 URLs, got %d and %v", sitemaps, from)
+	}
+	if requests["/sitemap_1.xml"] != 1 || requests["/sitemap_3.xml"] != 2 {
+		t.Fatalf("Expected sitemaps downloaded once and a 503 retried, got %v", requests)
+	}
+	if maxInFlight.Load() > 2 {
+		t.Fatalf("Expected at most 2 downloads at once, got %d", maxInFlight.Load())
+	}
+
+	f.MaxDepth = 1
+	if err := f.Fetch(context.Background(), server.URL+"/sitemap_index.xml", func(Entry) error { return nil }); err == nil {
+		t.Fatal("Expected an error for indexes nested too deeply")
+	}
+	f.MaxDepth = 0
+	stop := errors.New("stop")
+	if err := f.Fetch(context.Background(), server.URL+"/sitemap_index.xml", func(Entry) error { return stop }); err != stop {
+		t.Fatalf("Expected the callback's error, got %v", err)
+	}
+	f.Retries = 0
+	if err := f.Fetch(context.Background(), server.URL+"/missing.xml", func(Entry) error { return nil }); err == nil {
+		t.Fatal("Expected an error for a missing sitemap")
+	}
+}
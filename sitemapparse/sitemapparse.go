@@ -0,0 +1,286 @@
+// Package sitemapparse reads sitemaps and sitemap indexes as a stream of
+// entries, decoding one url or sitemap element at a time, so a 50MB shard
+// or a multi-gigabyte dump of concatenated sitemaps is read in constant
+// memory:
+//
+//	dec, err := sitemapparse.NewDecoder(f)
+//	if err != nil {
+//		return err
+//	}
+//	for {
+//		entry, err := dec.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		if entry.URL != nil {
+//			sm.AddURL(*entry.URL)
+//		}
+//	}
+//
+// Fetcher downloads a published set, nested indexes included, and Diff
+// compares it with what a Write would publish.
+package sitemapparse
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/coffyg/sitemap"
+)
+
+// Entry is one url element of a sitemap or sitemap element of an index.
+// Exactly one of URL and Sitemap is set.
+type Entry struct {
+	URL     *sitemap.SitemapURL
+	Sitemap *sitemap.Sitemap
+	From    string // loc of the sitemap holding the entry, set by Fetcher
+}
+
+// Decoder reads the entries of a sitemap, a sitemap index, or several of
+// them concatenated, gzipped or not, from a stream.
+type Decoder struct {
+	dec  *xml.Decoder
+	rc   io.ReadCloser
+	root string // local name of the open root element, if any
+}
+
+// NewDecoder returns a Decoder reading from r. Gzipped input, including
+// the concatenated members of a multi-part gzip file, is decompressed.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := bufio.NewReader(r)
+	var rc io.ReadCloser = io.NopCloser(br)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %v", err)
+		}
+		rc = zr
+	}
+	return &Decoder{dec: xml.NewDecoder(rc), rc: rc}, nil
+}
+
+// Next returns the next entry, or io.EOF once the input is exhausted.
+// Elements other than url and sitemap, and any the package does not model,
+// are skipped.
+func (d *Decoder) Next() (Entry, error) {
+	for {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			if d.root != "" {
+				return Entry{}, d.errorf("unexpected end of input inside <%s>", d.root)
+			}
+			d.rc.Close()
+			return Entry{}, io.EOF
+		}
+		if err != nil {
+			return Entry{}, d.errorf("%v", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if d.root == "" {
+				if tok.Name.Local != "urlset" && tok.Name.Local != "sitemapindex" {
+					return Entry{}, d.errorf("root element <%s> is neither urlset nor sitemapindex", tok.Name.Local)
+				}
+				d.root = tok.Name.Local
+				continue
+			}
+			switch {
+			case d.root == "urlset" && tok.Name.Local == "url":
+				var u urlElement
+				if err := d.dec.DecodeElement(&u, &tok); err != nil {
+					return Entry{}, d.errorf("%v", err)
+				}
+				return Entry{URL: u.sitemapURL()}, nil
+			case d.root == "sitemapindex" && tok.Name.Local == "sitemap":
+				var s sitemapElement
+				if err := d.dec.DecodeElement(&s, &tok); err != nil {
+					return Entry{}, d.errorf("%v", err)
+				}
+				return Entry{Sitemap: &sitemap.Sitemap{Loc: s.Loc, LastMod: s.LastMod}}, nil
+			}
+			if err := d.dec.Skip(); err != nil {
+				return Entry{}, d.errorf("%v", err)
+			}
+		case xml.EndElement:
+			// The root closed; a concatenated document may follow
+			d.root = ""
+		}
+	}
+}
+
+// errorf returns an error noting the input offset it occurred at.
+func (d *Decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("failed to parse sitemap at offset %d: %s", d.dec.InputOffset(), fmt.Sprintf(format, args...))
+}
+
+// Each calls fn with every entry read from r, stopping at the first error.
+func Each(r io.Reader, fn func(Entry) error) error {
+	d, err := NewDecoder(r)
+	if err != nil {
+		return err
+	}
+	for {
+		entry, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// sitemapElement is a sitemap element of an index.
+type sitemapElement struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// urlElement is a url element with the extensions the package models,
+// matched by namespace rather than prefix.
+type urlElement struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+	Alternates []struct {
+		Rel      string `xml:"rel,attr"`
+		Hreflang string `xml:"hreflang,attr"`
+		Media    string `xml:"media,attr"`
+		Href     string `xml:"href,attr"`
+	} `xml:"http://www.w3.org/1999/xhtml link"`
+	Images []struct {
+		Loc         string `xml:"http://www.google.com/schemas/sitemap-image/1.1 loc"`
+		Caption     string `xml:"http://www.google.com/schemas/sitemap-image/1.1 caption"`
+		GeoLocation string `xml:"http://www.google.com/schemas/sitemap-image/1.1 geo_location"`
+		Title       string `xml:"http://www.google.com/schemas/sitemap-image/1.1 title"`
+		License     string `xml:"http://www.google.com/schemas/sitemap-image/1.1 license"`
+	} `xml:"http://www.google.com/schemas/sitemap-image/1.1 image"`
+	Videos []struct {
+		ThumbnailLoc    string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 thumbnail_loc"`
+		Title           string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 title"`
+		Description     string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 description"`
+		ContentLoc      string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 content_loc"`
+		PlayerLoc       string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 player_loc"`
+		Duration        int      `xml:"http://www.google.com/schemas/sitemap-video/1.1 duration"`
+		ExpirationDate  string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 expiration_date"`
+		Rating          *float64 `xml:"http://www.google.com/schemas/sitemap-video/1.1 rating"`
+		ViewCount       *int64   `xml:"http://www.google.com/schemas/sitemap-video/1.1 view_count"`
+		PublicationDate string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 publication_date"`
+		Tags            []string `xml:"http://www.google.com/schemas/sitemap-video/1.1 tag"`
+		FamilyFriendly  string   `xml:"http://www.google.com/schemas/sitemap-video/1.1 family_friendly"`
+		Restriction     *struct {
+			Relationship string `xml:"relationship,attr"`
+			Countries    string `xml:",chardata"`
+		} `xml:"http://www.google.com/schemas/sitemap-video/1.1 restriction"`
+		Prices []struct {
+			Currency   string `xml:"currency,attr"`
+			Type       string `xml:"type,attr"`
+			Resolution string `xml:"resolution,attr"`
+			Value      string `xml:",chardata"`
+		} `xml:"http://www.google.com/schemas/sitemap-video/1.1 price"`
+		RequiresSubscription string `xml:"http://www.google.com/schemas/sitemap-video/1.1 requires_subscription"`
+		Uploader             *struct {
+			Info string `xml:"info,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"http://www.google.com/schemas/sitemap-video/1.1 uploader"`
+		Platform *struct {
+			Relationship string `xml:"relationship,attr"`
+			Platforms    string `xml:",chardata"`
+		} `xml:"http://www.google.com/schemas/sitemap-video/1.1 platform"`
+		Live string `xml:"http://www.google.com/schemas/sitemap-video/1.1 live"`
+	} `xml:"http://www.google.com/schemas/sitemap-video/1.1 video"`
+	News *struct {
+		Publication struct {
+			Name     string `xml:"http://www.google.com/schemas/sitemap-news/0.9 name"`
+			Language string `xml:"http://www.google.com/schemas/sitemap-news/0.9 language"`
+		} `xml:"http://www.google.com/schemas/sitemap-news/0.9 publication"`
+		PublicationDate string `xml:"http://www.google.com/schemas/sitemap-news/0.9 publication_date"`
+		Title           string `xml:"http://www.google.com/schemas/sitemap-news/0.9 title"`
+	} `xml:"http://www.google.com/schemas/sitemap-news/0.9 news"`
+	PageMap *struct {
+		DataObjects []struct {
+			Type       string `xml:"type,attr"`
+			ID         string `xml:"id,attr"`
+			Attributes []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"http://www.google.com/schemas/sitemap-pagemap/1.0 Attribute"`
+		} `xml:"http://www.google.com/schemas/sitemap-pagemap/1.0 DataObject"`
+	} `xml:"http://www.google.com/schemas/sitemap-pagemap/1.0 PageMap"`
+}
+
+// sitemapURL converts u to the type SitemapOptions.AddURL takes.
+func (u *urlElement) sitemapURL() *sitemap.SitemapURL {
+	su := &sitemap.SitemapURL{
+		Loc:        u.Loc,
+		LastMod:    u.LastMod,
+		ChangeFreq: u.ChangeFreq,
+		Priority:   u.Priority,
+	}
+	for _, alt := range u.Alternates {
+		su.Alternates = append(su.Alternates, sitemap.Alternate{Rel: alt.Rel, Hreflang: alt.Hreflang, Media: alt.Media, Href: alt.Href})
+	}
+	for _, img := range u.Images {
+		su.Images = append(su.Images, sitemap.Image{Loc: img.Loc, Caption: img.Caption, GeoLocation: img.GeoLocation, Title: img.Title, License: img.License})
+	}
+	for _, v := range u.Videos {
+		video := sitemap.Video{
+			ThumbnailLoc:         v.ThumbnailLoc,
+			Title:                v.Title,
+			Description:          v.Description,
+			ContentLoc:           v.ContentLoc,
+			PlayerLoc:            v.PlayerLoc,
+			Duration:             v.Duration,
+			ExpirationDate:       v.ExpirationDate,
+			Rating:               v.Rating,
+			ViewCount:            v.ViewCount,
+			PublicationDate:      v.PublicationDate,
+			Tags:                 v.Tags,
+			FamilyFriendly:       v.FamilyFriendly,
+			RequiresSubscription: v.RequiresSubscription,
+			Live:                 v.Live,
+		}
+		if v.Restriction != nil {
+			video.Restriction = &sitemap.VideoRestriction{Relationship: v.Restriction.Relationship, Countries: v.Restriction.Countries}
+		}
+		for _, price := range v.Prices {
+			video.Prices = append(video.Prices, sitemap.VideoPrice{Currency: price.Currency, Type: price.Type, Resolution: price.Resolution, Value: price.Value})
+		}
+		if v.Uploader != nil {
+			video.Uploader = &sitemap.VideoUploader{Info: v.Uploader.Info, Name: v.Uploader.Name}
+		}
+		if v.Platform != nil {
+			video.Platform = &sitemap.VideoPlatform{Relationship: v.Platform.Relationship, Platforms: v.Platform.Platforms}
+		}
+		su.Videos = append(su.Videos, video)
+	}
+	if u.News != nil {
+		su.News = &sitemap.News{
+			Publication:     sitemap.NewsPublication{Name: u.News.Publication.Name, Language: u.News.Publication.Language},
+			PublicationDate: u.News.PublicationDate,
+			Title:           u.News.Title,
+		}
+	}
+	if u.PageMap != nil {
+		su.PageMap = &sitemap.PageMap{}
+		for _, obj := range u.PageMap.DataObjects {
+			do := sitemap.DataObject{Type: obj.Type, ID: obj.ID}
+			for _, attr := range obj.Attributes {
+				do.Attributes = append(do.Attributes, sitemap.Attribute{Name: attr.Name, Value: attr.Value})
+			}
+			su.PageMap.DataObjects = append(su.PageMap.DataObjects, do)
+		}
+	}
+	return su
+}
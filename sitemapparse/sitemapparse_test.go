@@ -0,0 +1,142 @@
+package sitemapparse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffyg/sitemap"
+)
+
+func TestDecoder(t *testing.T) {
+	dir := t.TempDir()
+	sm := sitemap.NewSitemapOptions(dir, "https://www.example.com")
+	sm.Now = func() time.Time { return time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC) }
+	sm.AddURL(sitemap.SitemapURL{
+		Loc:        "/a",
+		LastMod:    "2024-01-02",
+		Alternates: []sitemap.Alternate{{Hreflang: "de", Href: "/de/a"}},
+		Images:     []sitemap.Image{{Loc: "https://www.example.com/a.jpg", Title: "A"}},
+	})
+	sm.AddURL(sitemap.SitemapURL{Loc: "/b", News: &sitemap.News{
+		Publication:     sitemap.NewsPublication{Name: "Example", Language: "en"},
+		PublicationDate: "2024-01-02",
+		Title:           "B",
+	}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sitemaps []string
+	if err := Each(bytes.NewReader(data), func(entry Entry) error {
+		sitemaps = append(sitemaps, entry.Sitemap.Loc)
+		return nil
+	}); err != nil || len(sitemaps) != 2 {
+		t.Fatalf("Expected the 2 sitemaps of the index, got %v: %v", sitemaps, err)
+	}
+
+	// Two sitemaps concatenated, each a gzip member of its own
+	var dump bytes.Buffer
+	var urls []*sitemap.SitemapURL
+	for _, name := range []string{"sitemap_1.xml", "sitemap_news.xml"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := gzip.NewWriter(&dump)
+		zw.Write(data)
+		zw.Close()
+	}
+	dec, err := NewDecoder(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		entry, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		urls = append(urls, entry.URL)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("Expected 2 URLs, got %d", len(urls))
+	}
+	a, b := urls[0], urls[1]
+	if a.Loc != "https://www.example.com/a" || a.LastMod != "2024-01-02" ||
+		len(a.Alternates) != 1 || a.Alternates[0].Href != "https://www.example.com/de/a" ||
+		len(a.Images) != 1 || a.Images[0].Title != "A" {
+		t.Fatalf("Unexpected first URL %+v", a)
+	}
+	if b.News == nil || b.News.Publication.Name != "Example" || b.News.Title != "B" {
+		t.Fatalf("Unexpected news URL %+v", b)
+	}
+
+	for name, input := range map[string]string{
+		"root":      `<rss></rss>`,
+		"truncated": `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://www.example.com/</loc></url>`,
+		"malformed": `<urlset><url><loc>x</url></urlset>`,
+	} {
+		err := Each(strings.NewReader(input), func(Entry) error { return nil })
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDecoderVideoRoundTrip(t *testing.T) {
+	rating, views := 4.2, int64(12345)
+	video := sitemap.Video{
+		ThumbnailLoc:         "https://www.example.com/v.jpg",
+		Title:                "Video",
+		Description:          "A video",
+		ContentLoc:           "https://www.example.com/v.mp4",
+		PlayerLoc:            "https://www.example.com/player?v=1",
+		Duration:             600,
+		ExpirationDate:       "2030-01-01T00:00:00+00:00",
+		Rating:               &rating,
+		ViewCount:            &views,
+		PublicationDate:      "2024-01-01T00:00:00+00:00",
+		Tags:                 []string{"cooking", "baking"},
+		FamilyFriendly:       "yes",
+		Restriction:          &sitemap.VideoRestriction{Relationship: "allow", Countries: "FR DE"},
+		Prices:               []sitemap.VideoPrice{{Currency: "EUR", Type: "rent", Resolution: "hd", Value: "1.99"}, {Currency: "USD", Value: "2.99"}},
+		RequiresSubscription: "no",
+		Uploader:             &sitemap.VideoUploader{Info: "https://www.example.com/ann", Name: "Ann"},
+		Platform:             &sitemap.VideoPlatform{Relationship: "deny", Platforms: "tv"},
+		Live:                 "no",
+	}
+	dir := t.TempDir()
+	sm := sitemap.NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(sitemap.SitemapURL{Loc: "/v", Videos: []sitemap.Video{video}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	f, err := os.Open(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec, err := NewDecoder(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := dec.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if len(entry.URL.Videos) != 1 || !reflect.DeepEqual(entry.URL.Videos[0], video) {
+		t.Fatalf("Expected the video to round-trip, got %+v", entry.URL.Videos)
+	}
+}
@@ -0,0 +1,292 @@
+// Package sitemaptest provides helpers for testing code that generates
+// sitemaps with github.com/coffyg/sitemap.
+package sitemaptest
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/coffyg/sitemap"
+)
+
+// ParseURLSet reads and decodes a single sitemap file, gzipped or not.
+func ParseURLSet(filePath string) (*sitemap.URLSet, error) {
+	urlSet, err := sitemap.LoadURLSet(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %v", filePath, err)
+	}
+	return urlSet, nil
+}
+
+// ParseIndex reads and decodes a sitemap index file, gzipped or not.
+func ParseIndex(filePath string) (*sitemap.SitemapIndex, error) {
+	index, err := sitemap.LoadSitemapIndex(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %v", filePath, err)
+	}
+	return index, nil
+}
+
+// ParseDir collects every URL written to dir. If a sitemap index is present,
+// the sitemap files it and its nested sitemap_index_N.xml indexes reference
+// are read in index order; otherwise the single sitemap.xml is read. Each
+// file is looked up in dir or in the subdirectory its loc path ends with,
+// such as dir/shards for .../shards/sitemap_1.xml, so copies elsewhere, as
+// in an ArchiveDir, are never read. Gzipped .xml.gz files are read as well.
+// Use ParseSet for a set written with ShardBaseURL or PublicNames.
+func ParseDir(dir string) ([]sitemap.SitemapURL, error) {
+	return parseSet(dir, func(sitemapURL *url.URL) (string, error) {
+		return findFile(dir, sitemapURL.Path)
+	})
+}
+
+// ParseSet collects every URL of the set written with s, as ParseDir does,
+// looking the files up only in s.Dir and its ShardDir under the names
+// PublicNames maps them from. ExternalSitemaps are skipped.
+func ParseSet(s *sitemap.SitemapOptions) ([]sitemap.SitemapURL, error) {
+	external := make(map[string]bool, len(s.ExternalSitemaps))
+	for _, sm := range s.ExternalSitemaps {
+		external[strings.TrimSpace(sm.Loc)] = true
+	}
+	return parseSet(s.Dir, func(sitemapURL *url.URL) (string, error) {
+		if external[sitemapURL.String()] {
+			return "", nil
+		}
+		name := localName(s.PublicNames, path.Base(sitemapURL.Path))
+		for _, dir := range []string{s.Dir, filepath.Join(s.Dir, s.ShardDir)} {
+			if filePath, ok := existingFile(dir, name); ok {
+				return filePath, nil
+			}
+		}
+		return "", fmt.Errorf("sitemap file '%s' not found in %s", name, s.Dir)
+	})
+}
+
+// parseSet collects the URLs of the set in dir, finding the file of each
+// sitemap referenced by an index with lookup. Sitemaps for which lookup
+// returns no path are skipped.
+func parseSet(dir string, lookup func(*url.URL) (string, error)) ([]sitemap.SitemapURL, error) {
+	indexPath, ok := existingFile(dir, "sitemap_index.xml")
+	if !ok {
+		sitemapPath, ok := existingFile(dir, "sitemap.xml")
+		if !ok {
+			sitemapPath = filepath.Join(dir, "sitemap.xml")
+		}
+		urlSet, err := ParseURLSet(sitemapPath)
+		if err != nil {
+			return nil, err
+		}
+		return urlSet.URLs, nil
+	}
+	return parseIndexFiles(indexPath, lookup, 0)
+}
+
+// parseIndexFiles collects the URLs of the sitemaps referenced by the index
+// at indexPath, following nested indexes up to one level deep.
+func parseIndexFiles(indexPath string, lookup func(*url.URL) (string, error), depth int) ([]sitemap.SitemapURL, error) {
+	index, err := ParseIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	var urls []sitemap.SitemapURL
+	for _, sm := range index.Sitemaps {
+		sitemapURL, err := url.Parse(sm.Loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sitemap URL '%s': %v", sm.Loc, err)
+		}
+		filePath, err := lookup(sitemapURL)
+		if err != nil {
+			return nil, err
+		}
+		if filePath == "" {
+			continue
+		}
+		if strings.HasPrefix(filepath.Base(filePath), "sitemap_index_") && depth == 0 {
+			nested, err := parseIndexFiles(filePath, lookup, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			urls = append(urls, nested...)
+			continue
+		}
+		urlSet, err := ParseURLSet(filePath)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, urlSet.URLs...)
+	}
+	return urls, nil
+}
+
+// findFile returns the path of the file a loc path such as
+// /shards/sitemap_1.xml names, with or without a .gz suffix, in dir or in
+// the subdirectory the trailing segments of the loc directory name.
+func findFile(dir string, locPath string) (string, error) {
+	name := path.Base(locPath)
+	segments := strings.Split(strings.Trim(path.Dir(locPath), "/"), "/")
+	for i := len(segments); i >= 0; i-- {
+		sub := filepath.Join(append([]string{dir}, segments[i:]...)...)
+		if filePath, ok := existingFile(sub, name); ok {
+			return filePath, nil
+		}
+	}
+	return "", fmt.Errorf("sitemap file '%s' not found in %s", name, dir)
+}
+
+// localName returns the local file name of the sitemap referenced as name,
+// undoing names as given in PublicNames.
+func localName(names map[string]string, name string) string {
+	plain, gz := strings.CutSuffix(name, ".gz")
+	for local, public := range names {
+		if public == plain {
+			plain = local
+			break
+		}
+	}
+	if gz {
+		return plain + ".gz"
+	}
+	return plain
+}
+
+// existingFile returns the path of the file called name, or name with a
+// .gz suffix, directly in dir.
+func existingFile(dir string, name string) (string, bool) {
+	for _, candidate := range []string{name, name + ".gz"} {
+		filePath := filepath.Join(dir, candidate)
+		if _, err := os.Stat(filePath); err == nil {
+			return filePath, true
+		}
+	}
+	return "", false
+}
+
+// Locs returns the loc of every URL written to dir, in output order.
+// It fails the test if the output cannot be parsed.
+func Locs(t testing.TB, dir string) []string {
+	t.Helper()
+	urls, err := ParseDir(dir)
+	if err != nil {
+		t.Fatalf("Error parsing sitemaps in %s: %v", dir, err)
+	}
+	locs := make([]string, len(urls))
+	for i, u := range urls {
+		locs[i] = u.Loc
+	}
+	return locs
+}
+
+// AssertContains fails the test unless every given loc is present in the
+// sitemaps written to dir.
+func AssertContains(t testing.TB, dir string, locs ...string) {
+	t.Helper()
+	present := toSet(Locs(t, dir))
+	for _, loc := range locs {
+		if !present[loc] {
+			t.Errorf("Sitemap does not contain %s", loc)
+		}
+	}
+}
+
+// AssertNotContains fails the test if any given loc is present in the
+// sitemaps written to dir.
+func AssertNotContains(t testing.TB, dir string, locs ...string) {
+	t.Helper()
+	present := toSet(Locs(t, dir))
+	for _, loc := range locs {
+		if present[loc] {
+			t.Errorf("Sitemap unexpectedly contains %s", loc)
+		}
+	}
+}
+
+// AssertLocs fails the test unless the sitemaps written to dir contain
+// exactly the given set of locs, ignoring order.
+func AssertLocs(t testing.TB, dir string, want []string) {
+	t.Helper()
+	got := Locs(t, dir)
+	missing, extra := diffSets(want, got)
+	for _, loc := range missing {
+		t.Errorf("Sitemap is missing %s", loc)
+	}
+	for _, loc := range extra {
+		t.Errorf("Sitemap has unexpected %s", loc)
+	}
+}
+
+// AssertGolden compares the set of locs written to dir against a golden file
+// containing one loc per line. Blank lines and lines starting with '#' are
+// ignored.
+func AssertGolden(t testing.TB, dir string, goldenPath string) {
+	t.Helper()
+	want, err := readGolden(goldenPath)
+	if err != nil {
+		t.Fatalf("Error reading golden file %s: %v", goldenPath, err)
+	}
+	AssertLocs(t, dir, want)
+}
+
+// WriteGolden writes the sorted set of locs written to dir to goldenPath in
+// the format read by AssertGolden.
+func WriteGolden(t testing.TB, dir string, goldenPath string) {
+	t.Helper()
+	locs := Locs(t, dir)
+	sort.Strings(locs)
+	if err := os.WriteFile(goldenPath, []byte(strings.Join(locs, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Error writing golden file %s: %v", goldenPath, err)
+	}
+}
+
+func readGolden(goldenPath string) ([]string, error) {
+	f, err := os.Open(goldenPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var locs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		locs = append(locs, line)
+	}
+	return locs, scanner.Err()
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// diffSets returns the items of want missing from got and the items of got
+// not in want, both sorted.
+func diffSets(want, got []string) (missing, extra []string) {
+	wantSet := toSet(want)
+	gotSet := toSet(got)
+	for item := range wantSet {
+		if !gotSet[item] {
+			missing = append(missing, item)
+		}
+	}
+	for item := range gotSet {
+		if !wantSet[item] {
+			extra = append(extra, item)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
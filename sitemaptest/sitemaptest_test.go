@@ -0,0 +1,126 @@
+package sitemaptest
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/coffyg/sitemap"
+)
+
+func TestHelpers(t *testing.T) {
+	dir := t.TempDir()
+	sm := sitemap.NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	for i := 0; i < 5; i++ {
+		sm.AddURL(sitemap.SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+
+	if locs := Locs(t, dir); len(locs) != 5 {
+		t.Fatalf("Expected 5 locs across shards, got %d", len(locs))
+	}
+	AssertContains(t, dir, "https://www.example.com/page/0", "https://www.example.com/page/4")
+	AssertNotContains(t, dir, "https://www.example.com/page/5")
+
+	golden := filepath.Join(t.TempDir(), "golden.txt")
+	WriteGolden(t, dir, golden)
+	AssertGolden(t, dir, golden)
+}
+
+func TestGzippedOutput(t *testing.T) {
+	dir := t.TempDir()
+	sm := sitemap.NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	for i := 0; i < 3; i++ {
+		sm.AddURL(sitemap.SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+
+	// Replace every file with its gzipped version
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(name + ".gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := gzip.NewWriter(f)
+		w.Write(data)
+		w.Close()
+		f.Close()
+		os.Remove(name)
+	}
+
+	AssertLocs(t, dir, []string{
+		"https://www.example.com/page/0",
+		"https://www.example.com/page/1",
+		"https://www.example.com/page/2",
+	})
+}
+
+func TestDiffSets(t *testing.T) {
+	missing, extra := diffSets([]string{"a", "b"}, []string{"b", "c"})
+	if len(missing) != 1 || missing[0] != "a" {
+		t.Fatalf("Unexpected missing items: %v", missing)
+	}
+	if len(extra) != 1 || extra[0] != "c" {
+		t.Fatalf("Unexpected extra items: %v", extra)
+	}
+}
+
+func TestParseNestedSet(t *testing.T) {
+	dir := t.TempDir()
+	sm := sitemap.NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 1
+	sm.MaxIndexEntries = 2
+	sm.NestedIndexes = true
+	sm.ShardDir = "shards"
+	sm.ArchiveDir = "archive"
+	sm.PublicNames = map[string]string{"sitemap_1.xml": "first.xml"}
+	for i := 0; i < 4; i++ {
+		sm.AddURL(sitemap.SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+
+	// A second, smaller run leaves its predecessor in ArchiveDir
+	sm.Reset()
+	sm.PublicNames = map[string]string{"sitemap_1.xml": "first.xml"}
+	for i := 0; i < 3; i++ {
+		sm.AddURL(sitemap.SitemapURL{Loc: "/page/" + strconv.Itoa(i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+	urls, err := ParseSet(sm)
+	if err != nil {
+		t.Fatalf("Error parsing sitemaps: %v", err)
+	}
+	if len(urls) != 3 || urls[0].Loc != "https://www.example.com/page/0" {
+		t.Fatalf("Expected the 3 URLs of the nested set, got %+v", urls)
+	}
+
+	sm.PublicNames = nil
+	sm.Reset()
+	sm.AddURL(sitemap.SitemapURL{Loc: "/page/0"})
+	sm.AddURL(sitemap.SitemapURL{Loc: "/page/1"})
+	sm.AddURL(sitemap.SitemapURL{Loc: "/page/2"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemaps: %v", err)
+	}
+	AssertLocs(t, dir, []string{"https://www.example.com/page/0", "https://www.example.com/page/1", "https://www.example.com/page/2"})
+}
@@ -0,0 +1,48 @@
+package sitemap
+
+import "strings"
+
+// Skipped summarizes the URLs dropped by the last successful Write, so that
+// a drop in data quality shows up in numbers instead of going unnoticed.
+type Skipped struct {
+	Total int
+	// ByReason counts the dropped URLs by kind of reason, see skipReason.
+	ByReason map[string]int
+	// Examples holds, by kind of reason, the first SkippedExamples URLs
+	// dropped for it.
+	Examples map[string][]ExcludedURL
+}
+
+// skipReason returns the kind of the reason a URL was excluded for, which
+// is the reason without its detail: "duplicate", "expired", "robots.txt",
+// "include", "exclude", "rejected", "over budget" or "invalid characters in
+// loc" and the like.
+func skipReason(reason string) string {
+	kind, _, _ := strings.Cut(reason, ": ")
+	return kind
+}
+
+// Skipped returns the URLs dropped by the last successful Write counted by
+// reason, with examples if SkippedExamples is set, or nil if Write has not
+// succeeded yet. The URLs themselves are listed in Report.Excluded.
+func (s *SitemapOptions) Skipped() *Skipped {
+	if s.report == nil {
+		return nil
+	}
+	skipped := &Skipped{
+		Total:    len(s.report.Excluded),
+		ByReason: make(map[string]int),
+	}
+	for _, excluded := range s.report.Excluded {
+		reason := skipReason(excluded.Reason)
+		skipped.ByReason[reason]++
+		if skipped.ByReason[reason] > s.SkippedExamples {
+			continue
+		}
+		if skipped.Examples == nil {
+			skipped.Examples = make(map[string][]ExcludedURL)
+		}
+		skipped.Examples[reason] = append(skipped.Examples[reason], excluded)
+	}
+	return skipped
+}
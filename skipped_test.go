@@ -0,0 +1,33 @@
+package sitemap
+
+import "testing"
+
+func TestSkipped(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.Exclude = []string{"/admin/*"}
+	sm.MaxTotalURLs = 3
+	sm.Overflow = OverflowLowestPriority
+	sm.SkippedExamples = 1
+	if sm.Skipped() != nil {
+		t.Fatalf("Expected no summary before Write")
+	}
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/a", Priority: "0.9"},
+		{Loc: "/a"},
+		{Loc: "/a"},
+		{Loc: "/admin/users"},
+		{Loc: "/b", Priority: "0.8"},
+		{Loc: "/c", Priority: "0.7"},
+		{Loc: "/d", Priority: "0.1"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	skipped := sm.Skipped()
+	if skipped.Total != 4 || skipped.ByReason["duplicate"] != 2 || skipped.ByReason["exclude"] != 1 || skipped.ByReason[overBudget] != 1 {
+		t.Fatalf("Unexpected counts: %+v", skipped)
+	}
+	if len(skipped.Examples["duplicate"]) != 1 || skipped.Examples[overBudget][0].Loc != "https://www.example.com/d" {
+		t.Fatalf("Unexpected examples: %+v", skipped.Examples)
+	}
+}
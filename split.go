@@ -0,0 +1,226 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// SplitSitemap reads an existing sitemap from r, gzipped or not, and writes
+// it to Dir as sitemap_index.xml plus shards that respect MaxURLs and
+// MaxFileSize. Every url element is copied verbatim and the namespaces
+// declared on the original urlset are repeated on each shard, so extension
+// data the package does not model is preserved. BaseURL and the URL filters
+// are not applied. With Gzip, the shards are compressed as Write stores them.
+//
+// Like Write, SplitSitemap claims Dir for the run and restores the previous
+// files if it fails, and it removes the shards of the previous index that
+// the new one no longer references, so a split into fewer shards leaves no
+// stale files behind.
+func (s *SitemapOptions) SplitSitemap(r io.Reader, baseSitemapURL string) error {
+	if _, err := parseBaseURL(baseSitemapURL); err != nil {
+		return fmt.Errorf("invalid base sitemap URL: %w", err)
+	}
+	data, err := readMaybeGzip(r)
+	if err != nil {
+		return err
+	}
+	root, entries, err := splitURLElements(data)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("sitemap contains no URLs")
+	}
+
+	shardBaseURL, err := s.shardBaseURL(baseSitemapURL)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	previous, err := s.previousShards()
+	if err != nil {
+		return err
+	}
+	return s.runTx(&writeTx{}, func() error {
+		return s.writeSplit(baseSitemapURL, shardBaseURL, root, entries, previous)
+	})
+}
+
+// previousShards returns the uncompressed paths of the sitemap files the
+// index left in Dir by an earlier run references, or nil if there is none.
+func (s *SitemapOptions) previousShards() ([]string, error) {
+	indexPath := filepath.Join(s.Dir, "sitemap_index.xml")
+	if existing, err := staleSitemaps(indexPath); err != nil || len(existing) == 0 {
+		return nil, err
+	}
+	files, err := s.indexedFiles(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the previous sitemap index: %v", err)
+	}
+	var shards []string
+	for _, filePath := range files {
+		if !isNestedIndex(filepath.Base(filePath)) {
+			shards = append(shards, filePath)
+		}
+	}
+	return shards, nil
+}
+
+// writeSplit writes the shards holding entries and the index referencing
+// them, removing the previous shards that are not rewritten.
+func (s *SitemapOptions) writeSplit(baseSitemapURL, shardBaseURL string, root []byte, entries [][]byte, previous []string) error {
+	if err := s.tx.mkdirAll(s.shardDir()); err != nil {
+		return err
+	}
+	if err := s.writeStylesheet(); err != nil {
+		return err
+	}
+
+	// Room left in a shard once the header, root and closing tags are counted
+	separator := s.lineBreak() + s.indent()
+	closing := s.lineBreak() + "</urlset>"
+	overhead := s.fileHeader(s.MaxURLs).Len() + len(root) + len(closing)
+
+	var sitemaps []Sitemap
+	written := make(map[string]bool)
+	writeShard := func(batch [][]byte) error {
+		name := fmt.Sprintf("sitemap_%d%s", len(sitemaps)+1, sitemapExt)
+		written[filepath.Join(s.shardDir(), name)] = true
+		buffer := s.fileHeader(len(batch))
+		buffer.Write(root)
+		urls := make([]SitemapURL, 0, len(batch))
+		for _, raw := range batch {
+			buffer.WriteString(separator)
+			buffer.Write(raw)
+			var u SitemapURL
+			if err := xml.Unmarshal(raw, &u); err != nil {
+				return err
+			}
+			urls = append(urls, u)
+		}
+		buffer.WriteString(closing)
+		if err := s.writeXMLFile(filepath.Join(s.shardDir(), name), buffer.Bytes()); err != nil {
+			return err
+		}
+		loc, err := s.resolveSitemapURL(shardBaseURL, s.sitemapFileName(name))
+		if err != nil {
+			return err
+		}
+		sitemaps = append(sitemaps, Sitemap{Loc: loc, LastMod: s.sitemapLastMod(name, urls)})
+		return nil
+	}
+
+	var batch [][]byte
+	size := overhead
+	for _, raw := range entries {
+		entrySize := len(raw) + len(separator)
+		if overhead+entrySize > s.maxFileSize() {
+			return errorf(ErrShardTooLarge, "url element of %d bytes exceeds the maximum file size", len(raw))
+		}
+		if len(batch) > 0 && (len(batch) == s.MaxURLs || size+entrySize > s.maxFileSize()) {
+			if err := writeShard(batch); err != nil {
+				return err
+			}
+			batch, size = nil, overhead
+		}
+		batch = append(batch, raw)
+		size += entrySize
+	}
+	if err := writeShard(batch); err != nil {
+		return err
+	}
+	for _, filePath := range previous {
+		if written[filePath] {
+			continue
+		}
+		for _, variant := range []string{filePath, filePath + gzipExt} {
+			if err := s.tx.removeFile(variant); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.removeOtherRoot(false); err != nil {
+		return err
+	}
+
+	if err := s.writeIndexFile(baseSitemapURL, sitemaps); err != nil {
+		return err
+	}
+	return s.validateSitemapIndexAndFiles()
+}
+
+// readMaybeGzip reads all of r, decompressing it if it is gzipped.
+func readMaybeGzip(r io.Reader) ([]byte, error) {
+	rc, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// maybeGunzip returns a reader of the decompressed content of r if it is
+// gzipped, or of r itself otherwise.
+func maybeGunzip(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return io.NopCloser(br), nil
+}
+
+// splitURLElements returns the raw urlset start tag of a sitemap and the
+// raw bytes of each of its url elements.
+func splitURLElements(data []byte) (root []byte, entries [][]byte, err error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse sitemap: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				if t.Name.Local != "urlset" {
+					return nil, nil, fmt.Errorf("expected a urlset, found <%s>", t.Name.Local)
+				}
+				root = data[offset:dec.InputOffset()]
+				if bytes.HasSuffix(root, []byte("/>")) {
+					// An empty self-closing urlset has no URLs
+					return nil, nil, nil
+				}
+				continue
+			}
+			if depth == 2 && t.Name.Local == "url" {
+				if err := dec.Skip(); err != nil {
+					return nil, nil, fmt.Errorf("failed to parse sitemap: %v", err)
+				}
+				entries = append(entries, data[offset:dec.InputOffset()])
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if root == nil {
+		return nil, nil, fmt.Errorf("sitemap has no urlset element")
+	}
+	return root, entries, nil
+}
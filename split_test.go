@@ -0,0 +1,152 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func externalSitemap(n int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">` + "\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "<url><loc>https://shop.example.com/p/%d</loc><lastmod>2024-03-0%d</lastmod>"+
+			"<image:image><image:loc>https://shop.example.com/p/%d.jpg</image:loc></image:image></url>\n", i, i%9+1, i)
+	}
+	b.WriteString("</urlset>\n")
+	return b.String()
+}
+
+func TestSplitSitemap(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://shop.example.com")
+	sm.MaxURLs = 4
+	if err := sm.SplitSitemap(strings.NewReader(externalSitemap(10)), "https://shop.example.com/sitemaps/"); err != nil {
+		t.Fatalf("SplitSitemap: %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(index), "<sitemap>"); got != 3 {
+		t.Fatalf("index references %d sitemaps, want 3:\n%s", got, index)
+	}
+	if !strings.Contains(string(index), "<lastmod>2024-03-04</lastmod>") {
+		t.Fatalf("index lastmod not taken from the shard:\n%s", index)
+	}
+
+	shard, err := os.ReadFile(filepath.Join(dir, "sitemap_3.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"`,
+		"<image:loc>https://shop.example.com/p/9.jpg</image:loc>",
+	} {
+		if !strings.Contains(string(shard), want) {
+			t.Fatalf("shard missing %s:\n%s", want, shard)
+		}
+	}
+	if got := strings.Count(string(shard), "<url>"); got != 2 {
+		t.Fatalf("last shard has %d URLs, want 2", got)
+	}
+}
+
+func TestSplitSitemapBySizeAndGzip(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(externalSitemap(6)))
+	w.Close()
+
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://shop.example.com")
+	sm.MaxFileSize = 800
+	if err := sm.SplitSitemap(&gz, "https://shop.example.com/"); err != nil {
+		t.Fatalf("SplitSitemap: %v", err)
+	}
+	shards, err := filepath.Glob(filepath.Join(dir, "sitemap_*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) < 3 {
+		t.Fatalf("expected the size limit to produce several shards, got %v", shards)
+	}
+	for _, name := range shards {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(name) != "sitemap_index.xml" && info.Size() > 800 {
+			t.Fatalf("%s is %d bytes", name, info.Size())
+		}
+	}
+
+	if err := sm.SplitSitemap(strings.NewReader("<sitemapindex></sitemapindex>"), "https://shop.example.com/"); err == nil {
+		t.Fatal("expected an error for a sitemap index")
+	}
+}
+
+func TestSplitSitemapGzipShards(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://shop.example.com")
+	sm.MaxURLs = 4
+	sm.Gzip = true
+	if err := sm.SplitSitemap(strings.NewReader(externalSitemap(10)), "https://shop.example.com/"); err != nil {
+		t.Fatalf("SplitSitemap: %v", err)
+	}
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Sitemaps) != 3 || index.Sitemaps[0].Loc != "https://shop.example.com/sitemap_1.xml.gz" {
+		t.Fatalf("expected the index to reference the gzipped shards, got %+v", index.Sitemaps)
+	}
+	for _, sitemap := range index.Sitemaps {
+		urlSet, err := LoadURLSet(filepath.Join(dir, filepath.Base(sitemap.Loc)))
+		if err != nil {
+			t.Fatalf("reading %s: %v", sitemap.Loc, err)
+		}
+		if len(urlSet.URLs) == 0 {
+			t.Fatalf("%s has no URLs", sitemap.Loc)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap_1.xml")); !os.IsNotExist(err) {
+		t.Fatalf("expected no plain shard without KeepPlain, got %v", err)
+	}
+}
+
+func TestSplitSitemapRemovesLeftoverShards(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://shop.example.com")
+	sm.MaxURLs = 4
+	if err := sm.SplitSitemap(strings.NewReader(externalSitemap(10)), "https://shop.example.com/sitemaps/"); err != nil {
+		t.Fatalf("SplitSitemap: %v", err)
+	}
+	if err := sm.SplitSitemap(strings.NewReader(externalSitemap(3)), "https://shop.example.com/sitemaps/"); err != nil {
+		t.Fatalf("SplitSitemap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sitemap_1.xml")); err != nil {
+		t.Fatalf("shard missing: %v", err)
+	}
+	for _, name := range []string{"sitemap_2.xml", "sitemap_3.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s left over from the earlier split", name)
+		}
+	}
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if err := sm.SplitSitemap(strings.NewReader(externalSitemap(3)), "https://shop.example.com/sitemaps/"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("SplitSitemap on a locked Dir: got %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,75 @@
+package sitemap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Querier is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// AddRows adds one URL per row of rows, using scan to map the current row to
+// a SitemapURL. rows is closed before AddRows returns.
+func (s *SitemapOptions) AddRows(rows *sql.Rows, scan func(rows *sql.Rows) (SitemapURL, error)) error {
+	defer rows.Close()
+	for rows.Next() {
+		url, err := scan(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan sitemap row: %v", err)
+		}
+		s.AddURL(url)
+	}
+	return rows.Err()
+}
+
+// KeysetQuery describes a keyset-paginated query used by AddKeysetQuery.
+// Query receives the last key seen and the page size as its two arguments,
+// for example:
+//
+//	SELECT id, slug, updated_at FROM posts WHERE id > $1 ORDER BY id LIMIT $2
+type KeysetQuery struct {
+	Query    string
+	Start    any // Key passed for the first page, e.g. 0
+	PageSize int
+	// Scan maps the current row to a SitemapURL and returns the row's key,
+	// which is passed to the query for the next page.
+	Scan func(rows *sql.Rows) (SitemapURL, any, error)
+}
+
+// AddKeysetQuery repeatedly executes q against db, one page at a time, and
+// adds a URL per returned row until a page comes back short.
+func (s *SitemapOptions) AddKeysetQuery(ctx context.Context, db Querier, q KeysetQuery) error {
+	if q.PageSize <= 0 {
+		return fmt.Errorf("keyset query page size must be positive, got %d", q.PageSize)
+	}
+
+	key := q.Start
+	for {
+		rows, err := db.QueryContext(ctx, q.Query, key, q.PageSize)
+		if err != nil {
+			return fmt.Errorf("failed to query sitemap page after key %v: %v", key, err)
+		}
+		count := 0
+		for rows.Next() {
+			url, rowKey, err := q.Scan(rows)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan sitemap row: %v", err)
+			}
+			s.AddURL(url)
+			key = rowKey
+			count++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if count < q.PageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package sitemap
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// pagesDriver is a minimal database/sql driver serving ids 1..total ordered
+// by id, honoring "id > $1 LIMIT $2" style arguments.
+type pagesDriver struct {
+	total   int64
+	queries int
+}
+
+type pagesConn struct{ d *pagesDriver }
+
+type pagesStmt struct{ d *pagesDriver }
+
+type pagesRows struct {
+	next, last int64
+}
+
+func (d *pagesDriver) Open(name string) (driver.Conn, error) { return &pagesConn{d}, nil }
+
+func (c *pagesConn) Prepare(query string) (driver.Stmt, error) { return &pagesStmt{c.d}, nil }
+func (c *pagesConn) Close() error                              { return nil }
+func (c *pagesConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("not supported") }
+
+func (s *pagesStmt) Close() error  { return nil }
+func (s *pagesStmt) NumInput() int { return -1 }
+func (s *pagesStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("not supported")
+}
+func (s *pagesStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.queries++
+	after, limit := int64(0), s.d.total
+	if len(args) == 2 {
+		after, limit = args[0].(int64), args[1].(int64)
+	}
+	last := after + limit
+	if last > s.d.total {
+		last = s.d.total
+	}
+	return &pagesRows{next: after + 1, last: last}, nil
+}
+
+func (r *pagesRows) Columns() []string { return []string{"id"} }
+func (r *pagesRows) Close() error      { return nil }
+func (r *pagesRows) Next(dest []driver.Value) error {
+	if r.next > r.last {
+		return io.EOF
+	}
+	dest[0] = r.next
+	r.next++
+	return nil
+}
+
+func TestAddRowsAndKeysetQuery(t *testing.T) {
+	d := &pagesDriver{total: 25}
+	sql.Register("sitemap-pages", d)
+	db, err := sql.Open("sitemap-pages", "")
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	rows, err := db.Query("SELECT id FROM pages")
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	err = sm.AddRows(rows, func(rows *sql.Rows) (SitemapURL, error) {
+		var id int64
+		err := rows.Scan(&id)
+		return SitemapURL{Loc: fmt.Sprintf("/pages/%d", id)}, err
+	})
+	if err != nil {
+		t.Fatalf("Error adding rows: %v", err)
+	}
+	if len(sm.URLs) != 25 {
+		t.Fatalf("Expected 25 URLs from rows, got %d", len(sm.URLs))
+	}
+
+	d.queries = 0
+	sm = NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	err = sm.AddKeysetQuery(context.Background(), db, KeysetQuery{
+		Query:    "SELECT id FROM pages WHERE id > $1 ORDER BY id LIMIT $2",
+		Start:    int64(0),
+		PageSize: 10,
+		Scan: func(rows *sql.Rows) (SitemapURL, any, error) {
+			var id int64
+			err := rows.Scan(&id)
+			return SitemapURL{Loc: fmt.Sprintf("/pages/%d", id)}, id, err
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error running keyset query: %v", err)
+	}
+	if len(sm.URLs) != 25 || d.queries != 3 {
+		t.Fatalf("Expected 25 URLs over 3 pages, got %d URLs over %d pages", len(sm.URLs), d.queries)
+	}
+	if sm.URLs[24].Loc != "https://www.example.com/pages/25" {
+		t.Fatalf("Unexpected last URL: %s", sm.URLs[24].Loc)
+	}
+}
@@ -0,0 +1,328 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State is a compact snapshot of the URLs written by a run, mapping each loc
+// to a fingerprint of its lastmod, changefreq, priority and extensions.
+// Passing the previous run's State to the next one enables delta sitemaps.
+type State struct {
+	URLs map[string]string
+	// Since maps each loc to when its fingerprint last changed, if known.
+	// Write uses it for the index lastmod of sitemaps whose URLs have a
+	// generated lastmod.
+	Since map[string]time.Time
+
+	meta map[string]map[string]string // Meta by loc, not saved
+}
+
+// NewState builds the State for the given URLs, such as those of a
+// published sitemap read back, to compare with the State of a run.
+func NewState(urls []SitemapURL) *State {
+	state := &State{URLs: make(map[string]string, len(urls))}
+	for _, u := range urls {
+		state.URLs[u.Loc] = fingerprint(u)
+		if u.Meta != nil {
+			if state.meta == nil {
+				state.meta = make(map[string]map[string]string)
+			}
+			state.meta[u.Loc] = u.Meta
+		}
+	}
+	return state
+}
+
+// merge adds the URLs of other, which may be nil, to st.
+func (st *State) merge(other *State) {
+	if other == nil {
+		return
+	}
+	for loc, fp := range other.URLs {
+		st.URLs[loc] = fp
+	}
+	for loc, since := range other.Since {
+		if st.Since == nil {
+			st.Since = make(map[string]time.Time)
+		}
+		st.Since[loc] = since
+	}
+	for loc, meta := range other.meta {
+		if st.meta == nil {
+			st.meta = make(map[string]map[string]string)
+		}
+		st.meta[loc] = meta
+	}
+}
+
+// Meta returns the Meta of the URL at loc. It is only known for States built
+// by Write in this process, not for those read by LoadState.
+func (st *State) Meta(loc string) map[string]string {
+	return st.meta[loc]
+}
+
+// fingerprint returns a short hash of the fields whose change means a URL
+// was modified: every value written for it but the loc. A lastmod generated
+// by the LastModStrategy is left out, as it changes on every run. Values
+// hashed with hashField are only hashed when set, so URLs without them keep
+// the fingerprints saved by earlier versions.
+func fingerprint(u SitemapURL) string {
+	lastMod := u.LastMod
+	if u.lastModGenerated {
+		lastMod = ""
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", lastMod, u.ChangeFreq, u.Priority)
+	for _, alt := range u.Alternates {
+		fmt.Fprintf(h, "\x00%s\x00%s", alt.Hreflang, alt.Href)
+		if alt.Rel != "alternate" {
+			hashField(h, "rel", alt.Rel)
+		}
+		hashField(h, "media", alt.Media)
+	}
+	if u.News != nil {
+		hashField(h, "news:name", u.News.Publication.Name)
+		hashField(h, "news:language", u.News.Publication.Language)
+		hashField(h, "news:publication_date", u.News.PublicationDate)
+		hashField(h, "news:title", u.News.Title)
+	}
+	for _, img := range u.Images {
+		fmt.Fprintf(h, "\x00%s\x00%s\x00%s\x00%s\x00%s", img.Loc, img.Caption, img.GeoLocation, img.Title, img.License)
+	}
+	for _, video := range u.Videos {
+		fmt.Fprintf(h, "\x00%s\x00%s\x00%s\x00%s\x00%d", video.Title, video.ThumbnailLoc, video.ContentLoc, video.PlayerLoc, video.Duration)
+		hashVideo(h, video)
+	}
+	if u.PageMap != nil {
+		for _, obj := range u.PageMap.DataObjects {
+			fmt.Fprintf(h, "\x00%s\x00%s", obj.Type, obj.ID)
+			for _, attr := range obj.Attributes {
+				fmt.Fprintf(h, "\x00%s=%s", attr.Name, attr.Value)
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// hashVideo adds the optional fields of video to h.
+func hashVideo(h io.Writer, video Video) {
+	hashField(h, "video:description", video.Description)
+	hashField(h, "video:expiration_date", video.ExpirationDate)
+	if video.Rating != nil {
+		hashField(h, "video:rating", strconv.FormatFloat(*video.Rating, 'f', -1, 64))
+	}
+	if video.ViewCount != nil {
+		hashField(h, "video:view_count", strconv.FormatInt(*video.ViewCount, 10))
+	}
+	hashField(h, "video:publication_date", video.PublicationDate)
+	for _, tag := range video.Tags {
+		hashField(h, "video:tag", tag)
+	}
+	hashField(h, "video:family_friendly", video.FamilyFriendly)
+	if video.Restriction != nil {
+		hashField(h, "video:restriction", video.Restriction.Relationship+" "+video.Restriction.Countries)
+	}
+	for _, price := range video.Prices {
+		hashField(h, "video:price", price.Currency+" "+price.Type+" "+price.Resolution+" "+price.Value)
+	}
+	hashField(h, "video:requires_subscription", video.RequiresSubscription)
+	if video.Uploader != nil {
+		hashField(h, "video:uploader", video.Uploader.Info+" "+video.Uploader.Name)
+	}
+	if video.Platform != nil {
+		hashField(h, "video:platform", video.Platform.Relationship+" "+video.Platform.Platforms)
+	}
+	hashField(h, "video:live", video.Live)
+}
+
+// hashField adds the named value to h if it is set.
+func hashField(h io.Writer, name, value string) {
+	if value != "" {
+		fmt.Fprintf(h, "\x00%s=%s", name, value)
+	}
+}
+
+// Changed reports whether u was added or modified relative to the state.
+func (st *State) Changed(u SitemapURL) bool {
+	previous, ok := st.URLs[u.Loc]
+	return !ok || previous != fingerprint(u)
+}
+
+// changedSince returns when the URL at loc with fingerprint fp last
+// changed: the time st recorded if the fingerprint is the same, otherwise
+// now. st may be nil.
+func (st *State) changedSince(loc, fp string, now time.Time) time.Time {
+	if st == nil || st.URLs[loc] != fp {
+		return now
+	}
+	if since, ok := st.Since[loc]; ok {
+		return since
+	}
+	return now
+}
+
+// trackSince records in st when each of its URLs last changed relative to
+// the previous State, with now for URLs added or modified since.
+func (st *State) trackSince(previous *State, now time.Time) {
+	st.Since = make(map[string]time.Time, len(st.URLs))
+	for loc, fp := range st.URLs {
+		st.Since[loc] = previous.changedSince(loc, fp, now).UTC().Truncate(time.Second)
+	}
+}
+
+// Diff compares st with the State of an earlier run and returns, sorted,
+// the locs added or modified since and the locs removed since. A nil
+// previous State means every loc was added.
+func (st *State) Diff(previous *State) (changed, removed []string) {
+	for loc, fp := range st.URLs {
+		if previous == nil || previous.URLs[loc] != fp {
+			changed = append(changed, loc)
+		}
+	}
+	if previous != nil {
+		for loc := range previous.URLs {
+			if _, ok := st.URLs[loc]; !ok {
+				removed = append(removed, loc)
+			}
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// State returns the snapshot of the URLs written by the last successful
+// Write, or nil if Write has not succeeded yet.
+func (s *SitemapOptions) State() *State {
+	return s.state
+}
+
+// recentOverflow is the reason recorded for changed URLs the recent
+// sitemap has no room for.
+const recentOverflow = "recent sitemap full"
+
+// recentURLs returns the urls added or modified since PreviousState, or nil
+// if recent sitemaps are disabled.
+func (s *SitemapOptions) recentURLs(urls []SitemapURL) []SitemapURL {
+	if !s.RecentSitemap || s.PreviousState == nil {
+		return nil
+	}
+	var recent []SitemapURL
+	for _, u := range urls {
+		if s.PreviousState.Changed(u) {
+			recent = append(recent, u)
+		}
+	}
+	return recent
+}
+
+// capRecent returns the first MaxURLs of the recent URLs, recording the
+// others in report as left out of the recent sitemap. They are still
+// written to the other sitemap files.
+func (s *SitemapOptions) capRecent(recent []SitemapURL, report *Report) []SitemapURL {
+	if len(recent) <= s.MaxURLs {
+		return recent
+	}
+	for _, u := range recent[s.MaxURLs:] {
+		s.exclude(report, ExcludedURL{Loc: u.Loc, Reason: recentOverflow, Meta: u.Meta})
+	}
+	return recent[:s.MaxURLs]
+}
+
+// LoadState reads a State previously written by Save.
+func LoadState(filePath string) (*State, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	state := &State{URLs: make(map[string]string), Since: make(map[string]time.Time)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		hash, loc, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid state file '%s' at line %d", filePath, line)
+		}
+		// Files written before Since was saved have no timestamps
+		if value, rest, ok := strings.Cut(loc, " "); ok {
+			if since, err := time.Parse(time.RFC3339, value); err == nil {
+				state.Since[rest] = since
+				loc = rest
+			}
+		}
+		state.URLs[loc] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read state file '%s': %v", filePath, err)
+	}
+	return state, nil
+}
+
+// Save atomically writes the state to filePath, one "fingerprint since loc"
+// line per URL sorted by loc, where since is an RFC3339 timestamp omitted if
+// unknown.
+func (st *State) Save(filePath string) error {
+	locs := make([]string, 0, len(st.URLs))
+	for loc := range st.URLs {
+		locs = append(locs, loc)
+	}
+	sort.Strings(locs)
+
+	var buffer bytes.Buffer
+	for _, loc := range locs {
+		buffer.WriteString(st.URLs[loc])
+		buffer.WriteByte(' ')
+		if since, ok := st.Since[loc]; ok {
+			buffer.WriteString(since.UTC().Format(time.RFC3339))
+			buffer.WriteByte(' ')
+		}
+		buffer.WriteString(loc)
+		buffer.WriteByte('\n')
+	}
+	return writeFileAtomic(filePath, buffer.Bytes(), 0644)
+}
+
+// stateFilePath returns the path of the state file, resolving relative
+// paths against Dir, or "" if state persistence is disabled.
+func (s *SitemapOptions) stateFilePath() string {
+	if s.StateFile == "" || filepath.IsAbs(s.StateFile) {
+		return s.StateFile
+	}
+	return filepath.Join(s.Dir, s.StateFile)
+}
+
+// loadPreviousState loads PreviousState from the state file if it is not
+// already set. A missing state file means this is the first run. The file
+// is read once per run, so a repeated Write does not pick up the State the
+// first one saved.
+func (s *SitemapOptions) loadPreviousState() error {
+	statePath := s.stateFilePath()
+	if statePath == "" || s.PreviousState != nil || s.previousLoaded {
+		return nil
+	}
+	state, err := LoadState(statePath)
+	if os.IsNotExist(err) {
+		s.previousLoaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.PreviousState = state
+	s.previousLoaded = true
+	return nil
+}
@@ -0,0 +1,372 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecentSitemap(t *testing.T) {
+	dir := t.TempDir()
+	now := func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	first := NewSitemapOptions(dir, "https://www.example.com")
+	first.Now = now
+	first.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	first.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-01-01"})
+	first.AddURL(SitemapURL{Loc: "/c", LastMod: "2024-01-01"})
+	if err := first.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing first run: %v", err)
+	}
+
+	second := NewSitemapOptions(dir, "https://www.example.com")
+	second.Now = now
+	second.PreviousState = first.State()
+	second.RecentSitemap = true
+	second.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	second.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-05-01"})
+	second.AddURL(SitemapURL{Loc: "/c", LastMod: "2024-01-01"})
+	second.AddURL(SitemapURL{Loc: "/d", LastMod: "2024-05-02"})
+	if err := second.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing second run: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, recentSitemapName))
+	if err != nil {
+		t.Fatalf("Recent sitemap not written: %v", err)
+	}
+	var recent URLSet
+	if err := xml.Unmarshal(data, &recent); err != nil {
+		t.Fatalf("Error parsing recent sitemap: %v", err)
+	}
+	if len(recent.URLs) != 2 || recent.URLs[0].Loc != "https://www.example.com/b" || recent.URLs[1].Loc != "https://www.example.com/d" {
+		t.Fatalf("Unexpected recent URLs: %+v", recent.URLs)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Sitemap index not written: %v", err)
+	}
+	var index SitemapIndex
+	if err := xml.Unmarshal(data, &index); err != nil {
+		t.Fatalf("Error parsing sitemap index: %v", err)
+	}
+	if len(index.Sitemaps) != 2 || index.Sitemaps[1].Loc != "https://www.example.com/sitemap_recent.xml" {
+		t.Fatalf("Recent sitemap not referenced from index: %+v", index.Sitemaps)
+	}
+
+	// Changed URLs beyond MaxURLs are reported, not silently dropped
+	capped := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	capped.Now = now
+	capped.MaxURLs = 1
+	capped.PreviousState = first.State()
+	capped.RecentSitemap = true
+	capped.AddURLs(second.URLs)
+	if err := capped.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing capped run: %v", err)
+	}
+	if skipped := capped.Skipped(); skipped.ByReason[recentOverflow] != 1 || capped.Report().Excluded[0].Loc != "https://www.example.com/d" {
+		t.Fatalf("Expected the URL left out of the recent sitemap to be reported, got %+v", capped.Report().Excluded)
+	}
+	if capped.Report().URLs != 4 {
+		t.Fatalf("Expected all 4 URLs in the other files, got %d", capped.Report().URLs)
+	}
+
+	// Nothing changed: no recent sitemap is written and the stale one is removed
+	third := NewSitemapOptions(dir, "https://www.example.com")
+	third.Now = now
+	third.PreviousState = second.State()
+	third.RecentSitemap = true
+	third.AddURLs(second.URLs)
+	if err := third.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing third run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, recentSitemapName)); !os.IsNotExist(err) {
+		t.Fatalf("Stale recent sitemap was not removed")
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	dir := t.TempDir()
+
+	first := NewSitemapOptions(dir, "https://www.example.com")
+	first.StateFile = "sitemap.state"
+	first.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	first.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-01-01"})
+	if err := first.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing first run: %v", err)
+	}
+
+	loaded, err := LoadState(filepath.Join(dir, "sitemap.state"))
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	if len(loaded.URLs) != 2 || loaded.URLs["https://www.example.com/a"] != first.State().URLs["https://www.example.com/a"] {
+		t.Fatalf("Loaded state does not match written state: %+v", loaded.URLs)
+	}
+
+	// The next run picks up the persisted state automatically
+	second := NewSitemapOptions(dir, "https://www.example.com")
+	second.StateFile = "sitemap.state"
+	second.RecentSitemap = true
+	second.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	second.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-01-01"})
+	second.AddURL(SitemapURL{Loc: "/new", LastMod: "2024-01-01"})
+	if err := second.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing second run: %v", err)
+	}
+	if second.PreviousState == nil || len(second.PreviousState.URLs) != 2 {
+		t.Fatalf("Previous state was not loaded from the state file")
+	}
+	if len(second.recentURLs(second.URLs)) != 1 {
+		t.Fatalf("Expected exactly one new URL relative to the persisted state")
+	}
+}
+
+func TestReset(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 10
+	sm.RecentSitemap = true
+	sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing first run: %v", err)
+	}
+	written := sm.State()
+
+	sm.Reset()
+	if len(sm.URLs) != 0 {
+		t.Fatalf("Reset did not clear URLs, %d remain", len(sm.URLs))
+	}
+	if sm.MaxURLs != 10 || !sm.RecentSitemap {
+		t.Fatalf("Reset did not retain configuration")
+	}
+	if sm.PreviousState != written || sm.State() != nil {
+		t.Fatalf("Reset did not carry the last written state over as previous state")
+	}
+
+	sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-01-01"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing second run: %v", err)
+	}
+	if recent := sm.recentURLs(sm.URLs); len(recent) != 1 || recent[0].Loc != "https://www.example.com/b" {
+		t.Fatalf("Expected only the URL added after Reset to be recent, got %+v", recent)
+	}
+}
+
+func TestCloneWhileAdding(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	for i := 0; i < 100; i++ {
+		sm.AddURL(SitemapURL{Loc: "/before/" + strconv.Itoa(i)})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			sm.AddURL(SitemapURL{Loc: "/during/" + strconv.Itoa(i)})
+		}
+	}()
+
+	snapshot := sm.Clone()
+	snapshot.Dir = t.TempDir()
+	count := len(snapshot.URLs)
+	if err := snapshot.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing snapshot: %v", err)
+	}
+	<-done
+
+	if count < 100 || len(snapshot.URLs) != count {
+		t.Fatalf("Snapshot changed while the original was appended to: %d then %d", count, len(snapshot.URLs))
+	}
+	if len(sm.URLs) != 1100 {
+		t.Fatalf("Expected 1100 URLs in the original, got %d", len(sm.URLs))
+	}
+}
+
+func TestRepeatedWrite(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.StateFile = "state.txt"
+	sm.RecentSitemap = true
+	sm.AddURL(SitemapURL{Loc: "/a", LastMod: "2024-01-01"})
+	sm.AddURL(SitemapURL{Loc: "/b", LastMod: "2024-01-01"})
+
+	readAll := func() map[string]string {
+		files := map[string]string{}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				t.Fatalf("Error reading %s: %v", entry.Name(), err)
+			}
+			files[entry.Name()] = string(data)
+		}
+		return files
+	}
+
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing first time: %v", err)
+	}
+	first := readAll()
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing second time: %v", err)
+	}
+	second := readAll()
+	if len(first) != len(second) {
+		t.Fatalf("Expected the same files, got %d and %d", len(first), len(second))
+	}
+	for name, data := range first {
+		if second[name] != data {
+			t.Fatalf("Expected %s to be regenerated identically", name)
+		}
+	}
+	if sm.PreviousState != nil || sm.Report().Changes != nil {
+		t.Fatalf("Expected the state saved by the first Write not to become the previous state")
+	}
+
+	// URLs added since are included
+	sm.AddURL(SitemapURL{Loc: "/c", LastMod: "2024-01-01"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing third time: %v", err)
+	}
+	if len(sm.State().URLs) != 3 {
+		t.Fatalf("Expected 3 URLs in the state, got %d", len(sm.State().URLs))
+	}
+}
+
+func TestIndexLastModFollowsChanges(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(36 * time.Hour)
+
+	write := func(now time.Time, priority string) *SitemapIndex {
+		sm := NewSitemapOptions(dir, "https://www.example.com")
+		sm.Now = func() time.Time { return now }
+		sm.StateFile = "sitemap.state"
+		sm.LastModFormat = LastModSeconds
+		sm.MaxURLs = 1
+		sm.AddURL(SitemapURL{Loc: "/a"})
+		sm.AddURL(SitemapURL{Loc: "/b", Priority: priority})
+		if err := sm.Write("https://www.example.com/"); err != nil {
+			t.Fatalf("Error writing sitemap: %v", err)
+		}
+		index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+		if err != nil {
+			t.Fatalf("Error loading sitemap index: %v", err)
+		}
+		return index
+	}
+
+	write(first, "0.5")
+	// Only /b changes, so only its sitemap gets the new lastmod
+	index := write(second, "0.8")
+	if len(index.Sitemaps) != 2 {
+		t.Fatalf("Expected 2 sitemaps in the index, got %d", len(index.Sitemaps))
+	}
+	if got, want := index.Sitemaps[0].LastMod, first.Format(time.RFC3339); got != want {
+		t.Fatalf("Expected unchanged sitemap to keep lastmod %s, got %s", want, got)
+	}
+	if got, want := index.Sitemaps[1].LastMod, second.Format(time.RFC3339); got != want {
+		t.Fatalf("Expected changed sitemap to get lastmod %s, got %s", want, got)
+	}
+
+	state, err := LoadState(filepath.Join(dir, "sitemap.state"))
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	if !state.Since["https://www.example.com/a"].Equal(first) || !state.Since["https://www.example.com/b"].Equal(second) {
+		t.Fatalf("Unexpected change times in the state file: %v", state.Since)
+	}
+}
+
+func TestFingerprintCoversExtensions(t *testing.T) {
+	base := func() SitemapURL {
+		return SitemapURL{
+			Loc:        "https://www.example.com/a",
+			Alternates: []Alternate{{Rel: "alternate", Hreflang: "fr", Href: "https://www.example.com/fr/a"}},
+			News: &News{
+				Publication:     NewsPublication{Name: "Example Times", Language: "en"},
+				PublicationDate: "2024-06-01",
+				Title:           "Title",
+			},
+			Images:  []Image{{Loc: "https://www.example.com/a.jpg", Caption: "Caption"}},
+			PageMap: &PageMap{DataObjects: []DataObject{{Type: "document", Attributes: []Attribute{{Name: "author", Value: "Ann"}}}}},
+		}
+	}
+	state := NewState([]SitemapURL{base()})
+	if state.Changed(base()) {
+		t.Fatalf("Expected an identical URL to be unchanged")
+	}
+	for name, change := range map[string]func(u *SitemapURL){
+		"alternate media":       func(u *SitemapURL) { u.Alternates[0].Media = "only screen and (max-width: 640px)" },
+		"news title":            func(u *SitemapURL) { u.News.Title = "New title" },
+		"news publication date": func(u *SitemapURL) { u.News.PublicationDate = "2024-06-02" },
+		"news publication":      func(u *SitemapURL) { u.News.Publication.Name = "Example Post" },
+		"news language":         func(u *SitemapURL) { u.News.Publication.Language = "fr" },
+		"news removed":          func(u *SitemapURL) { u.News = nil },
+		"image caption":         func(u *SitemapURL) { u.Images[0].Caption = "Other" },
+		"pagemap attribute":     func(u *SitemapURL) { u.PageMap.DataObjects[0].Attributes[0].Value = "Bob" },
+	} {
+		u := base()
+		change(&u)
+		if !state.Changed(u) {
+			t.Errorf("Expected a change to the %s to be detected", name)
+		}
+	}
+}
+
+func TestFingerprintCoversVideos(t *testing.T) {
+	rating, views := 4.5, int64(100)
+	base := func() SitemapURL {
+		return SitemapURL{
+			Loc: "https://www.example.com/a",
+			Videos: []Video{{
+				ThumbnailLoc:         "https://www.example.com/a.jpg",
+				Title:                "Title",
+				Description:          "Description",
+				ContentLoc:           "https://www.example.com/a.mp4",
+				Rating:               &rating,
+				ViewCount:            &views,
+				Tags:                 []string{"cooking"},
+				Restriction:          &VideoRestriction{Relationship: "allow", Countries: "FR"},
+				Prices:               []VideoPrice{{Currency: "EUR", Value: "1.99"}},
+				RequiresSubscription: "no",
+				Uploader:             &VideoUploader{Name: "Ann"},
+				Platform:             &VideoPlatform{Relationship: "allow", Platforms: "web"},
+			}},
+		}
+	}
+	state := NewState([]SitemapURL{base()})
+	if state.Changed(base()) {
+		t.Fatalf("Expected an identical URL to be unchanged")
+	}
+	for name, change := range map[string]func(v *Video){
+		"description":           func(v *Video) { v.Description = "Other" },
+		"expiration date":       func(v *Video) { v.ExpirationDate = "2030-01-01" },
+		"rating":                func(v *Video) { r := 3.0; v.Rating = &r },
+		"view count":            func(v *Video) { n := int64(101); v.ViewCount = &n },
+		"publication date":      func(v *Video) { v.PublicationDate = "2024-06-01" },
+		"tags":                  func(v *Video) { v.Tags = append(v.Tags, "baking") },
+		"family friendly":       func(v *Video) { v.FamilyFriendly = "no" },
+		"restriction":           func(v *Video) { v.Restriction.Countries = "FR DE" },
+		"price":                 func(v *Video) { v.Prices[0].Value = "2.99" },
+		"requires subscription": func(v *Video) { v.RequiresSubscription = "yes" },
+		"uploader":              func(v *Video) { v.Uploader.Info = "https://www.example.com/ann" },
+		"platform":              func(v *Video) { v.Platform.Relationship = "deny" },
+		"live":                  func(v *Video) { v.Live = "yes" },
+	} {
+		u := base()
+		change(&u.Videos[0])
+		if !state.Changed(u) {
+			t.Errorf("Expected a change to the video %s to be detected", name)
+		}
+	}
+}
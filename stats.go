@@ -0,0 +1,128 @@
+package sitemap
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Stats summarizes the URLs of one sitemap file or of a whole Write.
+type Stats struct {
+	URLs int
+	// ChangeFreq counts the URLs by changefreq; URLs without one count
+	// under "".
+	ChangeFreq map[string]int
+	// Priority counts the URLs by priority rounded to one decimal, such as
+	// "0.5"; URLs without one count under "".
+	Priority map[string]int
+	// LastModAge describes the age of the lastmod values at write time.
+	LastModAge AgeStats
+	// Extensions counts the URLs using each extension: "image", "video",
+	// "news", "xhtml" and "pagemap".
+	Extensions map[string]int
+
+	ages []time.Duration
+}
+
+// AgeStats holds percentiles of the lastmod ages. Lastmods in the future
+// count as zero.
+type AgeStats struct {
+	Count int // URLs with a lastmod
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// urlStats collects the stats of urls. Call finish before exposing them.
+func (s *SitemapOptions) urlStats(urls []SitemapURL) Stats {
+	now := s.now()
+	st := Stats{
+		URLs:       len(urls),
+		ChangeFreq: make(map[string]int),
+		Priority:   make(map[string]int),
+		Extensions: make(map[string]int),
+	}
+	for _, u := range urls {
+		st.ChangeFreq[u.ChangeFreq]++
+		st.Priority[priorityBucket(u.Priority)]++
+		if u.LastMod != "" {
+			if t, err := s.parseLastMod(u.LastMod); err == nil {
+				st.ages = append(st.ages, max(now.Sub(t), 0))
+			}
+		}
+		if len(u.Images) > 0 {
+			st.Extensions["image"]++
+		}
+		if len(u.Videos) > 0 {
+			st.Extensions["video"]++
+		}
+		if u.News != nil {
+			st.Extensions["news"]++
+		}
+		if len(u.Alternates) > 0 {
+			st.Extensions["xhtml"]++
+		}
+		if u.PageMap != nil {
+			st.Extensions["pagemap"]++
+		}
+	}
+	return st
+}
+
+// priorityBucket rounds a priority to one decimal.
+func priorityBucket(priority string) string {
+	value, err := strconv.ParseFloat(priority, 64)
+	if err != nil {
+		return priority
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
+
+// add merges the unfinished stats other into st.
+func (st *Stats) add(other Stats) {
+	if st.ChangeFreq == nil {
+		st.ChangeFreq = make(map[string]int)
+		st.Priority = make(map[string]int)
+		st.Extensions = make(map[string]int)
+	}
+	st.URLs += other.URLs
+	for key, n := range other.ChangeFreq {
+		st.ChangeFreq[key] += n
+	}
+	for key, n := range other.Priority {
+		st.Priority[key] += n
+	}
+	for key, n := range other.Extensions {
+		st.Extensions[key] += n
+	}
+	st.ages = append(st.ages, other.ages...)
+}
+
+// finish computes the age percentiles and releases the collected ages.
+func (st *Stats) finish() {
+	slices.Sort(st.ages)
+	if n := len(st.ages); n > 0 {
+		percentile := func(p int) time.Duration {
+			return st.ages[(n-1)*p/100]
+		}
+		st.LastModAge = AgeStats{
+			Count: n,
+			P50:   percentile(50),
+			P90:   percentile(90),
+			P99:   percentile(99),
+			Max:   st.ages[n-1],
+		}
+	}
+	st.ages = nil
+}
+
+// recordStats adds the stats of the sitemap file name to the report.
+func (r *Report) recordStats(name string, st Stats) {
+	r.Stats.add(st)
+	st.finish()
+	if r.SitemapStats == nil {
+		r.SitemapStats = make(map[string]Stats)
+	}
+	r.SitemapStats[name] = st
+}
@@ -0,0 +1,56 @@
+package sitemap
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 5
+	now := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	sm.Now = func() time.Time { return now }
+	for i := 0; i < 10; i++ {
+		u := SitemapURL{
+			Loc:        fmt.Sprintf("/page/%d", i),
+			LastMod:    now.AddDate(0, 0, -i).Format("2006-01-02"),
+			ChangeFreq: "daily",
+			Priority:   "0.55",
+		}
+		if i%2 == 0 {
+			u.ChangeFreq = ""
+			u.Priority = ""
+			u.Images = []Image{{Loc: "https://www.example.com/image.jpg"}}
+		}
+		sm.AddURL(u)
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	stats := sm.Report().Stats
+	if stats.URLs != 10 || stats.ChangeFreq["daily"] != 5 || stats.ChangeFreq[""] != 5 {
+		t.Fatalf("Unexpected changefreq stats: %+v", stats)
+	}
+	if stats.Priority["0.6"] != 5 || stats.Priority[""] != 5 {
+		t.Fatalf("Unexpected priority stats: %v", stats.Priority)
+	}
+	if stats.Extensions["image"] != 5 {
+		t.Fatalf("Expected 5 URLs with images, got %v", stats.Extensions)
+	}
+	day := 24 * time.Hour
+	expected := AgeStats{Count: 10, P50: 4 * day, P90: 8 * day, P99: 8 * day, Max: 9 * day}
+	if stats.LastModAge != expected {
+		t.Fatalf("Expected lastmod ages %+v, got %+v", expected, stats.LastModAge)
+	}
+
+	if len(sm.Report().SitemapStats) != 2 {
+		t.Fatalf("Expected stats for 2 sitemaps, got %v", sm.Report().SitemapStats)
+	}
+	first := sm.Report().SitemapStats["sitemap_1.xml"]
+	if first.URLs != 5 || first.LastModAge.Max != 4*day {
+		t.Fatalf("Unexpected stats for the first sitemap: %+v", first)
+	}
+}
@@ -0,0 +1,138 @@
+package sitemap
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteTo writes the URLs as a single sitemap to w, for example standard
+// output in a shell pipeline. It fails if they need more than one file, as
+// with more than MaxURLs URLs, news articles or groups; use WriteTar then.
+// With Gzip, the gzipped sitemap is written. The report and State are kept
+// as for Write, but AfterWrite is not called and nothing is written to Dir:
+// the state file is only read, and outputs such as ManifestFile and Mirrors
+// are skipped.
+func (s *SitemapOptions) WriteTo(w io.Writer) (int64, error) {
+	dir, err := os.MkdirTemp("", "sitemap-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := s.writeStream(dir, s.BaseURL+"/"); err != nil {
+		return 0, err
+	}
+	if indexes, err := staleSitemaps(filepath.Join(dir, "sitemap_index.xml")); err != nil {
+		return 0, err
+	} else if len(indexes) > 0 {
+		return 0, fmt.Errorf("the URLs need several sitemap files; use WriteTar")
+	}
+	name := "sitemap.xml"
+	if s.Gzip {
+		name += gzipExt
+	}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// WriteTar writes the files Write would produce to w as a tar stream, with
+// the paths they would have below Dir. baseSitemapURL is used as for Write.
+// As with WriteTo, the report and State are kept but AfterWrite is not
+// called and nothing is written to Dir.
+func (s *SitemapOptions) WriteTar(w io.Writer, baseSitemapURL string) error {
+	dir, err := os.MkdirTemp("", "sitemap-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := s.writeStream(dir, baseSitemapURL); err != nil {
+		return err
+	}
+	return ArchiveDir(w, dir)
+}
+
+// writeStream writes a Clone of s to dir and keeps its report and State.
+// The state file is only read, for PreviousState, and the outputs written
+// next to the set or outside it, from ChangesFile to Mirrors, are turned
+// off, so nothing changes in Dir or elsewhere.
+func (s *SitemapOptions) writeStream(dir, baseSitemapURL string) error {
+	c := s.Clone()
+	c.StateFile = s.stateFilePath()
+	if err := c.loadPreviousState(); err != nil {
+		return err
+	}
+	c.Dir = dir
+	c.StateFile = ""
+	c.ChangesFile = ""
+	c.ManifestFile = ""
+	c.MetricsFile = ""
+	c.ReviewFile = ""
+	c.ArchiveDir = ""
+	c.VerifyDir = ""
+	c.Mirrors = nil
+	c.AfterWrite = nil
+	if err := c.Write(baseSitemapURL); err != nil {
+		return err
+	}
+	s.report = c.report
+	s.state = c.state
+	return nil
+}
+
+// ArchiveDir writes the files below dir, such as those written by Write or
+// SplitSitemap, to w as a tar stream. Hidden files are skipped.
+func ArchiveDir(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    filepath.ToSlash(name),
+			Mode:    0644,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
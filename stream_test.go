@@ -0,0 +1,158 @@
+package sitemap
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddURL(SitemapURL{Loc: "/about"})
+
+	var buf bytes.Buffer
+	n, err := sm.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if n != int64(buf.Len()) || !strings.Contains(buf.String(), "<loc>https://www.example.com/about</loc>") {
+		t.Fatalf("Unexpected output (%d bytes): %s", n, buf.String())
+	}
+	if sm.Report() == nil || sm.Report().URLs != 2 {
+		t.Fatalf("Expected the report of the run, got %+v", sm.Report())
+	}
+
+	sm.MaxURLs = 1
+	if _, err := sm.WriteTo(io.Discard); err == nil || !strings.Contains(err.Error(), "WriteTar") {
+		t.Fatalf("Expected an error for URLs needing an index, got %v", err)
+	}
+}
+
+func TestWriteToGzip(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.Gzip = true
+	sm.AddURL(SitemapURL{Loc: "/about"})
+
+	var buf bytes.Buffer
+	if _, err := sm.WriteTo(&buf); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Expected gzipped output: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Error reading output: %v", err)
+	}
+	if !strings.Contains(string(data), "<loc>https://www.example.com/about</loc>") {
+		t.Fatalf("Unexpected output: %s", data)
+	}
+
+	sm.MaxURLs = 1
+	sm.AddURL(SitemapURL{Loc: "/contact"})
+	if _, err := sm.WriteTo(io.Discard); err == nil || !strings.Contains(err.Error(), "WriteTar") {
+		t.Fatalf("Expected an error for URLs needing a gzipped index, got %v", err)
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.ShardDir = "shards"
+	for i := 0; i < 3; i++ {
+		sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page-%d", i)})
+	}
+
+	var buf bytes.Buffer
+	if err := sm.WriteTar(&buf, "https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing tar: %v", err)
+	}
+	names := map[string]bool{}
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading tar: %v", err)
+		}
+		names[header.Name] = true
+	}
+	for _, want := range []string{"sitemap_index.xml", "sitemap.xsl", "shards/sitemap_1.xml", "shards/sitemap_2.xml"} {
+		if !names[want] {
+			t.Fatalf("Expected %s in the archive, got %v", want, names)
+		}
+	}
+}
+
+func TestWriteStreamLeavesDirUntouched(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.StateFile = "state.json"
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	sm.Reset()
+	sm.PreviousState = nil
+	sm.ManifestFile = "manifest.json"
+	sm.MetricsFile = "sitemap.prom"
+	sm.ReviewFile = "review.json"
+	sm.ChangesFile = "changes.json"
+	sm.AddURL(SitemapURL{Loc: "/"})
+	sm.AddURL(SitemapURL{Loc: "/new"})
+
+	before, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.WriteTo(io.Discard); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := sm.WriteTar(&buf, "https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing tar: %v", err)
+	}
+	after, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("Expected Dir to be untouched, had %v and now %v", before, after)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "state.json")); err != nil || !bytes.Equal(data, state) {
+		t.Fatalf("Expected the state file to be unchanged: %v", err)
+	}
+
+	// The previous state is still read, and the side outputs are not tarred
+	if changes := sm.Report().Changes; changes == nil || len(changes.Added) != 1 || changes.Added[0] != "https://www.example.com/new" {
+		t.Fatalf("Expected the changes against the state file, got %+v", changes)
+	}
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading tar: %v", err)
+		}
+		if header.Name != "sitemap.xml" && header.Name != "sitemap.xsl" {
+			t.Fatalf("Unexpected %s in the archive", header.Name)
+		}
+	}
+}
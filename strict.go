@@ -0,0 +1,139 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// changeFreqs are the changefreq values allowed by the protocol.
+var changeFreqs = map[string]bool{
+	"always":  true,
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+	"never":   true,
+}
+
+// checkChangeFreq lowercases the changefreq of u and, if it is not one of
+// the protocol values, returns an error in Strict mode or otherwise drops it
+// with a warning in report, which may be nil.
+func (s *SitemapOptions) checkChangeFreq(u *SitemapURL, report *Report) error {
+	if u.ChangeFreq == "" {
+		return nil
+	}
+	if freq := strings.ToLower(u.ChangeFreq); changeFreqs[freq] {
+		u.ChangeFreq = freq
+		return nil
+	}
+	if s.Strict {
+		return &ValidationError{Loc: u.Loc, Problem: fmt.Sprintf("invalid changefreq '%s'", u.ChangeFreq)}
+	}
+	if report != nil {
+		s.addIssue(&report.Warnings, WarningRepaired, Issue{
+			Loc:     u.Loc,
+			Problem: fmt.Sprintf("invalid changefreq '%s' omitted", u.ChangeFreq),
+			Meta:    u.Meta,
+		})
+	}
+	u.ChangeFreq = ""
+	return nil
+}
+
+// checkPriority returns an error in Strict mode if the priority of u is not
+// a decimal between 0.0 and 1.0, as the protocol requires, and otherwise
+// drops it with a warning in report, which may be nil.
+func (s *SitemapOptions) checkPriority(u *SitemapURL, report *Report) error {
+	if u.Priority == "" || validPriority(u.Priority) {
+		return nil
+	}
+	if s.Strict {
+		return &ValidationError{Loc: u.Loc, Problem: fmt.Sprintf("invalid priority '%s'", u.Priority)}
+	}
+	if report != nil {
+		s.addIssue(&report.Warnings, WarningRepaired, Issue{
+			Loc:     u.Loc,
+			Problem: fmt.Sprintf("invalid priority '%s' omitted", u.Priority),
+			Meta:    u.Meta,
+		})
+	}
+	u.Priority = ""
+	return nil
+}
+
+// validPriority reports whether priority is a plain decimal, without an
+// exponent, between 0.0 and 1.0.
+func validPriority(priority string) bool {
+	if strings.Trim(priority, "0123456789.") != "" {
+		return false
+	}
+	p, err := strconv.ParseFloat(priority, 64)
+	return err == nil && p >= 0 && p <= 1
+}
+
+// checkScope reports the URLs outside the directory of scope, the URL the
+// sitemap files listing them are served from. It returns an error in Strict
+// mode and otherwise adds warnings to report. URLs on other hosts are left
+// to checkOrigin.
+func (s *SitemapOptions) checkScope(urls []SitemapURL, scope string, report *Report) error {
+	dir, err := parseBaseURL(scope)
+	if err != nil {
+		return err
+	}
+	dirPath := dir.Path
+	if dirPath == "/" {
+		return nil
+	}
+	if s.RequireRootSitemap {
+		return fmt.Errorf("sitemap files at %s are not at the root of the host and RequireRootSitemap is set", scope)
+	}
+	for _, su := range urls {
+		u, err := url.Parse(su.Loc)
+		if err != nil || !strings.EqualFold(u.Scheme, dir.Scheme) || !strings.EqualFold(u.Host, dir.Host) {
+			continue
+		}
+		if strings.HasPrefix(u.EscapedPath()+"/", dirPath) {
+			continue
+		}
+		problem := "outside the scope of the sitemap files at " + dir.Scheme + "://" + dir.Host + dirPath
+		if s.Strict {
+			return &ValidationError{Loc: su.Loc, Problem: problem}
+		}
+		s.addIssue(&report.Warnings, WarningRepaired, Issue{Loc: su.Loc, Problem: problem, Meta: su.Meta})
+	}
+	return nil
+}
+
+// checkOrigin reports a URL whose scheme or host differs from base, as
+// crawlers ignore entries outside the sitemap's site. It returns an error in
+// Strict mode and otherwise adds a warning to report, which may be nil. The
+// check is skipped if base is nil.
+func (s *SitemapOptions) checkOrigin(su SitemapURL, base *url.URL, report *Report) error {
+	if base == nil {
+		return nil
+	}
+	loc := su.Loc
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil
+	}
+	var problem string
+	switch {
+	case !strings.EqualFold(u.Scheme, base.Scheme):
+		problem = fmt.Sprintf("scheme %s differs from base URL scheme %s", u.Scheme, base.Scheme)
+	case !strings.EqualFold(u.Host, base.Host):
+		problem = fmt.Sprintf("host %s differs from base URL host %s", u.Host, base.Host)
+	default:
+		return nil
+	}
+	if s.Strict {
+		return &ValidationError{Loc: loc, Problem: problem}
+	}
+	if report != nil {
+		s.addIssue(&report.Warnings, WarningRepaired, Issue{Loc: loc, Problem: problem, Meta: su.Meta})
+	}
+	return nil
+}
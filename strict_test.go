@@ -0,0 +1,114 @@
+package sitemap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChangeFreqValidation(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/a", ChangeFreq: "Daily"})
+	sm.AddURL(SitemapURL{Loc: "/b", ChangeFreq: "sometimes"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if sm.URLs[0].ChangeFreq != "daily" || sm.URLs[1].ChangeFreq != "" {
+		t.Fatalf("changefreq not repaired: %+v", sm.URLs)
+	}
+	warnings := sm.Report().Warnings
+	if len(warnings) != 1 || warnings[0].Loc != "https://www.example.com/b" {
+		t.Fatalf("unexpected warnings: %+v", warnings)
+	}
+
+	strict := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	strict.Strict = true
+	strict.AddURL(SitemapURL{Loc: "/b", ChangeFreq: "sometimes"})
+	err := strict.Write("https://www.example.com/")
+	if err == nil || !strings.Contains(err.Error(), "https://www.example.com/b") {
+		t.Fatalf("expected an error naming the URL, got %v", err)
+	}
+}
+
+func TestPriorityValidation(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/a", Priority: "0.8"})
+	sm.AddURL(SitemapURL{Loc: "/b", Priority: "1.5"})
+	sm.AddURL(SitemapURL{Loc: "/c", Priority: "5e-1"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if sm.URLs[0].Priority != "0.8" || sm.URLs[1].Priority != "" || sm.URLs[2].Priority != "" {
+		t.Fatalf("priority not repaired: %+v", sm.URLs)
+	}
+	if warnings := sm.Report().Warnings; len(warnings) != 2 {
+		t.Fatalf("unexpected warnings: %+v", warnings)
+	}
+
+	strict := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	strict.Strict = true
+	strict.AddURL(SitemapURL{Loc: "/b", Priority: "1.5"})
+	err := strict.Write("https://www.example.com/")
+	if err == nil || !strings.Contains(err.Error(), "invalid priority") {
+		t.Fatalf("expected an invalid priority error, got %v", err)
+	}
+}
+
+func TestOriginMismatch(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/ok"})
+	sm.AddURL(SitemapURL{Loc: "http://www.example.com/insecure"})
+	sm.AddURL(SitemapURL{Loc: "https://example.com/apex"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var problems []string
+	for _, w := range sm.Report().Warnings {
+		problems = append(problems, w.Problem)
+	}
+	want := "scheme http differs from base URL scheme https\nhost example.com differs from base URL host www.example.com"
+	if got := strings.Join(problems, "\n"); got != want {
+		t.Fatalf("warnings:\n%s", got)
+	}
+
+	sm.Strict = true
+	if err := sm.Write("https://www.example.com/"); err == nil || !strings.Contains(err.Error(), "http://www.example.com/insecure") {
+		t.Fatalf("expected a strict error naming the URL, got %v", err)
+	}
+}
+
+func TestSitemapScope(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURLs([]SitemapURL{{Loc: "/sitemaps/page"}, {Loc: "/other"}})
+	if err := sm.Write("https://www.example.com/sitemaps/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	warnings := sm.Report().Warnings
+	if len(warnings) != 1 || warnings[0].Loc != "https://www.example.com/other" || !strings.Contains(warnings[0].Problem, "https://www.example.com/sitemaps/") {
+		t.Fatalf("expected a scope warning for /other, got %+v", warnings)
+	}
+
+	// Shards in ShardDir have the narrower scope
+	sharded := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sharded.ShardDir = "shards"
+	sharded.MaxURLs = 1
+	sharded.AddURLs([]SitemapURL{{Loc: "/shards/a"}, {Loc: "/b"}})
+	if err := sharded.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if warnings := sharded.Report().Warnings; len(warnings) != 1 || warnings[0].Loc != "https://www.example.com/b" {
+		t.Fatalf("expected a scope warning for /b, got %+v", warnings)
+	}
+
+	sm.Strict = true
+	if err := sm.Write("https://www.example.com/sitemaps/"); err == nil || !strings.Contains(err.Error(), "https://www.example.com/other") {
+		t.Fatalf("expected a strict scope error, got %v", err)
+	}
+	sm.Strict = false
+	sm.RequireRootSitemap = true
+	if err := sm.Write("https://www.example.com/sitemaps/"); err == nil || !strings.Contains(err.Error(), "RequireRootSitemap") {
+		t.Fatalf("expected a root placement error, got %v", err)
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write at the root: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package sitemap
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"text/template"
+)
+
+// DefaultStylesheet is the bundled stylesheet template, rendered with
+// StylesheetBranding. Copy it as a starting point for StylesheetTemplate.
+//
+//go:embed sitemap.xsl.tmpl
+var DefaultStylesheet []byte
+
+// StylesheetBranding holds the values injected into the stylesheet template.
+type StylesheetBranding struct {
+	SiteName    string // Page title and heading, "Sitemap" if empty
+	LogoURL     string // Image shown above the heading, omitted if empty
+	AccentColor string // CSS color for headings and links, e.g. "#0a7cff"
+}
+
+var stylesheetFuncs = template.FuncMap{
+	"xml": func(value string) (string, error) {
+		var buffer bytes.Buffer
+		if err := xml.EscapeText(&buffer, []byte(value)); err != nil {
+			return "", err
+		}
+		return buffer.String(), nil
+	},
+}
+
+// renderStylesheet renders the bundled stylesheet, or StylesheetTemplate if
+// set, with the configured branding.
+func (s *SitemapOptions) renderStylesheet() ([]byte, error) {
+	source := string(DefaultStylesheet)
+	if s.StylesheetTemplate != "" {
+		source = s.StylesheetTemplate
+	}
+	tmpl, err := template.New("stylesheet").Funcs(stylesheetFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stylesheet template: %v", err)
+	}
+
+	branding := s.Branding
+	if branding.SiteName == "" {
+		branding.SiteName = "Sitemap"
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, branding); err != nil {
+		return nil, fmt.Errorf("failed to render stylesheet template: %v", err)
+	}
+	return buffer.Bytes(), nil
+}
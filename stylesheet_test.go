@@ -0,0 +1,59 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestStylesheetBranding(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+
+	stylesheet, err := sm.renderStylesheet()
+	if err != nil {
+		t.Fatalf("Error rendering default stylesheet: %v", err)
+	}
+	if !strings.Contains(string(stylesheet), "<h1>Sitemap</h1>") || strings.Contains(string(stylesheet), "<img") {
+		t.Fatalf("Unexpected default stylesheet:\n%s", stylesheet)
+	}
+
+	sm.Branding = StylesheetBranding{
+		SiteName:    "Shop & Co",
+		LogoURL:     "https://cdn.example.com/logo.png?size=2",
+		AccentColor: "#0a7cff",
+	}
+	stylesheet, err = sm.renderStylesheet()
+	if err != nil {
+		t.Fatalf("Error rendering branded stylesheet: %v", err)
+	}
+	content := string(stylesheet)
+	for _, expected := range []string{"<h1>Shop &amp; Co</h1>", `src="https://cdn.example.com/logo.png?size=2"`, "color: #0a7cff"} {
+		if !strings.Contains(content, expected) {
+			t.Fatalf("Branded stylesheet missing %q:\n%s", expected, content)
+		}
+	}
+	if err := xml.Unmarshal(stylesheet, new(struct{})); err != nil {
+		t.Fatalf("Branded stylesheet is not well-formed XML: %v", err)
+	}
+
+	sm.StylesheetTemplate = `<custom>{{xml .SiteName}}</custom>`
+	stylesheet, err = sm.renderStylesheet()
+	if err != nil || string(stylesheet) != "<custom>Shop &amp; Co</custom>" {
+		t.Fatalf("Unexpected custom stylesheet %q: %v", stylesheet, err)
+	}
+}
+
+func TestCustomizeDefaultStylesheet(t *testing.T) {
+	if !strings.Contains(string(DefaultStylesheet), "{{xml .SiteName}}") {
+		t.Fatalf("Expected the embedded stylesheet template, got:\n%s", DefaultStylesheet)
+	}
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.StylesheetTemplate = strings.Replace(string(DefaultStylesheet), "<h1>", `<h1 class="custom">`, 1)
+	stylesheet, err := sm.renderStylesheet()
+	if err != nil {
+		t.Fatalf("Error rendering customized stylesheet: %v", err)
+	}
+	if !strings.Contains(string(stylesheet), `<h1 class="custom">Sitemap</h1>`) {
+		t.Fatalf("Unexpected customized stylesheet:\n%s", stylesheet)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package sitemap
+
+import "os"
+
+// syncDir fsyncs a directory so that entries renamed into it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
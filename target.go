@@ -0,0 +1,81 @@
+package sitemap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target is an environment the sitemaps are written for, such as staging or
+// production.
+type Target struct {
+	Name           string // identifies the target in errors
+	Dir            string // directory the sitemap files are written to
+	BaseURL        string // base URL of the pages in this environment
+	BaseSitemapURL string // base URL the sitemap files are served from
+}
+
+// WriteTargets writes the current URLs once for every target. Locs,
+// alternates and images below BaseURL are rebased onto the target's BaseURL;
+// other URLs are written unchanged. Each target is written from its own
+// Clone, so the Report and State of s are not updated.
+func (s *SitemapOptions) WriteTargets(targets []Target) error {
+	from, err := parseBaseURL(s.BaseURL)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		to, err := parseBaseURL(target.BaseURL)
+		if err != nil {
+			return fmt.Errorf("target %s: %w", target.Name, err)
+		}
+		c := s.Clone()
+		c.Dir = target.Dir
+		c.BaseURL = target.BaseURL
+		for i := range c.URLs {
+			c.URLs[i] = rebaseURL(c.URLs[i], from.String(), to.String())
+		}
+		if err := c.Write(target.BaseSitemapURL); err != nil {
+			return fmt.Errorf("target %s: %w", target.Name, err)
+		}
+	}
+	return nil
+}
+
+// rebaseURL returns a copy of u with the URLs starting with from moved
+// below to.
+func rebaseURL(u SitemapURL, from, to string) SitemapURL {
+	rebase := func(loc string) string {
+		if rest, ok := strings.CutPrefix(loc, from); ok {
+			return to + rest
+		}
+		return loc
+	}
+	u.Loc = rebase(u.Loc)
+	if len(u.Alternates) > 0 {
+		alternates := make([]Alternate, len(u.Alternates))
+		for i, alt := range u.Alternates {
+			alt.Href = rebase(alt.Href)
+			alternates[i] = alt
+		}
+		u.Alternates = alternates
+	}
+	if len(u.Images) > 0 {
+		images := make([]Image, len(u.Images))
+		for i, img := range u.Images {
+			img.Loc = rebase(img.Loc)
+			images[i] = img
+		}
+		u.Images = images
+	}
+	if len(u.Videos) > 0 {
+		videos := make([]Video, len(u.Videos))
+		for i, video := range u.Videos {
+			video.ThumbnailLoc = rebase(video.ThumbnailLoc)
+			video.ContentLoc = rebase(video.ContentLoc)
+			video.PlayerLoc = rebase(video.PlayerLoc)
+			videos[i] = video
+		}
+		u.Videos = videos
+	}
+	return u
+}
@@ -0,0 +1,52 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTargets(t *testing.T) {
+	prodDir, stagingDir := t.TempDir(), t.TempDir()
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/products", Images: []Image{{Loc: "https://www.example.com/shoe.jpg"}}})
+	sm.AddURL(SitemapURL{Loc: "https://blog.example.org/post"})
+
+	err := sm.WriteTargets([]Target{
+		{Name: "production", Dir: prodDir, BaseURL: "https://www.example.com", BaseSitemapURL: "https://www.example.com/"},
+		{Name: "staging", Dir: stagingDir, BaseURL: "https://staging.example.com/shop", BaseSitemapURL: "https://staging.example.com/shop/"},
+	})
+	if err != nil {
+		t.Fatalf("WriteTargets: %v", err)
+	}
+
+	read := func(dir string) string {
+		data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	prod, staging := read(prodDir), read(stagingDir)
+	if !strings.Contains(prod, "<loc>https://www.example.com/products</loc>") {
+		t.Fatalf("production sitemap:\n%s", prod)
+	}
+	for _, want := range []string{
+		"<loc>https://staging.example.com/shop/products</loc>",
+		"<image:loc>https://staging.example.com/shop/shoe.jpg</image:loc>",
+		"<loc>https://blog.example.org/post</loc>",
+	} {
+		if !strings.Contains(staging, want) {
+			t.Fatalf("staging sitemap missing %s:\n%s", want, staging)
+		}
+	}
+	if sm.URLs[0].Loc != "https://www.example.com/products" || sm.URLs[0].Images[0].Loc != "https://www.example.com/shoe.jpg" {
+		t.Fatalf("original URLs were modified: %+v", sm.URLs[0])
+	}
+
+	err = sm.WriteTargets([]Target{{Name: "broken", Dir: t.TempDir(), BaseURL: "ftp://example.com"}})
+	if err == nil || !strings.Contains(err.Error(), "target broken") {
+		t.Fatalf("expected target error, got %v", err)
+	}
+}
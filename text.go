@@ -0,0 +1,81 @@
+package sitemap
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// XMLToText writes the loc of every URL in the XML sitemap read from r,
+// gzipped or not, to w as a plain text sitemap with one URL per line.
+func XMLToText(r io.Reader, w io.Writer) error {
+	rc, err := maybeGunzip(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	bw := bufio.NewWriter(w)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse sitemap: %v", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "url" {
+			continue
+		}
+		var u SitemapURL
+		if err := dec.DecodeElement(&u, &start); err != nil {
+			return fmt.Errorf("failed to parse sitemap: %v", err)
+		}
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			bw.WriteString(loc)
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// TextToXML converts the plain text sitemap read from r, one absolute URL
+// per line, into an XML sitemap written to w. Blank lines are skipped. The
+// output is not split; pass it to SplitSitemap if it exceeds the limits.
+func TextToXML(r io.Reader, w io.Writer) error {
+	urlSet := URLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		loc := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if loc == "" {
+			continue
+		}
+		u, err := url.Parse(loc)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("line %d: '%s' is not an absolute http(s) URL", line, loc)
+		}
+		urlSet.URLs = append(urlSet.URLs, SitemapURL{Loc: loc})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	data, err := xml.MarshalIndent(urlSet, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package sitemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTextConversion(t *testing.T) {
+	text := "https://www.example.com/\n\nhttps://www.example.com/a?x=1&y=2\n"
+	var xmlOut bytes.Buffer
+	if err := TextToXML(strings.NewReader(text), &xmlOut); err != nil {
+		t.Fatalf("TextToXML: %v", err)
+	}
+	if !strings.Contains(xmlOut.String(), "<loc>https://www.example.com/a?x=1&amp;y=2</loc>") {
+		t.Fatalf("unexpected XML:\n%s", xmlOut.String())
+	}
+
+	var textOut bytes.Buffer
+	if err := XMLToText(&xmlOut, &textOut); err != nil {
+		t.Fatalf("XMLToText: %v", err)
+	}
+	if got, want := textOut.String(), "https://www.example.com/\nhttps://www.example.com/a?x=1&y=2\n"; got != want {
+		t.Fatalf("round trip = %q, want %q", got, want)
+	}
+
+	err := TextToXML(strings.NewReader("https://www.example.com/\n/relative\n"), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error naming line 2, got %v", err)
+	}
+}
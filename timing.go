@@ -0,0 +1,58 @@
+package sitemap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timings is the time a Write spent in each phase of generating the files,
+// for comparing configurations such as MemoryLimit, NewGzipWriter levels
+// and EncodeWorkers. Encode and Compress add up the time of every encoding
+// goroutine, so with EncodeWorkers they may exceed the duration of the
+// Write. With WritePut, files are validated while they are published and
+// that time counts as Publish.
+type Timings struct {
+	Collect  time.Duration // preparing, filtering and checking the URLs
+	Encode   time.Duration // marshaling the sitemap files and indexes
+	Compress time.Duration // compressing with Gzip and for Mirrors
+	Publish  time.Duration // storing the files, or sending them with WritePut
+	Validate time.Duration // validating the files against the XSDs
+}
+
+// phase is one of the phases measured in Timings.
+type phase int
+
+const (
+	phaseCollect phase = iota
+	phaseEncode
+	phaseCompress
+	phasePublish
+	phaseValidate
+	phaseCount
+)
+
+// phaseTimes accumulates the time spent in each phase by a run. It is safe
+// for concurrent use.
+type phaseTimes [phaseCount]atomic.Int64
+
+// timings returns the accumulated times.
+func (t *phaseTimes) timings() Timings {
+	get := func(p phase) time.Duration {
+		return time.Duration(t[p].Load())
+	}
+	return Timings{
+		Collect:  get(phaseCollect),
+		Encode:   get(phaseEncode),
+		Compress: get(phaseCompress),
+		Publish:  get(phasePublish),
+		Validate: get(phaseValidate),
+	}
+}
+
+// since adds the time elapsed since start to phase p of the current run,
+// if any.
+func (s *SitemapOptions) since(p phase, start time.Time) {
+	if s.tx != nil {
+		s.tx.phases[p].Add(int64(time.Since(start)))
+	}
+}
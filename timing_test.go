@@ -0,0 +1,113 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.MaxURLs = 100
+	for i := 0; i < 1000; i++ {
+		sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page/%d", i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	timings := sm.Report().Timings
+	if timings.Collect <= 0 || timings.Encode <= 0 || timings.Publish <= 0 || timings.Validate <= 0 {
+		t.Fatalf("Expected every phase to be measured, got %+v", timings)
+	}
+
+	sm.Gzip = true
+	for i := 0; i < 1000; i++ {
+		sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/page/%d", i)})
+	}
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if timings := sm.Report().Timings; timings.Compress <= 0 {
+		t.Fatalf("Expected the compression to be measured, got %+v", timings)
+	}
+}
+
+// benchmarkConfigs are the configurations compared by BenchmarkWrite.
+var benchmarkConfigs = []struct {
+	name  string
+	apply func(sm *SitemapOptions)
+}{
+	{"buffered", func(sm *SitemapOptions) {}},
+	{"streaming", func(sm *SitemapOptions) { sm.MemoryLimit = 64 << 20 }},
+	{"gzip", func(sm *SitemapOptions) { sm.Gzip = true }},
+	{"gzip-speed", func(sm *SitemapOptions) {
+		sm.Gzip = true
+		sm.NewGzipWriter = func(w io.Writer) io.WriteCloser {
+			gz, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
+			return gz
+		}
+	}},
+	{"gzip-best", func(sm *SitemapOptions) {
+		sm.Gzip = true
+		sm.NewGzipWriter = func(w io.Writer) io.WriteCloser {
+			gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+			return gz
+		}
+	}},
+	{"workers", func(sm *SitemapOptions) {
+		sm.Gzip = true
+		sm.EncodeWorkers = runtime.GOMAXPROCS(0)
+	}},
+}
+
+// BenchmarkWrite writes sets of 1M and 10M URLs with each of
+// benchmarkConfigs and reports the seconds spent per phase. The 10M sets
+// are skipped with -short. Run it with, for example:
+//
+//	go test -run '^$' -bench 'Write/1M' -benchtime 1x
+func BenchmarkWrite(b *testing.B) {
+	for _, size := range []struct {
+		name string
+		urls int
+	}{
+		{"1M", 1_000_000},
+		{"10M", 10_000_000},
+	} {
+		for _, config := range benchmarkConfigs {
+			b.Run(size.name+"/"+config.name, func(b *testing.B) {
+				if size.urls > 1_000_000 && testing.Short() {
+					b.Skip("skipping 10M URLs in short mode")
+				}
+				var timings Timings
+				for i := 0; i < b.N; i++ {
+					sm := NewSitemapOptions(b.TempDir(), "https://www.example.com")
+					config.apply(sm)
+					lastMod := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+					for j := 0; j < size.urls; j++ {
+						sm.AddURL(SitemapURL{Loc: fmt.Sprintf("/products/%d", j), LastMod: lastMod.Format(time.DateOnly), Priority: "0.5"})
+					}
+					if err := sm.Write("https://www.example.com/"); err != nil {
+						b.Fatalf("Error writing sitemap: %v", err)
+					}
+					t := sm.Report().Timings
+					timings.Collect += t.Collect
+					timings.Encode += t.Encode
+					timings.Compress += t.Compress
+					timings.Publish += t.Publish
+					timings.Validate += t.Validate
+				}
+				perOp := func(d time.Duration) float64 {
+					return d.Seconds() / float64(b.N)
+				}
+				b.ReportMetric(perOp(timings.Collect), "collect-s/op")
+				b.ReportMetric(perOp(timings.Encode), "encode-s/op")
+				b.ReportMetric(perOp(timings.Compress), "compress-s/op")
+				b.ReportMetric(perOp(timings.Publish), "publish-s/op")
+				b.ReportMetric(perOp(timings.Validate), "validate-s/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package sitemap
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	urlElementPattern     = regexp.MustCompile(`(?s)<url>(.*?)</url>`)
+	locElementPattern     = regexp.MustCompile(`(?s)<loc>(.*?)</loc>`)
+	lastModElementPattern = regexp.MustCompile(`<lastmod>[^<]*</lastmod>`)
+)
+
+// TouchLastMod sets lastmod values of the sitemaps already in Dir to t
+// without rebuilding them, for content that changed while the URL set did
+// not. Each of locs gets t in the sitemap file holding it and the index
+// entries of those files get t too; without locs every local entry of the
+// index gets t and no sitemap file is rewritten. Locs not found in the
+// sitemaps fail the call before any file changes. Other elements are kept
+// byte for byte. Failures restore the previous files as for Write.
+func (s *SitemapOptions) TouchLastMod(baseSitemapURL string, t time.Time, locs ...string) error {
+	if _, err := parseBaseURL(baseSitemapURL); err != nil {
+		return fmt.Errorf("invalid base sitemap URL: %w", err)
+	}
+	unlock, err := lockDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lastMod := s.formatLastMod(t)
+	wanted := make(map[string]bool, len(locs))
+	for _, loc := range locs {
+		resolved, err := s.resolveURL(loc)
+		if err != nil {
+			return err
+		}
+		wanted[resolved] = true
+	}
+
+	indexPath := filepath.Join(s.Dir, "sitemap_index.xml")
+	files, err := s.auditFiles(indexPath)
+	hasIndex := err == nil
+	if os.IsNotExist(err) {
+		if len(locs) == 0 {
+			return fmt.Errorf("no sitemap index in %s", s.Dir)
+		}
+		files, err = []string{filepath.Join(s.Dir, "sitemap.xml")}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Find every loc before changing anything
+	updated := make(map[string][]byte)
+	for _, filePath := range files {
+		if len(wanted) == 0 {
+			break
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if data, ok := touchURLs(data, wanted, lastMod); ok {
+			updated[filePath] = data
+		}
+	}
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for loc := range wanted {
+			missing = append(missing, loc)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("%d locs are not in the sitemaps, such as %s", len(missing), missing[0])
+	}
+
+	touched := func(filePath string) bool {
+		_, ok := updated[filePath]
+		return len(locs) == 0 || ok
+	}
+	tx := &writeTx{}
+	err = s.runTx(tx, func() error {
+		for _, filePath := range files {
+			data, ok := updated[filePath]
+			if !ok {
+				continue
+			}
+			if err := s.tx.writeFile(filePath, data); err != nil {
+				return err
+			}
+			s.tx.completed++
+			if err := s.validateXMLFile(filePath, false); err != nil {
+				return err
+			}
+		}
+		if hasIndex {
+			if _, err := s.touchIndex(indexPath, lastMod, touched); err != nil {
+				return err
+			}
+		}
+		return s.describeSet()
+	})
+	if err != nil {
+		return err
+	}
+	return s.afterWrite(baseSitemapURL, tx)
+}
+
+// touchIndex sets the lastmod of the entries of the index at indexPath for
+// which touched reports true, following nested indexes, and rewrites it if
+// any changed.
+func (s *SitemapOptions) touchIndex(indexPath, lastMod string, touched func(filePath string) bool) (bool, error) {
+	index, err := LoadSitemapIndex(indexPath)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for i, sitemap := range index.Sitemaps {
+		filePath, ok := s.localSitemapPath(sitemap.Loc)
+		if !ok {
+			continue
+		}
+		if isNestedIndex(filepath.Base(filePath)) {
+			ok, err = s.touchIndex(filePath, lastMod, touched)
+			if err != nil {
+				return false, err
+			}
+		} else {
+			ok = touched(filePath)
+		}
+		if ok {
+			index.Sitemaps[i].LastMod = lastMod
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := s.writeIndexXML(indexPath, index.Sitemaps); err != nil {
+		return false, err
+	}
+	return true, s.validateXMLFile(indexPath, true)
+}
+
+// touchURLs sets the lastmod of the url elements of a sitemap whose loc is
+// in wanted, removing the locs it finds from wanted. It reports whether any
+// was found.
+func touchURLs(data []byte, wanted map[string]bool, lastMod string) ([]byte, bool) {
+	found := false
+	data = urlElementPattern.ReplaceAllFunc(data, func(element []byte) []byte {
+		match := locElementPattern.FindSubmatchIndex(element)
+		if match == nil {
+			return element
+		}
+		loc := html.UnescapeString(strings.TrimSpace(string(element[match[2]:match[3]])))
+		if !wanted[loc] {
+			return element
+		}
+		delete(wanted, loc)
+		found = true
+
+		value := "<lastmod>" + lastMod + "</lastmod>"
+		if lastModElementPattern.Match(element) {
+			return lastModElementPattern.ReplaceAll(element, []byte(value))
+		}
+		// Insert after loc with the indentation loc has
+		indent := string(element[len("<url>"):match[0]])
+		if strings.TrimSpace(indent) != "" {
+			indent = ""
+		}
+		touched := append([]byte(nil), element[:match[1]]...)
+		touched = append(touched, indent+value...)
+		return append(touched, element[match[1]:]...)
+	})
+	return data, found
+}
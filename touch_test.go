@@ -0,0 +1,94 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTouchLastMod(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	sm.MaxURLs = 2
+	sm.AddURLs([]SitemapURL{
+		{Loc: "/a", LastMod: "2024-01-01"},
+		{Loc: "/b", Images: []Image{{Loc: "https://www.example.com/b.jpg"}}},
+		{Loc: "/c", LastMod: "2024-01-01"},
+	})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	touched := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if err := sm.TouchLastMod("https://www.example.com/", touched, "/b"); err != nil {
+		t.Fatalf("Error touching lastmod: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap_1.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	urlSet, err := ParseURLSet(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("Error parsing sitemap: %v", err)
+	}
+	if urlSet.URLs[0].LastMod != "2024-01-01" || urlSet.URLs[1].LastMod != "2024-05-01" {
+		t.Fatalf("Expected only /b to be touched, got %+v", urlSet.URLs)
+	}
+	if !strings.Contains(string(data), "<image:loc>https://www.example.com/b.jpg</image:loc>") {
+		t.Fatalf("Expected the image to be kept:\n%s", data)
+	}
+
+	index, err := LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	if index.Sitemaps[0].LastMod != "2024-05-01" || index.Sitemaps[1].LastMod != "2024-01-01" {
+		t.Fatalf("Expected only the first entry to be touched, got %+v", index.Sitemaps)
+	}
+
+	// Without locs only the index changes
+	later := touched.AddDate(0, 1, 0)
+	if err := sm.TouchLastMod("https://www.example.com/", later); err != nil {
+		t.Fatalf("Error touching lastmod: %v", err)
+	}
+	index, err = LoadSitemapIndex(filepath.Join(dir, "sitemap_index.xml"))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	for _, sitemap := range index.Sitemaps {
+		if sitemap.LastMod != "2024-06-01" {
+			t.Fatalf("Expected every entry to be touched, got %+v", index.Sitemaps)
+		}
+	}
+	second, err := os.ReadFile(filepath.Join(dir, "sitemap_2.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	if strings.Contains(string(second), "2024-06-01") {
+		t.Fatalf("Expected the sitemap files to be left alone")
+	}
+
+	err = sm.TouchLastMod("https://www.example.com/", later, "/a", "/missing")
+	if err == nil || !strings.Contains(err.Error(), "https://www.example.com/missing") {
+		t.Fatalf("Expected an error for the missing loc, got %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "sitemap_1.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	if strings.Contains(string(data), "2024-06-01") {
+		t.Fatalf("Expected no file to change when a loc is missing")
+	}
+}
+
+func TestTouchURLsInsertsLastMod(t *testing.T) {
+	data := "<urlset>\n  <url>\n    <loc>https://www.example.com/?a=1&amp;b=2</loc>\n  </url>\n</urlset>"
+	wanted := map[string]bool{"https://www.example.com/?a=1&b=2": true}
+	touched, ok := touchURLs([]byte(data), wanted, "2024-05-01")
+	expected := "<urlset>\n  <url>\n    <loc>https://www.example.com/?a=1&amp;b=2</loc>\n    <lastmod>2024-05-01</lastmod>\n  </url>\n</urlset>"
+	if !ok || len(wanted) != 0 || string(touched) != expected {
+		t.Fatalf("Unexpected result %v:\n%s", ok, touched)
+	}
+}
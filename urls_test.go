@@ -0,0 +1,92 @@
+package sitemap
+
+import "testing"
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		baseURL, loc, expected string
+	}{
+		{"https://www.example.com", "/about", "https://www.example.com/about"},
+		{"https://www.example.com/", "about", "https://www.example.com/about"},
+		{"www.example.com", "/about", "https://www.example.com/about"},
+		{"https://www.example.com:8443/blog", "/post?id=1", "https://www.example.com:8443/blog/post?id=1"},
+		{"https://www.example.com/blog/", "post", "https://www.example.com/blog/post"},
+		{"https://www.example.com", "https://other.example.com/x", "https://other.example.com/x"},
+		{"https://www.example.com", "//cdn.example.com/x", "https://cdn.example.com/x"},
+	}
+	for _, tt := range tests {
+		sm := NewSitemapOptions(t.TempDir(), tt.baseURL)
+		got, err := sm.resolveURL(tt.loc)
+		if err != nil {
+			t.Fatalf("resolveURL(%q) with base %q: %v", tt.loc, tt.baseURL, err)
+		}
+		if got != tt.expected {
+			t.Fatalf("resolveURL(%q) with base %q = %q, expected %q", tt.loc, tt.baseURL, got, tt.expected)
+		}
+	}
+}
+
+func TestResolveSitemapURL(t *testing.T) {
+	tests := []struct {
+		baseSitemapURL, expected string
+	}{
+		{"https://www.example.com", "https://www.example.com/sitemap_1.xml"},
+		{"https://www.example.com/sitemaps", "https://www.example.com/sitemaps/sitemap_1.xml"},
+		{"https://www.example.com/sitemaps//", "https://www.example.com/sitemaps/sitemap_1.xml"},
+		{"http://localhost:8080/a/b/", "http://localhost:8080/a/b/sitemap_1.xml"},
+	}
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	for _, tt := range tests {
+		got, err := sm.resolveSitemapURL(tt.baseSitemapURL, "sitemap_1.xml")
+		if err != nil {
+			t.Fatalf("resolveSitemapURL(%q): %v", tt.baseSitemapURL, err)
+		}
+		if got != tt.expected {
+			t.Fatalf("resolveSitemapURL(%q) = %q, expected %q", tt.baseSitemapURL, got, tt.expected)
+		}
+	}
+}
+
+func TestInvalidBaseURLs(t *testing.T) {
+	for _, baseURL := range []string{"", "ftp://example.com", "https://", "https://example.com:99999", "https://example.com/?q=1", "http://exa mple.com"} {
+		if _, err := New(t.TempDir(), baseURL); err == nil {
+			t.Fatalf("Expected error for base URL %q", baseURL)
+		}
+	}
+	if _, err := New(t.TempDir(), "https://www.example.com/"); err != nil {
+		t.Fatalf("Unexpected error for valid base URL: %v", err)
+	}
+
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.AddURL(SitemapURL{Loc: "/"})
+	if err := sm.Write("not a url"); err == nil {
+		t.Fatalf("Expected error for malformed base sitemap URL")
+	}
+}
+
+func TestAbsoluteLoc(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com/shop")
+	sm.Strict = true
+	sm.AddURL(SitemapURL{Loc: "blog.example.com/post", Absolute: true})
+	sm.AddURL(SitemapURL{Loc: "https://help.example.com/faq", Absolute: true})
+	sm.AddURL(SitemapURL{Loc: "blog.example.com/joined"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := []string{
+		"https://blog.example.com/post",
+		"https://help.example.com/faq",
+		"https://www.example.com/shop/blog.example.com/joined",
+	}
+	for i, u := range sm.URLs {
+		if u.Loc != want[i] {
+			t.Fatalf("loc %d = %s, want %s", i, u.Loc, want[i])
+		}
+	}
+
+	bad := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	bad.AddURL(SitemapURL{Loc: "/no-host", Absolute: true})
+	if err := bad.Write("https://www.example.com/"); err == nil {
+		t.Fatal("expected an error for an absolute loc without a host")
+	}
+}
@@ -0,0 +1,279 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	videoNamespace = "http://www.google.com/schemas/sitemap-video/1.1"
+	// maxVideoDuration is the longest duration Google accepts, 8 hours.
+	maxVideoDuration = 28800
+	// maxVideoDescription, maxVideoTags and maxUploaderName are limits
+	// from Google's video sitemap documentation.
+	maxVideoDescription = 2048
+	maxVideoTags        = 32
+	maxUploaderName     = 255
+)
+
+// Video is a video on a page, written as a video:video element. A video
+// needs a thumbnail, a title, a description and a ContentLoc or PlayerLoc;
+// all other fields are optional. Fields taking yes or no are written only
+// when set.
+type Video struct {
+	ThumbnailLoc string `xml:"video:thumbnail_loc"`
+	Title        string `xml:"video:title"`
+	Description  string `xml:"video:description"`
+	ContentLoc   string `xml:"video:content_loc,omitempty"`
+	PlayerLoc    string `xml:"video:player_loc,omitempty"`
+	// Duration is the length in seconds, from 1 to 28800.
+	Duration       int    `xml:"video:duration,omitempty"`
+	ExpirationDate string `xml:"video:expiration_date,omitempty"`
+	// Rating is from 0.0 to 5.0.
+	Rating          *float64          `xml:"video:rating,omitempty"`
+	ViewCount       *int64            `xml:"video:view_count,omitempty"`
+	PublicationDate string            `xml:"video:publication_date,omitempty"`
+	Tags            []string          `xml:"video:tag,omitempty"`
+	FamilyFriendly  string            `xml:"video:family_friendly,omitempty"`
+	Restriction     *VideoRestriction `xml:"video:restriction,omitempty"`
+	Prices          []VideoPrice      `xml:"video:price,omitempty"`
+	// RequiresSubscription is yes or no.
+	RequiresSubscription string         `xml:"video:requires_subscription,omitempty"`
+	Uploader             *VideoUploader `xml:"video:uploader,omitempty"`
+	Platform             *VideoPlatform `xml:"video:platform,omitempty"`
+	// Live is yes or no.
+	Live string `xml:"video:live,omitempty"`
+}
+
+// VideoRestriction allows or denies the video in the listed countries.
+type VideoRestriction struct {
+	Relationship string `xml:"relationship,attr"` // allow or deny
+	// Countries are space-separated ISO 3166 alpha-2 codes.
+	Countries string `xml:",chardata"`
+}
+
+// VideoPlatform allows or denies the video on the listed platforms.
+type VideoPlatform struct {
+	Relationship string `xml:"relationship,attr"` // allow or deny
+	// Platforms are space-separated values among web, mobile and tv.
+	Platforms string `xml:",chardata"`
+}
+
+// VideoPrice is the price to download or view the video.
+type VideoPrice struct {
+	Currency   string `xml:"currency,attr"`             // ISO 4217 code
+	Type       string `xml:"type,attr,omitempty"`       // rent or own
+	Resolution string `xml:"resolution,attr,omitempty"` // hd or sd
+	Value      string `xml:",chardata"`
+}
+
+// VideoUploader is the uploader of the video, with an optional page about
+// them in Info.
+type VideoUploader struct {
+	Info string `xml:"info,attr,omitempty"`
+	Name string `xml:",chardata"`
+}
+
+var (
+	countryCodesPattern = regexp.MustCompile(`^[A-Za-z]{2}( +[A-Za-z]{2})*$`)
+	currencyPattern     = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// validateVideos checks the videos of urls against Google's requirements.
+// Videos missing a required field are dropped and invalid optional fields
+// are cleared, each problem recorded in the report. The videos of urls are
+// replaced rather than modified so the caller's slices are untouched.
+func (s *SitemapOptions) validateVideos(urls []SitemapURL, report *Report) {
+	for i := range urls {
+		if len(urls[i].Videos) == 0 {
+			continue
+		}
+		valid := make([]Video, 0, len(urls[i].Videos))
+		for _, video := range urls[i].Videos {
+			problems, ok := s.checkVideo(urls[i].Loc, &video)
+			for _, problem := range problems {
+				s.addIssue(&report.VideoIssues, WarningVideo, Issue{Loc: urls[i].Loc, Problem: problem, Meta: urls[i].Meta})
+			}
+			if ok {
+				valid = append(valid, video)
+			}
+		}
+		urls[i].Videos = valid
+	}
+}
+
+// checkVideo clears the invalid optional fields of v and returns the
+// problems found and whether the video can still be listed on page.
+func (s *SitemapOptions) checkVideo(page string, v *Video) ([]string, bool) {
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("video '%s': ", v.Title)+fmt.Sprintf(format, args...))
+	}
+
+	// Required fields
+	ok := true
+	for _, field := range []struct{ name, value string }{
+		{"thumbnail_loc", v.ThumbnailLoc},
+		{"title", v.Title},
+		{"description", v.Description},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			report("missing %s", field.name)
+			ok = false
+		}
+	}
+	if v.ContentLoc == "" && v.PlayerLoc == "" {
+		report("needs content_loc or player_loc")
+		ok = false
+	}
+	for _, field := range []struct{ name, value string }{
+		{"thumbnail_loc", v.ThumbnailLoc},
+		{"content_loc", v.ContentLoc},
+		{"player_loc", v.PlayerLoc},
+	} {
+		if field.value != "" && !isHTTPURL(field.value) {
+			report("%s %s is not an absolute http(s) URL", field.name, field.value)
+			ok = false
+		}
+	}
+	if v.ContentLoc != "" && v.ContentLoc == page {
+		report("content_loc must not be the page URL")
+		ok = false
+	}
+	if n := utf8.RuneCountInString(v.Description); n > maxVideoDescription {
+		report("description longer than %d characters", maxVideoDescription)
+		ok = false
+	}
+	if !ok {
+		return problems, false
+	}
+
+	// Optional fields
+	if v.Duration != 0 && (v.Duration < 1 || v.Duration > maxVideoDuration) {
+		report("duration %d outside 1 to %d seconds", v.Duration, maxVideoDuration)
+		v.Duration = 0
+	}
+	if v.Rating != nil && (*v.Rating < 0 || *v.Rating > 5) {
+		report("rating %g outside 0.0 to 5.0", *v.Rating)
+		v.Rating = nil
+	}
+	if v.ViewCount != nil && *v.ViewCount < 0 {
+		report("negative view_count %d", *v.ViewCount)
+		v.ViewCount = nil
+	}
+	for _, date := range []struct {
+		name  string
+		value *string
+	}{
+		{"expiration_date", &v.ExpirationDate},
+		{"publication_date", &v.PublicationDate},
+	} {
+		if *date.value == "" {
+			continue
+		}
+		if _, err := s.parseLastMod(*date.value); err != nil {
+			report("invalid %s '%s'", date.name, *date.value)
+			*date.value = ""
+		}
+	}
+	if len(v.Tags) > maxVideoTags {
+		report("more than %d tags", maxVideoTags)
+		v.Tags = v.Tags[:maxVideoTags:maxVideoTags]
+	}
+	for _, flag := range []struct {
+		name  string
+		value *string
+	}{
+		{"family_friendly", &v.FamilyFriendly},
+		{"requires_subscription", &v.RequiresSubscription},
+		{"live", &v.Live},
+	} {
+		if *flag.value == "" {
+			continue
+		}
+		if value := strings.ToLower(strings.TrimSpace(*flag.value)); value == "yes" || value == "no" {
+			*flag.value = value
+			continue
+		}
+		report("%s must be yes or no, got '%s'", flag.name, *flag.value)
+		*flag.value = ""
+	}
+	if r := v.Restriction; r != nil && (!validRelationship(r.Relationship) || !countryCodesPattern.MatchString(strings.TrimSpace(r.Countries))) {
+		report("invalid restriction '%s' %s", r.Relationship, r.Countries)
+		v.Restriction = nil
+	}
+	if p := v.Platform; p != nil && (!validRelationship(p.Relationship) || !validPlatforms(p.Platforms)) {
+		report("invalid platform '%s' %s", p.Relationship, p.Platforms)
+		v.Platform = nil
+	}
+	if len(v.Prices) > 0 {
+		prices := make([]VideoPrice, 0, len(v.Prices))
+		for _, price := range v.Prices {
+			if problem := videoPriceProblem(price); problem != "" {
+				report("%s", problem)
+				continue
+			}
+			prices = append(prices, price)
+		}
+		v.Prices = prices
+	}
+	if u := v.Uploader; u != nil {
+		switch {
+		case utf8.RuneCountInString(u.Name) > maxUploaderName:
+			report("uploader name longer than %d characters", maxUploaderName)
+			v.Uploader = nil
+		case u.Info != "" && !sameHost(u.Info, page):
+			// Google requires the uploader page on the video's domain
+			report("uploader info %s is not on the page's host", u.Info)
+			v.Uploader = &VideoUploader{Name: u.Name}
+		}
+	}
+	return problems, true
+}
+
+// validRelationship reports whether relationship is allow or deny.
+func validRelationship(relationship string) bool {
+	return relationship == "allow" || relationship == "deny"
+}
+
+// validPlatforms reports whether platforms lists only web, mobile and tv.
+func validPlatforms(platforms string) bool {
+	fields := strings.Fields(platforms)
+	for _, platform := range fields {
+		if platform != "web" && platform != "mobile" && platform != "tv" {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+// videoPriceProblem returns why price is invalid, or an empty string.
+func videoPriceProblem(price VideoPrice) string {
+	switch {
+	case !currencyPattern.MatchString(price.Currency):
+		return fmt.Sprintf("price currency '%s' is not an ISO 4217 code", price.Currency)
+	case price.Type != "" && price.Type != "rent" && price.Type != "own":
+		return fmt.Sprintf("price type '%s' must be rent or own", price.Type)
+	case price.Resolution != "" && price.Resolution != "hd" && price.Resolution != "sd":
+		return fmt.Sprintf("price resolution '%s' must be hd or sd", price.Resolution)
+	case strings.TrimSpace(price.Value) == "":
+		return "price without a value"
+	}
+	return ""
+}
+
+// isHTTPURL reports whether loc is an absolute http(s) URL.
+func isHTTPURL(loc string) bool {
+	u, err := url.Parse(loc)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// sameHost reports whether a and b are URLs on the same host.
+func sameHost(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && strings.EqualFold(ua.Hostname(), ub.Hostname())
+}
@@ -0,0 +1,89 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVideos(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSitemapOptions(dir, "https://www.example.com")
+	rating, views := 4.2, int64(12345)
+	sm.AddURL(SitemapURL{Loc: "/videos/grilling", Videos: []Video{
+		{
+			ThumbnailLoc:         "https://www.example.com/thumbs/123.jpg",
+			Title:                "Grilling steaks for summer",
+			Description:          "Alkis shows you how to get perfectly done steaks every time",
+			ContentLoc:           "https://streamserver.example.com/video123.mp4",
+			PlayerLoc:            "https://www.example.com/videoplayer.php?video=123",
+			Duration:             600,
+			ExpirationDate:       "2030-11-05T19:20:30+08:00",
+			Rating:               &rating,
+			ViewCount:            &views,
+			PublicationDate:      "2024-11-05T19:20:30+08:00",
+			Tags:                 []string{"steak", "summer"},
+			FamilyFriendly:       "YES",
+			Restriction:          &VideoRestriction{Relationship: "allow", Countries: "IE GB US CA"},
+			Prices:               []VideoPrice{{Currency: "EUR", Type: "rent", Resolution: "hd", Value: "1.99"}},
+			RequiresSubscription: "yes",
+			Uploader:             &VideoUploader{Info: "https://www.example.com/users/grillymcgrillerson", Name: "GrillyMcGrillerson"},
+			Platform:             &VideoPlatform{Relationship: "allow", Platforms: "web tv"},
+			Live:                 "no",
+		},
+		{
+			ThumbnailLoc: "https://www.example.com/thumbs/456.jpg",
+			Title:        "Broken",
+			Description:  "Has no content or player location",
+		},
+	}})
+	rating2 := 6.0
+	sm.AddURL(SitemapURL{Loc: "/videos/other", Videos: []Video{{
+		ThumbnailLoc:   "https://www.example.com/thumbs/789.jpg",
+		Title:          "Other",
+		Description:    "Invalid optional fields",
+		PlayerLoc:      "https://www.example.com/player?video=789",
+		Duration:       28801,
+		Rating:         &rating2,
+		FamilyFriendly: "maybe",
+		Restriction:    &VideoRestriction{Relationship: "block", Countries: "US"},
+		Platform:       &VideoPlatform{Relationship: "deny", Platforms: "console"},
+		Prices:         []VideoPrice{{Currency: "euro", Value: "1"}},
+		Uploader:       &VideoUploader{Info: "https://other.example.org/me", Name: "Me"},
+	}}})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("Error reading sitemap: %v", err)
+	}
+	output := string(data)
+	for _, want := range []string{
+		`xmlns:video="http://www.google.com/schemas/sitemap-video/1.1"`,
+		`<video:duration>600</video:duration>`,
+		`<video:rating>4.2</video:rating>`,
+		`<video:view_count>12345</video:view_count>`,
+		`<video:family_friendly>yes</video:family_friendly>`,
+		`<video:restriction relationship="allow">IE GB US CA</video:restriction>`,
+		`<video:price currency="EUR" type="rent" resolution="hd">1.99</video:price>`,
+		`<video:uploader info="https://www.example.com/users/grillymcgrillerson">GrillyMcGrillerson</video:uploader>`,
+		`<video:platform relationship="allow">web tv</video:platform>`,
+		`<video:live>no</video:live>`,
+		`<video:uploader>Me</video:uploader>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("Expected %s in output:\n%s", want, output)
+		}
+	}
+	for _, unwanted := range []string{"Broken", "28801", "<video:rating>6", "maybe", "block", "console", "euro"} {
+		if strings.Contains(output, unwanted) {
+			t.Fatalf("Expected %s to be dropped:\n%s", unwanted, output)
+		}
+	}
+	if issues := sm.Report().VideoIssues; len(issues) != 8 {
+		t.Fatalf("Expected 8 video issues, got %d: %+v", len(issues), issues)
+	}
+}
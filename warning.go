@@ -0,0 +1,54 @@
+package sitemap
+
+// WarningKind classifies a Warning.
+type WarningKind int
+
+const (
+	// WarningExcluded is a URL dropped by a filter, an expiry, OnAddURL or
+	// InvalidChars; Problem is the reason recorded in Report.Excluded.
+	WarningExcluded WarningKind = iota
+	// WarningDuplicate is a URL dropped as a duplicate of an earlier one.
+	WarningDuplicate
+	// WarningRepaired is a value repaired or dropped, as in Report.Warnings.
+	WarningRepaired
+	// WarningImage is an image dropped or cleared, as in Report.ImageIssues.
+	WarningImage
+	// WarningVideo is a video dropped or cleared, as in Report.VideoIssues.
+	WarningVideo
+	// WarningHreflang is a broken alternate cluster, as in
+	// Report.HreflangIssues.
+	WarningHreflang
+	// WarningExternal is a broken external sitemap, as in
+	// Report.ExternalIssues.
+	WarningExternal
+)
+
+// Warning is a non-fatal problem passed to OnWarning as it is found.
+type Warning struct {
+	Kind WarningKind
+	Issue
+}
+
+// warn passes issue to OnWarning, if set.
+func (s *SitemapOptions) warn(kind WarningKind, issue Issue) {
+	if s.OnWarning != nil {
+		s.OnWarning(Warning{Kind: kind, Issue: issue})
+	}
+}
+
+// addIssue appends issue to list, one of the issue lists of a report, and
+// passes it to OnWarning.
+func (s *SitemapOptions) addIssue(list *[]Issue, kind WarningKind, issue Issue) {
+	*list = append(*list, issue)
+	s.warn(kind, issue)
+}
+
+// exclude records a dropped URL in report and passes it to OnWarning.
+func (s *SitemapOptions) exclude(report *Report, excluded ExcludedURL) {
+	report.Excluded = append(report.Excluded, excluded)
+	kind := WarningExcluded
+	if excluded.Reason == "duplicate" {
+		kind = WarningDuplicate
+	}
+	s.warn(kind, Issue{Loc: excluded.Loc, Problem: excluded.Reason, Meta: excluded.Meta})
+}
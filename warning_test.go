@@ -0,0 +1,45 @@
+package sitemap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOnWarning(t *testing.T) {
+	sm := NewSitemapOptions(t.TempDir(), "https://www.example.com")
+	sm.Exclude = []string{"/private/*"}
+	var warnings []Warning
+	sm.OnWarning = func(w Warning) { warnings = append(warnings, w) }
+	sm.OnAddURL = func(u *SitemapURL) error {
+		if strings.HasSuffix(u.Loc, "/spam") {
+			return errors.New("spam")
+		}
+		return nil
+	}
+	sm.WithMemoryLimit(1)
+
+	sm.AddURL(SitemapURL{Loc: "/spam"})
+	if len(warnings) != 1 || warnings[0].Kind != WarningExcluded || warnings[0].Problem != "rejected: spam" {
+		t.Fatalf("Expected the rejection as soon as AddURL returns, got %+v", warnings)
+	}
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	sm.AddURL(SitemapURL{Loc: "/a"})
+	if len(warnings) != 2 || warnings[1].Kind != WarningDuplicate {
+		t.Fatalf("Expected the duplicate when flushed, got %+v", warnings)
+	}
+
+	sm.WithMemoryLimit(0)
+	sm.AddURL(SitemapURL{Loc: "/private/b"})
+	sm.AddURL(SitemapURL{Loc: "/c", ChangeFreq: "sometimes"})
+	if err := sm.Write("https://www.example.com/"); err != nil {
+		t.Fatalf("Error writing sitemap: %v", err)
+	}
+	if len(warnings) != 4 || warnings[2].Kind != WarningRepaired || warnings[3].Kind != WarningExcluded {
+		t.Fatalf("Expected the repair and the exclusion, got %+v", warnings)
+	}
+	report := sm.Report()
+	if len(report.Excluded) != 3 || len(report.Warnings) != 1 {
+		t.Fatalf("Expected the warnings in the report too, got %+v", report)
+	}
+}